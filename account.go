@@ -0,0 +1,55 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+var (
+	callerAccountOnce  sync.Once
+	callerAccountID    string
+	callerAccountAlias string
+)
+
+// callerAccount returns the ID and the alias of the account of the
+// credentials of the bot. They are described once, and are empty if they
+// cannot be described.
+func callerAccount() (string, string) {
+	callerAccountOnce.Do(func() {
+		identity, err := stsClient.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+		if err != nil {
+			logError("cannot describe the account", err, nil)
+			return
+		}
+		callerAccountID = aws.StringValue(identity.Account)
+		aliases, err := iamClient.ListAccountAliases(&iam.ListAccountAliasesInput{})
+		if err != nil {
+			logError("cannot describe the alias of the account", err, nil)
+			return
+		}
+		if len(aliases.AccountAliases) > 0 {
+			callerAccountAlias = aws.StringValue(aliases.AccountAliases[0])
+		}
+	})
+	return callerAccountID, callerAccountAlias
+}
+
+// accountContext returns the footer of cards stating the account and the
+// region a resource was described in. The account of the bot is used if
+// the account is empty.
+func accountContext(account, region string) string {
+	id, alias := callerAccount()
+	if account == "" {
+		account = id
+	}
+	if account == "" {
+		return tr("region %s", region)
+	}
+	if account == id && alias != "" {
+		account = alias + " (" + id + ")"
+	}
+	return tr("account %s, region %s", account, region)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/nlopes/slack"
+)
+
+// maxAttachmentActions is the maximum number of buttons Slack shows in a
+// single attachment.
+const maxAttachmentActions = 5
+
+func init() {
+	actionHandlers["instance_action"] = handleInstanceAction
+}
+
+// instanceActions returns the buttons acting on the instance in its
+// current state.
+func instanceActions(instance *ec2.Instance) []slack.Attachment {
+	id := aws.StringValue(instance.InstanceId)
+	var actions []slack.AttachmentAction
+	if instance.State == nil {
+		return nil
+	}
+	switch aws.StringValue(instance.State.Name) {
+	case ec2.InstanceStateNameStopped:
+		actions = append(actions, slack.AttachmentAction{
+			Name:  "start",
+			Text:  tr("Start"),
+			Type:  "button",
+			Style: "primary",
+			Value: id,
+		}, resizeButton(id), consoleOutputButton(id), snapshotButton(id), terminateButton(id))
+	case ec2.InstanceStateNameRunning:
+		actions = append(actions, slack.AttachmentAction{
+			Name:  "stop",
+			Text:  tr("Stop"),
+			Type:  "button",
+			Style: "danger",
+			Value: id,
+			Confirm: &slack.ConfirmationField{
+				Title:       tr("Stop %s?", id),
+				Text:        tr("The instance will be stopped."),
+				OkText:      tr("Stop"),
+				DismissText: tr("Cancel"),
+			},
+		}, rebootButton(id), drainButton(id), resizeButton(id), consoleOutputButton(id), screenshotButton(id), snapshotButton(id), terminateButton(id))
+	}
+	// the API calls are looked up in every state, such as who terminated it
+	actions = append(actions, cloudTrailButton(id))
+	var attachments []slack.Attachment
+	for len(actions) > 0 {
+		n := len(actions)
+		if n > maxAttachmentActions {
+			n = maxAttachmentActions
+		}
+		attachments = append(attachments, slack.Attachment{
+			Fallback:   id,
+			CallbackID: "instance_action",
+			Actions:    actions[:n],
+		})
+		actions = actions[n:]
+	}
+	return attachments
+}
+
+func rebootButton(id string) slack.AttachmentAction {
+	return slack.AttachmentAction{
+		Name:  "reboot",
+		Text:  tr("Reboot"),
+		Type:  "button",
+		Style: "danger",
+		Value: id,
+		Confirm: &slack.ConfirmationField{
+			Title:       tr("Reboot %s?", id),
+			Text:        tr("The instance will be rebooted."),
+			OkText:      tr("Reboot"),
+			DismissText: tr("Cancel"),
+		},
+	}
+}
+
+func handleInstanceAction(cb *ActionCallback, action slack.AttachmentAction) (*slack.Msg, error) {
+	if err := authorizeAction(cb.Team.ID, cb.User.ID, action.Name, action.Value); err != nil {
+		return nil, err
+	}
+
+	var (
+		change *ec2.InstanceStateChange
+		err    error
+	)
+	switch action.Name {
+	case "start":
+		change, err = startInstance(action.Value)
+	case "stop":
+		change, err = stopInstance(action.Value)
+	case "snapshot":
+		return nil, cb.event().createSnapshot(action.Value)
+	case "drain":
+		return nil, cb.event().drainInstance(action.Value)
+	case "console_output":
+		return nil, cb.event().uploadConsoleOutput(action.Value)
+	case "cloudtrail":
+		return nil, cb.event().postCloudTrailEvents(action.Value)
+	case "ssm_inventory":
+		return nil, cb.event().postInventory(action.Value)
+	case "screenshot":
+		return nil, cb.event().uploadScreenshot(action.Value)
+	case "terminate":
+		return nil, confirmTerminate(cb, action.Value)
+	case "resize":
+		return nil, confirmResize(cb, action.Value)
+	case "reboot":
+		err = rebootInstance(action.Value)
+		ev := cb.event()
+		ev.audit(action.Name, action.Value, err)
+		if err != nil {
+			return nil, ev.post(tr("failed to reboot %s: %v", action.Value, err), nil)
+		}
+		return nil, ev.post(tr("%s is rebooting (requested by <@%s>)", action.Value, cb.User.ID), nil)
+	default:
+		err = fmt.Errorf("unknown action %q", action.Name)
+	}
+	cb.event().audit(action.Name, action.Value, err)
+	if err != nil {
+		return nil, err
+	}
+	return cb.updateState(change), nil
+}
+
+func startInstance(id string) (*ec2.InstanceStateChange, error) {
+	svc := regionEC2Client(instanceRegion(id))
+	resp, err := svc.StartInstances(&ec2.StartInstancesInput{
+		InstanceIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.StartingInstances) == 0 {
+		return nil, fmt.Errorf("%s was not started", id)
+	}
+	return resp.StartingInstances[0], nil
+}
+
+func stopInstance(id string) (*ec2.InstanceStateChange, error) {
+	svc := regionEC2Client(instanceRegion(id))
+	resp, err := svc.StopInstances(&ec2.StopInstancesInput{
+		InstanceIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.StoppingInstances) == 0 {
+		return nil, fmt.Errorf("%s was not stopped", id)
+	}
+	return resp.StoppingInstances[0], nil
+}
+
+func rebootInstance(id string) error {
+	svc := regionEC2Client(instanceRegion(id))
+	_, err := svc.RebootInstances(&ec2.RebootInstancesInput{
+		InstanceIds: []*string{aws.String(id)},
+	})
+	return err
+}
+
+// confirmReboot handles "@ec2bot reboot i-xxx" by asking for confirmation
+// with a reboot button for each instance.
+func (ev *Event) confirmReboot(args []string) error {
+	ids := hostIDPattern.FindAllString(strings.Join(args, " "), -1)
+	if len(ids) == 0 {
+		return errors.New(tr("usage: reboot <instance id>"))
+	}
+	attachments := make([]slack.Attachment, len(ids))
+	for i, id := range ids {
+		attachments[i] = slack.Attachment{
+			Fallback:   id,
+			Text:       code(id),
+			CallbackID: "instance_action",
+			MarkdownIn: []string{"text"},
+			Actions:    []slack.AttachmentAction{rebootButton(id)},
+		}
+	}
+	return ev.post(tr("Reboot the following instances?"), attachments)
+}
+
+// updateState returns the clicked card with the new state of the instance
+// and the transition in place of the action buttons.
+func (cb *ActionCallback) updateState(change *ec2.InstanceStateChange) *slack.Msg {
+	if cb.OriginalMessage == nil {
+		return nil
+	}
+	msg := *cb.OriginalMessage
+	current := aws.StringValue(change.CurrentState.Name)
+	attachments := make([]slack.Attachment, 0, len(msg.Attachments))
+	replaced := false
+	for _, a := range msg.Attachments {
+		fields := make([]slack.AttachmentField, len(a.Fields))
+		for j, f := range a.Fields {
+			if f.Title == tr("State") {
+				f.Value = current
+			}
+			fields[j] = f
+		}
+		a.Fields = fields
+		if a.CallbackID == "instance_action" {
+			if replaced {
+				continue
+			}
+			replaced = true
+			a = slack.Attachment{
+				Text: tr(
+					"%s → %s by <@%s>",
+					aws.StringValue(change.PreviousState.Name),
+					current,
+					cb.User.ID,
+				),
+			}
+		}
+		attachments = append(attachments, a)
+	}
+	msg.Attachments = attachments
+	return &msg
+}
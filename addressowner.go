@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/bgpat/ec2bot/internal/cache"
+)
+
+var (
+	// lookupAddressOwner replies which subnet or VPC the addresses no
+	// instance has belong to, $LOOKUP_ADDRESS_OWNER, which needs
+	// ec2:DescribeSubnets and ec2:DescribeVpcs.
+	lookupAddressOwner = boolEnv("LOOKUP_ADDRESS_OWNER", false)
+
+	// networks are the subnets and the VPCs of the searched regions by
+	// region.
+	networks = cache.NewLRU(100, 10*time.Minute)
+)
+
+// regionNetworks are the subnets and the VPCs of a region.
+type regionNetworks struct {
+	Subnets []*ec2.Subnet
+	VPCs    []*ec2.Vpc
+}
+
+// describeNetworks returns the cached subnets and VPCs of the region.
+func describeNetworks(region string) (*regionNetworks, error) {
+	if v, ok := networks.Get(region); ok {
+		return v.(*regionNetworks), nil
+	}
+	svc := regionEC2Client(region)
+	subnets, err := svc.DescribeSubnets(&ec2.DescribeSubnetsInput{})
+	if err != nil {
+		return nil, err
+	}
+	vpcs, err := svc.DescribeVpcs(&ec2.DescribeVpcsInput{})
+	if err != nil {
+		return nil, err
+	}
+	n := &regionNetworks{Subnets: subnets.Subnets, VPCs: vpcs.Vpcs}
+	networks.Add(region, n)
+	return n, nil
+}
+
+// cidrContains reports whether the CIDR block contains the address.
+func cidrContains(cidr string, ip net.IP) bool {
+	_, network, err := net.ParseCIDR(cidr)
+	return err == nil && network.Contains(ip)
+}
+
+// subnetContains reports whether the IPv4 or the IPv6 blocks of the subnet
+// contain the address.
+func subnetContains(s *ec2.Subnet, ip net.IP) bool {
+	if cidrContains(aws.StringValue(s.CidrBlock), ip) {
+		return true
+	}
+	for _, a := range s.Ipv6CidrBlockAssociationSet {
+		if cidrContains(aws.StringValue(a.Ipv6CidrBlock), ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// vpcContains reports whether the primary or the associated blocks of the
+// VPC contain the address.
+func vpcContains(v *ec2.Vpc, ip net.IP) bool {
+	if cidrContains(aws.StringValue(v.CidrBlock), ip) {
+		return true
+	}
+	for _, a := range v.CidrBlockAssociationSet {
+		if cidrContains(aws.StringValue(a.CidrBlock), ip) {
+			return true
+		}
+	}
+	for _, a := range v.Ipv6CidrBlockAssociationSet {
+		if cidrContains(aws.StringValue(a.Ipv6CidrBlock), ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// networkName formats the ID and the redacted name of a subnet or a VPC.
+func networkName(id string, tags []*ec2.Tag) string {
+	name := currentConfig().redactTag("Name", tagValue(tags, "Name"))
+	if name == "" {
+		return code(id)
+	}
+	return code(id) + " (" + name + ")"
+}
+
+// addressOwner describes the subnet, or the VPC if no subnet has it, whose
+// address space has the address in the searched regions, and the account
+// and the region of it. It returns empty strings if the query is not an
+// address or none has it.
+func addressOwner(query string) (string, string) {
+	ip := net.ParseIP(query)
+	if !lookupAddressOwner || ip == nil {
+		return "", ""
+	}
+	for _, region := range lookupRegions() {
+		n, err := describeNetworks(region)
+		if err != nil {
+			logError("failed to describe networks", err, logFields{"region": region})
+			continue
+		}
+		for _, s := range n.Subnets {
+			if subnetContains(s, ip) {
+				return tr("%s is in the subnet %s of the VPC %s", code(query), networkName(aws.StringValue(s.SubnetId), s.Tags), code(aws.StringValue(s.VpcId))), accountContext("", region)
+			}
+		}
+		for _, v := range n.VPCs {
+			if vpcContains(v, ip) {
+				return tr("%s is in the VPC %s but in none of its subnets", code(query), networkName(aws.StringValue(v.VpcId), v.Tags)), accountContext("", region)
+			}
+		}
+	}
+	return "", ""
+}
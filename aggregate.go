@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/nlopes/slack"
+)
+
+// aggregateThreshold is the number of resources in a single message above
+// which one summary is posted instead of a card per resource.
+var aggregateThreshold = 5
+
+// summaryRows is the number of rows in a page of a summary or a list,
+// $SUMMARY_PAGE_SIZE.
+var summaryRows = 20
+
+func init() {
+	if v := getenv("SUMMARY_PAGE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			logWarn("cannot parse $SUMMARY_PAGE_SIZE, use default '20'", nil)
+		} else {
+			summaryRows = n
+		}
+	}
+	if v := getenv("AGGREGATE_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			logWarn("cannot parse $AGGREGATE_THRESHOLD, use default '5'", nil)
+			return
+		}
+		aggregateThreshold = n
+	}
+}
+
+// compactChannel reports whether the channel of the event posts summary
+// rows instead of cards regardless of the number of resources.
+func (ev *Event) compactChannel() bool {
+	return getChannelPrefs(ev.Event.Channel).Compact
+}
+
+func (ev *Event) postInstanceSummary(instances []*ec2.Instance) error {
+	return ev.postSummary(tr("%d instances", len(instances)), ev.instanceSummaryRows(instances))
+}
+
+// instanceSummaryRows renders a row of each instance, which has the private
+// address only if the cards rendered for the event show the details.
+func (ev *Event) instanceSummaryRows(instances []*ec2.Instance) []slack.Attachment {
+	return instanceRows(instances, ev.detailedCards())
+}
+
+// instanceRows renders a row of each instance, with its private address if
+// addresses is set.
+func instanceRows(instances []*ec2.Instance, addresses bool) []slack.Attachment {
+	rows := make([]slack.Attachment, len(instances))
+	for i, instance := range instances {
+		state := ""
+		if instance.State != nil {
+			state = aws.StringValue(instance.State.Name)
+		}
+		zone := ""
+		if instance.Placement != nil {
+			zone = aws.StringValue(instance.Placement.AvailabilityZone)
+		}
+		address := ""
+		if addresses {
+			address = aws.StringValue(instance.PrivateIpAddress)
+		}
+		rows[i] = summaryRow(
+			"instance",
+			aws.StringValue(instance.InstanceId),
+			aws.StringValue(instance.InstanceId),
+			currentConfig().redactTag("Name", tagValue(instance.Tags, "Name")),
+			aws.StringValue(instance.InstanceType),
+			state,
+			address,
+			zone,
+		)
+	}
+	return rows
+}
+
+func (ev *Event) postLoadBalancerSummary(loadBalancers []*elb.LoadBalancerDescription, loadBalancersV2 []*elbv2.LoadBalancer) error {
+	rows := loadBalancerSummaryRows(loadBalancers, loadBalancersV2)
+	return ev.postSummary(tr("%d load balancers", len(rows)), rows)
+}
+
+func loadBalancerSummaryRows(loadBalancers []*elb.LoadBalancerDescription, loadBalancersV2 []*elbv2.LoadBalancer) []slack.Attachment {
+	rows := make([]slack.Attachment, 0, len(loadBalancers)+len(loadBalancersV2))
+	for _, lb := range loadBalancers {
+		rows = append(rows, summaryRow(
+			"loadbalancer",
+			aws.StringValue(lb.DNSName),
+			aws.StringValue(lb.LoadBalancerName),
+			aws.StringValue(lb.Scheme),
+		))
+	}
+	for _, lb := range loadBalancersV2 {
+		rows = append(rows, summaryRow(
+			"loadbalancer",
+			aws.StringValue(lb.DNSName),
+			aws.StringValue(lb.LoadBalancerName),
+			aws.StringValue(lb.Type),
+			aws.StringValue(lb.Scheme),
+		))
+	}
+	return rows
+}
+
+// postSummary posts rows in a single message, which is paginated if they
+// do not fit in a page.
+func (ev *Event) postSummary(text string, rows []slack.Attachment) error {
+	if len(rows) > summaryRows {
+		return ev.postPagedSummary(text, rows)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return ev.post(text, rows)
+}
+
+// summaryRow renders a single resource with a button expanding it into a
+// full card. The query is used to resolve the resource again on expansion.
+func summaryRow(resourceType, query string, columns ...string) slack.Attachment {
+	values := make([]string, 0, len(columns))
+	for i, c := range columns {
+		if c == "" {
+			continue
+		}
+		if i == 0 {
+			c = code(c)
+		}
+		values = append(values, c)
+	}
+	return slack.Attachment{
+		Fallback:   strings.Join(columns, " "),
+		Text:       strings.Join(values, "  "),
+		CallbackID: "expand",
+		MarkdownIn: []string{"text"},
+		Actions: []slack.AttachmentAction{
+			slack.AttachmentAction{
+				Name:  resourceType,
+				Text:  tr("Expand"),
+				Type:  "button",
+				Value: query,
+			},
+		},
+	}
+}
+
+// handleExpand posts the full card of a row of a summary.
+func handleExpand(cb *ActionCallback, action slack.AttachmentAction) (*slack.Msg, error) {
+	ev := cb.event()
+	switch action.Name {
+	case "instance":
+		instance, err := getInstance(action.Value)
+		if err != nil {
+			return nil, err
+		}
+		if instance == nil {
+			return nil, ev.postNoInstance([]string{action.Value})
+		}
+		return nil, ev.postInstance(instance)
+	case "loadbalancer":
+		lb, err := getLoadBalancer(action.Value)
+		if err != nil {
+			return nil, err
+		}
+		if lb != nil {
+			return nil, ev.postLoadBalancer(lb)
+		}
+		lbV2, err := getLoadBalancerV2(action.Value)
+		if err != nil {
+			return nil, err
+		}
+		if lbV2 != nil {
+			return nil, ev.postLoadBalancerV2(lbV2)
+		}
+		return nil, ev.postNoLoadBalancer([]string{action.Value})
+	case "volume":
+		region, id, err := splitRegionalQuery(action.Value)
+		if err != nil {
+			return nil, err
+		}
+		volume, err := getVolume(region, id)
+		if err != nil {
+			return nil, err
+		}
+		if volume == nil {
+			return nil, ev.post(tr("%s is not found", code(id)), nil)
+		}
+		return nil, ev.postVolume(region, volume)
+	case "security_group":
+		region, id, err := splitRegionalQuery(action.Value)
+		if err != nil {
+			return nil, err
+		}
+		group, err := getSecurityGroup(region, id)
+		if err != nil {
+			return nil, err
+		}
+		if group == nil {
+			return nil, ev.post(tr("%s is not found", code(id)), nil)
+		}
+		return nil, ev.postSecurityGroup(region, group)
+	}
+	return nil, fmt.Errorf("unknown resource type %q", action.Name)
+}
+
+// splitRegionalQuery splits the query of a row of a resource looked up in
+// a region, such as "us-east-1/vol-0123456789abcdef0".
+func splitRegionalQuery(query string) (string, string, error) {
+	i := strings.Index(query, "/")
+	if i < 0 {
+		return "", "", fmt.Errorf("invalid query %q", query)
+	}
+	return query[:i], query[i+1:], nil
+}
+
+func tagValue(tags []*ec2.Tag, key string) string {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key {
+			return aws.StringValue(tag.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/nlopes/slack"
+)
+
+func TestInstanceSummaryRowsHideAddresses(t *testing.T) {
+	origGroup := detailsUserGroup
+	defer func() { detailsUserGroup = origGroup }()
+	userGroupCacheMu.Lock()
+	userGroupCache["T1/S1"] = userGroupMembers{
+		UpdatedAt: time.Now(),
+		Members:   map[string]struct{}{"U1": {}},
+	}
+	userGroupCacheMu.Unlock()
+	defer func() {
+		userGroupCacheMu.Lock()
+		delete(userGroupCache, "T1/S1")
+		userGroupCacheMu.Unlock()
+	}()
+
+	instances := []*ec2.Instance{fakeInstance("i-00000001", "10.0.0.1")}
+	tests := []struct {
+		name    string
+		group   string
+		user    string
+		dm      bool
+		address bool
+	}{
+		{name: "unrestricted", user: "U2", address: true},
+		{name: "member by DM", group: "S1", user: "U1", dm: true, address: true},
+		{name: "member in channel", group: "S1", user: "U1"},
+		{name: "non-member by DM", group: "S1", user: "U2", dm: true},
+		{name: "non-member in channel", group: "S1", user: "U2"},
+	}
+	for _, tt := range tests {
+		detailsUserGroup = tt.group
+		ev := &Event{
+			TeamID: "T1",
+			Event:  &slack.Msg{Channel: "C1", User: tt.user},
+			prefs:  &UserPrefs{DM: tt.dm},
+		}
+		rows := ev.instanceSummaryRows(instances)
+		if len(rows) != 1 {
+			t.Errorf("%s: got %d rows, want 1", tt.name, len(rows))
+			continue
+		}
+		if !strings.Contains(rows[0].Text, "i-00000001") {
+			t.Errorf("%s: row %q has no instance ID", tt.name, rows[0].Text)
+		}
+		for _, s := range []string{rows[0].Text, rows[0].Fallback} {
+			if got := strings.Contains(s, "10.0.0.1"); got != tt.address {
+				t.Errorf("%s: address in %q is %v, want %v", tt.name, s, got, tt.address)
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/labstack/echo"
+	"github.com/nlopes/slack"
+)
+
+var (
+	// alarmTopics are the ARNs of the SNS topics of the CloudWatch alarm
+	// notifications, $ALARM_TOPICS, which enable the /alarms endpoint.
+	// Notifications of other topics are rejected even if they are signed.
+	alarmTopics = strings.FieldsFunc(getenv("ALARM_TOPICS"), isComma)
+	// alarmChannels are the Slack channels of the alarms by their names,
+	// $ALARM_CHANNELS, such as "web-cpu=C0123456,*=C0654321" where "*" is
+	// the default.
+	alarmChannels = parseChannelMap("ALARM_CHANNELS")
+)
+
+// alarmNotification is the message of the CloudWatch alarm notifications.
+type alarmNotification struct {
+	AlarmName        string `json:"AlarmName"`
+	AlarmDescription string `json:"AlarmDescription"`
+	AlarmArn         string `json:"AlarmArn"`
+	NewStateValue    string `json:"NewStateValue"`
+	NewStateReason   string `json:"NewStateReason"`
+	OldStateValue    string `json:"OldStateValue"`
+	Trigger          struct {
+		MetricName string `json:"MetricName"`
+		Namespace  string `json:"Namespace"`
+		Dimensions []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"Dimensions"`
+	} `json:"Trigger"`
+}
+
+// arn returns the parts of the ARN of the alarm, such as
+// "arn:aws:cloudwatch:us-east-1:123456789012:alarm:web-cpu".
+func (n *alarmNotification) arn() (partition, region, account string) {
+	parts := strings.SplitN(n.AlarmArn, ":", 6)
+	if len(parts) < 5 {
+		return "aws", awsRegion(), ""
+	}
+	return parts[1], parts[3], parts[4]
+}
+
+// text returns the queries of the resources of the dimensions of the alarm.
+// Load balancers are queried by their ARNs, which are built from the ARN of
+// the alarm.
+func (n *alarmNotification) text() string {
+	partition, region, account := n.arn()
+	lbARN := "arn:" + partition + ":elasticloadbalancing:" + region + ":" + account + ":loadbalancer/"
+	var queries []string
+	for _, d := range n.Trigger.Dimensions {
+		switch d.Name {
+		case "InstanceId":
+			queries = append(queries, d.Value)
+		case "LoadBalancerName", "LoadBalancer":
+			queries = append(queries, lbARN+d.Value)
+		}
+	}
+	return strings.Join(queries, "\n")
+}
+
+// card returns the card of the alarm colored by its state.
+func (n *alarmNotification) card() []slack.Attachment {
+	_, region, _ := n.arn()
+	dimensions := make([]string, len(n.Trigger.Dimensions))
+	for i, d := range n.Trigger.Dimensions {
+		dimensions[i] = d.Name + "=" + d.Value
+	}
+	return []slack.Attachment{{
+		Fallback:   n.AlarmName + ": " + n.NewStateValue,
+		Title:      n.AlarmName,
+		TitleLink:  cloudWatchURL(region, "alarmsV2:alarm/"+url.PathEscape(n.AlarmName)),
+		Text:       n.NewStateReason,
+		Color:      alarmColors[alarmSeverity(n.NewStateValue)],
+		MarkdownIn: []string{"fields"},
+		Fields: []slack.AttachmentField{
+			{Title: tr("State"), Value: code(n.OldStateValue) + " → " + code(n.NewStateValue), Short: true},
+			{Title: tr("Metric"), Value: code(n.Trigger.Namespace + " " + n.Trigger.MetricName), Short: true},
+			{Title: tr("Dimensions"), Value: code(strings.Join(dimensions, ", "))},
+			{Title: tr("Description"), Value: optional(&n.AlarmDescription)},
+		},
+	}}
+}
+
+// handleAlarm confirms the subscriptions to the alarm topics, and posts the
+// cards of the alarms entering the ALARM state to the channel of the alarm
+// with those of the resources of their dimensions.
+func handleAlarm(c echo.Context) error {
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		return err
+	}
+	var m snsMessage
+	if err := json.Unmarshal(body, &m); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if !alarmTopic(m.TopicArn) {
+		return c.String(http.StatusForbidden, "unknown topic "+m.TopicArn)
+	}
+	if err := verifySNSMessage(&m); err != nil {
+		logWarn("failed to verify SNS message", logFields{"topic_arn": m.TopicArn, "error": err.Error()})
+		return c.String(http.StatusUnauthorized, "failed to verify signature")
+	}
+	switch m.Type {
+	case "SubscriptionConfirmation":
+		if err := confirmSubscription(m.SubscribeURL); err != nil {
+			logError("failed to confirm SNS subscription", err, logFields{"topic_arn": m.TopicArn})
+			return err
+		}
+		return c.String(http.StatusOK, "subscription confirmed")
+	case "Notification":
+	default:
+		return c.String(http.StatusOK, "ignore "+m.Type)
+	}
+	var n alarmNotification
+	if err := json.Unmarshal([]byte(m.Message), &n); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if n.NewStateValue != cloudwatch.StateValueAlarm {
+		return c.String(http.StatusOK, "ignore "+n.NewStateValue)
+	}
+	channel := mappedChannel(alarmChannels, n.AlarmName)
+	if channel == "" {
+		return c.String(http.StatusOK, "no channel of alarm "+n.AlarmName)
+	}
+	goBackground(func() {
+		enrich("alarm:"+m.MessageID, channel, tr("CloudWatch alarm %s", n.AlarmName), n.card(), n.text())
+	})
+	return c.String(http.StatusOK, "queued")
+}
+
+// alarmTopic reports whether the ARN is one of the alarm topics.
+func alarmTopic(arn string) bool {
+	for _, t := range alarmTopics {
+		if t == arn {
+			return true
+		}
+	}
+	return false
+}
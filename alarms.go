@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/bgpat/ec2bot/internal/cache"
+	"github.com/nlopes/slack"
+)
+
+var (
+	// showAlarms enables the lists of the CloudWatch alarms of resources on
+	// their cards, $SHOW_ALARMS, which need cloudwatch:DescribeAlarms.
+	showAlarms = boolEnv("SHOW_ALARMS", false)
+
+	// regionAlarms are the alarms of each region, which are searched by
+	// dimensions since CloudWatch cannot filter alarms by them.
+	regionAlarms = cache.NewLRU(100, time.Minute)
+)
+
+// alarmStates are the states of alarms from the least severe, and the
+// colors of the lists whose most severe state they are.
+var (
+	alarmStates = []string{cloudwatch.StateValueOk, cloudwatch.StateValueInsufficientData, cloudwatch.StateValueAlarm}
+	alarmColors = []string{"good", "#9e9e9e", "danger"}
+)
+
+func alarmSeverity(state string) int {
+	for i, s := range alarmStates {
+		if s == state {
+			return i
+		}
+	}
+	return 0
+}
+
+// describeAlarms returns all metric alarms of the region.
+func describeAlarms(region string) ([]*cloudwatch.MetricAlarm, error) {
+	if v, ok := regionAlarms.Get(region); ok {
+		return v.([]*cloudwatch.MetricAlarm), nil
+	}
+	var alarms []*cloudwatch.MetricAlarm
+	svc := regionCloudWatchClient(region)
+	err := svc.DescribeAlarmsPages(&cloudwatch.DescribeAlarmsInput{}, func(page *cloudwatch.DescribeAlarmsOutput, lastPage bool) bool {
+		alarms = append(alarms, page.MetricAlarms...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	regionAlarms.Add(region, alarms)
+	return alarms, nil
+}
+
+// findAlarms returns the alarms of the region whose dimensions have the
+// value.
+func findAlarms(region, dimension, value string) ([]*cloudwatch.MetricAlarm, error) {
+	alarms, err := describeAlarms(region)
+	if err != nil {
+		return nil, err
+	}
+	var found []*cloudwatch.MetricAlarm
+	for _, alarm := range alarms {
+		for _, d := range alarm.Dimensions {
+			if aws.StringValue(d.Name) == dimension && aws.StringValue(d.Value) == value {
+				found = append(found, alarm)
+				break
+			}
+		}
+	}
+	return found, nil
+}
+
+// loadBalancerV2Dimension returns the value of the "LoadBalancer"
+// dimension of the ALB or NLB such as "app/web/0123456789abcdef".
+func loadBalancerV2Dimension(arn string) string {
+	i := strings.Index(arn, ":loadbalancer/")
+	if i < 0 {
+		return ""
+	}
+	return arn[i+len(":loadbalancer/"):]
+}
+
+// alarmsAttachment lists the alarms of the resource with their states. It
+// returns nil if it is disabled or the resource has no alarms.
+func alarmsAttachment(region, dimension, value string) *slack.Attachment {
+	if !showAlarms || value == "" {
+		return nil
+	}
+	alarms, err := findAlarms(region, dimension, value)
+	if err != nil {
+		logError("failed to describe alarms", err, logFields{dimension: value})
+		return nil
+	}
+	if len(alarms) == 0 {
+		return nil
+	}
+	severity := 0
+	lines := make([]string, len(alarms))
+	for i, alarm := range alarms {
+		state := aws.StringValue(alarm.StateValue)
+		if s := alarmSeverity(state); s > severity {
+			severity = s
+		}
+		lines[i] = code(state) + " " + link(
+			cloudWatchURL(region, "alarmsV2:alarm/"+url.PathEscape(aws.StringValue(alarm.AlarmName))),
+			aws.StringValue(alarm.AlarmName),
+		)
+	}
+	return &slack.Attachment{
+		Title:      tr("CloudWatch Alarms"),
+		Text:       strings.Join(lines, "\n"),
+		Color:      alarmColors[severity],
+		MarkdownIn: []string{"text"},
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/bgpat/ec2bot/internal/cache"
+	"github.com/nlopes/slack"
+)
+
+var (
+	// showImage enables the names and the ages of the AMIs on the cards of
+	// instances, $SHOW_AMI, which need ec2:DescribeImages.
+	showImage = boolEnv("SHOW_AMI", false)
+	// imageMaxAge is the age of the AMIs flagged as stale,
+	// $AMI_MAX_AGE_DAYS.
+	imageMaxAge = 180 * 24 * time.Hour
+
+	// images are the AMIs by region and ID, which are nil if they are
+	// deregistered or not shared with the account.
+	images = cache.NewLRU(1000, time.Hour)
+)
+
+func init() {
+	if v := getenv("AMI_MAX_AGE_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			logWarn("cannot parse $AMI_MAX_AGE_DAYS, use default '180'", nil)
+			return
+		}
+		imageMaxAge = time.Duration(n) * 24 * time.Hour
+	}
+}
+
+// describeImage returns the AMI of the ID, or nil if it is not found.
+func describeImage(region, id string) (*ec2.Image, error) {
+	key := region + "/" + id
+	if v, ok := images.Get(key); ok {
+		return v.(*ec2.Image), nil
+	}
+	svc := regionEC2Client(region)
+	resp, err := svc.DescribeImages(&ec2.DescribeImagesInput{
+		ImageIds: []*string{aws.String(id)},
+	})
+	var image *ec2.Image
+	switch {
+	case err != nil:
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != "InvalidAMIID.NotFound" && aerr.Code() != "InvalidAMIID.Unavailable" {
+			return nil, err
+		}
+	case len(resp.Images) > 0:
+		image = resp.Images[0]
+	}
+	images.Add(key, image)
+	return image, nil
+}
+
+// imageField shows the name and the age of the AMI of the instance, which
+// is flagged if it is older than imageMaxAge or deregistered.
+func imageField(instance *ec2.Instance) *slack.AttachmentField {
+	id := aws.StringValue(instance.ImageId)
+	if !showImage || id == "" {
+		return nil
+	}
+	image, err := describeImage(instanceRegion(aws.StringValue(instance.InstanceId)), id)
+	if err != nil {
+		logError("failed to describe image", err, logFields{"image_id": id})
+		return nil
+	}
+	field := &slack.AttachmentField{Title: tr("AMI"), Short: true}
+	if image == nil {
+		field.Value = code(id) + "\n:warning: " + tr("deregistered")
+		return field
+	}
+	field.Value = optional(image.Name) + "\n" + code(id)
+	created, err := time.Parse(time.RFC3339, aws.StringValue(image.CreationDate))
+	if err != nil {
+		return field
+	}
+	age := time.Since(created)
+	field.Value += "\n" + tr("created %s ago", tr("%d days", int(age.Hours()/24)))
+	if age > imageMaxAge {
+		field.Value += " :warning:"
+	}
+	return field
+}
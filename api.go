@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/labstack/echo"
+)
+
+// apiTokens are the bearer tokens of the read-only lookup API, $API_TOKENS.
+// The API is disabled unless they are set.
+var apiTokens = strings.FieldsFunc(getenv("API_TOKENS"), isComma)
+
+// apiError is the body of the error responses of the API.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// registerAPI adds the endpoints of the lookup API, which resolve queries
+// like messages, such as "GET /api/v1/instances/name:web-1", and return the
+// matching resources as a JSON array. Queries may contain slashes, such as
+// those of pods and ARNs.
+func registerAPI(e *echo.Echo) {
+	if len(apiTokens) == 0 {
+		return
+	}
+	g := e.Group("/api/v1", requireAPIToken)
+	g.GET("/instances/*", handleAPIInstances)
+	g.GET("/loadbalancers/*", handleAPILoadBalancers)
+}
+
+// requireAPIToken rejects the requests without one of the API tokens.
+func requireAPIToken(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		auth := c.Request().Header.Get("Authorization")
+		if strings.HasPrefix(auth, "Bearer ") {
+			token := []byte(strings.TrimPrefix(auth, "Bearer "))
+			for _, t := range apiTokens {
+				if subtle.ConstantTimeCompare(token, []byte(t)) == 1 {
+					return next(c)
+				}
+			}
+		}
+		return c.JSON(http.StatusUnauthorized, &apiError{"invalid token"})
+	}
+}
+
+// apiQuery returns the query of the path.
+func apiQuery(c echo.Context) (string, error) {
+	return url.PathUnescape(c.Param("*"))
+}
+
+func handleAPIInstances(c echo.Context) error {
+	if !resolverEnabled("instance") {
+		return c.JSON(http.StatusNotFound, &apiError{"instance lookups are disabled"})
+	}
+	query, err := apiQuery(c)
+	if err != nil || query == "" {
+		return c.JSON(http.StatusBadRequest, &apiError{"invalid query"})
+	}
+	instances, err := resolveInstances(query)
+	if err != nil {
+		logError("failed to resolve instances", err, logFields{"query": query})
+		return c.JSON(http.StatusInternalServerError, &apiError{"failed to resolve instances"})
+	}
+	if len(instances) == 0 {
+		return c.JSON(http.StatusNotFound, &apiError{"not found"})
+	}
+	results := make([]*ec2.Instance, len(instances))
+	for i, instance := range instances {
+		results[i] = redactedInstance(instance)
+	}
+	return c.JSON(http.StatusOK, results)
+}
+
+func handleAPILoadBalancers(c echo.Context) error {
+	query, err := apiQuery(c)
+	if err != nil || query == "" {
+		return c.JSON(http.StatusBadRequest, &apiError{"invalid query"})
+	}
+	var results []interface{}
+	if resolverEnabled("loadbalancer") {
+		lb, err := getLoadBalancer(query)
+		if err != nil {
+			logError("failed to resolve load balancer", err, logFields{"query": query})
+			return c.JSON(http.StatusInternalServerError, &apiError{"failed to resolve load balancer"})
+		}
+		if lb != nil {
+			results = append(results, lb)
+		}
+	}
+	if resolverEnabled("loadbalancer_v2") {
+		lb, err := getLoadBalancerV2(query)
+		if err != nil {
+			logError("failed to resolve load balancer", err, logFields{"query": query})
+			return c.JSON(http.StatusInternalServerError, &apiError{"failed to resolve load balancer"})
+		}
+		if lb != nil {
+			results = append(results, lb)
+		}
+	}
+	if len(results) == 0 {
+		return c.JSON(http.StatusNotFound, &apiError{"not found"})
+	}
+	return c.JSON(http.StatusOK, results)
+}
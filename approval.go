@@ -0,0 +1,420 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/nlopes/slack"
+)
+
+const approvalsBucket = "approvals"
+
+const (
+	approvalPending  = "pending"
+	approvalApproved = "approved"
+	approvalRejected = "rejected"
+	approvalExpired  = "expired"
+)
+
+// Approval is a request to perform a destructive action which has to be
+// approved before it is executed. RequesterEmail is the email address of
+// the Slack profile of the requester, who may not decide through an
+// approval link either.
+type Approval struct {
+	ID             string    `json:"id"`
+	Action         string    `json:"action"`
+	Resource       string    `json:"resource"`
+	Parameter      string    `json:"parameter,omitempty"`
+	RequestedBy    string    `json:"requested_by"`
+	RequesterEmail string    `json:"requester_email,omitempty"`
+	TeamID         string    `json:"team_id"`
+	Channel        string    `json:"channel"`
+	Thread         string    `json:"thread"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	Status         string    `json:"status"`
+	DecidedBy      string    `json:"decided_by,omitempty"`
+	DecidedAt      time.Time `json:"decided_at"`
+}
+
+var (
+	botURL             = getenv("BOT_URL")
+	approvalSigningKey = []byte(getenv("APPROVAL_SIGNING_KEY"))
+
+	approvalMu sync.Mutex
+
+	// approvalRequiredActions must be approved by a second authorized user
+	// before they are executed.
+	approvalRequiredActions = strings.FieldsFunc(getenv("APPROVAL_REQUIRED_ACTIONS"), isComma)
+
+	// approvalTTL is how long an approval request may be approved.
+	approvalTTL = 15 * time.Minute
+
+	// approvalEmails are the addresses of the approvers without access to
+	// Slack, $APPROVAL_EMAILS, who are sent signed approval links through
+	// SES ($SES_SENDER). $BOT_URL and $APPROVAL_SIGNING_KEY have to be set.
+	approvalEmails = strings.FieldsFunc(getenv("APPROVAL_EMAILS"), isComma)
+
+	// approvalHandlers execute approved actions by their name.
+	approvalHandlers = make(map[string]func(*Approval) error)
+)
+
+func init() {
+	actionHandlers["approval"] = handleApprovalAction
+	if v := getenv("APPROVAL_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			logWarn("cannot parse $APPROVAL_TTL, use default '15m'", nil)
+			return
+		}
+		approvalTTL = d
+	}
+}
+
+func requiresApproval(action string) bool {
+	for _, a := range approvalRequiredActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func newApproval(action, resource, parameter, user, teamID, channel, thread string, ttl time.Duration) (*Approval, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	a := &Approval{
+		ID:          hex.EncodeToString(b),
+		Action:      action,
+		Resource:    resource,
+		Parameter:   parameter,
+		RequestedBy: user,
+		TeamID:      teamID,
+		Channel:     channel,
+		Thread:      thread,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+		Status:      approvalPending,
+	}
+	return a, store.Put(approvalsBucket, a.ID, a)
+}
+
+func getApproval(id string) (*Approval, error) {
+	a := new(Approval)
+	ok, err := store.Get(approvalsBucket, id, a)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return a, nil
+}
+
+// decide records the decision of approver and executes the action in the
+// background if it was approved. The outcome is posted to the Slack thread
+// of the request.
+func (a *Approval) decide(approve bool, approver string) error {
+	if approve && a.requestedBy(approver) {
+		return errors.New("you cannot approve your own request")
+	}
+	if err := a.record(approve, approver); err != nil {
+		return err
+	}
+	decided := *a
+	goBackground(decided.execute)
+	return nil
+}
+
+// record stores the decision of approver unless the approval was already
+// decided or has expired. approvalMu is only held while the state changes,
+// so that slow actions do not block the other approvals.
+func (a *Approval) record(approve bool, approver string) error {
+	approvalMu.Lock()
+	defer approvalMu.Unlock()
+	// reload to detect decisions made concurrently
+	current, err := getApproval(a.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return errors.New("approval not found")
+	}
+	*a = *current
+	if a.Status != approvalPending {
+		return fmt.Errorf("approval is already %s", a.Status)
+	}
+	if time.Now().After(a.ExpiresAt) {
+		a.Status = approvalExpired
+		if err := store.Put(approvalsBucket, a.ID, a); err != nil {
+			logError("failed to expire approval", err, logFields{"approval_id": a.ID})
+		}
+		return errors.New("approval has expired")
+	}
+	a.Status = approvalRejected
+	if approve {
+		a.Status = approvalApproved
+	}
+	a.DecidedBy = approver
+	a.DecidedAt = time.Now()
+	return store.Put(approvalsBucket, a.ID, a)
+}
+
+// execute runs the action of the decided approval if it was approved, and
+// posts the outcome to the Slack thread of the request.
+func (a *Approval) execute() {
+	text := fmt.Sprintf("%s of %s was %s by %s", a.Action, a.Resource, a.Status, a.DecidedBy)
+	if a.Status == approvalApproved {
+		var err error
+		if handler, ok := approvalHandlers[a.Action]; ok {
+			err = handler(a)
+		} else {
+			err = fmt.Errorf("unknown action %q", a.Action)
+		}
+		if err != nil {
+			logError("failed to execute approved action", err, logFields{"approval_id": a.ID, "action": a.Action})
+			text += fmt.Sprintf(" but failed: %v", err)
+		}
+	}
+	_, _, err := slackClient(a.TeamID).PostMessage(a.Channel, text, messageParameters(a.Thread, nil))
+	if err != nil {
+		logError("failed to post approval result", err, logFields{"approval_id": a.ID, "channel": a.Channel})
+	}
+}
+
+// requestedBy reports whether the approver, a Slack mention or an email
+// address, is the requester.
+func (a *Approval) requestedBy(approver string) bool {
+	return approver == "<@"+a.RequestedBy+">" ||
+		approver == a.RequestedBy ||
+		a.RequesterEmail != "" && strings.EqualFold(approver, a.RequesterEmail)
+}
+
+// event returns an event replying in the thread of the request on behalf
+// of the requester.
+func (a *Approval) event() *Event {
+	return &Event{
+		TeamID: a.TeamID,
+		Event: &slack.Msg{
+			Channel:   a.Channel,
+			User:      a.RequestedBy,
+			Timestamp: a.Thread,
+		},
+	}
+}
+
+func (a *Approval) target() string {
+	if a.Parameter == "" {
+		return code(a.Resource)
+	}
+	return fmt.Sprintf("%s (%s)", code(a.Resource), a.Parameter)
+}
+
+// requestApproval posts a request to perform the action on the resource
+// which another authorized user has to approve before it expires.
+func (ev *Event) requestApproval(action, resource, parameter string) error {
+	a, err := newApproval(action, resource, parameter, ev.Event.User, ev.TeamID, ev.Event.Channel, ev.Event.Timestamp, approvalTTL)
+	if err != nil {
+		return err
+	}
+	if err := a.sendApprovalLinks(); err != nil {
+		logError("failed to send approval links", err, logFields{"approval_id": a.ID})
+	}
+	return ev.post(
+		tr("<@%s> requests %s of %s", ev.Event.User, action, a.target()),
+		[]slack.Attachment{
+			slack.Attachment{
+				Fallback:   a.ID,
+				Text:       tr("Another authorized user must approve this request within %s.", approvalTTL),
+				CallbackID: "approval",
+				Actions: []slack.AttachmentAction{
+					slack.AttachmentAction{
+						Name:  "approve",
+						Text:  tr("Approve"),
+						Type:  "button",
+						Style: "primary",
+						Value: a.ID,
+					},
+					slack.AttachmentAction{
+						Name:  "reject",
+						Text:  tr("Reject"),
+						Type:  "button",
+						Style: "danger",
+						Value: a.ID,
+					},
+				},
+			},
+		},
+	)
+}
+
+// handleApprovalAction decides on an approval request posted to Slack. The
+// requester may only reject their own request.
+func handleApprovalAction(cb *ActionCallback, action slack.AttachmentAction) (*slack.Msg, error) {
+	a, err := getApproval(action.Value)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, errors.New(tr("approval not found"))
+	}
+	approve := action.Name == "approve"
+	if approve {
+		if a.RequestedBy == cb.User.ID {
+			return nil, errors.New(tr("you cannot approve your own request"))
+		}
+		if err := authorizeAction(cb.Team.ID, cb.User.ID, a.Action, a.Resource); err != nil {
+			return nil, err
+		}
+	}
+	decideErr := a.decide(approve, "<@"+cb.User.ID+">")
+	if a.Status == approvalPending {
+		return nil, decideErr
+	}
+	if cb.OriginalMessage == nil {
+		return nil, nil
+	}
+	msg := *cb.OriginalMessage
+	msg.Attachments = []slack.Attachment{
+		slack.Attachment{
+			Text: tr("%s by %s", a.Status, a.DecidedBy),
+		},
+	}
+	if a.DecidedBy == "" {
+		msg.Attachments[0].Text = a.Status
+	}
+	return &msg, nil
+}
+
+// approvalURL returns a signed link with which approver can decide on the
+// approval without access to Slack. The link expires with the approval.
+func (a *Approval) approvalURL(approver string) string {
+	q := url.Values{
+		"id":       {a.ID},
+		"approver": {approver},
+		"exp":      {strconv.FormatInt(a.ExpiresAt.Unix(), 10)},
+	}
+	q.Set("sig", signApproval(q))
+	return botURL + "/approvals?" + q.Encode()
+}
+
+// sendApprovalLinks emails the signed links of the approval to
+// approvalEmails except the requester. It does nothing unless the links are
+// enabled.
+func (a *Approval) sendApprovalLinks() error {
+	if len(approvalEmails) == 0 || len(approvalSigningKey) == 0 || botURL == "" || sesSender == "" {
+		return nil
+	}
+	if user, err := slackClient(a.TeamID).GetUserInfo(a.RequestedBy); err != nil {
+		logWarn("failed to get the email address of the requester", logFields{"user": a.RequestedBy, "error": err.Error()})
+	} else {
+		a.RequesterEmail = user.Profile.Email
+		if err := store.Put(approvalsBucket, a.ID, a); err != nil {
+			return err
+		}
+	}
+	for _, approver := range approvalEmails {
+		if a.requestedBy(approver) {
+			continue
+		}
+		err := EmailSink{To: []string{approver}}.Send(&Notification{
+			Title: fmt.Sprintf("ec2bot: approve %s of %s", a.Action, a.Resource),
+			Text: fmt.Sprintf("%s of %s was requested by a Slack user (%s).\nApprove or reject it by %s:\n%s",
+				a.Action, a.Resource, a.RequestedBy, a.ExpiresAt.Format(time.RFC1123), a.approvalURL(approver)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func signApproval(q url.Values) string {
+	mac := hmac.New(sha256.New, approvalSigningKey)
+	fmt.Fprintf(mac, "%s\n%s\n%s", q.Get("id"), q.Get("approver"), q.Get("exp"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifyApproval(q url.Values) error {
+	if len(approvalSigningKey) == 0 {
+		return errors.New("signed approval links are disabled")
+	}
+	if !hmac.Equal([]byte(q.Get("sig")), []byte(signApproval(q))) {
+		return errors.New("invalid signature")
+	}
+	exp, err := strconv.ParseInt(q.Get("exp"), 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return errors.New("link has expired")
+	}
+	return nil
+}
+
+var approvalPage = template.Must(template.New("approval").Parse(`<!DOCTYPE html>
+<html>
+<head><title>ec2bot approval</title></head>
+<body>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+{{with .Approval}}
+<p><strong>{{.Action}}</strong> of <code>{{.Resource}}</code> requested by {{.RequestedBy}}.</p>
+<p>Status: {{.Status}}</p>
+{{if eq .Status "pending"}}
+<form method="post">
+<button name="decision" value="approve">Approve</button>
+<button name="decision" value="reject">Reject</button>
+</form>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// handleApproval serves signed approval links. GET shows the request and
+// POST records the decision, so that link scanners cannot decide.
+func handleApproval(c echo.Context) error {
+	q := c.QueryParams()
+	if err := verifyApproval(q); err != nil {
+		return c.String(http.StatusForbidden, err.Error())
+	}
+	a, err := getApproval(q.Get("id"))
+	if err != nil {
+		logError("failed to get approval", err, logFields{"approval_id": q.Get("id")})
+		return err
+	}
+	if a == nil {
+		return c.String(http.StatusNotFound, "approval not found")
+	}
+	if a.requestedBy(q.Get("approver")) {
+		return c.String(http.StatusForbidden, "you cannot approve your own request")
+	}
+
+	data := struct {
+		Approval *Approval
+		Message  string
+	}{Approval: a}
+	if c.Request().Method == http.MethodPost {
+		approve := c.FormValue("decision") == "approve"
+		if err := a.decide(approve, q.Get("approver")); err != nil {
+			data.Message = err.Error()
+		} else {
+			data.Message = "Your decision has been recorded."
+		}
+	}
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	c.Response().WriteHeader(http.StatusOK)
+	return approvalPage.Execute(c.Response(), data)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/bgpat/ec2bot/internal/resolver"
+)
+
+// findARNQueries returns the ARNs in the event which fn accepts.
+func (ev *Event) findARNQueries(fn func(*resolver.ARN) bool) []string {
+	var queries []string
+	for _, q := range ev.findQuery(resolver.ARNPattern) {
+		if a, err := resolver.ParseARN(q); err == nil && fn(a) {
+			queries = append(queries, q)
+		}
+	}
+	return queries
+}
+
+// getInstanceByARN returns the instance in the region of the ARN. The
+// cache is used for the searched regions.
+func getInstanceByARN(arn string) (*ec2.Instance, error) {
+	a, err := resolver.ParseARN(arn)
+	if err != nil {
+		return nil, err
+	}
+	if a.Region == "" || searchedRegion(a.Region) {
+		return getInstance(a.Resource)
+	}
+	svc := instanceDescriber(a.Region)
+	resp, err := svc.DescribeInstances(&ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String(a.Resource)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, reservation := range resp.Reservations {
+		if len(reservation.Instances) > 0 {
+			return reservation.Instances[0], nil
+		}
+	}
+	return nil, nil
+}
+
+// getLoadBalancerByARN returns the Classic ELB in the region of the ARN.
+func getLoadBalancerByARN(arn string) (*elb.LoadBalancerDescription, error) {
+	a, err := resolver.ParseARN(arn)
+	if err != nil {
+		return nil, err
+	}
+	svc := loadBalancerDescriber(a.Region)
+	resp, err := svc.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{
+		LoadBalancerNames: []*string{aws.String(a.Resource)},
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == elb.ErrCodeAccessPointNotFoundException {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(resp.LoadBalancerDescriptions) == 0 {
+		return nil, nil
+	}
+	return resp.LoadBalancerDescriptions[0], nil
+}
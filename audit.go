@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/nlopes/slack"
+)
+
+const auditBucket = "audit"
+
+// auditRows is the number of entries shown by "/ec2 admin audit".
+const auditRows = 20
+
+// AuditEntry records an action performed through the bot.
+type AuditEntry struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	TeamID   string    `json:"team_id"`
+	User     string    `json:"user"`
+	Channel  string    `json:"channel"`
+	Action   string    `json:"action"`
+	Resource string    `json:"resource"`
+	Outcome  string    `json:"outcome"`
+	Error    string    `json:"error,omitempty"`
+}
+
+var (
+	auditTable = getenv("AUDIT_DYNAMODB_TABLE")
+
+	// auditSinks receive every audit entry in addition to the store, such
+	// as "slack:C0123456" for a dedicated audit channel.
+	auditSinks []Sink
+)
+
+// audit records the outcome of an action performed by the user of the
+// event on the resource.
+func (ev *Event) audit(action, resource string, err error) {
+	recordAction(ev.Event.User, action, err)
+
+	b := make([]byte, 4)
+	rand.Read(b)
+	now := time.Now().UTC()
+	entry := &AuditEntry{
+		ID:       now.Format("20060102T150405.000000000Z") + "-" + hex.EncodeToString(b),
+		Time:     now,
+		TeamID:   ev.TeamID,
+		User:     ev.Event.User,
+		Channel:  ev.Event.Channel,
+		Action:   action,
+		Resource: resource,
+		Outcome:  "succeeded",
+	}
+	if err != nil {
+		entry.Outcome = "failed"
+		entry.Error = err.Error()
+	}
+
+	if err := store.Put(auditBucket, entry.ID, entry); err != nil {
+		logError("failed to store audit entry", err, logFields{"audit_id": entry.ID})
+	}
+	if auditTable != "" {
+		if err := entry.putDynamoDB(auditTable); err != nil {
+			logError("failed to put audit entry to DynamoDB", err, logFields{"audit_id": entry.ID})
+		}
+	}
+	if err := notify(auditSinks, entry.notification()); err != nil {
+		logError("failed to notify audit entry", err, logFields{"audit_id": entry.ID})
+	}
+}
+
+// putDynamoDB appends the entry to the table. Existing entries are never
+// overwritten.
+func (e *AuditEntry) putDynamoDB(table string) error {
+	item, err := dynamodbattribute.MarshalMap(e)
+	if err != nil {
+		return err
+	}
+	svc := dynamoDBClient
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	return err
+}
+
+func (e *AuditEntry) String() string {
+	s := fmt.Sprintf(
+		"%s <@%s> %s %s in <#%s>: %s",
+		e.Time.Format(time.RFC3339),
+		e.User,
+		e.Action,
+		code(e.Resource),
+		e.Channel,
+		e.Outcome,
+	)
+	if e.Error != "" {
+		s += " (" + e.Error + ")"
+	}
+	return s
+}
+
+func (e *AuditEntry) notification() *Notification {
+	return &Notification{
+		Title: fmt.Sprintf("ec2bot audit: %s %s", e.Action, e.Resource),
+		Text:  e.String(),
+	}
+}
+
+// recentAuditEntries returns the last n entries in the store, newest first.
+func recentAuditEntries(n int) []*AuditEntry {
+	keys := store.Keys(auditBucket)
+	entries := make([]*AuditEntry, 0, n)
+	for i := len(keys) - 1; i >= 0 && len(entries) < n; i-- {
+		e := new(AuditEntry)
+		if ok, err := store.Get(auditBucket, keys[i], e); err != nil || !ok {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// auditAttachment lists the recent audit entries for "/ec2 admin audit".
+func auditAttachment(n int) slack.Attachment {
+	entries := recentAuditEntries(n)
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = e.String()
+	}
+	text := strings.Join(lines, "\n")
+	if text == "" {
+		text = "none"
+	}
+	return slack.Attachment{
+		Title:      fmt.Sprintf("Last %d actions", len(entries)),
+		Text:       text,
+		MarkdownIn: []string{"text"},
+	}
+}
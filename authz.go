@@ -0,0 +1,130 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// detailsUserGroup restricts full details (YAML dump, IP addresses and
+// tags) to members of a Slack user group. Everyone else gets a minimal
+// card. An empty value shows full details to everyone.
+var detailsUserGroup = getenv("SLACK_DETAILS_USERGROUP")
+
+type userGroupMembers struct {
+	UpdatedAt time.Time
+	Members   map[string]struct{}
+}
+
+var (
+	userGroupCacheMu sync.Mutex
+	userGroupCache   = make(map[string]userGroupMembers)
+)
+
+// canViewDetails reports whether the user who posted the event may see
+// sensitive details.
+func (ev *Event) canViewDetails() bool {
+	if detailsUserGroup == "" {
+		return true
+	}
+	return isUserGroupMember(ev.TeamID, detailsUserGroup, ev.Event.User)
+}
+
+// detailedCards reports whether the cards rendered for the event show the
+// sensitive details. Cards posted to channels never show the restricted
+// details, which everyone in them would see.
+func (ev *Event) detailedCards() bool {
+	if detailsUserGroup == "" {
+		return true
+	}
+	return (ev.private || ev.userPrefs().DM) && ev.canViewDetails()
+}
+
+// privateCopy returns a copy of the event rendering the detailed cards sent
+// only to the user, or nil if the cards posted already show the details or
+// the user may not see them.
+func (ev *Event) privateCopy() *Event {
+	if detailsUserGroup == "" || ev.userPrefs().DM || !ev.canViewDetails() {
+		return nil
+	}
+	c := *ev
+	c.private = true
+	return &c
+}
+
+// postPrivately sends the message to the user by direct message.
+func (ev *Event) postPrivately(text string, attachments []slack.Attachment) error {
+	im, err := ev.chat().OpenDM(ev.Event.User)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		ev.logDryRun("dry run: would send direct message", text, attachments)
+		return nil
+	}
+	return ev.chat().PostMessage(im, "", text, attachments)
+}
+
+// uploadPrivately shares the file with the user by direct message if the
+// details are restricted, or where upload would share it otherwise.
+func (ev *Event) uploadPrivately(file *File) error {
+	if detailsUserGroup == "" || ev.userPrefs().DM {
+		return ev.upload(file)
+	}
+	im, err := ev.chat().OpenDM(ev.Event.User)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		ev.logDryRun("dry run: would upload file", file.Title, nil)
+		return nil
+	}
+	return ev.chat().UploadFile(im, "", file)
+}
+
+// postDetailedCopy sends the detailed copy of the cards rendered by render
+// to the user if the cards posted to the channel are restricted.
+func (ev *Event) postDetailedCopy(text string, render func(*Event) ([]slack.Attachment, error)) {
+	p := ev.privateCopy()
+	if p == nil {
+		return
+	}
+	attachments, err := render(p)
+	if err == nil {
+		err = p.postPrivately(text, attachments)
+	}
+	if err != nil {
+		logError("failed to send detailed card", err, ev.logFields())
+	}
+}
+
+// isUserGroupMember reports whether user is a member of the user group of
+// the team. Members are cached for $USERGROUP_CACHE_TTL.
+func isUserGroupMember(teamID, group, user string) bool {
+	key := teamID + "/" + group
+
+	userGroupCacheMu.Lock()
+	defer userGroupCacheMu.Unlock()
+	cache, ok := userGroupCache[key]
+	if !ok || cache.UpdatedAt.Add(userGroupCacheTTL).Before(time.Now()) {
+		users, err := slackClient(teamID).GetUserGroupMembers(group)
+		if err != nil {
+			logError("failed to get user group members", err, logFields{"team_id": teamID, "usergroup": group})
+			if !ok {
+				return false
+			}
+		} else {
+			cache = userGroupMembers{
+				UpdatedAt: time.Now(),
+				Members:   make(map[string]struct{}, len(users)),
+			}
+			for _, u := range users {
+				cache.Members[u] = struct{}{}
+			}
+			userGroupCache[key] = cache
+		}
+	}
+	_, ok = cache.Members[user]
+	return ok
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudtrail"
+	"github.com/aws/aws-sdk-go/service/cloudtrail/cloudtrailiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/ses/sesiface"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// awsSession is shared by the clients of the default region so that the
+// configuration and the credentials are loaded once.
+var awsSession = newAWSSession()
+
+// The clients of the default region are shared by all requests. They are
+// interfaces so that they may be replaced with fakes.
+var (
+	ec2Client        ec2iface.EC2API               = ec2.New(awsSession, endpointConfig(ec2Endpoint, awsRegion()))
+	elbClient        elbiface.ELBAPI               = elb.New(awsSession, endpointConfig(elbEndpoint, awsRegion()))
+	elbv2Client      elbv2iface.ELBV2API           = elbv2.New(awsSession, endpointConfig(elbEndpoint, awsRegion()))
+	ssmClient        ssmiface.SSMAPI               = ssm.New(awsSession)
+	cloudWatchClient cloudwatchiface.CloudWatchAPI = cloudwatch.New(awsSession)
+	cloudTrailClient cloudtrailiface.CloudTrailAPI = cloudtrail.New(awsSession)
+	dynamoDBClient   dynamodbiface.DynamoDBAPI     = dynamodb.New(awsSession)
+	sesClient        sesiface.SESAPI               = ses.New(awsSession)
+	snsClient        snsiface.SNSAPI               = sns.New(awsSession)
+	iamClient        iamiface.IAMAPI               = iam.New(awsSession)
+	stsClient        stsiface.STSAPI               = sts.New(awsSession)
+	// the Price List API is only served in us-east-1
+	pricingClient pricingiface.PricingAPI = pricing.New(awsSession, aws.NewConfig().WithRegion("us-east-1"))
+)
+
+var (
+	// ec2Endpoint is the URL of the EC2 API, $AWS_EC2_ENDPOINT, such as
+	// that of a VPC endpoint or a partition unknown to the SDK.
+	ec2Endpoint = getenv("AWS_EC2_ENDPOINT")
+	// elbEndpoint is the URL of the ELB API of Classic ELBs, ALBs and NLBs,
+	// $AWS_ELB_ENDPOINT.
+	elbEndpoint = getenv("AWS_ELB_ENDPOINT")
+)
+
+// endpointConfig returns the config of the clients of the region using the
+// endpoint. "{region}" in the endpoint is replaced with the region, and
+// endpoints without it are only used in the default region.
+func endpointConfig(endpoint, region string) *aws.Config {
+	config := aws.NewConfig()
+	switch {
+	case strings.Contains(endpoint, "{region}"):
+		config = config.WithEndpoint(strings.Replace(endpoint, "{region}", region, -1))
+	case endpoint != "" && region == awsRegion():
+		config = config.WithEndpoint(endpoint)
+	}
+	return config
+}
+
+var (
+	regionSessionsMu sync.Mutex
+	regionSessions   = make(map[string]*session.Session)
+)
+
+// regionSession returns the session shared by the clients of the region,
+// which is the default session for the default region.
+func regionSession(region string) *session.Session {
+	if region == "" || region == awsRegion() {
+		return awsSession
+	}
+	regionSessionsMu.Lock()
+	defer regionSessionsMu.Unlock()
+	s, ok := regionSessions[region]
+	if !ok {
+		s = awsSession.Copy(aws.NewConfig().WithRegion(region))
+		regionSessions[region] = s
+	}
+	return s
+}
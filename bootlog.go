@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/nlopes/slack"
+)
+
+// consoleOutputLimit is the number of bytes at the end of the console
+// output which are uploaded.
+var consoleOutputLimit = 16 * 1024
+
+func init() {
+	mentionCommands["console"] = (*Event).consoleOutput
+	if v := getenv("CONSOLE_OUTPUT_KB"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			logWarn("cannot parse $CONSOLE_OUTPUT_KB, use default '16'", nil)
+			return
+		}
+		consoleOutputLimit = n * 1024
+	}
+}
+
+func consoleOutputButton(id string) slack.AttachmentAction {
+	return slack.AttachmentAction{
+		Name:  "console_output",
+		Text:  tr("Console output"),
+		Type:  "button",
+		Value: id,
+	}
+}
+
+// consoleOutput handles "@ec2bot console i-xxx" by uploading the console
+// output of each instance.
+func (ev *Event) consoleOutput(args []string) error {
+	ids := hostIDPattern.FindAllString(strings.Join(args, " "), -1)
+	if len(ids) == 0 {
+		return errors.New(tr("usage: console <instance id>"))
+	}
+	for _, id := range ids {
+		if err := authorizeAction(ev.TeamID, ev.Event.User, "console_output", id); err != nil {
+			ev.post(err.Error(), nil)
+			continue
+		}
+		if err := ev.uploadConsoleOutput(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadConsoleOutput uploads the tail of the console output of the
+// instance as a text snippet.
+func (ev *Event) uploadConsoleOutput(id string) error {
+	output, err := getConsoleOutput(id)
+	ev.audit("console_output", id, err)
+	if err != nil {
+		return ev.post(tr("failed to get console output of %s: %v", id, err), nil)
+	}
+	if len(output) == 0 {
+		return ev.post(tr("no console output is available for %s yet", code(id)), nil)
+	}
+	return ev.upload(&File{
+		Name:    id + "-console.log",
+		Type:    "text",
+		Title:   tr("Console output of %s", id),
+		Content: tail(output, consoleOutputLimit),
+	})
+}
+
+// getConsoleOutput returns the decoded console output of the instance.
+func getConsoleOutput(id string) ([]byte, error) {
+	svc := regionEC2Client(instanceRegion(id))
+	resp, err := svc.GetConsoleOutput(&ec2.GetConsoleOutputInput{
+		InstanceId: aws.String(id),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Output == nil {
+		return nil, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(*resp.Output)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode console output: %v", err)
+	}
+	return b, nil
+}
+
+// tail returns the last n bytes of b starting at a line boundary.
+func tail(b []byte, n int) []byte {
+	if len(b) <= n {
+		return b
+	}
+	b = b[len(b)-n:]
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		b = b[i+1:]
+	}
+	return b
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/bgpat/ec2bot/internal/awsclients"
+)
+
+// fakeAWS is the fake APIs of the searched regions.
+type fakeAWS struct {
+	EC2 map[string]*awsclients.FakeEC2
+	ELB map[string]*awsclients.FakeELB
+}
+
+// useFakeAWS makes the bot search the regions of the fakes, starting with
+// empty caches which expire after their TTLs. The returned function
+// restores the clients and the caches.
+func useFakeAWS(f fakeAWS) func() {
+	seen := make(map[string]bool)
+	var regions []string
+	for region := range f.EC2 {
+		seen[region] = true
+		regions = append(regions, region)
+	}
+	for region := range f.ELB {
+		if !seen[region] {
+			regions = append(regions, region)
+		}
+	}
+	sort.Strings(regions)
+
+	origRegions, origRefresh := awsRegions, backgroundRefresh
+	origEC2, origELB := instanceDescriber, loadBalancerDescriber
+	cacheMu.Lock()
+	origInstances, origLoadBalancers := instanceCache, loadBalancerCache
+	instanceCache, loadBalancerCache = InstanceCache{}, LoadBalancerCache{}
+	cacheMu.Unlock()
+	breakersMu.Lock()
+	breakers = make(map[string]*CircuitBreaker)
+	breakersMu.Unlock()
+	loadBalancerTags.Purge()
+
+	awsRegions, backgroundRefresh = regions, false
+	instanceDescriber = func(region string) awsclients.InstanceDescriber {
+		if fake, ok := f.EC2[region]; ok {
+			return fake
+		}
+		return new(awsclients.FakeEC2)
+	}
+	loadBalancerDescriber = func(region string) awsclients.LoadBalancerDescriber {
+		if fake, ok := f.ELB[region]; ok {
+			return fake
+		}
+		return new(awsclients.FakeELB)
+	}
+	return func() {
+		awsRegions, backgroundRefresh = origRegions, origRefresh
+		instanceDescriber, loadBalancerDescriber = origEC2, origELB
+		cacheMu.Lock()
+		instanceCache, loadBalancerCache = origInstances, origLoadBalancers
+		cacheMu.Unlock()
+		loadBalancerTags.Purge()
+	}
+}
+
+// fakeInstance returns a running instance with the private address and
+// the host name based on it.
+func fakeInstance(id, privateIP string) *ec2.Instance {
+	return &ec2.Instance{
+		InstanceId:       aws.String(id),
+		PrivateIpAddress: aws.String(privateIP),
+		PrivateDnsName:   aws.String("ip-" + strings.Replace(privateIP, ".", "-", -1) + ".ec2.internal"),
+		State:            &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+	}
+}
+
+// fakeReservations returns a reservation of each instance.
+func fakeReservations(instances ...*ec2.Instance) []*ec2.Reservation {
+	reservations := make([]*ec2.Reservation, len(instances))
+	for i, instance := range instances {
+		reservations[i] = &ec2.Reservation{
+			ReservationId: aws.String("r-" + strings.TrimPrefix(aws.StringValue(instance.InstanceId), "i-")),
+			OwnerId:       aws.String("123456789012"),
+			Instances:     []*ec2.Instance{instance},
+		}
+	}
+	return reservations
+}
+
+// fakeLoadBalancer returns a Classic ELB of the name in us-east-1.
+func fakeLoadBalancer(name string) *elb.LoadBalancerDescription {
+	return &elb.LoadBalancerDescription{
+		LoadBalancerName: aws.String(name),
+		DNSName:          aws.String(name + "-1234567890.us-east-1.elb.amazonaws.com"),
+	}
+}
+
+func TestCachesConcurrentLookupsAndRefreshes(t *testing.T) {
+	fakeEC2 := &awsclients.FakeEC2{
+		Reservations: fakeReservations(
+			fakeInstance("i-00000001", "10.0.0.1"),
+			fakeInstance("i-00000002", "10.0.0.2"),
+			fakeInstance("i-00000003", "10.0.0.3"),
+		),
+		PageSize: 2,
+	}
+	fakeELB := &awsclients.FakeELB{
+		LoadBalancers: []*elb.LoadBalancerDescription{
+			fakeLoadBalancer("web"),
+			fakeLoadBalancer("api"),
+		},
+		PageSize: 1,
+	}
+	defer useFakeAWS(fakeAWS{
+		EC2: map[string]*awsclients.FakeEC2{"us-east-1": fakeEC2},
+		ELB: map[string]*awsclients.FakeELB{"us-east-1": fakeELB},
+	})()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				instance, err := getInstance("10.0.0.2")
+				if err != nil {
+					t.Errorf("getInstance: %v", err)
+					return
+				}
+				if instance == nil || aws.StringValue(instance.InstanceId) != "i-00000002" {
+					t.Errorf("getInstance(10.0.0.2) = %v, want i-00000002", instance)
+					return
+				}
+				if region := instanceRegion("i-00000002"); region != "us-east-1" {
+					t.Errorf("instanceRegion = %q, want us-east-1", region)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				lb, err := getLoadBalancer("api-1234567890.us-east-1.elb.amazonaws.com")
+				if err != nil {
+					t.Errorf("getLoadBalancer: %v", err)
+					return
+				}
+				if lb == nil || aws.StringValue(lb.LoadBalancerName) != "api" {
+					t.Errorf("getLoadBalancer = %v, want api", lb)
+					return
+				}
+				if region := loadBalancerRegion("api"); region != "us-east-1" {
+					t.Errorf("loadBalancerRegion = %q, want us-east-1", region)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if _, err := refreshInstances(); err != nil {
+					t.Errorf("refreshInstances: %v", err)
+					return
+				}
+				if _, err := refreshLoadBalancers(); err != nil {
+					t.Errorf("refreshLoadBalancers: %v", err)
+					return
+				}
+				if s := cacheSnapshot(); s == nil {
+					t.Error("cacheSnapshot returned nil")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
+	if n := len(instanceCache.Index); n == 0 {
+		t.Error("instance index is empty after the refreshes")
+	}
+	if resp := loadBalancerCache.LoadBalancers; resp == nil || len(resp.LoadBalancerDescriptions) != 2 {
+		t.Errorf("got load balancers %v, want 2", resp)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/bgpat/ec2bot/internal/cache"
+	"github.com/nlopes/slack"
+)
+
+const cacheUsage = "usage: /ec2 admin cache [flush|refresh <name>|all]\n" +
+	"caches: instances, loadbalancers, loadbalancers_v2, loadbalancer_tags, loadbalancer_v2_tags, not_found_instances"
+
+// resourceCaches are the caches of resources which may be refreshed.
+var resourceCaches = []string{"instances", "loadbalancers", "loadbalancers_v2"}
+
+// lruCaches returns the LRU caches by name. The negative cache is missing
+// if it is disabled.
+func lruCaches() map[string]*cache.LRU {
+	caches := map[string]*cache.LRU{
+		"loadbalancer_tags":    loadBalancerTags,
+		"loadbalancer_v2_tags": loadBalancerV2Tags,
+	}
+	if notFoundInstances != nil {
+		caches["not_found_instances"] = notFoundInstances
+	}
+	return caches
+}
+
+// cache runs the admin commands inspecting, flushing and refreshing the
+// caches.
+func (cmd *Command) cache(args []string) *CommandResponse {
+	if len(args) == 0 {
+		return &CommandResponse{
+			ResponseType: "ephemeral",
+			Text:         "ec2bot caches",
+			Attachments:  []slack.Attachment{cacheAttachment()},
+		}
+	}
+	if len(args) != 2 {
+		return ephemeral(cacheUsage)
+	}
+	names := []string{args[1]}
+	if args[1] == "all" {
+		names = append(append([]string{}, resourceCaches...), "loadbalancer_tags", "loadbalancer_v2_tags", "not_found_instances")
+	}
+	var done []string
+	for _, name := range names {
+		var err error
+		switch args[0] {
+		case "flush":
+			err = flushCache(name)
+		case "refresh":
+			err = refreshCache(name)
+		default:
+			return ephemeral(cacheUsage)
+		}
+		if err != nil {
+			return ephemeral(err.Error())
+		}
+		done = append(done, name)
+	}
+	logInfo("cache "+args[0], logFields{"caches": done, "user": cmd.UserID})
+	return ephemeral(fmt.Sprintf("%s: %s", args[0], strings.Join(done, ", ")))
+}
+
+// flushCache expires the cache of resources so that it is refreshed by the
+// next lookup, or empties the LRU cache.
+func flushCache(name string) error {
+	if l, ok := lruCaches()[name]; ok {
+		l.Purge()
+		return nil
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	switch name {
+	case "instances":
+		instanceCache.UpdatedAt = time.Time{}
+	case "loadbalancers":
+		loadBalancerCache.UpdatedAt = time.Time{}
+	case "loadbalancers_v2":
+		loadBalancerV2Cache.UpdatedAt = time.Time{}
+	case "not_found_instances":
+		// disabled
+	default:
+		return fmt.Errorf("unknown cache %q\n%s", name, cacheUsage)
+	}
+	return nil
+}
+
+// refreshCache refreshes the cache of resources in the background. LRU
+// caches are filled by lookups, so they are flushed instead.
+func refreshCache(name string) error {
+	var refresh func() error
+	switch name {
+	case "instances":
+		refresh = func() error { _, err := refreshInstances(); return err }
+	case "loadbalancers":
+		refresh = func() error { _, err := refreshLoadBalancers(); return err }
+	case "loadbalancers_v2":
+		refresh = func() error { _, err := refreshLoadBalancersV2(); return err }
+	default:
+		return flushCache(name)
+	}
+	goBackground(func() {
+		if err := refresh(); err != nil {
+			logError("failed to refresh cache", err, logFields{"cache": name})
+		}
+	})
+	return nil
+}
+
+// cacheAttachment summarizes the caches with their statistics.
+func cacheAttachment() slack.Attachment {
+	type summary struct {
+		size      int
+		regions   int
+		updatedAt time.Time
+	}
+	summaries := make(map[string]*summary)
+	cacheMu.RLock()
+	s := &summary{updatedAt: instanceCache.UpdatedAt, regions: len(regionSet(instanceCache.Regions))}
+	if instanceCache.Instances != nil {
+		for _, r := range instanceCache.Instances.Reservations {
+			s.size += len(r.Instances)
+		}
+	}
+	summaries["instances"] = s
+	s = &summary{updatedAt: loadBalancerCache.UpdatedAt, regions: len(regionSet(loadBalancerCache.Regions))}
+	if loadBalancerCache.LoadBalancers != nil {
+		s.size = len(loadBalancerCache.LoadBalancers.LoadBalancerDescriptions)
+	}
+	summaries["loadbalancers"] = s
+	s = &summary{updatedAt: loadBalancerV2Cache.UpdatedAt, size: len(loadBalancerV2Cache.LoadBalancers)}
+	regions := make(map[string]struct{})
+	for _, lb := range loadBalancerV2Cache.LoadBalancers {
+		regions[arnRegion(aws.StringValue(lb.LoadBalancerArn))] = struct{}{}
+	}
+	s.regions = len(regions)
+	summaries["loadbalancers_v2"] = s
+	cacheMu.RUnlock()
+
+	stats := make(map[string]cacheStats)
+	cacheStatsMu.Lock()
+	for name, s := range cacheStatsOf {
+		stats[name] = *s
+	}
+	cacheStatsMu.Unlock()
+
+	var lines []string
+	for _, name := range resourceCaches {
+		s, st := summaries[name], stats[name]
+		age := "never refreshed"
+		if !s.updatedAt.IsZero() {
+			age = "refreshed " + time.Since(s.updatedAt).Truncate(time.Second).String() + " ago"
+		}
+		breaker := ""
+		if b := breakerFor(name); b.open() {
+			breaker = ", circuit breaker open"
+		} else if b.failing() {
+			breaker = ", last refresh failed"
+		}
+		lines = append(lines, fmt.Sprintf(
+			"%s: %d items in %d regions, %s%s, %d hits, %d misses, %d refreshes, %d errors",
+			code(name), s.size, s.regions, age, breaker, st.Hits, st.Misses, st.Refreshes, st.RefreshErrors,
+		))
+	}
+	lrus := lruCaches()
+	names := make([]string, 0, len(lrus))
+	for name := range lrus {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l := lrus[name]
+		st := stats[name]
+		lines = append(lines, fmt.Sprintf(
+			"%s: %d of %d items, TTL %s, %d hits, %d misses",
+			code(name), len(l.Items()), l.Size, l.TTL, st.Hits, st.Misses,
+		))
+	}
+	return slack.Attachment{
+		Text:       strings.Join(lines, "\n"),
+		MarkdownIn: []string{"text"},
+	}
+}
+
+// regionSet returns the regions of the resources of a cache.
+func regionSet(regions map[string]string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, r := range regions {
+		set[r] = struct{}{}
+	}
+	return set
+}
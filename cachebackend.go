@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheBackend stores the caches shared by the replicas of the bot so that
+// only one of them calls the AWS APIs in each interval.
+type CacheBackend interface {
+	// Get returns the value of the key, or nil if it does not exist.
+	Get(key string) ([]byte, error)
+	// Set stores the value of the key, which expires after ttl.
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+// cacheBackend is the shared backend configured by $CACHE_BACKEND such as
+// "redis://:password@localhost:6379/0". The caches are kept in memory only
+// if it is nil.
+var cacheBackend CacheBackend
+
+// cacheKeyPrefix is prepended to the keys of the shared caches.
+const cacheKeyPrefix = "ec2bot:cache:"
+
+var (
+	sharedCacheScopeOnce sync.Once
+	sharedCacheScopeID   string
+)
+
+func init() {
+	var err error
+	cacheBackend, err = newCacheBackend(getenv("CACHE_BACKEND"))
+	if err != nil {
+		logError("cannot parse $CACHE_BACKEND, use default 'memory'", err, nil)
+	}
+}
+
+func newCacheBackend(s string) (CacheBackend, error) {
+	if s == "" || s == "memory" {
+		return nil, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "redis":
+		return newRedisBackend(u)
+	}
+	return nil, fmt.Errorf("unknown cache backend %q", u.Scheme)
+}
+
+// sharedCacheScope identifies the resources which the bot sees by the
+// account, the searched regions, the assumed role and the endpoint, so that
+// bots of other accounts or regions sharing the backend never read the
+// caches of each other.
+func sharedCacheScope() string {
+	sharedCacheScopeOnce.Do(func() {
+		account, _ := callerAccount()
+		regions := append([]string(nil), lookupRegions()...)
+		sort.Strings(regions)
+		h := sha256.Sum256([]byte(strings.Join([]string{
+			account,
+			strings.Join(regions, ","),
+			getenv("AWS_ASSUME_ROLE_ARN"),
+			getenv("AWS_ENDPOINT"),
+		}, "\n")))
+		sharedCacheScopeID = hex.EncodeToString(h[:8])
+	})
+	return sharedCacheScopeID
+}
+
+// sharedCacheKey returns the key of the shared cache in the backend.
+func sharedCacheKey(key string) string {
+	return cacheKeyPrefix + sharedCacheScope() + ":" + key
+}
+
+// loadShared decodes the shared cache of the key into v. It returns false
+// if there is no shared backend or the cache is missing.
+func loadShared(key string, v interface{}) bool {
+	if cacheBackend == nil {
+		return false
+	}
+	b, err := cacheBackend.Get(sharedCacheKey(key))
+	if err != nil {
+		logError("failed to load shared cache", err, nil)
+		return false
+	}
+	if b == nil {
+		return false
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		logError("failed to decode shared cache", err, nil)
+		return false
+	}
+	return true
+}
+
+// storeShared stores v as the shared cache of the key, which expires after
+// the TTL of the cache.
+func storeShared(key string, v interface{}, ttl time.Duration) {
+	if cacheBackend == nil {
+		return
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		logError("failed to encode shared cache", err, nil)
+		return
+	}
+	if err := cacheBackend.Set(sharedCacheKey(key), b, ttl); err != nil {
+		logError("failed to store shared cache", err, nil)
+	}
+}
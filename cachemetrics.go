@@ -0,0 +1,102 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// cacheStats are the counters of a cache exposed as metrics.
+type cacheStats struct {
+	Hits               uint64
+	Misses             uint64
+	Refreshes          uint64
+	RefreshErrors      uint64
+	RefreshSeconds     float64
+	LastRefreshSeconds float64
+}
+
+var (
+	cacheStatsMu sync.Mutex
+	cacheStatsOf = make(map[string]*cacheStats)
+)
+
+func getCacheStats(name string) *cacheStats {
+	s, ok := cacheStatsOf[name]
+	if !ok {
+		s = new(cacheStats)
+		cacheStatsOf[name] = s
+	}
+	return s
+}
+
+// recordCacheLookup counts a lookup of the cache, which is a hit if it was
+// answered without calling the AWS APIs.
+func recordCacheLookup(name string, hit bool) {
+	cacheStatsMu.Lock()
+	defer cacheStatsMu.Unlock()
+	s := getCacheStats(name)
+	if hit {
+		s.Hits++
+	} else {
+		s.Misses++
+	}
+}
+
+// recordCacheRefresh records a refresh of the cache started at start.
+func recordCacheRefresh(name string, start time.Time, err error) {
+	d := time.Since(start).Seconds()
+	cacheStatsMu.Lock()
+	defer cacheStatsMu.Unlock()
+	s := getCacheStats(name)
+	s.Refreshes++
+	if err != nil {
+		s.RefreshErrors++
+	}
+	s.RefreshSeconds += d
+	s.LastRefreshSeconds = d
+}
+
+// cacheMetrics returns the counters of the caches and their ages.
+func cacheMetrics() []Metric {
+	cacheMu.RLock()
+	updatedAt := map[string]time.Time{
+		"instances":        instanceCache.UpdatedAt,
+		"loadbalancers":    loadBalancerCache.UpdatedAt,
+		"loadbalancers_v2": loadBalancerV2Cache.UpdatedAt,
+	}
+	cacheMu.RUnlock()
+
+	cacheStatsMu.Lock()
+	defer cacheStatsMu.Unlock()
+	names := make([]string, 0, len(cacheStatsOf))
+	for name := range cacheStatsOf {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var metrics []Metric
+	add := func(name, help, typ string, cache string, value float64) {
+		metrics = append(metrics, Metric{
+			Name:   name,
+			Help:   help,
+			Type:   typ,
+			Labels: map[string]string{"cache": cache},
+			Value:  value,
+		})
+	}
+	for _, name := range names {
+		s := cacheStatsOf[name]
+		add("cache_hits_total", "Number of lookups answered from the cache.", "counter", name, float64(s.Hits))
+		add("cache_misses_total", "Number of lookups which called the AWS APIs.", "counter", name, float64(s.Misses))
+		add("cache_refreshes_total", "Number of refreshes of the cache.", "counter", name, float64(s.Refreshes))
+		add("cache_refresh_errors_total", "Number of failed refreshes of the cache.", "counter", name, float64(s.RefreshErrors))
+		add("cache_refresh_seconds_total", "Total time spent refreshing the cache.", "counter", name, s.RefreshSeconds)
+		add("cache_last_refresh_seconds", "Duration of the last refresh of the cache.", "gauge", name, s.LastRefreshSeconds)
+	}
+	for _, name := range []string{"instances", "loadbalancers", "loadbalancers_v2"} {
+		if t := updatedAt[name]; !t.IsZero() {
+			add("cache_age_seconds", "Time since the cache was refreshed.", "gauge", name, time.Since(t).Seconds())
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// cacheTTLErrors are the invalid TTLs of caches, which stop the bot at
+// startup rather than being replaced with defaults silently.
+var cacheTTLErrors []string
+
+var (
+	// instanceCacheTTL is how long the instances are cached, and the
+	// interval of their background refresh.
+	instanceCacheTTL = cacheTTLEnv("INSTANCE_CACHE_TTL", 5*time.Minute)
+	// loadBalancerCacheTTL is how long the load balancers are cached. It
+	// defaults to the TTL of the instances.
+	loadBalancerCacheTTL = cacheTTLEnv("LOADBALANCER_CACHE_TTL", instanceCacheTTL)
+	// tagCacheTTL is how long the tags of load balancers are cached. It
+	// defaults to the TTL of the load balancers.
+	tagCacheTTL = cacheTTLEnv("TAG_CACHE_TTL", loadBalancerCacheTTL)
+	// userGroupCacheTTL is how long the members of user groups are
+	// cached.
+	userGroupCacheTTL = cacheTTLEnv("USERGROUP_CACHE_TTL", 5*time.Minute)
+)
+
+// cacheTTLEnv returns the TTL of the environment variable, which has to be
+// a positive duration, or def if it is not set.
+func cacheTTLEnv(key string, def time.Duration) time.Duration {
+	v := getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		invalidCacheTTL(key, v)
+		return def
+	}
+	return d
+}
+
+func invalidCacheTTL(key, v string) {
+	cacheTTLErrors = append(cacheTTLErrors, fmt.Sprintf("invalid $%s %q, expected a positive duration such as '5m'", key, v))
+}
+
+// checkCacheTTLs returns an error if any TTL of caches is invalid.
+func checkCacheTTLs() error {
+	if len(cacheTTLErrors) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(cacheTTLErrors, "; "))
+}
+
+// cacheTTL returns the TTL of the cache of the resolver.
+func cacheTTL(resolver string) time.Duration {
+	if resolver == "instance" {
+		return instanceCacheTTL
+	}
+	return loadBalancerCacheTTL
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/nlopes/slack"
+)
+
+// CardField is a field of the cards of a resolver rendered from the API
+// response.
+type CardField struct {
+	Title string `json:"title"`
+	// Path is the path of the value in the SDK struct of the resource such
+	// as "Placement.AvailabilityZone". A number selects an element of a
+	// list, and other names select the value of the tag with the key in a
+	// list of tags, such as "NetworkInterfaces.0.SubnetId" or "Tags.Name".
+	Path string `json:"path"`
+	// Code renders the value as code.
+	Code  bool `json:"code"`
+	Short bool `json:"short"`
+	// Detailed hides the field from users without the permission to view
+	// the details of resources.
+	Detailed bool `json:"detailed"`
+}
+
+// cardTypes are the SDK structs of the resources of the resolvers which
+// render cards.
+var cardTypes = map[string]reflect.Type{
+	"instance":        reflect.TypeOf(ec2.Instance{}),
+	"loadbalancer":    reflect.TypeOf(elb.LoadBalancerDescription{}),
+	"loadbalancer_v2": reflect.TypeOf(elbv2.LoadBalancer{}),
+}
+
+// checkFields returns an error if a path of the fields does not exist in
+// the resources of the resolver.
+func checkFields(resolver string, fields []*CardField) error {
+	t, ok := cardTypes[resolver]
+	if !ok {
+		return fmt.Errorf("fields of unknown resolver %q", resolver)
+	}
+	for _, f := range fields {
+		if f.Title == "" {
+			return fmt.Errorf("field %q of %s: missing title", f.Path, resolver)
+		}
+		if err := checkPath(t, f.Path); err != nil {
+			return fmt.Errorf("field %q of %s: %v", f.Title, resolver, err)
+		}
+	}
+	return nil
+}
+
+func checkPath(t reflect.Type, path string) error {
+	if path == "" {
+		return fmt.Errorf("missing path")
+	}
+	for _, name := range strings.Split(path, ".") {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := t.FieldByName(name)
+			if !ok || f.PkgPath != "" {
+				return fmt.Errorf("unknown field %q of %s", name, t.Name())
+			}
+			t = f.Type
+		case reflect.Slice:
+			t = t.Elem()
+		default:
+			return fmt.Errorf("%q of %s", name, t)
+		}
+	}
+	return nil
+}
+
+// lookupPath returns the value of the path in v, or false if it is nil or
+// missing.
+func lookupPath(v reflect.Value, path string) (reflect.Value, bool) {
+	for _, name := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			v = v.FieldByName(name)
+		case reflect.Slice:
+			elem, ok := sliceElement(v, name)
+			if !ok {
+				return v, false
+			}
+			v = elem
+		default:
+			return v, false
+		}
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, v.IsValid()
+}
+
+// sliceElement returns the element of the index, or the value of the tag
+// of the key in a list of tags.
+func sliceElement(v reflect.Value, name string) (reflect.Value, bool) {
+	if i, err := strconv.Atoi(name); err == nil {
+		if i < 0 || i >= v.Len() {
+			return v, false
+		}
+		return v.Index(i), true
+	}
+	for i := 0; i < v.Len(); i++ {
+		e := reflect.Indirect(v.Index(i))
+		if e.Kind() != reflect.Struct {
+			return v, false
+		}
+		key, value := e.FieldByName("Key"), e.FieldByName("Value")
+		if !key.IsValid() || !value.IsValid() {
+			return v, false
+		}
+		if k, ok := reflect.Indirect(key).Interface().(string); ok && k == name {
+			return value, true
+		}
+	}
+	return v, false
+}
+
+// formatValue renders a value of the API response.
+func (ev *Event) formatValue(v reflect.Value) string {
+	switch x := v.Interface().(type) {
+	case string:
+		return x
+	case time.Time:
+		return ev.formatTime(x)
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map:
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return fmt.Sprint(v.Interface())
+		}
+		return string(b)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// configuredFields renders the fields of the config of the resolver. It
+// reports false if no fields are configured, in which case the default
+// fields are rendered.
+func (ev *Event) configuredFields(resolver string, resource interface{}, detailed bool) ([]slack.AttachmentField, bool) {
+	configured, ok := currentConfig().Fields[resolver]
+	if !ok {
+		return nil, false
+	}
+	fields := make([]slack.AttachmentField, 0, len(configured))
+	for _, f := range configured {
+		if f.Detailed && !detailed {
+			continue
+		}
+		v, ok := lookupPath(reflect.ValueOf(resource), f.Path)
+		if !ok {
+			continue
+		}
+		value := ev.formatValue(v)
+		if f.Code {
+			value = code(value)
+		}
+		fields = append(fields, slack.AttachmentField{
+			Title: f.Title,
+			Value: value,
+			Short: f.Short,
+		})
+	}
+	return fields, true
+}
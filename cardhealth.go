@@ -0,0 +1,136 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+// ResourceHealth is the health of a resource computed from its state,
+// status checks and load balancer health, which colors its card.
+type ResourceHealth int
+
+// The healths in order from the best; the worst one of a resource wins.
+const (
+	HealthUnknown ResourceHealth = iota
+	Healthy
+	Degraded
+	Unhealthy
+)
+
+// color returns the color of the cards of the health, which is empty if it
+// is unknown.
+func (h ResourceHealth) color() string {
+	switch h {
+	case Healthy:
+		return "good"
+	case Degraded:
+		return "warning"
+	case Unhealthy:
+		return "danger"
+	}
+	return ""
+}
+
+// worse returns the worse of the healths.
+func (h ResourceHealth) worse(o ResourceHealth) ResourceHealth {
+	if o > h {
+		return o
+	}
+	return h
+}
+
+// instanceStateHealth returns the health of an instance in the state.
+func instanceStateHealth(state string) ResourceHealth {
+	switch state {
+	case ec2.InstanceStateNameRunning:
+		return Healthy
+	case ec2.InstanceStateNamePending, ec2.InstanceStateNameStopping, ec2.InstanceStateNameShuttingDown:
+		return Degraded
+	case ec2.InstanceStateNameStopped, ec2.InstanceStateNameTerminated:
+		return Unhealthy
+	}
+	return HealthUnknown
+}
+
+// targetHealth returns the health of an instance registered to a load
+// balancer in the state, which is that of a Classic ELB such as
+// "InService" or that of a target group such as "healthy".
+func targetHealth(state string) ResourceHealth {
+	switch state {
+	case "InService", elbv2.TargetHealthStateEnumHealthy:
+		return Healthy
+	case elbv2.TargetHealthStateEnumInitial, elbv2.TargetHealthStateEnumDraining:
+		return Degraded
+	case "OutOfService", elbv2.TargetHealthStateEnumUnhealthy:
+		return Unhealthy
+	}
+	return HealthUnknown
+}
+
+// instanceHealth returns the health of the instance by its state and the
+// results of its status checks if they are enabled.
+func instanceHealth(instance *ec2.Instance) ResourceHealth {
+	if instance.State == nil {
+		return HealthUnknown
+	}
+	health := instanceStateHealth(aws.StringValue(instance.State.Name))
+	if health != Healthy || !showStatusChecks {
+		return health
+	}
+	id := aws.StringValue(instance.InstanceId)
+	status, err := getInstanceStatus(id)
+	if err != nil {
+		logError("failed to describe instance status", err, logFields{"instance_id": id})
+		return health
+	}
+	if status == nil {
+		return health
+	}
+	failed, pending := statusCheckResults(status)
+	switch {
+	case len(failed) > 0:
+		return Unhealthy
+	case len(pending) > 0:
+		return Degraded
+	}
+	return health
+}
+
+// backendHealth returns the health of a load balancer by those of its
+// backends: degraded if some of them are unhealthy, and unhealthy if all
+// of them are. It is unknown without backends.
+func backendHealth(healths []ResourceHealth) ResourceHealth {
+	if len(healths) == 0 {
+		return HealthUnknown
+	}
+	unhealthy := 0
+	for _, h := range healths {
+		if h != Healthy {
+			unhealthy++
+		}
+	}
+	switch unhealthy {
+	case 0:
+		return Healthy
+	case len(healths):
+		return Unhealthy
+	}
+	return Degraded
+}
+
+// loadBalancerV2Health returns the health of an ALB or NLB by its state.
+func loadBalancerV2Health(lb *elbv2.LoadBalancer) ResourceHealth {
+	if lb.State == nil {
+		return HealthUnknown
+	}
+	switch aws.StringValue(lb.State.Code) {
+	case elbv2.LoadBalancerStateEnumActive:
+		return Healthy
+	case elbv2.LoadBalancerStateEnumProvisioning, elbv2.LoadBalancerStateEnumActiveImpaired:
+		return Degraded
+	case elbv2.LoadBalancerStateEnumFailed:
+		return Unhealthy
+	}
+	return HealthUnknown
+}
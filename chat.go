@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/nlopes/slack"
+)
+
+// ChatBackend is the chat service the replies to events are posted to. The
+// messages are rendered as Slack attachments, which the other services
+// convert.
+type ChatBackend interface {
+	// PostMessage posts the message in the channel, in the thread if it is
+	// not empty.
+	PostMessage(channel, thread, text string, attachments []slack.Attachment) error
+	// UploadFile shares the file in the channel, in the thread if it is not
+	// empty.
+	UploadFile(channel, thread string, file *File) error
+	// OpenDM returns the channel of the direct messages with the user.
+	OpenDM(user string) (string, error)
+	// Mention returns the text mentioning the bot, or an empty string if
+	// it is unknown.
+	Mention() string
+}
+
+// slackBackend posts to the workspace of the team.
+type slackBackend struct {
+	teamID string
+}
+
+func (b slackBackend) PostMessage(channel, thread, text string, attachments []slack.Attachment) error {
+	_, _, err := slackClient(b.teamID).PostMessage(
+		channel,
+		text,
+		messageParameters(thread, attachments),
+	)
+	return err
+}
+
+func (b slackBackend) UploadFile(channel, thread string, file *File) error {
+	return uploadFile(b.teamID, channel, thread, file)
+}
+
+func (b slackBackend) OpenDM(user string) (string, error) {
+	_, _, im, err := slackClient(b.teamID).OpenIMChannel(user)
+	return im, err
+}
+
+// Mention returns the mention of the bot user of the workspace, or that of
+// the token for single workspace installations.
+func (b slackBackend) Mention() string {
+	id := botUserID
+	if team := getTeam(b.teamID); team != nil {
+		id = team.BotUserID
+	}
+	if id == "" {
+		return ""
+	}
+	return "<@" + id + ">"
+}
+
+// chat returns the chat service the event was received from.
+func (ev *Event) chat() ChatBackend {
+	if ev.backend != nil {
+		return ev.backend
+	}
+	return slackBackend{teamID: ev.TeamID}
+}
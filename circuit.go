@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// breakerFailures is the number of consecutive failures of refreshes
+	// of a cache which opens its circuit breaker, $CIRCUIT_BREAKER_FAILURES.
+	breakerFailures = 3
+	// breakerCooldown is how long a circuit breaker stays open before a
+	// refresh is tried again, $CIRCUIT_BREAKER_COOLDOWN.
+	breakerCooldown = durationEnv("CIRCUIT_BREAKER_COOLDOWN", time.Minute)
+
+	breakersMu sync.Mutex
+	breakers   = make(map[string]*CircuitBreaker)
+)
+
+// CircuitBreaker stops refreshing a cache for a while after consecutive
+// failures, so that lookups are served from the stale cache at once
+// instead of waiting for the failing or throttled APIs.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	lastErr   error
+}
+
+func init() {
+	if v := getenv("CIRCUIT_BREAKER_FAILURES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			logWarn("cannot parse $CIRCUIT_BREAKER_FAILURES, use default '3'", nil)
+			return
+		}
+		breakerFailures = n
+	}
+}
+
+// breakerFor returns the circuit breaker of the cache.
+func breakerFor(key string) *CircuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	b, ok := breakers[key]
+	if !ok {
+		b = new(CircuitBreaker)
+		breakers[key] = b
+	}
+	return b
+}
+
+// open reports whether refreshes are skipped.
+func (b *CircuitBreaker) open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().Before(b.openUntil)
+}
+
+// failing reports whether the last refresh failed.
+func (b *CircuitBreaker) failing() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastErr != nil
+}
+
+// record counts the result of a refresh. The breaker is closed by a
+// success, and opened by consecutive failures or a failure of the first
+// refresh after it was open.
+func (b *CircuitBreaker) record(key string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastErr = err
+	if err == nil {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= breakerFailures {
+		b.openUntil = time.Now().Add(breakerCooldown)
+		logWarn("open circuit breaker", logFields{"cache": key, "failures": b.failures, "until": b.openUntil})
+	}
+}
+
+// staleNotice returns a notice that the results of the resolver are served
+// from caches which cannot be refreshed, or an empty string.
+func (ev *Event) staleNotice(resolver string) string {
+	var keys []string
+	switch resolver {
+	case "instance":
+		keys = []string{"instances"}
+	case "loadbalancer":
+		keys = []string{"loadbalancers", "loadbalancers_v2"}
+	}
+	for _, key := range keys {
+		if !breakerFor(key).failing() {
+			continue
+		}
+		cacheMu.RLock()
+		updatedAt := map[string]time.Time{
+			"instances":        instanceCache.UpdatedAt,
+			"loadbalancers":    loadBalancerCache.UpdatedAt,
+			"loadbalancers_v2": loadBalancerV2Cache.UpdatedAt,
+		}[key]
+		cacheMu.RUnlock()
+		if !updatedAt.IsZero() {
+			return tr(":warning: AWS APIs are failing, so these results are cached at %s.", ev.formatTime(updatedAt))
+		}
+	}
+	return ""
+}
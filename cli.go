@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/ghodss/yaml"
+	"github.com/nlopes/slack"
+)
+
+const cliUsage = `usage: ec2bot [-o yaml|json] lookup <text>...
+       ec2bot [-o yaml|json] list <key>:<value>[,<value>...]...
+       ec2bot version
+
+lookup resolves the identifiers in the text as the bot does in messages.
+list prints the instances matching the query as "/ec2 list" does.`
+
+// CLIResult is the resources resolved from a query in the command line
+// mode.
+type CLIResult struct {
+	Query          string                       `json:"query"`
+	Instances      []*ec2.Instance              `json:"instances,omitempty"`
+	LoadBalancer   *elb.LoadBalancerDescription `json:"load_balancer,omitempty"`
+	LoadBalancerV2 *elbv2.LoadBalancer          `json:"load_balancer_v2,omitempty"`
+}
+
+// runCLI runs the command line mode with the arguments and returns the exit
+// status, which is 1 if nothing is found.
+func runCLI(args []string) int {
+	logOutput = os.Stderr
+	fs := flag.NewFlagSet("ec2bot", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, cliUsage) }
+	format := fs.String("o", "yaml", "output format, yaml or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	args = fs.Args()
+	if len(args) == 1 && args[0] == "version" {
+		fmt.Println(buildInfo())
+		return 0
+	}
+	if len(args) < 2 || (*format != "yaml" && *format != "json") {
+		fs.Usage()
+		return 2
+	}
+	c, err := loadedConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	setConfig(c)
+
+	var results interface{}
+	found := false
+	switch args[0] {
+	case "lookup":
+		r, err := cliLookup(strings.Join(args[1:], " "))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		for _, result := range r {
+			found = found || len(result.Instances) > 0 || result.LoadBalancer != nil || result.LoadBalancerV2 != nil
+		}
+		results = r
+	case "list":
+		filters, err := parseInstanceQuery(args[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		instances, truncated, err := queryInstances(filters)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if truncated {
+			fmt.Fprintf(os.Stderr, "only the first %d instances are listed\n", tagQueryLimit)
+		}
+		found = len(instances) > 0
+		results = instances
+	default:
+		fs.Usage()
+		return 2
+	}
+
+	var b []byte
+	if *format == "json" {
+		b, err = json.MarshalIndent(results, "", "  ")
+		b = append(b, '\n')
+	} else {
+		b, err = yaml.Marshal(results)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	os.Stdout.Write(b)
+	if !found {
+		return 1
+	}
+	return 0
+}
+
+// cliLookup resolves the queries found in the text by the patterns of
+// messages. The text is resolved as a single query if none is found, so
+// that names and partial IDs may be looked up directly.
+func cliLookup(text string) ([]*CLIResult, error) {
+	ev := &Event{Event: &slack.Msg{Text: text}}
+	queries := ev.findInstanceQueries()
+	lbQueries := ev.findLoadBalancerQueries()
+	if len(queries)+len(lbQueries) == 0 {
+		queries = []string{text}
+	}
+	var results []*CLIResult
+	for _, q := range queries {
+		instances, err := resolveInstances(q)
+		if err != nil {
+			return nil, err
+		}
+		for i, instance := range instances {
+			instances[i] = rawInstance(instance)
+		}
+		results = append(results, &CLIResult{Query: q, Instances: instances})
+	}
+	for _, q := range lbQueries {
+		result := &CLIResult{Query: q}
+		var err error
+		if result.LoadBalancer, err = resolveLoadBalancer(q); err != nil {
+			return nil, err
+		}
+		if result.LoadBalancer == nil {
+			if result.LoadBalancerV2, err = getLoadBalancerV2(q); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudtrail"
+	"github.com/nlopes/slack"
+)
+
+var (
+	// cloudTrailEventNames are the names of the API calls listed by the
+	// CloudTrail lookups, $CLOUDTRAIL_EVENT_NAMES, such as
+	// "StopInstances,TerminateInstances,ModifyInstanceAttribute". All calls
+	// except reads are listed unless it is set.
+	cloudTrailEventNames = strings.FieldsFunc(getenv("CLOUDTRAIL_EVENT_NAMES"), isComma)
+	// cloudTrailPeriod is how long ago the API calls are looked up,
+	// $CLOUDTRAIL_PERIOD, up to the 90 days CloudTrail keeps.
+	cloudTrailPeriod = 7 * 24 * time.Hour
+)
+
+// cloudTrailLimit is the maximum number of API calls listed.
+const cloudTrailLimit = 20
+
+// cloudTrailReadPrefixes are the prefixes of the names of the reads.
+var cloudTrailReadPrefixes = []string{"Describe", "Get", "List"}
+
+func init() {
+	mentionCommands["trail"] = (*Event).cloudTrail
+	if v := getenv("CLOUDTRAIL_PERIOD"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			logWarn("cannot parse $CLOUDTRAIL_PERIOD, use default '168h'", nil)
+			return
+		}
+		cloudTrailPeriod = d
+	}
+}
+
+func cloudTrailButton(id string) slack.AttachmentAction {
+	return slack.AttachmentAction{
+		Name:  "cloudtrail",
+		Text:  tr("Who touched this?"),
+		Type:  "button",
+		Value: id,
+	}
+}
+
+// cloudTrail handles "@ec2bot trail i-xxx" by listing the recent API calls
+// on each instance.
+func (ev *Event) cloudTrail(args []string) error {
+	ids := hostIDPattern.FindAllString(strings.Join(args, " "), -1)
+	if len(ids) == 0 {
+		return errors.New(tr("usage: trail <instance id>"))
+	}
+	for _, id := range ids {
+		if err := authorizeAction(ev.TeamID, ev.Event.User, "cloudtrail", id); err != nil {
+			ev.post(err.Error(), nil)
+			continue
+		}
+		if err := ev.postCloudTrailEvents(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cloudTrailEvent is the record of an API call in CloudTrail.
+type cloudTrailEvent struct {
+	UserIdentity struct {
+		ARN string `json:"arn"`
+	} `json:"userIdentity"`
+	SourceIPAddress string `json:"sourceIPAddress"`
+	ErrorCode       string `json:"errorCode"`
+}
+
+// postCloudTrailEvents posts who called which API on the instance and when,
+// from the latest.
+func (ev *Event) postCloudTrailEvents(id string) error {
+	events, err := lookupCloudTrailEvents(id)
+	ev.audit("cloudtrail", id, err)
+	if err != nil {
+		return ev.post(tr("failed to look up CloudTrail events of %s: %v", id, err), nil)
+	}
+	if len(events) == 0 {
+		return ev.post(tr("no API calls on %s are found in CloudTrail", code(id)), nil)
+	}
+	lines := make([]string, len(events))
+	for i, e := range events {
+		var record cloudTrailEvent
+		json.Unmarshal([]byte(aws.StringValue(e.CloudTrailEvent)), &record)
+		who := record.UserIdentity.ARN
+		if who == "" {
+			who = aws.StringValue(e.Username)
+		}
+		line := ev.formatTime(aws.TimeValue(e.EventTime)) + " " + code(aws.StringValue(e.EventName)) + " " + tr("by %s", who)
+		if record.SourceIPAddress != "" {
+			line += " " + tr("from %s", record.SourceIPAddress)
+		}
+		if record.ErrorCode != "" {
+			line += " (" + record.ErrorCode + ")"
+		}
+		lines[i] = line
+	}
+	return ev.post(tr("API calls on %s", code(id)), []slack.Attachment{{
+		Text:       strings.Join(lines, "\n"),
+		MarkdownIn: []string{"text"},
+		Footer:     tr("Last %s of CloudTrail", cloudTrailPeriod),
+	}})
+}
+
+// lookupCloudTrailEvents returns the latest API calls on the resource up to
+// cloudTrailLimit, which are those of cloudTrailEventNames or all except
+// reads.
+func lookupCloudTrailEvents(id string) ([]*cloudtrail.Event, error) {
+	svc := regionCloudTrailClient(instanceRegion(id))
+	var events []*cloudtrail.Event
+	err := svc.LookupEventsPages(&cloudtrail.LookupEventsInput{
+		LookupAttributes: []*cloudtrail.LookupAttribute{{
+			AttributeKey:   aws.String(cloudtrail.LookupAttributeKeyResourceName),
+			AttributeValue: aws.String(id),
+		}},
+		StartTime: aws.Time(time.Now().Add(-cloudTrailPeriod)),
+	}, func(page *cloudtrail.LookupEventsOutput, lastPage bool) bool {
+		for _, e := range page.Events {
+			if listedCloudTrailEvent(aws.StringValue(e.EventName)) {
+				events = append(events, e)
+			}
+			if len(events) == cloudTrailLimit {
+				return false
+			}
+		}
+		return true
+	})
+	return events, err
+}
+
+func listedCloudTrailEvent(name string) bool {
+	if len(cloudTrailEventNames) > 0 {
+		for _, n := range cloudTrailEventNames {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+	for _, prefix := range cloudTrailReadPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+	return true
+}
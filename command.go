@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/nlopes/slack"
+)
+
+// Command is a slash command request sent by Slack.
+type Command struct {
+	Token       string `form:"token"`
+	TeamID      string `form:"team_id"`
+	ChannelID   string `form:"channel_id"`
+	UserID      string `form:"user_id"`
+	UserName    string `form:"user_name"`
+	Command     string `form:"command"`
+	Text        string `form:"text"`
+	ResponseURL string `form:"response_url"`
+	TriggerID   string `form:"trigger_id"`
+}
+
+// CommandResponse is the message returned to a slash command.
+type CommandResponse struct {
+	ResponseType string             `json:"response_type,omitempty"`
+	Text         string             `json:"text"`
+	Attachments  []slack.Attachment `json:"attachments,omitempty"`
+}
+
+const commandUsage = "usage: /ec2 prefs [timezone <tz> | compact on|off | dm on|off | mute <type> | unmute <type> | reset]\n" +
+	"       /ec2 channel [format yaml|json | compact on|off | posture on|off]\n" +
+	"       /ec2 list <key>:<value>[,<value>...]... (keys: az, image, key, sg, state, subnet, type, vpc, tag:<key>[=<value>])\n" +
+	"       /ec2 admin stats [<days>d]\n" +
+	"       /ec2 admin audit [<count>]\n" +
+	"       /ec2 admin loglevel [debug|info|warn|error]\n" +
+	"       /ec2 admin replay <permalink> | <channel> <ts>\n" +
+	"       /ec2 admin reload\n" +
+	"       /ec2 admin cache [flush|refresh <name>|all]\n" +
+	"       /ec2 version"
+
+func handleCommand(c echo.Context) error {
+	cmd := new(Command)
+	if err := c.Bind(cmd); err != nil {
+		logError("failed to bind command", err, nil)
+		return err
+	}
+
+	if cmd.Token != slackVerifyToken {
+		logWarn("failed to verify token", logFields{"team_id": cmd.TeamID})
+		return c.String(http.StatusUnauthorized, "failed to verify token")
+	}
+
+	args := strings.Fields(cmd.Text)
+	if len(args) == 0 {
+		return c.JSON(http.StatusOK, ephemeral(commandUsage+"\n\n"+buildInfo().String()))
+	}
+	switch args[0] {
+	case "prefs":
+		return c.JSON(http.StatusOK, cmd.prefs(args[1:]))
+	case "channel":
+		return c.JSON(http.StatusOK, cmd.channel(args[1:]))
+	case "admin":
+		return c.JSON(http.StatusOK, cmd.admin(args[1:]))
+	case "find", "list":
+		return c.JSON(http.StatusOK, cmd.find(args[1:]))
+	case "version":
+		return c.JSON(http.StatusOK, ephemeral(buildInfo().String()))
+	}
+	return c.JSON(http.StatusOK, ephemeral(commandUsage+"\n\n"+buildInfo().String()))
+}
+
+func (cmd *Command) prefs(args []string) *CommandResponse {
+	prefs := getUserPrefs(cmd.UserID)
+	switch {
+	case len(args) == 0:
+		return ephemeral(prefs.String())
+	case len(args) == 1 && args[0] == "reset":
+		prefs = new(UserPrefs)
+	case len(args) == 2:
+		if err := prefs.set(args[0], args[1]); err != nil {
+			return ephemeral(fmt.Sprintf("%v\n%s", err, commandUsage))
+		}
+	default:
+		return ephemeral(commandUsage)
+	}
+	if err := putUserPrefs(cmd.UserID, prefs); err != nil {
+		logError("failed to save preferences", err, logFields{"user": cmd.UserID})
+		return ephemeral("failed to save preferences")
+	}
+	return ephemeral("preferences updated\n" + prefs.String())
+}
+
+func (cmd *Command) find(args []string) *CommandResponse {
+	ev := &Event{
+		TeamID: cmd.TeamID,
+		Event:  &slack.Msg{Channel: cmd.ChannelID, User: cmd.UserID},
+	}
+	msg, err := ev.tagQueryMessage(strings.Join(args, " "), 0)
+	if err != nil {
+		return ephemeral(err.Error())
+	}
+	return &CommandResponse{
+		ResponseType: "in_channel",
+		Text:         msg.Text,
+		Attachments:  msg.Attachments,
+	}
+}
+
+func (cmd *Command) admin(args []string) *CommandResponse {
+	if !isAdmin(cmd.UserID) {
+		return ephemeral("admin commands are restricted to $SLACK_ADMIN_USERS")
+	}
+	if len(args) == 0 {
+		return ephemeral(commandUsage)
+	}
+	switch args[0] {
+	case "stats":
+		period := ""
+		if len(args) > 1 {
+			period = args[1]
+		}
+		days, err := parseDays(period)
+		if err != nil {
+			return ephemeral(err.Error())
+		}
+		return &CommandResponse{
+			ResponseType: "in_channel",
+			Text:         "ec2bot stats",
+			Attachments: append(
+				collectStats(days).attachments(days),
+				cacheSnapshot().fleetAttachment(),
+			),
+		}
+	case "audit":
+		n := auditRows
+		if len(args) > 1 {
+			var err error
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return ephemeral(fmt.Sprintf("invalid count %q", args[1]))
+			}
+		}
+		return &CommandResponse{
+			ResponseType: "ephemeral",
+			Text:         "ec2bot audit log",
+			Attachments:  []slack.Attachment{auditAttachment(n)},
+		}
+	case "loglevel":
+		if len(args) > 1 {
+			if err := setLogLevel(args[1]); err != nil {
+				return ephemeral(err.Error())
+			}
+			logInfo("log level changed", logFields{"level": currentLogLevel(), "user": cmd.UserID})
+		}
+		return ephemeral("log level: " + currentLogLevel())
+	case "replay":
+		return cmd.replay(args[1:])
+	case "cache":
+		return cmd.cache(args[1:])
+	case "reload":
+		if err := reloadConfig(); err != nil {
+			logError("failed to reload config", err, logFields{"user": cmd.UserID})
+			return ephemeral(err.Error())
+		}
+		logInfo("config reloaded by command", logFields{"user": cmd.UserID})
+		return ephemeral("config reloaded")
+	}
+	return ephemeral(commandUsage)
+}
+
+func isAdmin(user string) bool {
+	for _, u := range adminUsers {
+		if u == user {
+			return true
+		}
+	}
+	return false
+}
+
+func ephemeral(text string) *CommandResponse {
+	return &CommandResponse{
+		ResponseType: "ephemeral",
+		Text:         text,
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/ghodss/yaml"
+)
+
+// Config is the optional YAML configuration file of settings which do not
+// fit in environment variables.
+type Config struct {
+	// Settings are the values of the environment variables by lowercase
+	// name, such as "instance_cache_ttl: 10m". The environment variables
+	// override them. Lists are joined with commas.
+	Settings map[string]interface{} `json:"settings"`
+	// SSMCommands are the commands which may be run on instances by name.
+	SSMCommands map[string]*SSMCommand `json:"ssm_commands"`
+	// Roles grant permissions to perform actions. Without roles, the users
+	// of $SLACK_OPERATOR_USERS may perform every action.
+	Roles []*Role `json:"roles"`
+	// Patterns are additional patterns triggering lookups.
+	Patterns []*MatchPattern `json:"patterns"`
+	// Exclusions are patterns of identifiers which are never looked up,
+	// such as the examples in documents.
+	Exclusions []string `json:"exclusions"`
+	// Redactions are patterns of the keys or values of tags whose values
+	// are never shown, such as "(?i)secret|token".
+	Redactions []string `json:"redactions"`
+	// Resolvers enable or disable the lookups of each type of resources:
+	// "instance", "loadbalancer" for Classic ELBs, "loadbalancer_v2" for
+	// ALBs and NLBs, and the registered resolvers. They are enabled unless
+	// they are set to false, and disabled ones are not cached either.
+	Resolvers map[string]bool `json:"resolvers"`
+	// Templates are the paths of text/template files rendering the cards
+	// by resolver name, such as "instance: /etc/ec2bot/instance.tmpl".
+	Templates map[string]string `json:"templates"`
+	// Fields are the fields of the cards by resolver name in order, which
+	// replace the default ones. Templates take precedence over them.
+	Fields map[string][]*CardField `json:"fields"`
+	// Owners map tag keys to the Slack users, user groups or channels of
+	// their values, such as "Owner: {alice: U0123456}" and
+	// "Team: {platform: S0123456}", which are mentioned on the cards of
+	// instances.
+	Owners map[string]map[string]string `json:"owners"`
+
+	exclusions []*regexp.Regexp
+	redactions []*regexp.Regexp
+	templates  map[string]*template.Template
+}
+
+var (
+	configPath = os.Getenv("CONFIG_PATH")
+	// config is the config file in use, which is replaced when it is
+	// reloaded. It is guarded by configMu with fileConfig.
+	config   = new(Config)
+	configMu sync.RWMutex
+
+	// fileConfig is the config file, which is loaded by the first call of
+	// getenv since settings are read while the package is initialized.
+	fileConfig     *Config
+	fileConfigErr  error
+	fileConfigOnce sync.Once
+	// usedSettings are the names of the environment variables read by
+	// getenv, which are the known settings. It is guarded by
+	// usedSettingsMu since getenv is also called after startup, such as
+	// by reloadConfig.
+	usedSettings   = make(map[string]struct{})
+	usedSettingsMu sync.Mutex
+)
+
+// loadedConfig returns the config file, loading it on the first call.
+func loadedConfig() (*Config, error) {
+	fileConfigOnce.Do(func() {
+		fileConfig, fileConfigErr = loadConfig(configPath)
+	})
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return fileConfig, fileConfigErr
+}
+
+// currentConfig returns the config file in use.
+func currentConfig() *Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
+
+func setConfig(c *Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = c
+}
+
+// getenv returns the value of the environment variable, or that of the
+// setting of the config file if it is not set.
+func getenv(key string) string {
+	usedSettingsMu.Lock()
+	usedSettings[key] = struct{}{}
+	usedSettingsMu.Unlock()
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	c, err := loadedConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch v := c.Settings[strings.ToLower(key)].(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, e := range v {
+			values[i] = fmt.Sprint(e)
+		}
+		return strings.Join(values, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func loadConfig(path string) (*Config, error) {
+	c := new(Config)
+	if path == "" {
+		return c, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := checkConfigKeys(b); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, p := range c.Patterns {
+		if err := p.compile(); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	for _, e := range c.Exclusions {
+		re, err := regexp.Compile(e)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid exclusion %q: %v", path, e, err)
+		}
+		c.exclusions = append(c.exclusions, re)
+	}
+	for _, r := range c.Redactions {
+		re, err := regexp.Compile(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid redaction %q: %v", path, r, err)
+		}
+		c.redactions = append(c.redactions, re)
+	}
+	for key, owners := range c.Owners {
+		for value, id := range owners {
+			if !slackOwnerIDPattern.MatchString(id) {
+				return nil, fmt.Errorf("%s: invalid owner %q of %s=%s", path, id, key, value)
+			}
+		}
+	}
+	return c, nil
+}
+
+// checkConfigKeys returns an error if the config file has unknown keys,
+// which are likely typos.
+func checkConfigKeys(b []byte) error {
+	var keys map[string]json.RawMessage
+	if err := yaml.Unmarshal(b, &keys); err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("json"); tag != "" {
+			known[tag] = true
+		}
+	}
+	for key := range keys {
+		if !known[key] {
+			return fmt.Errorf("unknown key %q", key)
+		}
+	}
+	return nil
+}
+
+// checkSettings returns an error if the config file has unknown settings,
+// resolvers, invalid fields or templates, and parses the templates, which need
+// the helpers unavailable while the package is initialized. It has to be
+// called after all settings are read and all resolvers are registered.
+func (c *Config) checkSettings() error {
+	for name := range c.Resolvers {
+		if !knownResolver(name) {
+			return fmt.Errorf("%s: unknown resolver %q", configPath, name)
+		}
+	}
+	for name := range c.Templates {
+		if !knownResolver(name) {
+			return fmt.Errorf("%s: template of unknown resolver %q", configPath, name)
+		}
+	}
+	for name, fields := range c.Fields {
+		if err := checkFields(name, fields); err != nil {
+			return fmt.Errorf("%s: %v", configPath, err)
+		}
+	}
+	if err := c.loadTemplates(); err != nil {
+		return fmt.Errorf("%s: %v", configPath, err)
+	}
+	usedSettingsMu.Lock()
+	defer usedSettingsMu.Unlock()
+	for key := range c.Settings {
+		if _, ok := usedSettings[strings.ToUpper(key)]; !ok {
+			names := make([]string, 0, len(usedSettings))
+			for name := range usedSettings {
+				names = append(names, strings.ToLower(name))
+			}
+			sort.Strings(names)
+			return fmt.Errorf("%s: unknown setting %q, known settings are %s", configPath, key, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// resolverEnabled reports whether the lookups of the type of resources are
+// enabled.
+func resolverEnabled(name string) bool {
+	enabled, ok := currentConfig().Resolvers[name]
+	return !ok || enabled
+}
+
+// excluded reports whether the identifier matches any of the exclusions.
+func (c *Config) excluded(s string) bool {
+	for _, re := range c.exclusions {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
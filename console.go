@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/nlopes/slack"
+)
+
+// awsRegion returns the region the bot queries.
+func awsRegion() string {
+	return aws.StringValue(awsSession.Config.Region)
+}
+
+// awsPartition returns the partition of the region used in ARNs.
+func awsPartition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	}
+	return "aws"
+}
+
+// consoleHost returns the AWS console host of the partition of region.
+func consoleHost(region string) string {
+	switch awsPartition(region) {
+	case "aws-cn":
+		return "console.amazonaws.cn"
+	case "aws-us-gov":
+		return "console.amazonaws-us-gov.com"
+	}
+	return "console.aws.amazon.com"
+}
+
+// consoleURL returns the URL of an EC2 console page showing the resource.
+func consoleURL(region, fragment string) string {
+	return fmt.Sprintf(
+		"https://%s/ec2/v2/home?region=%s#%s",
+		consoleHost(region),
+		url.QueryEscape(region),
+		fragment,
+	)
+}
+
+func instanceConsoleURL(region, instanceID string) string {
+	return consoleURL(region, "Instances:instanceId="+instanceID)
+}
+
+func loadBalancerConsoleURL(region, name string) string {
+	return consoleURL(region, "LoadBalancers:loadBalancerName="+name)
+}
+
+// cloudWatchLogGroups are the log groups of the CloudWatch agent linked
+// on the cards of instances, $CLOUDWATCH_LOG_GROUPS. The agent names the
+// log streams by the instance IDs by default.
+var cloudWatchLogGroups = strings.FieldsFunc(getenv("CLOUDWATCH_LOG_GROUPS"), isComma)
+
+// cloudWatchURL returns the URL of a CloudWatch console page.
+func cloudWatchURL(region, fragment string) string {
+	return fmt.Sprintf(
+		"https://%s/cloudwatch/home?region=%s#%s",
+		consoleHost(region),
+		url.QueryEscape(region),
+		fragment,
+	)
+}
+
+// cloudWatchMetricsURL returns the URL of the CloudWatch metrics
+// searching the resource, such as an instance ID or a load balancer name.
+func cloudWatchMetricsURL(region, search string) string {
+	return cloudWatchURL(region, "metricsV2:graph=~();search="+url.QueryEscape(search))
+}
+
+// cloudWatchLogsURL returns the URL of the log stream of the log group.
+// The console escapes the names twice with "$" instead of "%".
+func cloudWatchLogsURL(region, group, stream string) string {
+	escape := func(s string) string {
+		return strings.Replace(url.QueryEscape(url.QueryEscape(s)), "%", "$", -1)
+	}
+	return cloudWatchURL(region, "logsV2:log-groups/log-group/"+escape(group)+"/log-events/"+escape(stream))
+}
+
+// instanceLinks returns the links to the CloudWatch metrics and the logs
+// of the agent of the instance.
+func instanceLinks(region, instanceID string) []string {
+	links := []string{link(cloudWatchMetricsURL(region, instanceID), tr("CloudWatch Metrics"))}
+	for _, group := range cloudWatchLogGroups {
+		links = append(links, link(cloudWatchLogsURL(region, group, instanceID), tr("Logs: %s", group)))
+	}
+	return links
+}
+
+// link formats a link in the Slack markup.
+func link(u, text string) string {
+	return "<" + u + "|" + text + ">"
+}
+
+// consoleAttachment links to the resource in the AWS console and the
+// other context links, such as its CloudWatch metrics.
+func consoleAttachment(consoleLink string, links ...string) slack.Attachment {
+	return slack.Attachment{
+		Title:     tr("Open in AWS Console"),
+		TitleLink: consoleLink,
+		Text:      strings.Join(links, " | "),
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// awsCredentials returns the credentials of $AWS_CREDENTIAL_SOURCE, which
+// is "env", "profile" ($AWS_PROFILE), "web_identity" ($AWS_ROLE_ARN and
+// $AWS_WEB_IDENTITY_TOKEN_FILE as set by IRSA), "ecs" or "ec2". The default
+// chain of the SDK is used if it is empty. If $AWS_ASSUME_ROLE_ARN is set,
+// the role is assumed with the credentials of the source, with the
+// optional $AWS_ASSUME_ROLE_EXTERNAL_ID. Session tags are not supported by
+// the version of the SDK.
+func awsCredentials(config *aws.Config) (*credentials.Credentials, error) {
+	creds, err := sourceCredentials(config)
+	if err != nil {
+		return nil, err
+	}
+	roleARN := getenv("AWS_ASSUME_ROLE_ARN")
+	if roleARN == "" {
+		return creds, nil
+	}
+	source := session.New(config.Copy().WithCredentials(creds))
+	duration := durationEnv("AWS_ASSUME_ROLE_DURATION", stscreds.DefaultDuration)
+	return stscreds.NewCredentials(source, roleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = roleSessionName
+		p.Duration = duration
+		if id := getenv("AWS_ASSUME_ROLE_EXTERNAL_ID"); id != "" {
+			p.ExternalID = aws.String(id)
+		}
+	}), nil
+}
+
+// sourceCredentials returns the credentials of $AWS_CREDENTIAL_SOURCE, or
+// nil for the default chain.
+func sourceCredentials(config *aws.Config) (*credentials.Credentials, error) {
+	switch source := getenv("AWS_CREDENTIAL_SOURCE"); source {
+	case "":
+		return nil, nil
+	case "env":
+		return credentials.NewEnvCredentials(), nil
+	case "profile":
+		s, err := session.NewSessionWithOptions(session.Options{
+			Config:            *config,
+			Profile:           getenv("AWS_PROFILE"),
+			SharedConfigState: session.SharedConfigEnable,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return s.Config.Credentials, nil
+	case "web_identity":
+		roleARN, tokenFile := os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+		if roleARN == "" || tokenFile == "" {
+			return nil, errors.New("$AWS_ROLE_ARN and $AWS_WEB_IDENTITY_TOKEN_FILE are required for web identity")
+		}
+		// the role is assumed without credentials
+		client := sts.New(session.New(config.Copy().WithCredentials(credentials.AnonymousCredentials)))
+		return credentials.NewCredentials(&webIdentityProvider{
+			client:    client,
+			roleARN:   roleARN,
+			tokenFile: tokenFile,
+		}), nil
+	case "ecs":
+		if os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") == "" && os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI") == "" {
+			return nil, errors.New("not running in an ECS task with a role")
+		}
+		return credentials.NewCredentials(defaults.RemoteCredProvider(*defaults.Config(), defaults.Handlers())), nil
+	case "ec2":
+		return credentials.NewCredentials(&ec2rolecreds.EC2RoleProvider{
+			Client: ec2metadata.New(session.New(config)),
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown $AWS_CREDENTIAL_SOURCE %q, expected env, profile, web_identity, ecs or ec2", source)
+	}
+}
+
+// roleSessionName is the session name of assumed roles, which is
+// $AWS_ROLE_SESSION_NAME or "ec2bot". It is read once at startup since the
+// credentials are refreshed in the background.
+var roleSessionName = func() string {
+	if name := getenv("AWS_ROLE_SESSION_NAME"); name != "" {
+		return name
+	}
+	return "ec2bot"
+}()
+
+// webIdentityProvider assumes a role with the token of a web identity
+// read from a file, which is rotated by Kubernetes.
+type webIdentityProvider struct {
+	credentials.Expiry
+
+	client    stsiface.STSAPI
+	roleARN   string
+	tokenFile string
+}
+
+func (p *webIdentityProvider) Retrieve() (credentials.Value, error) {
+	token, err := ioutil.ReadFile(p.tokenFile)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	resp, err := p.client.AssumeRoleWithWebIdentity(&sts.AssumeRoleWithWebIdentityInput{
+		RoleArn:          aws.String(p.roleARN),
+		RoleSessionName:  aws.String(roleSessionName),
+		WebIdentityToken: aws.String(strings.TrimSpace(string(token))),
+	})
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	p.SetExpiration(aws.TimeValue(resp.Credentials.Expiration), time.Minute)
+	return credentials.Value{
+		AccessKeyID:     aws.StringValue(resp.Credentials.AccessKeyId),
+		SecretAccessKey: aws.StringValue(resp.Credentials.SecretAccessKey),
+		SessionToken:    aws.StringValue(resp.Credentials.SessionToken),
+		ProviderName:    "WebIdentityProvider",
+	}, nil
+}
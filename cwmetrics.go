@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/bgpat/ec2bot/internal/cache"
+	"github.com/nlopes/slack"
+)
+
+const (
+	// metricsPeriod is the period of the datapoints of the summaries.
+	metricsPeriod = 5 * time.Minute
+	// metricsRange is the range of the summaries.
+	metricsRange = time.Hour
+)
+
+var (
+	// showMetrics enables the summaries of the CloudWatch metrics on the
+	// cards of running instances, $SHOW_METRICS, which need
+	// cloudwatch:GetMetricData.
+	showMetrics = boolEnv("SHOW_METRICS", false)
+
+	// metricSummaries are the recent summaries by instance ID, so that
+	// expanding the same instance repeatedly does not query CloudWatch.
+	metricSummaries = cache.NewLRU(1000, time.Minute)
+)
+
+// sparkTicks are the bars of sparklines from the lowest.
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// instanceMetric is a metric of the summaries of instances.
+type instanceMetric struct {
+	ID    string
+	Name  string
+	Stat  string
+	Title string
+}
+
+var instanceMetrics = []instanceMetric{
+	{ID: "cpu", Name: "CPUUtilization", Stat: "Average", Title: "CPU"},
+	{ID: "net_in", Name: "NetworkIn", Stat: "Sum", Title: "Network In"},
+	{ID: "net_out", Name: "NetworkOut", Stat: "Sum", Title: "Network Out"},
+	{ID: "status", Name: "StatusCheckFailed", Stat: "Maximum", Title: "Status Check"},
+}
+
+// getInstanceMetrics returns the datapoints of the metrics of the instance
+// in the last metricsRange in chronological order by ID.
+func getInstanceMetrics(instance *ec2.Instance) (map[string][]float64, error) {
+	id := aws.StringValue(instance.InstanceId)
+	if v, ok := metricSummaries.Get(id); ok {
+		return v.(map[string][]float64), nil
+	}
+	end := time.Now().Truncate(metricsPeriod)
+	queries := make([]*cloudwatch.MetricDataQuery, len(instanceMetrics))
+	for i, m := range instanceMetrics {
+		queries[i] = &cloudwatch.MetricDataQuery{
+			Id: aws.String(m.ID),
+			MetricStat: &cloudwatch.MetricStat{
+				Metric: &cloudwatch.Metric{
+					Namespace:  aws.String("AWS/EC2"),
+					MetricName: aws.String(m.Name),
+					Dimensions: []*cloudwatch.Dimension{{
+						Name:  aws.String("InstanceId"),
+						Value: aws.String(id),
+					}},
+				},
+				Period: aws.Int64(int64(metricsPeriod / time.Second)),
+				Stat:   aws.String(m.Stat),
+			},
+		}
+	}
+	svc := regionCloudWatchClient(instanceRegion(id))
+	values := make(map[string][]float64)
+	err := svc.GetMetricDataPages(&cloudwatch.GetMetricDataInput{
+		StartTime:         aws.Time(end.Add(-metricsRange)),
+		EndTime:           aws.Time(end),
+		ScanBy:            aws.String(cloudwatch.ScanByTimestampAscending),
+		MetricDataQueries: queries,
+	}, func(page *cloudwatch.GetMetricDataOutput, lastPage bool) bool {
+		for _, r := range page.MetricDataResults {
+			id := aws.StringValue(r.Id)
+			values[id] = append(values[id], aws.Float64ValueSlice(r.Values)...)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	metricSummaries.Add(id, values)
+	return values, nil
+}
+
+// sparkline renders the values as bars scaled between 0 and the maximum.
+func sparkline(values []float64) string {
+	max := 0.0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	bars := make([]rune, len(values))
+	for i, v := range values {
+		n := 0
+		if max > 0 {
+			n = int(v / max * float64(len(sparkTicks)-1))
+		}
+		bars[i] = sparkTicks[n]
+	}
+	return string(bars)
+}
+
+// formatBytes renders a number of bytes with a binary unit.
+func formatBytes(b float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for b >= 1024 && i < len(units)-1 {
+		b /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", b, units[i])
+}
+
+// summarizeMetric renders the datapoints of a metric.
+func summarizeMetric(m instanceMetric, values []float64) string {
+	var sum, max float64
+	for _, v := range values {
+		sum += v
+		if v > max {
+			max = v
+		}
+	}
+	switch m.ID {
+	case "cpu":
+		return fmt.Sprintf("%s %s", code(sparkline(values)), tr("avg %.1f%%, max %.1f%%", sum/float64(len(values)), max))
+	case "status":
+		if max > 0 {
+			return ":warning: " + tr("failed")
+		}
+		return tr("passed")
+	}
+	return fmt.Sprintf("%s %s", code(sparkline(values)), tr("%s in total", formatBytes(sum)))
+}
+
+// metricsAttachment summarizes the recent metrics of the instance. It
+// returns nil if it is disabled or the instance is not running.
+func metricsAttachment(instance *ec2.Instance) *slack.Attachment {
+	if !showMetrics || instance.State == nil || aws.StringValue(instance.State.Name) != ec2.InstanceStateNameRunning {
+		return nil
+	}
+	values, err := getInstanceMetrics(instance)
+	if err != nil {
+		logError("failed to get metrics", err, logFields{"instance_id": aws.StringValue(instance.InstanceId)})
+		return nil
+	}
+	var fields []slack.AttachmentField
+	for _, m := range instanceMetrics {
+		if len(values[m.ID]) == 0 {
+			continue
+		}
+		fields = append(fields, slack.AttachmentField{
+			Title: tr(m.Title),
+			Value: summarizeMetric(m, values[m.ID]),
+			Short: true,
+		})
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return &slack.Attachment{
+		Title:      tr("Metrics in the last hour"),
+		Fields:     fields,
+		MarkdownIn: []string{"fields"},
+	}
+}
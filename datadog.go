@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+var (
+	// datadogToken is the token of the webhooks of Datadog monitors, which
+	// is passed as the "token" query parameter, $DATADOG_WEBHOOK_TOKEN. It
+	// enables the /datadog endpoint.
+	datadogToken = getenv("DATADOG_WEBHOOK_TOKEN")
+	// datadogChannels are the Slack channels of the alerts by the ID of
+	// their monitor, $DATADOG_CHANNELS, such as "1234567=C0123456,*=C0654321"
+	// where "*" is the default.
+	datadogChannels = parseChannelMap("DATADOG_CHANNELS")
+)
+
+// datadogWebhook is the body of the webhooks, whose payload is configured
+// in the Webhooks integration as:
+//
+//	{
+//	  "id": "$ID",
+//	  "monitor_id": "$ALERT_ID",
+//	  "transition": "$ALERT_TRANSITION",
+//	  "title": "$EVENT_TITLE",
+//	  "body": "$EVENT_MSG",
+//	  "link": "$LINK",
+//	  "hostname": "$HOSTNAME",
+//	  "tags": "$TAGS"
+//	}
+type datadogWebhook struct {
+	ID         string `json:"id"`
+	MonitorID  string `json:"monitor_id"`
+	Transition string `json:"transition"`
+	Title      string `json:"title"`
+	Body       string `json:"body"`
+	Link       string `json:"link"`
+	Hostname   string `json:"hostname"`
+	Tags       string `json:"tags"`
+}
+
+// text returns the texts of the alert scanned for resources, where the
+// hostname and the host tags are converted to queries.
+func (w *datadogWebhook) text() string {
+	texts := []string{w.Title, w.Body, hostQuery(w.Hostname)}
+	for _, tag := range strings.FieldsFunc(w.Tags, isComma) {
+		i := strings.Index(tag, ":")
+		if i < 0 {
+			continue
+		}
+		switch key, value := tag[:i], tag[i+1:]; key {
+		case "host":
+			texts = append(texts, hostQuery(value))
+		case "instance-id":
+			texts = append(texts, value)
+		case "name":
+			texts = append(texts, tag)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// handleDatadog posts the cards of the hosts of the alerts of Datadog
+// monitors to the channel of the monitor, in the thread of a message linking
+// to the event. Recoveries are ignored.
+func handleDatadog(c echo.Context) error {
+	if subtle.ConstantTimeCompare([]byte(c.QueryParam("token")), []byte(datadogToken)) != 1 {
+		return c.String(http.StatusUnauthorized, "failed to verify token")
+	}
+	w := new(datadogWebhook)
+	if err := c.Bind(w); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if w.Transition == "Recovered" {
+		return c.String(http.StatusOK, "ignore "+w.Transition)
+	}
+	channel := mappedChannel(datadogChannels, w.MonitorID)
+	if channel == "" {
+		return c.String(http.StatusOK, "no channel of monitor "+w.MonitorID)
+	}
+	header := tr("Datadog alert %s", w.Title)
+	if w.Link != "" {
+		header = tr("Datadog alert %s", link(w.Link, w.Title))
+	}
+	goBackground(func() { enrich("datadog:"+w.ID, channel, header, nil, w.text()) })
+	return c.String(http.StatusOK, "queued")
+}
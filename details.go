@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/nlopes/slack"
+)
+
+// detailsLimit is the maximum size of the YAML in the "Details" section of
+// cards, $DETAILS_LIMIT. Longer details are truncated at a line with a
+// button uploading them as a file, since Slack cuts long attachments
+// anywhere.
+var detailsLimit = 3000
+
+func init() {
+	actionHandlers["details"] = handleDetails
+	if v := getenv("DETAILS_LIMIT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			logWarn("cannot parse $DETAILS_LIMIT, use default '3000'", nil)
+		} else {
+			detailsLimit = n
+		}
+	}
+}
+
+// detailsAttachment renders the "Details" section of the card of the
+// resource in the format. The query is used to look up the resource again
+// on upload.
+func detailsAttachment(resourceType, query, format string, details []byte) slack.Attachment {
+	a := slack.Attachment{
+		Title: tr("Details"),
+		Text:  string(details),
+	}
+	if len(details) <= detailsLimit {
+		return a
+	}
+	text := string(details[:detailsLimit])
+	if i := strings.LastIndex(text, "\n"); i > 0 {
+		text = text[:i+1]
+	}
+	a.Text = text + "..."
+	a.Footer = tr("truncated, %d of %d bytes", len(text), len(details))
+	a.CallbackID = "details"
+	a.Actions = []slack.AttachmentAction{{
+		Name:  resourceType + ":" + format,
+		Text:  tr("Upload full details"),
+		Type:  "button",
+		Value: query,
+	}}
+	return a
+}
+
+// handleDetails uploads the full details of the resource of a card.
+func handleDetails(cb *ActionCallback, action slack.AttachmentAction) (*slack.Msg, error) {
+	ev := cb.event()
+	if !ev.canViewDetails() {
+		return nil, errors.New(tr("you are not allowed to view the details"))
+	}
+	var resource interface{}
+	var name string
+	resourceType, format := action.Name, "yaml"
+	if i := strings.Index(action.Name, ":"); i >= 0 {
+		resourceType, format = action.Name[:i], action.Name[i+1:]
+	}
+	if !isDetailsFormat(format) {
+		format = "yaml"
+	}
+	switch resourceType {
+	case "instance":
+		instance, err := getInstance(action.Value)
+		if err != nil {
+			return nil, err
+		}
+		if instance != nil {
+			resource, name = redactedInstance(rawInstance(instance)), aws.StringValue(instance.InstanceId)
+		}
+	case "loadbalancer":
+		lb, err := getLoadBalancer(action.Value)
+		if err != nil {
+			return nil, err
+		}
+		if lb != nil {
+			resource, name = lb, aws.StringValue(lb.LoadBalancerName)
+		}
+	case "loadbalancer_v2":
+		lb, err := getLoadBalancerV2(action.Value)
+		if err != nil {
+			return nil, err
+		}
+		if lb != nil {
+			resource, name = lb, aws.StringValue(lb.LoadBalancerName)
+		}
+	default:
+		return nil, fmt.Errorf("unknown resource type %q", resourceType)
+	}
+	if resource == nil {
+		return nil, ev.post(tr("%s is not found", code(action.Value)), nil)
+	}
+	b, err := marshalDetails(resource, format)
+	if err != nil {
+		return nil, err
+	}
+	return nil, ev.uploadPrivately(&File{
+		Name:    name + "." + format,
+		Type:    format,
+		Title:   name,
+		Content: b,
+	})
+}
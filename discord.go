@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nlopes/slack"
+)
+
+// discord is the client of the Discord bot of $DISCORD_TOKEN, which scans
+// the messages of the guilds it joined over the gateway like those of Slack
+// and posts the cards as embeds. It runs alongside Slack, or instead of it
+// if no Slack token is set. It is nil if the integration is disabled.
+//
+// The bot needs the Message Content intent. Lookups and mention commands
+// work as on Slack, while interactive buttons, slash commands and dialogs
+// are Slack only, so the buttons are dropped.
+var discord *DiscordClient
+
+const discordAPI = "https://discord.com/api/v10"
+
+// The limits of the messages of Discord.
+const (
+	discordMaxEmbeds      = 10
+	discordMaxFields      = 25
+	discordMaxFieldValue  = 1024
+	discordMaxDescription = 4096
+)
+
+// The intents of the gateway: GUILD_MESSAGES, DIRECT_MESSAGES and
+// MESSAGE_CONTENT.
+const discordIntents = 1<<9 | 1<<12 | 1<<15
+
+// discordColors are the colors of the embeds of the named colors of Slack.
+var discordColors = map[string]int{
+	"good":    0x2eb886,
+	"warning": 0xdaa038,
+	"danger":  0xa30200,
+}
+
+func init() {
+	if token := getenv("DISCORD_TOKEN"); token != "" {
+		discord = &DiscordClient{
+			Token:  token,
+			client: &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+}
+
+// DiscordClient is a minimal client of the Discord API and gateway.
+type DiscordClient struct {
+	Token string
+
+	client *http.Client
+
+	mu     sync.Mutex
+	userID string
+}
+
+type discordGatewayPayload struct {
+	Op       int             `json:"op"`
+	Data     json.RawMessage `json:"d,omitempty"`
+	Sequence *int64          `json:"s,omitempty"`
+	Type     string          `json:"t,omitempty"`
+}
+
+type discordMessage struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id"`
+	Content   string `json:"content"`
+	Author    struct {
+		ID  string `json:"id"`
+		Bot bool   `json:"bot"`
+	} `json:"author"`
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title,omitempty"`
+	URL         string              `json:"url,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color,omitempty"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+	Footer      *discordEmbedFooter `json:"footer,omitempty"`
+	Image       *discordEmbedImage  `json:"image,omitempty"`
+	Thumbnail   *discordEmbedImage  `json:"thumbnail,omitempty"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbedFooter struct {
+	Text string `json:"text"`
+}
+
+type discordEmbedImage struct {
+	URL string `json:"url"`
+}
+
+type discordCreateMessage struct {
+	Content          string                   `json:"content,omitempty"`
+	Embeds           []discordEmbed           `json:"embeds,omitempty"`
+	MessageReference *discordMessageReference `json:"message_reference,omitempty"`
+}
+
+type discordMessageReference struct {
+	MessageID       string `json:"message_id"`
+	FailIfNotExists bool   `json:"fail_if_not_exists"`
+}
+
+// call sends the request to the path of the API and decodes the response
+// into v unless it is nil.
+func (d *DiscordClient) call(method, path, contentType string, body io.Reader, v interface{}) error {
+	req, err := http.NewRequest(method, discordAPI+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bot "+d.Token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, e.Message)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (d *DiscordClient) callJSON(method, path string, in, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return d.call(method, path, "application/json", bytes.NewReader(b), out)
+}
+
+// run connects to the gateway and queues the messages as events, and
+// reconnects on failures until the process exits.
+func (d *DiscordClient) run() {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := d.connect()
+		logError("disconnected from Discord gateway", err, nil)
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		time.Sleep(backoff)
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// connect runs a session of the gateway until it is closed.
+func (d *DiscordClient) connect() error {
+	var gateway struct {
+		URL string `json:"url"`
+	}
+	if err := d.call(http.MethodGet, "/gateway/bot", "", nil, &gateway); err != nil {
+		return err
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(gateway.URL+"?v=10&encoding=json", nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var hello discordGatewayPayload
+	if err := conn.ReadJSON(&hello); err != nil {
+		return err
+	}
+	var h struct {
+		HeartbeatInterval int64 `json:"heartbeat_interval"`
+	}
+	if err := json.Unmarshal(hello.Data, &h); err != nil {
+		return err
+	}
+
+	// writes are serialized since the heartbeats are sent concurrently
+	var writeMu sync.Mutex
+	send := func(op int, data interface{}) error {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(&discordGatewayPayload{Op: op, Data: b})
+	}
+	identify := map[string]interface{}{
+		"token":   d.Token,
+		"intents": discordIntents,
+		"properties": map[string]string{
+			"os":      runtime.GOOS,
+			"browser": "ec2bot",
+			"device":  "ec2bot",
+		},
+	}
+	if err := send(2, identify); err != nil {
+		return err
+	}
+
+	var seq int64
+	var seqMu sync.Mutex
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(time.Duration(h.HeartbeatInterval) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				seqMu.Lock()
+				s := seq
+				seqMu.Unlock()
+				if err := send(1, s); err != nil {
+					logError("failed to send Discord heartbeat", err, nil)
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		var p discordGatewayPayload
+		if err := conn.ReadJSON(&p); err != nil {
+			return err
+		}
+		if p.Sequence != nil {
+			seqMu.Lock()
+			seq = *p.Sequence
+			seqMu.Unlock()
+		}
+		switch p.Op {
+		case 0:
+			d.dispatch(p.Type, p.Data)
+		case 1:
+			seqMu.Lock()
+			s := seq
+			seqMu.Unlock()
+			if err := send(1, s); err != nil {
+				return err
+			}
+		case 7:
+			return errors.New("reconnect requested")
+		case 9:
+			return errors.New("invalid session")
+		}
+	}
+}
+
+// dispatch handles an event of the gateway.
+func (d *DiscordClient) dispatch(t string, data json.RawMessage) {
+	switch t {
+	case "READY":
+		var ready struct {
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		}
+		if err := json.Unmarshal(data, &ready); err != nil {
+			logError("failed to decode Discord ready event", err, nil)
+			return
+		}
+		d.mu.Lock()
+		d.userID = ready.User.ID
+		d.mu.Unlock()
+	case "MESSAGE_CREATE":
+		var m discordMessage
+		if err := json.Unmarshal(data, &m); err != nil {
+			logError("failed to decode Discord message", err, nil)
+			return
+		}
+		if m.Author.Bot {
+			return
+		}
+		ev := &Event{
+			EventID: "discord:" + m.ID,
+			TeamID:  m.GuildID,
+			Event: &slack.Msg{
+				Type:    "message",
+				Channel: m.ChannelID,
+				User:    m.Author.ID,
+				// mentions by nickname are written as "<@!id>"
+				Text:      strings.Replace(m.Content, "<@!", "<@", -1),
+				Timestamp: m.ID,
+			},
+			backend: d,
+		}
+		if !ev.seen() {
+			ev.enqueue()
+		}
+	}
+}
+
+func (d *DiscordClient) PostMessage(channel, thread, text string, attachments []slack.Attachment) error {
+	embeds := discordEmbeds(attachments)
+	if text == "" && len(embeds) == 0 {
+		return nil
+	}
+	for first := true; first || len(embeds) > 0; first = false {
+		n := len(embeds)
+		if n > discordMaxEmbeds {
+			n = discordMaxEmbeds
+		}
+		msg := &discordCreateMessage{Embeds: embeds[:n]}
+		if first {
+			msg.Content = text
+		}
+		if thread != "" {
+			msg.MessageReference = &discordMessageReference{MessageID: thread}
+		}
+		if err := d.callJSON(http.MethodPost, "/channels/"+channel+"/messages", msg, nil); err != nil {
+			return err
+		}
+		embeds = embeds[n:]
+	}
+	return nil
+}
+
+// discordEmbeds converts the attachments into embeds. The attachments
+// consisting only of buttons are dropped.
+func discordEmbeds(attachments []slack.Attachment) []discordEmbed {
+	embeds := make([]discordEmbed, 0, len(attachments))
+	for _, a := range attachments {
+		e := discordEmbed{
+			Title:       a.Title,
+			URL:         a.TitleLink,
+			Description: a.Text,
+			Color:       discordColor(a.Color),
+		}
+		if a.Pretext != "" {
+			e.Description = strings.TrimSpace(a.Pretext + "\n" + e.Description)
+		}
+		e.Description = truncateRunes(e.Description, discordMaxDescription)
+		for i, f := range a.Fields {
+			if i == discordMaxFields {
+				break
+			}
+			value := f.Value
+			if value == "" {
+				value = placeholder
+			}
+			value = truncateRunes(value, discordMaxFieldValue)
+			e.Fields = append(e.Fields, discordEmbedField{Name: f.Title, Value: value, Inline: f.Short})
+		}
+		if a.Footer != "" {
+			e.Footer = &discordEmbedFooter{Text: a.Footer}
+		}
+		if a.ImageURL != "" {
+			e.Image = &discordEmbedImage{URL: a.ImageURL}
+		}
+		if a.ThumbURL != "" {
+			e.Thumbnail = &discordEmbedImage{URL: a.ThumbURL}
+		}
+		if e.Title == "" && e.Description == "" && len(e.Fields) == 0 && e.Footer == nil && e.Image == nil {
+			continue
+		}
+		embeds = append(embeds, e)
+	}
+	return embeds
+}
+
+// truncateRunes truncates s to n characters with an ellipsis.
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
+
+// discordColor returns the color of an attachment, which is a named color
+// or "#rrggbb".
+func discordColor(color string) int {
+	if c, ok := discordColors[color]; ok {
+		return c
+	}
+	c, err := strconv.ParseInt(strings.TrimPrefix(color, "#"), 16, 32)
+	if err != nil {
+		return 0
+	}
+	return int(c)
+}
+
+func (d *DiscordClient) UploadFile(channel, thread string, file *File) error {
+	msg := &discordCreateMessage{Content: file.Comment}
+	if msg.Content == "" {
+		msg.Content = file.Title
+	}
+	if thread != "" {
+		msg.MessageReference = &discordMessageReference{MessageID: thread}
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if err := w.WriteField("payload_json", string(payload)); err != nil {
+		return err
+	}
+	part, err := w.CreateFormFile("files[0]", file.Name)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(file.Content); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return d.call(http.MethodPost, "/channels/"+channel+"/messages", w.FormDataContentType(), &body, nil)
+}
+
+func (d *DiscordClient) OpenDM(user string) (string, error) {
+	var channel struct {
+		ID string `json:"id"`
+	}
+	if err := d.callJSON(http.MethodPost, "/users/@me/channels", map[string]string{"recipient_id": user}, &channel); err != nil {
+		return "", err
+	}
+	return channel.ID, nil
+}
+
+func (d *DiscordClient) Mention() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.userID == "" {
+		return ""
+	}
+	return "<@" + d.userID + ">"
+}
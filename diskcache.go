@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// cachePath is the file the caches are persisted to after each refresh so
+// that a restarted bot answers from them while the first refresh runs. The
+// caches are replaced as a whole on each refresh and read once at startup,
+// so they are written as a single JSON document like the store rather than
+// to an embedded key-value store.
+var cachePath = getenv("CACHE_PATH")
+
+// cacheMaxBytes is the maximum size of the file, $CACHE_MAX_BYTES. Larger
+// caches are not persisted, so that huge fleets do not fill the disk or
+// stall the refreshes writing them.
+var cacheMaxBytes = 64 << 20
+
+func init() {
+	if v := getenv("CACHE_MAX_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			logWarn("cannot parse $CACHE_MAX_BYTES, use default '67108864'", nil)
+			return
+		}
+		cacheMaxBytes = n
+	}
+}
+
+// persistedCaches is the content of the file at $CACHE_PATH.
+type persistedCaches struct {
+	Instances       InstanceCache       `json:"instances"`
+	LoadBalancers   LoadBalancerCache   `json:"load_balancers"`
+	LoadBalancersV2 LoadBalancerV2Cache `json:"load_balancers_v2"`
+}
+
+// persistMu serializes writes of the file.
+var persistMu sync.Mutex
+
+// restoreCaches loads the caches persisted by the previous process. They
+// keep the time they were refreshed at, so they are refreshed as usual.
+func restoreCaches(path string) error {
+	if path == "" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() > int64(cacheMaxBytes) {
+		logWarn("persisted caches are too large to restore", logFields{"bytes": info.Size(), "max_bytes": cacheMaxBytes})
+		return nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var c persistedCaches
+	if err := json.Unmarshal(b, &c); err != nil {
+		return err
+	}
+	if c.Instances.Instances != nil {
+		c.Instances.Index = indexInstances(c.Instances.Instances)
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	instanceCache = c.Instances
+	loadBalancerCache = c.LoadBalancers
+	loadBalancerV2Cache = c.LoadBalancersV2
+	takeSnapshot()
+	return nil
+}
+
+// persistCaches writes the caches to $CACHE_PATH. Failures are logged as
+// the caches are only persisted for a faster restart.
+func persistCaches() {
+	if cachePath == "" {
+		return
+	}
+	cacheMu.RLock()
+	b, err := json.Marshal(persistedCaches{
+		Instances:       instanceCache,
+		LoadBalancers:   loadBalancerCache,
+		LoadBalancersV2: loadBalancerV2Cache,
+	})
+	cacheMu.RUnlock()
+	if err != nil {
+		logError("failed to encode caches", err, nil)
+		return
+	}
+	if len(b) > cacheMaxBytes {
+		logWarn("caches are too large to persist", logFields{"bytes": len(b), "max_bytes": cacheMaxBytes})
+		return
+	}
+	persistMu.Lock()
+	defer persistMu.Unlock()
+	tmp := cachePath + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		logError("failed to persist caches", err, nil)
+		return
+	}
+	if err := os.Rename(tmp, cachePath); err != nil {
+		logError("failed to persist caches", err, nil)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// regionPattern matches the names of regions in all partitions, such as
+// "eu-west-1", "cn-north-1" and "us-gov-west-1".
+const regionPattern = `[a-z]{2}(?:-[a-z]+)+-[0-9]+`
+
+// privateDNSDomains are the domain names of custom DHCP option sets used
+// for private host names in addition to the default ones.
+var privateDNSDomains = strings.FieldsFunc(getenv("PRIVATE_DNS_DOMAINS"), isComma)
+
+// privateDNSNameRegexp matches IP-based ("ip-10-0-0-1") and resource-based
+// ("i-0123456789abcdef0") private host names in the regional
+// "compute.internal" domain, the "ec2.internal" domain of us-east-1 and the
+// custom domains.
+func privateDNSNameRegexp(domains []string) *regexp.Regexp {
+	suffixes := []string{
+		regionPattern + `\.compute\.internal`,
+		`ec2\.internal`,
+	}
+	for _, d := range domains {
+		suffixes = append(suffixes, regexp.QuoteMeta(strings.Trim(d, ".")))
+	}
+	return regexp.MustCompile(`\b(?:ip-[0-9]+-[0-9]+-[0-9]+-[0-9]+|i-[0-9a-f]{8,17})\.(?:` + strings.Join(suffixes, "|") + `)\b`)
+}
+
+// hostAddress returns the IP address encoded in an IP-based host name such
+// as "ip-10-0-0-1.ec2.internal", or an empty string.
+func hostAddress(host string) string {
+	label := strings.SplitN(host, ".", 2)[0]
+	if !strings.HasPrefix(label, "ip-") {
+		return ""
+	}
+	return strings.Replace(strings.TrimPrefix(label, "ip-"), "-", ".", -1)
+}
+
+// hostInstanceID returns the instance ID of a resource-based host name such
+// as "i-0123456789abcdef0.ec2.internal", or an empty string.
+func hostInstanceID(host string) string {
+	label := strings.SplitN(host, ".", 2)[0]
+	if !strings.HasPrefix(label, "i-") {
+		return ""
+	}
+	return label
+}
+
+// publicDNSNamePattern matches public host names such as
+// "ec2-203-0-113-1.eu-west-1.compute.amazonaws.com",
+// "ec2-203-0-113-1.compute-1.amazonaws.com" in us-east-1 and
+// "ec2-203-0-113-1.cn-north-1.compute.amazonaws.com.cn" in China.
+var publicDNSNamePattern = regexp.MustCompile(`\bec2-[0-9]+-[0-9]+-[0-9]+-[0-9]+\.(?:` + regionPattern + `\.compute|compute-1)\.amazonaws\.com(?:\.cn)?\b`)
+
+// publicHostAddress returns the IP address encoded in a public host name
+// such as "ec2-203-0-113-1.compute-1.amazonaws.com", or an empty string.
+func publicHostAddress(host string) string {
+	label := strings.SplitN(host, ".", 2)[0]
+	if !strings.HasPrefix(label, "ec2-") {
+		return ""
+	}
+	return strings.Replace(strings.TrimPrefix(label, "ec2-"), "-", ".", -1)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/nlopes/slack"
+)
+
+// drainPollInterval is the interval between checks of whether connection
+// draining has completed.
+var drainPollInterval = 10 * time.Second
+
+// drainTimeout is the maximum time to wait for connection draining.
+const drainTimeout = time.Hour
+
+// Registration is a registration of an instance to a Classic ELB or a
+// target group.
+type Registration struct {
+	LoadBalancerName    string
+	LoadBalancerDNSName string
+	TargetGroupARN      string
+	TargetGroupName     string
+	Port                int64
+	// LoadBalancerARNs are the ALBs and NLBs of the target group.
+	LoadBalancerARNs []string
+	// State is the health of the target when it was found.
+	State string
+}
+
+func init() {
+	mentionCommands["drain"] = (*Event).drain
+}
+
+func drainButton(id string) slack.AttachmentAction {
+	return slack.AttachmentAction{
+		Name:  "drain",
+		Text:  tr("Drain"),
+		Type:  "button",
+		Style: "danger",
+		Value: id,
+		Confirm: &slack.ConfirmationField{
+			Title:       tr("Drain %s?", id),
+			Text:        tr("The instance will be deregistered from all load balancers and target groups."),
+			OkText:      tr("Drain"),
+			DismissText: tr("Cancel"),
+		},
+	}
+}
+
+// drain handles "@ec2bot drain i-xxx" by deregistering each instance from
+// its load balancers.
+func (ev *Event) drain(args []string) error {
+	ids := hostIDPattern.FindAllString(strings.Join(args, " "), -1)
+	if len(ids) == 0 {
+		return errors.New(tr("usage: drain <instance id>"))
+	}
+	for _, id := range ids {
+		if err := authorizeAction(ev.TeamID, ev.Event.User, "drain", id); err != nil {
+			ev.post(err.Error(), nil)
+			continue
+		}
+		if err := ev.drainInstance(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drainInstance deregisters the instance from every load balancer and
+// target group it is registered to, and reports in the thread when
+// connection draining completes.
+func (ev *Event) drainInstance(id string) error {
+	registrations, err := findRegistrations(id)
+	if err != nil {
+		ev.audit("drain", id, err)
+		return ev.post(tr("failed to drain %s: %v", code(id), err), nil)
+	}
+	if len(registrations) == 0 {
+		return ev.post(tr("%s is not registered to any load balancer", code(id)), nil)
+	}
+	for _, r := range registrations {
+		err := r.deregister(id)
+		ev.audit("drain", id, err)
+		if err != nil {
+			ev.post(tr("failed to deregister %s from %s: %v", code(id), r, err), nil)
+			continue
+		}
+		ev.post(tr("deregistering %s from %s (requested by <@%s>)", code(id), r, ev.Event.User), nil)
+		r := r
+		goBackground(func() { ev.waitDrained(id, r) })
+	}
+	return nil
+}
+
+func (r Registration) String() string {
+	if r.LoadBalancerName != "" {
+		return r.LoadBalancerName
+	}
+	return fmt.Sprintf("%s:%d", r.TargetGroupName, r.Port)
+}
+
+// findRegistrations returns the Classic ELBs and target groups the instance
+// is registered to.
+func findRegistrations(id string) ([]Registration, error) {
+	var registrations []Registration
+
+	svc := regionELBClient(instanceRegion(id))
+	err := svc.DescribeLoadBalancersPages(nil, func(resp *elb.DescribeLoadBalancersOutput, last bool) bool {
+		for _, lb := range resp.LoadBalancerDescriptions {
+			for _, i := range lb.Instances {
+				if aws.StringValue(i.InstanceId) == id {
+					registrations = append(registrations, Registration{
+						LoadBalancerName:    aws.StringValue(lb.LoadBalancerName),
+						LoadBalancerDNSName: aws.StringValue(lb.DNSName),
+					})
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	svc2 := regionELBV2Client(instanceRegion(id))
+	var groups []*elbv2.TargetGroup
+	err = svc2.DescribeTargetGroupsPages(nil, func(resp *elbv2.DescribeTargetGroupsOutput, last bool) bool {
+		groups = append(groups, resp.TargetGroups...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		if aws.StringValue(g.TargetType) != elbv2.TargetTypeEnumInstance {
+			continue
+		}
+		resp, err := svc2.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{
+			TargetGroupArn: g.TargetGroupArn,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range resp.TargetHealthDescriptions {
+			if aws.StringValue(d.Target.Id) != id {
+				continue
+			}
+			r := Registration{
+				TargetGroupARN:   aws.StringValue(g.TargetGroupArn),
+				TargetGroupName:  aws.StringValue(g.TargetGroupName),
+				Port:             aws.Int64Value(d.Target.Port),
+				LoadBalancerARNs: aws.StringValueSlice(g.LoadBalancerArns),
+			}
+			if d.TargetHealth != nil {
+				r.State = aws.StringValue(d.TargetHealth.State)
+			}
+			registrations = append(registrations, r)
+		}
+	}
+	return registrations, nil
+}
+
+func (r Registration) deregister(id string) error {
+	if r.LoadBalancerName != "" {
+		svc := regionELBClient(instanceRegion(id))
+		_, err := svc.DeregisterInstancesFromLoadBalancer(&elb.DeregisterInstancesFromLoadBalancerInput{
+			LoadBalancerName: aws.String(r.LoadBalancerName),
+			Instances:        []*elb.Instance{{InstanceId: aws.String(id)}},
+		})
+		return err
+	}
+	svc := regionELBV2Client(instanceRegion(id))
+	_, err := svc.DeregisterTargets(&elbv2.DeregisterTargetsInput{
+		TargetGroupArn: aws.String(r.TargetGroupARN),
+		Targets:        []*elbv2.TargetDescription{r.target(id)},
+	})
+	return err
+}
+
+func (r Registration) target(id string) *elbv2.TargetDescription {
+	return &elbv2.TargetDescription{
+		Id:   aws.String(id),
+		Port: aws.Int64(r.Port),
+	}
+}
+
+// drained reports whether connection draining of the instance has
+// completed.
+func (r Registration) drained(id string) (bool, error) {
+	if r.LoadBalancerName != "" {
+		svc := regionELBClient(instanceRegion(id))
+		resp, err := svc.DescribeInstanceHealth(&elb.DescribeInstanceHealthInput{
+			LoadBalancerName: aws.String(r.LoadBalancerName),
+			Instances:        []*elb.Instance{{InstanceId: aws.String(id)}},
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == elb.ErrCodeInvalidEndPointException {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		for _, s := range resp.InstanceStates {
+			if aws.StringValue(s.State) != "OutOfService" ||
+				strings.Contains(aws.StringValue(s.Description), "in progress") {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	svc := regionELBV2Client(instanceRegion(id))
+	resp, err := svc.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: aws.String(r.TargetGroupARN),
+		Targets:        []*elbv2.TargetDescription{r.target(id)},
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, d := range resp.TargetHealthDescriptions {
+		if d.TargetHealth != nil && aws.StringValue(d.TargetHealth.State) == elbv2.TargetHealthStateEnumDraining {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// waitDrained posts to the thread of the event when connection draining of
+// the instance from r completes.
+func (ev *Event) waitDrained(id string, r Registration) {
+	deadline := time.Now().Add(drainTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+		ok, err := r.drained(id)
+		if err != nil {
+			logError("failed to check draining", err, logFields{"instance_id": id, "registration": r.String()})
+			ev.post(tr("failed to check draining of %s from %s: %v", code(id), r, err), nil)
+			return
+		}
+		if ok {
+			ev.post(tr("%s has been drained from %s", code(id), r), nil)
+			return
+		}
+	}
+	ev.post(tr("timed out waiting for %s to be drained from %s", code(id), r), nil)
+}
@@ -0,0 +1,23 @@
+package main
+
+import "encoding/json"
+
+// dryRun is set by $DRY_RUN to log the messages which would be posted in
+// reply to events instead of posting them, so that changes of patterns and
+// settings may be tested against the traffic of production.
+var dryRun = boolEnv("DRY_RUN", false)
+
+// logDryRun logs a message which is not posted in the dry run mode.
+func (ev *Event) logDryRun(msg, text string, v interface{}) {
+	f := ev.logFields()
+	f["text"] = text
+	if v != nil {
+		b, err := json.Marshal(v)
+		if err != nil {
+			logError("failed to marshal message of dry run", err, f)
+			return
+		}
+		f["content"] = string(b)
+	}
+	logInfo(msg, f)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/labstack/echo"
+)
+
+// eventsToken enables the /events endpoint, which receives EC2 Instance
+// State-change Notifications from EventBridge, directly through an API
+// destination or through an SNS topic, to update the cached instances
+// between refreshes. Spot interruption warnings and rebalance
+// recommendations are received too, and shown on the cards. The token has to be passed as the "token" query
+// parameter of the endpoint URL. AWS Health events are received too, and
+// posted to the health channel.
+var eventsToken = getenv("EVENTS_TOKEN")
+
+// snsMessage is the body of the requests of SNS HTTP(S) subscriptions.
+type snsMessage struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	Token            string `json:"Token"`
+	SubscribeURL     string `json:"SubscribeURL"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+}
+
+// ec2StateChange is an EC2 Instance State-change Notification event.
+type ec2StateChange struct {
+	ID         string   `json:"id"`
+	Source     string   `json:"source"`
+	DetailType string   `json:"detail-type"`
+	Region     string   `json:"region"`
+	Resources  []string `json:"resources"`
+	Detail     struct {
+		InstanceID string `json:"instance-id"`
+		State      string `json:"state"`
+		// InstanceAction is the action of spot interruption warnings.
+		InstanceAction string `json:"instance-action"`
+	} `json:"detail"`
+}
+
+func handleEC2Event(c echo.Context) error {
+	token := c.QueryParam("token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(eventsToken)) != 1 {
+		return c.String(http.StatusUnauthorized, "failed to verify token")
+	}
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		return err
+	}
+	if c.Request().Header.Get("X-Amz-Sns-Message-Type") != "" {
+		var m snsMessage
+		if err := json.Unmarshal(body, &m); err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		switch m.Type {
+		case "SubscriptionConfirmation":
+			if err := confirmSubscription(m.SubscribeURL); err != nil {
+				logError("failed to confirm SNS subscription", err, logFields{"topic_arn": m.TopicArn})
+				return err
+			}
+			return c.String(http.StatusOK, "subscription confirmed")
+		case "Notification":
+			body = []byte(m.Message)
+		default:
+			return c.String(http.StatusOK, "ignore "+m.Type)
+		}
+	}
+	var ev ec2StateChange
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if ev.Source == "aws.health" {
+		return handleHealthEvent(c, body)
+	}
+	if ev.Source == "aws.ec2" && (ev.DetailType == spotInterruptionWarning || ev.DetailType == rebalanceRecommendation) {
+		recordSpotNotice(ev.Detail.InstanceID, ev.DetailType, ev.Detail.InstanceAction)
+		notifyEC2Event(&ev)
+		return c.String(http.StatusOK, "record spot notice")
+	}
+	if ev.Source != "aws.ec2" || ev.DetailType != "EC2 Instance State-change Notification" {
+		return c.String(http.StatusOK, "ignore event")
+	}
+	if !searchedRegion(ev.Region) || ev.Detail.InstanceID == "" {
+		return c.String(http.StatusOK, "ignore instance in other region")
+	}
+	if err := updateInstance(ev.Region, ev.Detail.InstanceID, ev.Detail.State); err != nil {
+		logError("failed to update instance", err, logFields{"instance_id": ev.Detail.InstanceID, "region": ev.Region})
+		return err
+	}
+	notifyEC2Event(&ev)
+	return c.String(http.StatusOK, "update instance")
+}
+
+// confirmSubscription confirms the subscription of the endpoint to an SNS
+// topic.
+func confirmSubscription(subscribeURL string) error {
+	u, err := url.Parse(subscribeURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || !snsHostPattern.MatchString(u.Host) {
+		return fmt.Errorf("invalid subscribe URL %q", subscribeURL)
+	}
+	resp, err := webhookClient.Get(subscribeURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cannot confirm subscription: %s", resp.Status)
+	}
+	return nil
+}
+
+// updateInstance replaces the cached instance whose state changed with the
+// one described by the API, so that launched instances are found and the
+// addresses of started instances are up to date. The instance is removed
+// from the cache if it is no longer described.
+func updateInstance(region, id, state string) error {
+	cacheMu.RLock()
+	loaded := instanceCache.Instances != nil
+	cacheMu.RUnlock()
+	if !loaded {
+		// the whole cache is loaded on the next lookup
+		return nil
+	}
+	svc := instanceDescriber(region)
+	// the filter does not fail for unknown IDs unlike InstanceIds
+	resp, err := svc.DescribeInstances(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("instance-id"),
+			Values: []*string{aws.String(id)},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	fetched, _ := slimReservations(resp.Reservations)
+
+	cacheMu.Lock()
+	reservations := make([]*ec2.Reservation, 0, len(instanceCache.Instances.Reservations)+1)
+	for _, r := range instanceCache.Instances.Reservations {
+		instances := make([]*ec2.Instance, 0, len(r.Instances))
+		for _, instance := range r.Instances {
+			if aws.StringValue(instance.InstanceId) != id {
+				instances = append(instances, instance)
+			}
+		}
+		switch {
+		case len(instances) == len(r.Instances):
+			reservations = append(reservations, r)
+		case len(instances) > 0:
+			reservation := *r
+			reservation.Instances = instances
+			reservations = append(reservations, &reservation)
+		}
+	}
+	instanceCache.Instances = &ec2.DescribeInstancesOutput{
+		Reservations: append(reservations, fetched...),
+	}
+	instanceCache.Index = indexInstances(instanceCache.Instances)
+	instanceCache.Regions = mergeRegions(instanceCache.Regions, map[string]string{id: region})
+	takeSnapshot()
+	cacheMu.Unlock()
+	if state == ec2.InstanceStateNamePending {
+		// queries of the launched instance are no longer unknown
+		forgetNotFound()
+	}
+	persistCaches()
+	return nil
+}
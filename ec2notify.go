@@ -0,0 +1,52 @@
+package main
+
+// eventChannels are the Slack channels notified of the events received by
+// the /events endpoint with the cards of their instances, $EVENT_CHANNELS.
+// Channels are mapped by the states of the instances and by "interruption"
+// and "rebalance" for spot notices, such as
+// "stopped=C0123456,terminated=C0123456,interruption=C0654321" where "*" is
+// the default. Nothing is posted unless it is set.
+var eventChannels = parseChannelMap("EVENT_CHANNELS")
+
+// eventKey returns the key of the channel of the event.
+func (ev *ec2StateChange) eventKey() string {
+	switch ev.DetailType {
+	case spotInterruptionWarning:
+		return "interruption"
+	case rebalanceRecommendation:
+		return "rebalance"
+	}
+	return ev.Detail.State
+}
+
+// eventHeader returns the message of the event.
+func (ev *ec2StateChange) eventHeader() string {
+	id := code(ev.Detail.InstanceID)
+	switch ev.DetailType {
+	case spotInterruptionWarning:
+		return tr("Spot instance %s will be interrupted (%s)", id, ev.Detail.InstanceAction)
+	case rebalanceRecommendation:
+		return tr("Spot instance %s is recommended to be rebalanced", id)
+	}
+	return tr("Instance %s is %s", id, ev.Detail.State)
+}
+
+// notifyEC2Event posts the card of the instance of the event to the channel
+// of the event. Instances are queried by their ARNs so that those in the
+// other regions are found.
+func notifyEC2Event(ev *ec2StateChange) {
+	channel := mappedChannel(eventChannels, ev.eventKey())
+	if channel == "" {
+		return
+	}
+	query := ev.Detail.InstanceID
+	if len(ev.Resources) > 0 {
+		query = ev.Resources[0]
+	}
+	// events without IDs are not deduplicated
+	eventID := ""
+	if ev.ID != "" {
+		eventID = "ec2:" + ev.ID
+	}
+	goBackground(func() { enrich(eventID, channel, ev.eventHeader(), nil, query) })
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/nlopes/slack"
+)
+
+// maxBackends is the maximum number of backend instances listed on a
+// Classic ELB card.
+const maxBackends = 20
+
+// listenerFields renders the listeners, the health check and the placement
+// of the Classic ELB.
+func listenerFields(lb *elb.LoadBalancerDescription) []slack.AttachmentField {
+	listeners := make([]string, 0, len(lb.ListenerDescriptions))
+	for _, d := range lb.ListenerDescriptions {
+		l := d.Listener
+		if l == nil {
+			continue
+		}
+		listeners = append(listeners, fmt.Sprintf(
+			"%s %d → %s %d",
+			aws.StringValue(l.Protocol), aws.Int64Value(l.LoadBalancerPort),
+			aws.StringValue(l.InstanceProtocol), aws.Int64Value(l.InstancePort),
+		))
+	}
+	fields := []slack.AttachmentField{{
+		Title: tr("Listeners"),
+		Value: strings.Join(listeners, "\n"),
+		Short: true,
+	}}
+	if hc := lb.HealthCheck; hc != nil {
+		fields = append(fields, slack.AttachmentField{
+			Title: tr("Health Check"),
+			Value: code(aws.StringValue(hc.Target)) + "\n" + tr(
+				"every %ds, timeout %ds, healthy after %d, unhealthy after %d",
+				aws.Int64Value(hc.Interval), aws.Int64Value(hc.Timeout),
+				aws.Int64Value(hc.HealthyThreshold), aws.Int64Value(hc.UnhealthyThreshold),
+			),
+			Short: true,
+		})
+	}
+	fields = append(fields, slack.AttachmentField{
+		Title: tr("Availability Zones"),
+		Value: strings.Join(aws.StringValueSlice(lb.AvailabilityZones), ", "),
+		Short: true,
+	})
+	if len(lb.Subnets) > 0 {
+		fields = append(fields, slack.AttachmentField{
+			Title: tr("Subnets"),
+			Value: strings.Join(aws.StringValueSlice(lb.Subnets), ", "),
+			Short: true,
+		})
+	}
+	return fields
+}
+
+// backendAttachments lists the backend instances of the Classic ELB with
+// their names and states in the instance cache and their live health, each
+// with a button expanding the instance, and the health of the load
+// balancer by them.
+func backendAttachments(lb *elb.LoadBalancerDescription) ([]slack.Attachment, ResourceHealth) {
+	if len(lb.Instances) == 0 {
+		return nil, HealthUnknown
+	}
+	name := aws.StringValue(lb.LoadBalancerName)
+	svc := regionELBClient(loadBalancerRegion(name))
+	resp, err := svc.DescribeInstanceHealth(&elb.DescribeInstanceHealthInput{
+		LoadBalancerName: lb.LoadBalancerName,
+	})
+	if err != nil {
+		logError("failed to describe instance health", err, logFields{"load_balancer": name})
+		return nil, HealthUnknown
+	}
+	attachments := make([]slack.Attachment, 0, len(resp.InstanceStates))
+	healths := make([]ResourceHealth, len(resp.InstanceStates))
+	for i, s := range resp.InstanceStates {
+		healths[i] = targetHealth(aws.StringValue(s.State))
+	}
+	for i, s := range resp.InstanceStates {
+		if i == maxBackends {
+			attachments = append(attachments, slack.Attachment{Text: tr("and %d more", len(resp.InstanceStates)-maxBackends)})
+			break
+		}
+		id := aws.StringValue(s.InstanceId)
+		var name, state string
+		instance, err := getInstance(id)
+		if err != nil {
+			logError("failed to get instance", err, logFields{"instance_id": id})
+		}
+		if instance != nil {
+			name = currentConfig().redactTag("Name", tagValue(instance.Tags, "Name"))
+			if instance.State != nil {
+				state = aws.StringValue(instance.State.Name)
+			}
+		}
+		row := summaryRow("instance", id, id, name, state, aws.StringValue(s.State), aws.StringValue(s.Description))
+		if aws.StringValue(s.State) != "InService" {
+			row.Color = "warning"
+		}
+		attachments = append(attachments, row)
+	}
+	if len(attachments) > 0 {
+		attachments[0].Title = tr("Instances")
+	}
+	return attachments, backendHealth(healths)
+}
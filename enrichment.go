@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/nlopes/slack"
+)
+
+// parseChannelMap parses the Slack channels of the alerts of another
+// service in the environment variable, such as "key1=C0123456,*=C0654321"
+// where "*" is the default.
+func parseChannelMap(key string) map[string]string {
+	channels := make(map[string]string)
+	for _, pair := range strings.FieldsFunc(getenv(key), isComma) {
+		i := strings.Index(pair, "=")
+		if i < 0 {
+			logWarn("cannot parse $"+key+", ignore "+pair, nil)
+			continue
+		}
+		channels[strings.TrimSpace(pair[:i])] = strings.TrimSpace(pair[i+1:])
+	}
+	return channels
+}
+
+// mappedChannel returns the channel of the key, or the default one.
+func mappedChannel(channels map[string]string, key string) string {
+	if channel, ok := channels[key]; ok {
+		return channel
+	}
+	return channels["*"]
+}
+
+// ipHostnamePattern matches the hostnames derived from private IP addresses
+// without domains, such as "ip-10-0-0-1".
+var ipHostnamePattern = regexp.MustCompile(`^ip-([0-9]+)-([0-9]+)-([0-9]+)-([0-9]+)$`)
+
+// hostQuery returns the query of a hostname of another service: instance
+// IDs, DNS names and IP addresses as is, the private IP addresses of
+// "ip-10-0-0-1", and the Name tag of others.
+func hostQuery(hostname string) string {
+	switch {
+	case hostname == "":
+		return ""
+	case fullInstanceIDPattern.MatchString(hostname),
+		privateDnsNamePattern.MatchString(hostname),
+		publicDNSNamePattern.MatchString(hostname),
+		ipv4Pattern.MatchString(hostname):
+		return hostname
+	case ipHostnamePattern.MatchString(hostname):
+		return strings.Join(ipHostnamePattern.FindStringSubmatch(hostname)[1:], ".")
+	}
+	return "name:" + hostname
+}
+
+// recordingBackend forwards the replies to the backend and records their
+// attachments.
+type recordingBackend struct {
+	ChatBackend
+	attachments []slack.Attachment
+}
+
+func (b *recordingBackend) PostMessage(channel, thread, text string, attachments []slack.Attachment) error {
+	b.attachments = append(b.attachments, attachments...)
+	return b.ChatBackend.PostMessage(channel, thread, text, attachments)
+}
+
+// enrich resolves the resources in the text of an alert of another service
+// and posts their cards to the channel, in the thread of the header and the
+// card of the alert if any. Alerts without resources post nothing, and those
+// of the event ID are processed once. It returns the attachments of the
+// cards posted.
+func enrich(eventID, channel, header string, card []slack.Attachment, text string) []slack.Attachment {
+	return enrichThread(eventID, channel, "", header, card, text)
+}
+
+// enrichThread is enrich posting the cards in the thread of the message of
+// the timestamp instead, such as the message of the alert posted by the other
+// service. The header is posted if the thread is empty.
+func enrichThread(eventID, channel, thread, header string, card []slack.Attachment, text string) []slack.Attachment {
+	backend := &recordingBackend{ChatBackend: slackBackend{}}
+	ev := &Event{
+		EventID: eventID,
+		Event: &slack.Msg{
+			Type:    "message",
+			Channel: channel,
+			Text:    text,
+		},
+		backend: backend,
+	}
+	if ev.seen() || !ev.hasQueries() {
+		return nil
+	}
+	if thread == "" {
+		_, ts, err := slackClient("").PostMessage(channel, header, messageParameters("", card))
+		if err != nil {
+			logError("failed to post alert", err, logFields{"event_id": eventID, "channel": channel})
+			return nil
+		}
+		thread = ts
+	}
+	ev.Event.Timestamp = thread
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if _, err := ev.resolve(ctx); err != nil {
+		ev.replyError("failed to resolve alert", err, logFields{"event_id": eventID})
+	}
+	return backend.attachments
+}
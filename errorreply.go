@@ -0,0 +1,33 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// newCorrelationID returns a random ID which identifies an error in the
+// reply to users and in logs.
+func newCorrelationID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// replyError logs the error with a correlation ID and replies to the
+// message in the thread with the ID instead of the error itself, which may
+// expose internals and mean nothing to users.
+func (ev *Event) replyError(msg string, err error, fields logFields) {
+	id := newCorrelationID()
+	f := ev.logFields()
+	for k, v := range fields {
+		f[k] = v
+	}
+	f["correlation_id"] = id
+	logError(msg, err, f)
+	if ev.Event == nil || ev.Event.Channel == "" {
+		return
+	}
+	if err := ev.post(tr("Sorry, something went wrong while looking this up. Please try again later. (error ID: %s)", id), nil); err != nil {
+		logError("failed to post error reply", err, f)
+	}
+}
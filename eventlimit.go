@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/bgpat/ec2bot/internal/awsclients"
+	"github.com/bgpat/ec2bot/internal/cache"
+)
+
+// throttleNoticeTTL is how long a channel is not notified again after the
+// notice of throttling.
+const throttleNoticeTTL = 10 * time.Minute
+
+var (
+	// eventLimiter limits the events processed by all channels to
+	// $EVENT_RATE per second with bursts of $EVENT_BURST. It is nil if
+	// the rate is zero.
+	eventLimiter = newEventLimiter("EVENT_RATE", 20, "EVENT_BURST", 50)
+	// channelEventLimiter holds the rate and the burst of the limiters of
+	// each channel, $CHANNEL_EVENT_RATE and $CHANNEL_EVENT_BURST.
+	channelEventLimiter = newEventLimiter("CHANNEL_EVENT_RATE", 1, "CHANNEL_EVENT_BURST", 10)
+	channelLimiters     = cache.NewLRU(1000, time.Hour)
+	// throttleNotices are the channels notified of throttling recently.
+	throttleNotices = cache.NewLRU(1000, throttleNoticeTTL)
+
+	throttledGlobal  uint64
+	throttledChannel uint64
+)
+
+// newEventLimiter returns the limiter of the rate and burst environment
+// variables, or nil if the rate is zero. The rate and the burst of
+// channels are copied from the returned limiter.
+func newEventLimiter(rateKey string, rate float64, burstKey string, burst float64) *awsclients.RateLimiter {
+	if v := getenv(rateKey); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			logWarn("cannot parse $"+rateKey+", use default '"+strconv.FormatFloat(rate, 'g', -1, 64)+"'", nil)
+		} else {
+			rate = f
+		}
+	}
+	if v := getenv(burstKey); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 1 {
+			logWarn("cannot parse $"+burstKey+", use default '"+strconv.FormatFloat(burst, 'g', -1, 64)+"'", nil)
+		} else {
+			burst = f
+		}
+	}
+	if rate == 0 {
+		return nil
+	}
+	return awsclients.NewRateLimiter(rate, burst)
+}
+
+// throttled reports whether the event exceeds the rate of its channel or
+// of all channels, in which case it is dropped. The channel is notified
+// once while it keeps being throttled.
+func (ev *Event) throttled() bool {
+	channel := ev.TeamID + "/" + ev.Event.Channel
+	if channelEventLimiter != nil {
+		var l *awsclients.RateLimiter
+		if v, ok := channelLimiters.Get(channel); ok {
+			l = v.(*awsclients.RateLimiter)
+		} else {
+			l = awsclients.NewRateLimiter(channelEventLimiter.Rate, channelEventLimiter.Burst)
+			channelLimiters.Add(channel, l)
+		}
+		if !l.Allow() {
+			atomic.AddUint64(&throttledChannel, 1)
+			ev.notifyThrottled(channel)
+			return true
+		}
+	}
+	if eventLimiter != nil && !eventLimiter.Allow() {
+		atomic.AddUint64(&throttledGlobal, 1)
+		ev.notifyThrottled(channel)
+		return true
+	}
+	return false
+}
+
+func (ev *Event) notifyThrottled(channel string) {
+	logWarn("drop event over rate limit", ev.logFields())
+	if _, ok := throttleNotices.Get(channel); ok {
+		return
+	}
+	throttleNotices.Add(channel, true)
+	if err := ev.post(tr("Too many messages to look up, some are ignored for a while."), nil); err != nil {
+		logError("failed to post throttling notice", err, ev.logFields())
+	}
+}
+
+// throttleMetrics returns the numbers of dropped events.
+func throttleMetrics() []Metric {
+	help := "Number of events dropped by rate limits."
+	return []Metric{
+		{Name: "events_throttled_total", Help: help, Type: "counter", Labels: map[string]string{"scope": "channel"}, Value: float64(atomic.LoadUint64(&throttledChannel))},
+		{Name: "events_throttled_total", Help: help, Type: "counter", Labels: map[string]string{"scope": "global"}, Value: float64(atomic.LoadUint64(&throttledGlobal))},
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"sync/atomic"
+)
+
+var (
+	// eventWorkers is the number of events processed at once,
+	// $EVENT_WORKERS.
+	eventWorkers = 8
+	// eventQueue holds the events waiting for a worker, up to
+	// $EVENT_QUEUE_SIZE events.
+	eventQueue chan *Event
+
+	droppedEvents uint64
+)
+
+func init() {
+	size := 100
+	if v := getenv("EVENT_QUEUE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			logWarn("cannot parse $EVENT_QUEUE_SIZE, use default '100'", nil)
+		} else {
+			size = n
+		}
+	}
+	eventQueue = make(chan *Event, size)
+	if v := getenv("EVENT_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			logWarn("cannot parse $EVENT_WORKERS, use default '8'", nil)
+		} else {
+			eventWorkers = n
+		}
+	}
+}
+
+// startEventWorkers starts the workers processing the queued events.
+func startEventWorkers() {
+	for i := 0; i < eventWorkers; i++ {
+		go func() {
+			for ev := range eventQueue {
+				ev.process()
+				background.Done()
+			}
+		}()
+	}
+}
+
+// seen reports whether the event was claimed already by this or another
+// replica, and claims it otherwise. Events are processed if the store
+// fails, since a duplicate reply is better than none.
+func (ev *Event) seen() bool {
+	if ev.EventID == "" {
+		return false
+	}
+	ok, err := eventStore.Claim(ev.EventID, eventStoreTTL)
+	if err != nil {
+		logError("failed to claim event", err, ev.logFields())
+		return false
+	}
+	return !ok
+}
+
+// enqueue queues the event for a worker so that the request is answered
+// at once. It reports false if the queue is full, in which case the event
+// is left to the retries of Slack. Queued events are tracked by background
+// so that they are processed before shutdown.
+func (ev *Event) enqueue() bool {
+	background.Add(1)
+	select {
+	case eventQueue <- ev:
+		return true
+	default:
+		background.Done()
+		// the retry is processed
+		if ev.EventID != "" {
+			if err := eventStore.Release(ev.EventID); err != nil {
+				logError("failed to release event", err, ev.logFields())
+			}
+		}
+		atomic.AddUint64(&droppedEvents, 1)
+		logWarn("drop event on full queue", ev.logFields())
+		return false
+	}
+}
+
+// process handles the message of the event. Failures and panics are
+// replied to in the thread since the request has been answered already.
+func (ev *Event) process() {
+	defer func() {
+		if r := recover(); r != nil {
+			ev.replyError("panic in event handler", fmt.Errorf("%v", r), logFields{"stack": string(debug.Stack())})
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	status, err := ev.handleMessage(ctx)
+	if err != nil {
+		ev.replyError("failed to handle message", err, logFields{"status": status})
+		return
+	}
+	f := ev.logFields()
+	f["status"] = status
+	logDebug("processed event", f)
+}
+
+// queueMetrics returns the length of the queue and the dropped events.
+func queueMetrics() []Metric {
+	return []Metric{
+		{Name: "event_queue_length", Help: "Number of events waiting for a worker.", Value: float64(len(eventQueue))},
+		{Name: "events_dropped_total", Help: "Number of events dropped on the full queue.", Type: "counter", Value: float64(atomic.LoadUint64(&droppedEvents))},
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/nlopes/slack"
+)
+
+// exportColumns are the header of the exported instances.
+var exportColumns = []string{"instance_id", "name", "instance_type", "state", "private_ip", "public_ip", "availability_zone", "tags"}
+
+func init() {
+	mentionCommands["export"] = (*Event).export
+}
+
+func exportButton(query string) slack.AttachmentAction {
+	return slack.AttachmentAction{
+		Name:  "export",
+		Text:  tr("Export CSV"),
+		Type:  "button",
+		Value: query,
+	}
+}
+
+// export handles "@ec2bot export [csv|tsv] state:running tag:<key>=<value>..."
+// by uploading all the instances matching the query.
+func (ev *Event) export(args []string) error {
+	format := "csv"
+	if len(args) > 0 && (args[0] == "csv" || args[0] == "tsv") {
+		format, args = args[0], args[1:]
+	}
+	if len(args) == 0 {
+		return errors.New(tr("usage: export [csv|tsv] <key>:<value>[,<value>...]..."))
+	}
+	return ev.exportInstances(strings.Join(args, " "), format)
+}
+
+// exportInstances uploads the instances matching the query, up to
+// maxInstances, as a CSV or TSV file. The file has the addresses and the
+// tags of the instances, so it is only sent to the users who may view the
+// details, by direct message if they are restricted.
+func (ev *Event) exportInstances(query, format string) error {
+	if !ev.canViewDetails() {
+		return errors.New(tr("you are not allowed to view the details"))
+	}
+	filters, err := parseInstanceQuery(strings.Fields(query))
+	if err != nil {
+		return err
+	}
+	instances, truncated, err := describeInstancesUpTo(filters, maxInstances)
+	if err != nil {
+		return err
+	}
+	if len(instances) == 0 {
+		return ev.post(tr("no instances match %s", code(query)), nil)
+	}
+	content, err := marshalInstanceTable(instances, format)
+	if err != nil {
+		return err
+	}
+	comment := tr("%s instances match %s", strconv.Itoa(len(instances)), code(query))
+	if truncated {
+		comment += " " + tr("(truncated to the first %d)", maxInstances)
+	}
+	// Slack highlights CSV but not TSV
+	fileType := format
+	if format == "tsv" {
+		fileType = "text"
+	}
+	return ev.uploadPrivately(&File{
+		Name:    "instances." + format,
+		Type:    fileType,
+		Title:   tr("Instances matching %s", query),
+		Comment: comment,
+		Content: content,
+	})
+}
+
+// marshalInstanceTable renders the instances as the rows of exportColumns,
+// separated by commas or by tabs for "tsv". The tags are joined as
+// "key=value" pairs separated by semicolons.
+func marshalInstanceTable(instances []*ec2.Instance, format string) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if format == "tsv" {
+		w.Comma = '\t'
+	}
+	if err := w.Write(exportColumns); err != nil {
+		return nil, err
+	}
+	for _, instance := range instances {
+		instance = redactedInstance(instance)
+		state := ""
+		if instance.State != nil {
+			state = aws.StringValue(instance.State.Name)
+		}
+		zone := ""
+		if instance.Placement != nil {
+			zone = aws.StringValue(instance.Placement.AvailabilityZone)
+		}
+		tags := make([]string, len(instance.Tags))
+		for i, tag := range instance.Tags {
+			tags[i] = aws.StringValue(tag.Key) + "=" + aws.StringValue(tag.Value)
+		}
+		sort.Strings(tags)
+		err := w.Write([]string{
+			aws.StringValue(instance.InstanceId),
+			tagValue(instance.Tags, "Name"),
+			aws.StringValue(instance.InstanceType),
+			state,
+			aws.StringValue(instance.PrivateIpAddress),
+			aws.StringValue(instance.PublicIpAddress),
+			zone,
+			strings.Join(tags, ";"),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+const channelPrefsBucket = "channel_prefs"
+
+// detailsFormats are the formats of the "Details" section of cards.
+var detailsFormats = []string{"yaml", "json"}
+
+// ChannelPrefs holds per-channel defaults applied to every reply in that
+// channel.
+type ChannelPrefs struct {
+	// Format is the format of the details, "yaml" unless it is set.
+	Format string `json:"format,omitempty"`
+	// Compact posts a single line per resource with a button expanding it
+	// instead of its card, for noisy channels such as those of alerts.
+	Compact bool `json:"compact,omitempty"`
+	// Posture warns of the risky configurations of instances on their
+	// cards, such as SSH open to the world.
+	Posture bool `json:"posture,omitempty"`
+}
+
+func init() {
+	mentionCommands["json"] = (*Event).lookupJSON
+}
+
+func getChannelPrefs(channel string) *ChannelPrefs {
+	prefs := new(ChannelPrefs)
+	if channel == "" {
+		return prefs
+	}
+	if _, err := store.Get(channelPrefsBucket, channel, prefs); err != nil {
+		logError("failed to get channel preferences", err, logFields{"channel": channel})
+	}
+	return prefs
+}
+
+func putChannelPrefs(channel string, prefs *ChannelPrefs) error {
+	return store.Put(channelPrefsBucket, channel, prefs)
+}
+
+func (p *ChannelPrefs) String() string {
+	format := p.Format
+	if format == "" {
+		format = "yaml"
+	}
+	return "format: " + format + "\ncompact: " + onOff(p.Compact) + "\nposture: " + onOff(p.Posture)
+}
+
+// set applies a single "<key> <value>" preference change.
+func (p *ChannelPrefs) set(key, value string) error {
+	switch key {
+	case "format":
+		if !isDetailsFormat(value) {
+			return fmt.Errorf("unknown format %q (%s)", value, strings.Join(detailsFormats, ", "))
+		}
+		p.Format = value
+	case "compact":
+		b, err := parseOnOff(value)
+		if err != nil {
+			return err
+		}
+		p.Compact = b
+	case "posture":
+		b, err := parseOnOff(value)
+		if err != nil {
+			return err
+		}
+		p.Posture = b
+	default:
+		return fmt.Errorf("unknown channel preference %q", key)
+	}
+	return nil
+}
+
+func isDetailsFormat(s string) bool {
+	for _, f := range detailsFormats {
+		if f == s {
+			return true
+		}
+	}
+	return false
+}
+
+// detailsFormat returns the format of the details of the replies to the
+// event, which is set by the command or the channel.
+func (ev *Event) detailsFormat() string {
+	if ev.format != "" {
+		return ev.format
+	}
+	if f := getChannelPrefs(ev.Event.Channel).Format; f != "" {
+		return f
+	}
+	return "yaml"
+}
+
+// marshalDetails encodes the details of a resource in the format.
+func marshalDetails(v interface{}, format string) ([]byte, error) {
+	if format == "json" {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return yaml.Marshal(v)
+}
+
+// lookupJSON handles "@ec2bot json <text>" by looking up the text with the
+// details in JSON.
+func (ev *Event) lookupJSON(args []string) error {
+	ev.format = "json"
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	_, err := ev.resolve(ctx)
+	return err
+}
+
+// channel handles "/ec2 channel [format yaml|json | compact on|off | posture on|off]".
+func (cmd *Command) channel(args []string) *CommandResponse {
+	prefs := getChannelPrefs(cmd.ChannelID)
+	switch len(args) {
+	case 0:
+		return ephemeral(prefs.String())
+	case 2:
+		if err := prefs.set(args[0], args[1]); err != nil {
+			return ephemeral(fmt.Sprintf("%v\n%s", err, commandUsage))
+		}
+	default:
+		return ephemeral(commandUsage)
+	}
+	if err := putChannelPrefs(cmd.ChannelID, prefs); err != nil {
+		logError("failed to save channel preferences", err, logFields{"channel": cmd.ChannelID})
+		return ephemeral("failed to save channel preferences")
+	}
+	return ephemeral("channel preferences updated\n" + prefs.String())
+}
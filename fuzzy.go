@@ -0,0 +1,132 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// maxCandidates is the maximum number of close matches suggested for a
+// query which was not found.
+const maxCandidates = 5
+
+// isWildcard reports whether the query contains wildcards.
+func isWildcard(query string) bool {
+	return strings.ContainsAny(query, "*?")
+}
+
+// wildcardRegexp compiles a pattern where "*" matches any string and "?"
+// matches any character.
+func wildcardRegexp(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = strings.Replace(quoted, `\*`, ".*", -1)
+	quoted = strings.Replace(quoted, `\?`, ".", -1)
+	return regexp.MustCompile("^" + quoted + "$")
+}
+
+// isFuzzyQuery reports whether close matches are suggested when the query
+// is not found. Only names and host names are matched fuzzily.
+func isFuzzyQuery(query string) bool {
+	if isWildcard(query) {
+		return false
+	}
+	return strings.HasPrefix(query, "name:") || privateDnsNamePattern.MatchString(query)
+}
+
+// closestInstances returns the instances whose Name tag or DNS names are
+// closest to the query, nearest first.
+func closestInstances(query string) ([]*ec2.Instance, error) {
+	resp, err := describeInstances()
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(query, "name:")
+	maxDistance := len(name) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	type candidate struct {
+		instance *ec2.Instance
+		distance int
+	}
+	var candidates []candidate
+	for _, reservation := range resp.Reservations {
+		for _, instance := range reservation.Instances {
+			keys := []string{tagValue(instance.Tags, "Name")}
+			if !strings.HasPrefix(query, "name:") {
+				keys = append(keys,
+					aws.StringValue(instance.PrivateDnsName),
+					aws.StringValue(instance.PublicDnsName),
+				)
+			}
+			best := -1
+			for _, k := range keys {
+				if k == "" {
+					continue
+				}
+				if d := levenshtein(strings.ToLower(name), strings.ToLower(k)); best < 0 || d < best {
+					best = d
+				}
+			}
+			if best >= 0 && best <= maxDistance {
+				candidates = append(candidates, candidate{instance, best})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+	if len(candidates) > maxCandidates {
+		candidates = candidates[:maxCandidates]
+	}
+	result := make([]*ec2.Instance, len(candidates))
+	for i, c := range candidates {
+		result[i] = c.instance
+	}
+	return result, nil
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// postCandidates suggests the instances closest to a query which was not
+// found.
+func (ev *Event) postCandidates(query string, instances []*ec2.Instance) error {
+	return ev.postSummary(
+		tr("%s was not found. Did you mean:", code(query)),
+		ev.instanceSummaryRows(instances),
+	)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/labstack/echo"
+)
+
+var (
+	// gitHubSecret is the secret of the GitHub webhooks of deployments,
+	// $GITHUB_WEBHOOK_SECRET, which enables the /github endpoint.
+	gitHubSecret = getenv("GITHUB_WEBHOOK_SECRET")
+	// gitHubChannels are the Slack channels of the deployments by the full
+	// names of their repositories, $GITHUB_CHANNELS, such as
+	// "bgpat/web=C0123456,*=C0654321" where "*" is the default.
+	gitHubChannels = parseChannelMap("GITHUB_CHANNELS")
+)
+
+// gitHubFinalStates are the states of the deployment statuses posted.
+var gitHubFinalStates = map[string]bool{
+	"success": true,
+	"failure": true,
+	"error":   true,
+}
+
+// gitHubDeployment is the deployment of the webhooks, whose payload lists
+// the target hosts as "hosts", such as
+// {"hosts": ["i-0123456789abcdef0", "web-1", "tag:Service=web"]} where
+// hosts are instance IDs, DNS names, IP addresses, Name tags or tag
+// queries.
+type gitHubDeployment struct {
+	Ref         string `json:"ref"`
+	Environment string `json:"environment"`
+	Description string `json:"description"`
+	Payload     struct {
+		Hosts []string `json:"hosts"`
+	} `json:"payload"`
+	Creator struct {
+		Login string `json:"login"`
+	} `json:"creator"`
+}
+
+// gitHubWebhook is the body of the deployment and deployment_status
+// webhooks.
+type gitHubWebhook struct {
+	Deployment       gitHubDeployment `json:"deployment"`
+	DeploymentStatus *struct {
+		State     string `json:"state"`
+		TargetURL string `json:"target_url"`
+	} `json:"deployment_status"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+}
+
+// verifyGitHubSignature reports whether the X-Hub-Signature-256 header,
+// such as "sha256=<hex>", is the signature of the body.
+func verifyGitHubSignature(header string, body []byte) bool {
+	mac := hmac.New(sha256.New, []byte(gitHubSecret))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(header), []byte(expected))
+}
+
+// handleGitHub posts the summaries of the deployments and their final
+// statuses to the channel of the repository, with the cards of the target
+// hosts in the thread.
+func handleGitHub(c echo.Context) error {
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		return err
+	}
+	if !verifyGitHubSignature(c.Request().Header.Get("X-Hub-Signature-256"), body) {
+		return c.String(http.StatusUnauthorized, "failed to verify signature")
+	}
+	event := c.Request().Header.Get("X-GitHub-Event")
+	if event != "deployment" && event != "deployment_status" {
+		return c.String(http.StatusOK, "ignore "+event)
+	}
+	var w gitHubWebhook
+	if err := json.Unmarshal(body, &w); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if w.DeploymentStatus != nil && !gitHubFinalStates[w.DeploymentStatus.State] {
+		return c.String(http.StatusOK, "ignore "+w.DeploymentStatus.State)
+	}
+	channel := mappedChannel(gitHubChannels, w.Repository.FullName)
+	if channel == "" {
+		return c.String(http.StatusOK, "no channel of repository "+w.Repository.FullName)
+	}
+	// deliveries without IDs are not deduplicated
+	eventID := ""
+	if id := c.Request().Header.Get("X-GitHub-Delivery"); id != "" {
+		eventID = "github:" + id
+	}
+	goBackground(func() { enrich(eventID, channel, w.summary(), nil, strings.Join(w.Deployment.queries(), "\n")) })
+	return c.String(http.StatusOK, "queued")
+}
+
+// summary returns the message of the deployment and its status.
+func (w *gitHubWebhook) summary() string {
+	d := &w.Deployment
+	lines := []string{tr("Deployment of %s to %s by %s",
+		link(w.Repository.HTMLURL, w.Repository.FullName)+"@"+code(d.Ref),
+		code(d.Environment),
+		d.Creator.Login,
+	)}
+	if s := w.DeploymentStatus; s != nil {
+		state := code(s.State)
+		if s.TargetURL != "" {
+			state = link(s.TargetURL, s.State)
+		}
+		lines = append(lines, tr("Status: %s", state))
+	}
+	if d.Description != "" {
+		lines = append(lines, ">"+d.Description)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// queries returns the queries of the target hosts. Tag queries are
+// resolved to the IDs of the matching instances.
+func (d *gitHubDeployment) queries() []string {
+	var queries []string
+	for _, host := range d.Payload.Hosts {
+		if !strings.HasPrefix(host, "tag:") {
+			queries = append(queries, hostQuery(host))
+			continue
+		}
+		filter, err := parseTagFilter(host)
+		if err != nil {
+			logWarn("invalid tag query of deployment", logFields{"query": host})
+			continue
+		}
+		instances, _, err := queryInstances([]*ec2.Filter{filter})
+		if err != nil {
+			logError("failed to query instances", err, logFields{"query": host})
+			continue
+		}
+		for _, instance := range instances {
+			queries = append(queries, aws.StringValue(instance.InstanceId))
+		}
+	}
+	return queries
+}
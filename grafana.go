@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/nlopes/slack"
+)
+
+var (
+	// grafanaToken is the token of the webhooks of Grafana alerting, which
+	// is passed as the "token" query parameter, $GRAFANA_WEBHOOK_TOKEN. It
+	// enables the /grafana endpoint.
+	grafanaToken = getenv("GRAFANA_WEBHOOK_TOKEN")
+	// grafanaChannels are the Slack channels of the alerts by the names of
+	// their contact points, $GRAFANA_CHANNELS, such as
+	// "web=C0123456,*=C0654321" where "*" is the default.
+	grafanaChannels = parseChannelMap("GRAFANA_CHANNELS")
+	// grafanaLabels are the labels of the hosts of the alerts,
+	// $GRAFANA_LABELS.
+	grafanaLabels = []string{"instance", "instance_id", "host", "hostname"}
+)
+
+func init() {
+	if v := getenv("GRAFANA_LABELS"); v != "" {
+		grafanaLabels = strings.FieldsFunc(v, isComma)
+	}
+}
+
+// grafanaSlackMessage is the annotation of the alerts linking to their
+// Slack messages, whose threads the cards are posted in.
+const grafanaSlackMessage = "slack_message"
+
+// grafanaHistoryWindow is how long ago the Slack messages of the alerts are
+// searched for when they are not linked.
+const grafanaHistoryWindow = 10 * time.Minute
+
+// grafanaWebhook is the body of the webhooks of Grafana alerting.
+type grafanaWebhook struct {
+	Receiver          string            `json:"receiver"`
+	Status            string            `json:"status"`
+	GroupKey          string            `json:"groupKey"`
+	Title             string            `json:"title"`
+	ExternalURL       string            `json:"externalURL"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	Alerts            []struct {
+		Status       string            `json:"status"`
+		Fingerprint  string            `json:"fingerprint"`
+		Labels       map[string]string `json:"labels"`
+		Annotations  map[string]string `json:"annotations"`
+		GeneratorURL string            `json:"generatorURL"`
+	} `json:"alerts"`
+}
+
+// eventID returns the ID of the firing alerts of the group, so that the
+// notifications repeated for them are processed once.
+func (w *grafanaWebhook) eventID() string {
+	var fingerprints []string
+	for _, a := range w.Alerts {
+		if a.Status == "firing" {
+			fingerprints = append(fingerprints, a.Fingerprint)
+		}
+	}
+	sort.Strings(fingerprints)
+	return "grafana:" + w.GroupKey + ":" + strings.Join(fingerprints, ",")
+}
+
+// text returns the queries of the host labels of the firing alerts. Ports
+// of the "instance" labels of Prometheus, such as "10.0.0.1:9100", are
+// removed.
+func (w *grafanaWebhook) text() string {
+	var queries []string
+	for _, a := range w.Alerts {
+		if a.Status != "firing" {
+			continue
+		}
+		for _, label := range grafanaLabels {
+			v := a.Labels[label]
+			if host, _, err := net.SplitHostPort(v); err == nil {
+				v = host
+			}
+			if v != "" {
+				queries = append(queries, hostQuery(v))
+			}
+		}
+	}
+	return strings.Join(queries, "\n")
+}
+
+// slackMessage returns the channel and the timestamp of the Slack message
+// linked by the annotation of the alerts, if any.
+func (w *grafanaWebhook) slackMessage() (string, string, bool) {
+	permalink := w.CommonAnnotations[grafanaSlackMessage]
+	for _, a := range w.Alerts {
+		if permalink != "" {
+			break
+		}
+		permalink = a.Annotations[grafanaSlackMessage]
+	}
+	m := permalinkPattern.FindStringSubmatch(permalink)
+	if m == nil {
+		return "", "", false
+	}
+	if m[4] != "" {
+		return m[1], m[4], true
+	}
+	return m[1], m[2] + "." + m[3], true
+}
+
+// findAlertMessage returns the timestamp of the latest message of the
+// channel showing the title, such as that posted by the Slack contact point
+// of Grafana, or an empty string if it is not found.
+func findAlertMessage(channel, title string) (string, error) {
+	if title == "" {
+		return "", nil
+	}
+	oldest := time.Now().Add(-grafanaHistoryWindow).Unix()
+	var resp struct {
+		Messages []slack.Msg `json:"messages"`
+	}
+	err := callAPI("", "conversations.history", url.Values{
+		"channel": {channel},
+		"oldest":  {strconv.FormatInt(oldest, 10)},
+		"limit":   {"100"},
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+	for _, msg := range resp.Messages {
+		if strings.Contains(msg.Text, title) {
+			return msg.Timestamp, nil
+		}
+		for _, a := range msg.Attachments {
+			if strings.Contains(a.Title, title) || strings.Contains(a.Fallback, title) {
+				return msg.Timestamp, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// handleGrafana posts the cards of the hosts of the firing alerts of
+// Grafana to the threads of their Slack messages, which are linked by the
+// "slack_message" annotation or searched for in the channel of the contact
+// point by the title. A message linking to the alerts is posted if it is not
+// found.
+func handleGrafana(c echo.Context) error {
+	if subtle.ConstantTimeCompare([]byte(c.QueryParam("token")), []byte(grafanaToken)) != 1 {
+		return c.String(http.StatusUnauthorized, "failed to verify token")
+	}
+	w := new(grafanaWebhook)
+	if err := c.Bind(w); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if w.Status != "firing" {
+		return c.String(http.StatusOK, "ignore "+w.Status)
+	}
+	channel, thread, linked := w.slackMessage()
+	if !linked {
+		channel = mappedChannel(grafanaChannels, w.Receiver)
+	}
+	if channel == "" {
+		return c.String(http.StatusOK, "no channel of receiver "+w.Receiver)
+	}
+	goBackground(func() {
+		if !linked {
+			ts, err := findAlertMessage(channel, w.Title)
+			if err != nil {
+				logError("failed to find alert message", err, logFields{"channel": channel})
+			}
+			thread = ts
+		}
+		header := tr("Grafana alert %s", w.Title)
+		if w.ExternalURL != "" {
+			header = tr("Grafana alert %s", link(w.ExternalURL, w.Title))
+		}
+		enrichThread(w.eventID(), channel, thread, header, nil, w.text())
+	})
+	return c.String(http.StatusOK, "queued")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/labstack/echo"
+)
+
+// HealthCheck is the status of a dependency of the bot.
+type HealthCheck struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Health is the response of /healthz.
+type Health struct {
+	OK     bool                    `json:"ok"`
+	Checks map[string]*HealthCheck `json:"checks"`
+}
+
+// checkCacheAge returns an error if the cache was never loaded or missed
+// refreshes. Caches are only loaded by lookups without background refresh,
+// so their age is not checked then.
+func checkCacheAge(updatedAt time.Time, ttl time.Duration) *HealthCheck {
+	switch {
+	case !backgroundRefresh:
+	case updatedAt.IsZero():
+		return &HealthCheck{Error: "not loaded"}
+	case time.Since(updatedAt) > 2*ttl:
+		return &HealthCheck{Error: "refreshed at " + updatedAt.Format(time.RFC3339)}
+	}
+	return &HealthCheck{OK: true}
+}
+
+func checkError(err error) *HealthCheck {
+	if err != nil {
+		return &HealthCheck{Error: err.Error()}
+	}
+	return &HealthCheck{OK: true}
+}
+
+// cacheUpdates returns when the caches of the enabled resolvers were
+// updated. It has to be called with cacheMu held.
+func cacheUpdates() map[string]time.Time {
+	updates := make(map[string]time.Time)
+	if resolverEnabled("instance") {
+		updates["instance"] = instanceCache.UpdatedAt
+	}
+	if resolverEnabled("loadbalancer") {
+		updates["loadbalancer"] = loadBalancerCache.UpdatedAt
+	}
+	if resolverEnabled("loadbalancer_v2") {
+		updates["loadbalancer_v2"] = loadBalancerV2Cache.UpdatedAt
+	}
+	return updates
+}
+
+// handleHealthz checks the Slack token, the AWS credentials and the
+// freshness of the caches. It responds 503 if any of them fails.
+func handleHealthz(c echo.Context) error {
+	h := &Health{OK: true, Checks: make(map[string]*HealthCheck)}
+	if slackAccessToken != "" {
+		_, err := api.AuthTest()
+		h.Checks["slack"] = checkError(err)
+	}
+	_, err := stsClient.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	h.Checks["aws"] = checkError(err)
+
+	cacheMu.RLock()
+	for name, updatedAt := range cacheUpdates() {
+		h.Checks[name+"_cache"] = checkCacheAge(updatedAt, cacheTTL(name))
+	}
+	cacheMu.RUnlock()
+
+	for name, check := range h.Checks {
+		if !check.OK {
+			h.OK = false
+			logWarn("health check failed", logFields{"check": name, "error": check.Error})
+		}
+	}
+	if !h.OK {
+		return c.JSON(http.StatusServiceUnavailable, h)
+	}
+	return c.JSON(http.StatusOK, h)
+}
+
+// slackAuthenticated is set once the Slack token is verified, or if the
+// bot has no token of its own.
+var slackAuthenticated int32
+
+// handleLivez responds while the process serves requests.
+func handleLivez(c echo.Context) error {
+	return c.String(http.StatusOK, "ok")
+}
+
+// handleReadyz responds 503 until the Slack token is verified and the
+// caches are loaded, so that events are not routed to a replica which would
+// answer every query with "not found".
+func handleReadyz(c echo.Context) error {
+	if atomic.LoadInt32(&slackAuthenticated) == 0 {
+		return c.String(http.StatusServiceUnavailable, "slack not authenticated")
+	}
+	loaded := true
+	cacheMu.RLock()
+	for _, updatedAt := range cacheUpdates() {
+		loaded = loaded && !updatedAt.IsZero()
+	}
+	cacheMu.RUnlock()
+	if !loaded {
+		return c.String(http.StatusServiceUnavailable, "caches not loaded")
+	}
+	return c.String(http.StatusOK, "ok")
+}
+
+// warmUpCaches loads the caches once when they are not refreshed in the
+// background, so that the bot becomes ready without waiting for lookups.
+func warmUpCaches() {
+	if err := refreshAll(); err != nil {
+		logError("failed to load caches", err, nil)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+var (
+	// healthChannel is the Slack channel notified of the AWS Health events
+	// of EC2 and ELB received by the /events endpoint, $HEALTH_CHANNEL, such
+	// as scheduled maintenance of instances. The EventBridge rule has to
+	// match the "aws.health" source too.
+	healthChannel = getenv("HEALTH_CHANNEL")
+	// teamTag is the tag of the owning teams of instances, $TEAM_TAG, which
+	// are listed on the notifications of AWS Health events.
+	teamTag = "Team"
+)
+
+func init() {
+	if v := getenv("TEAM_TAG"); v != "" {
+		teamTag = v
+	}
+}
+
+// healthServices are the services of the AWS Health events notified.
+var healthServices = map[string]bool{
+	"EC2":                  true,
+	"ELASTICLOADBALANCING": true,
+}
+
+// healthEvent is an AWS Health event delivered by EventBridge.
+type healthEvent struct {
+	ID      string `json:"id"`
+	Region  string `json:"region"`
+	Account string `json:"account"`
+	Detail  struct {
+		EventArn          string `json:"eventArn"`
+		Service           string `json:"service"`
+		EventTypeCode     string `json:"eventTypeCode"`
+		EventTypeCategory string `json:"eventTypeCategory"`
+		StartTime         string `json:"startTime"`
+		EndTime           string `json:"endTime"`
+		EventDescription  []struct {
+			Language          string `json:"language"`
+			LatestDescription string `json:"latestDescription"`
+		} `json:"eventDescription"`
+		AffectedEntities []struct {
+			EntityValue string `json:"entityValue"`
+		} `json:"affectedEntities"`
+	} `json:"detail"`
+}
+
+// queries returns the queries of the affected resources. Classic ELBs,
+// which are affected by their names, are queried by their ARNs.
+func (h *healthEvent) queries() []string {
+	var queries []string
+	for _, e := range h.Detail.AffectedEntities {
+		q := e.EntityValue
+		if h.Detail.Service == "ELASTICLOADBALANCING" && !strings.HasPrefix(q, "arn:") {
+			q = "arn:" + awsPartition(h.Region) + ":elasticloadbalancing:" + h.Region + ":" + h.Account + ":loadbalancer/" + q
+		}
+		queries = append(queries, q)
+	}
+	return queries
+}
+
+// teams returns the owning teams of the affected instances by their team
+// tags.
+func (h *healthEvent) teams() []string {
+	if h.Detail.Service != "EC2" {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var teams []string
+	for _, q := range h.queries() {
+		instances, err := resolveInstances(q)
+		if err != nil {
+			logError("failed to resolve instances", err, logFields{"query": q})
+			continue
+		}
+		for _, instance := range instances {
+			if team := tagValue(instance.Tags, teamTag); team != "" && !seen[team] {
+				seen[team] = true
+				teams = append(teams, team)
+			}
+		}
+	}
+	sort.Strings(teams)
+	return teams
+}
+
+// header returns the message of the event with its period, owning teams
+// and description.
+func (h *healthEvent) header() string {
+	lines := []string{tr("AWS Health event %s", code(h.Detail.EventTypeCode))}
+	if h.Detail.StartTime != "" {
+		lines = append(lines, tr("From %s to %s", h.Detail.StartTime, optional(&h.Detail.EndTime)))
+	}
+	if teams := h.teams(); len(teams) > 0 {
+		lines = append(lines, tr("Teams: %s", strings.Join(teams, ", ")))
+	}
+	for _, d := range h.Detail.EventDescription {
+		if d.Language == "en_US" || d.Language == "" {
+			lines = append(lines, ">>>"+truncateRunes(d.LatestDescription, 2000))
+			break
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// handleHealthEvent posts the cards of the resources affected by the AWS
+// Health event to the health channel, in the thread of the event.
+func handleHealthEvent(c echo.Context, body []byte) error {
+	if healthChannel == "" {
+		return c.String(http.StatusOK, "ignore health event")
+	}
+	var h healthEvent
+	if err := json.Unmarshal(body, &h); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if !healthServices[h.Detail.Service] {
+		return c.String(http.StatusOK, "ignore "+h.Detail.Service)
+	}
+	// events without IDs are not deduplicated
+	eventID := ""
+	if h.ID != "" {
+		eventID = "health:" + h.ID
+	}
+	goBackground(func() { enrich(eventID, healthChannel, h.header(), nil, strings.Join(h.queries(), "\n")) })
+	return c.String(http.StatusOK, "queued")
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+)
+
+// language selects the translation of card labels and error messages.
+var language = getenv("BOT_LANGUAGE")
+
+// translations maps English messages to their translations by language.
+var translations = map[string]map[string]string{
+	"ja": {
+		"Instance ID":                          "インスタンス ID",
+		"Instance Type":                        "インスタンスタイプ",
+		"Private DNS Name":                     "プライベート DNS 名",
+		"Private IP Address":                   "プライベート IP アドレス",
+		"Public DNS Name":                      "パブリック DNS 名",
+		"Public IP Address":                    "パブリック IP アドレス",
+		"State":                                "状態",
+		"Launch Time":                          "起動時刻",
+		"Tags":                                 "タグ",
+		"Details":                              "詳細",
+		"Name":                                 "名前",
+		"DNS Name":                             "DNS 名",
+		"Scheme":                               "スキーム",
+		"Open in AWS Console":                  "AWS コンソールで開く",
+		"Expand":                               "展開",
+		"%d instances":                         "%d 件のインスタンス",
+		"%d load balancers":                    "%d 件のロードバランサー",
+		"failed to get instance":               "インスタンスが見つかりませんでした",
+		"failed to get load balancer":          "ロードバランサーが見つかりませんでした",
+		"Start":                                "起動",
+		"Stop":                                 "停止",
+		"Stop %s?":                             "%s を停止しますか?",
+		"The instance will be stopped.":        "インスタンスを停止します。",
+		"Reboot":                               "再起動",
+		"Reboot %s?":                           "%s を再起動しますか?",
+		"The instance will be rebooted.":       "インスタンスを再起動します。",
+		"failed to reboot %s: %v":              "%s の再起動に失敗しました: %v",
+		"%s is rebooting (requested by <@%s>)": "%s を再起動しています (<@%s>)",
+		"usage: reboot <instance id>":          "使い方: reboot <インスタンス ID>",
+		"Reboot the following instances?":      "以下のインスタンスを再起動しますか?",
+		"Terminate":                            "終了",
+		"Terminate instance":                   "インスタンスの終了",
+		"Type %s to terminate the instance. This cannot be undone.": "インスタンスを終了するには %s と入力してください。この操作は取り消せません。",
+		"invalid request":                       "不正なリクエストです",
+		"does not match %s":                     "%s と一致しません",
+		"failed to terminate %s: %v":            "%s の終了に失敗しました: %v",
+		"%s: %s → %s by <@%s>":                  "%s: %s → %s (<@%s>)",
+		"%s has termination protection enabled": "%s は削除保護が有効です",
+		"Snapshot":                              "スナップショット",
+		"Create an image of %s?":                "%s のイメージを作成しますか？",
+		"The instance will not be rebooted, so the file systems may be inconsistent.": "インスタンスは再起動されないため、ファイルシステムの整合性は保証されません。",
+		"usage: snapshot <instance id|volume id>":                                     "使い方: snapshot <インスタンスID|ボリュームID>",
+		"failed to snapshot %s: %v":                                                   "%s のスナップショットに失敗しました: %v",
+		"%s is being created from %s (requested by <@%s>)":                            "%[2]s から %[1]s を作成しています (<@%[3]s> のリクエスト)",
+		"Console output":                            "コンソール出力",
+		"usage: console <instance id>":              "使い方: console <インスタンス ID>",
+		"failed to get console output of %s: %v":    "%s のコンソール出力の取得に失敗しました: %v",
+		"no console output is available for %s yet": "%s のコンソール出力はまだありません",
+		"Console output of %s":                      "%s のコンソール出力",
+		"Screenshot":                                "スクリーンショット",
+		"usage: screenshot <instance id>":           "使い方: screenshot <インスタンス ID>",
+		"failed to get screenshot of %s: %v":        "%s のスクリーンショットの取得に失敗しました: %v",
+		"Screenshot of %s":                          "%s のスクリーンショット",
+		"unknown command %q":                        "不明なコマンドです: %q",
+		"no commands are configured":                "コマンドが設定されていません",
+		"usage: run <command> <instance id>":        "使い方: run <コマンド> <インスタンス ID>",
+		"failed to run %s on %s: %v":                "%[2]s での %[1]s の実行に失敗しました: %[3]v",
+		"running %s on %s (requested by <@%s>)":     "%[2]s で %[1]s を実行しています (<@%[3]s>)",
+		"failed to get the result of %s on %s: %v":  "%[2]s での %[1]s の結果の取得に失敗しました: %[3]v",
+		"%s on %s: %s":                              "%[2]s での %[1]s: %[3]s",
+		"timed out waiting for %s on %s":            "%[2]s での %[1]s がタイムアウトしました",
+		"SSM Agent":                                 "SSM エージェント",
+		"Start Session Manager session":             "Session Manager でセッションを開始",
+		"Drain":                                     "切り離し",
+		"Drain %s?":                                 "%s を切り離しますか?",
+		"The instance will be deregistered from all load balancers and target groups.": "インスタンスをすべてのロードバランサーとターゲットグループから登録解除します。",
+		"usage: drain <instance id>":                     "使い方: drain <インスタンス ID>",
+		"failed to drain %s: %v":                         "%s の切り離しに失敗しました: %v",
+		"%s is not registered to any load balancer":      "%s はどのロードバランサーにも登録されていません",
+		"failed to deregister %s from %s: %v":            "%[2]s からの %[1]s の登録解除に失敗しました: %[3]v",
+		"deregistering %s from %s (requested by <@%s>)":  "%[2]s から %[1]s を登録解除しています (<@%[3]s>)",
+		"failed to check draining of %s from %s: %v":     "%[2]s からの %[1]s の切り離し状況の確認に失敗しました: %[3]v",
+		"%s has been drained from %s":                    "%[2]s からの %[1]s の切り離しが完了しました",
+		"timed out waiting for %s to be drained from %s": "%[2]s からの %[1]s の切り離しがタイムアウトしました",
+		"Resize":          "サイズ変更",
+		"Resize instance": "インスタンスのサイズ変更",
+		"no instance types are compatible with %s":                             "%s と互換性のあるインスタンスタイプがありません",
+		"%s is not compatible with %s":                                         "%s は %s と互換性がありません",
+		"%s (%s) will be stopped, resized and started again if it is running.": "%s (%s) は実行中であれば停止し、サイズを変更してから再び起動します。",
+		"stopping %s":                   "%s を停止しています",
+		"changing the type of %s to %s": "%s のタイプを %s に変更しています",
+		"starting %s":                   "%s を起動しています",
+		"failed to resize %s: %v":       "%s のサイズ変更に失敗しました: %v",
+		"%s has been resized to %s (requested by <@%s>)": "%s のタイプを %s に変更しました (<@%s>)",
+		"Edit tags":                            "タグを編集",
+		"Save":                                 "保存",
+		"Key":                                  "キー",
+		"Value":                                "値",
+		"Leave empty to delete the tag.":       "空欄にするとタグを削除します。",
+		"tags starting with aws: are reserved": "aws: で始まるタグは予約されています",
+		"failed to edit tags of %s: %v":        "%s のタグの編集に失敗しました: %v",
+		"%s: deleted tag %s (requested by <@%s>)":                      "%s: タグ %s を削除しました (<@%s>)",
+		"%s: set tag %s to %s (requested by <@%s>)":                    "%s: タグ %s を %s に設定しました (<@%s>)",
+		"<@%s> is not allowed to %s %s":                                "<@%[1]s> には %[3]s に対する %[2]s の権限がありません",
+		"<@%s> requests %s of %s":                                      "<@%[1]s> が %[3]s の %[2]s をリクエストしました",
+		"Another authorized user must approve this request within %s.": "権限を持つ別のユーザーが %s 以内に承認する必要があります。",
+		"Approve":                             "承認",
+		"Reject":                              "却下",
+		"approval not found":                  "承認リクエストが見つかりません",
+		"you cannot approve your own request": "自分のリクエストは承認できません",
+		"%s by %s":                            "%s (%s)",
+		"usage: lookup <instance id|address|name>":  "使い方: lookup <インスタンス ID|アドレス|名前>",
+		"%d instances match %s":                     "%[2]s に一致するインスタンスが %[1]d 件あります",
+		"usage: list <key>:<value>[,<value>...]...": "使い方: list <キー>:<値>[,<値>...]...",
+		"keys: %s":                            "キー: %s",
+		"invalid query %q":                    "不正なクエリです: %q",
+		"invalid tag query %q":                "不正なタグクエリです: %q",
+		"no instances match %s":               "%s に一致するインスタンスはありません",
+		"Previous":                            "前へ",
+		"Next":                                "次へ",
+		"page %d of %d":                       "%d / %d ページ",
+		"%s instances match %s":               "%[2]s に一致するインスタンスが %[1]s 件あります",
+		"%s was not found. Did you mean:":     "%s は見つかりませんでした。もしかして:",
+		"Type":                                "タイプ",
+		"Cancel":                              "キャンセル",
+		"%s → %s by <@%s>":                    "%s → %s (<@%s>)",
+		"Pods on %s":                          "%s の Pod",
+		"and %d more":                         "他 %d 件",
+		"Retry":                               "再試行",
+		"still not found at %s":               "%s の時点で見つかりません",
+		"all instances were found":            "すべてのインスタンスが見つかりました",
+		"Region":                              "リージョン",
+		"region %s":                           "リージョン %s",
+		"account %s, region %s":               "アカウント %s、リージョン %s",
+		"Account":                             "アカウント",
+		"Regions":                             "リージョン",
+		"Identity":                            "認証情報",
+		"Role":                                "ロール",
+		"not loaded":                          "未取得",
+		"%d cached at %s, %d hits, %d misses": "%d 件 (%s 取得)、ヒット %d 回、ミス %d 回",
+		"Too many messages to look up, some are ignored for a while.":                               "検索するメッセージが多すぎるため、しばらくの間一部を無視します。",
+		"Sorry, something went wrong while looking this up. Please try again later. (error ID: %s)": "検索中にエラーが発生しました。しばらくしてから再度お試しください。(エラー ID: %s)",
+		":warning: AWS APIs are failing, so these results are cached at %s.":                        ":warning: AWS API がエラーのため、%s 時点のキャッシュを表示しています。",
+		"truncated, %d of %d bytes":               "%[2]d バイト中 %[1]d バイトを表示しています",
+		"Upload full details":                     "詳細をすべてアップロード",
+		"you are not allowed to view the details": "詳細を表示する権限がありません",
+		"%s is not found":                         "%s が見つかりません",
+		"CloudWatch Metrics":                      "CloudWatch メトリクス",
+		"Logs: %s":                                "ログ: %s",
+		"less than a minute":                      "1 分未満",
+		"1 minute":                                "1 分",
+		"%d minutes":                              "%d 分",
+		"1 hour":                                  "1 時間",
+		"%d hours":                                "%d 時間",
+		"%d days":                                 "%d 日",
+		"launched %s ago":                         "%s 前に起動",
+		"up %s":                                   "起動から %s",
+		"%s ago":                                  "%s 前",
+		"Stopped":                                 "停止時刻",
+		"$%.4f/hour ($%.2f/month)":                "$%.4f/時間 ($%.2f/月)",
+		"On-Demand Price":                         "オンデマンド料金",
+		"Spot Price":                              "スポット料金",
+		"Network In":                              "受信",
+		"Network Out":                             "送信",
+		"Status Check":                            "ステータスチェック",
+		"avg %.1f%%, max %.1f%%":                  "平均 %.1f%%、最大 %.1f%%",
+		"failed":                                  "失敗",
+		"passed":                                  "成功",
+		"%s in total":                             "合計 %s",
+		"Metrics in the last hour":                "直近 1 時間のメトリクス",
+		"CloudWatch Alarms":                       "CloudWatch アラーム",
+		"system":                                  "システム",
+		"instance":                                "インスタンス",
+		"%d/%d checks passed":                     "%d/%d のチェックに合格",
+		"%d/%d checks failed":                     "%d/%d のチェックに失敗",
+		"initializing (%s)":                       "初期化中 (%s)",
+		"Status Checks":                           "ステータスチェック",
+		"%s from %s: %s":                          "%[2]s から %[1]s: %[3]s",
+		"Scheduled Events":                        "スケジュールされたイベント",
+		"Lifecycle":                               "ライフサイクル",
+		"on-demand":                               "オンデマンド",
+		"interruption (%s) warned at %s":          "%[2]s に中断 (%[1]s) の警告",
+		"rebalance recommended at %s":             "%s にリバランス推奨",
+		"encrypted":                               "暗号化あり",
+		"not encrypted":                           "暗号化なし",
+		"Volumes":                                 "ボリューム",
+		"Volume ID":                               "ボリューム ID",
+		"Size":                                    "サイズ",
+		"Availability Zone":                       "アベイラビリティーゾーン",
+		"Encryption":                              "暗号化",
+		"KMS Key":                                 "KMS キー",
+		"Attachment":                              "アタッチ先",
+		"Created":                                 "作成日時",
+		"Security Groups":                         "セキュリティグループ",
+		"all traffic":                             "すべてのトラフィック",
+		"from":                                    "送信元",
+		"to":                                      "送信先",
+		"none":                                    "なし",
+		"Inbound Rules":                           "インバウンドルール",
+		"Outbound Rules":                          "アウトバウンドルール",
+		"Auto Scaling Group":                      "Auto Scaling グループ",
+		"Load Balancers":                          "ロードバランサー",
+		"Listeners":                               "リスナー",
+		"Health Check":                            "ヘルスチェック",
+		"every %ds, timeout %ds, healthy after %d, unhealthy after %d": "%d 秒ごと、タイムアウト %d 秒、正常しきい値 %d、非正常しきい値 %d",
+		"Availability Zones": "アベイラビリティーゾーン",
+		"Subnets":            "サブネット",
+		"Instances":          "インスタンス",
+		"this summary has expired, look the resources up again": "この一覧は期限切れです。もう一度検索してください",
+		"PagerDuty incident %s":                                 "PagerDuty インシデント %s",
+		"Opsgenie alert %s":                                     "Opsgenie アラート %s",
+		"Resources of the alert":                                "アラートのリソース",
+		"Datadog alert %s":                                      "Datadog アラート %s",
+		"CloudWatch alarm %s":                                   "CloudWatch アラーム %s",
+		"Metric":                                                "メトリクス",
+		"Dimensions":                                            "ディメンション",
+		"Description":                                           "説明",
+		"Spot instance %s will be interrupted (%s)":             "スポットインスタンス %s は中断されます (%s)",
+		"Spot instance %s is recommended to be rebalanced":      "スポットインスタンス %s のリバランスが推奨されています",
+		"Instance %s is %s":                                     "インスタンス %s は %s です",
+		"AWS Health event %s":                                   "AWS Health イベント %s",
+		"From %s to %s":                                         "%s から %s まで",
+		"Teams: %s":                                             "チーム: %s",
+		"Deployment of %s to %s by %s":                          "%[3]s による %[1]s の %[2]s へのデプロイ",
+		"Status: %s":                                            "ステータス: %s",
+		"Grafana alert %s":                                      "Grafana アラート %s",
+		"Instance":                                              "インスタンス",
+		"An instance ID, a name:<Name tag>, an IP address or a host name.": "インスタンス ID、name:<Name タグ>、IP アドレスまたはホスト名",
+		"Output format":                     "出力形式",
+		"Owner":                             "オーナー",
+		"Notify owner":                      "オーナーに通知",
+		"%s has no owners":                  "%s にはオーナーがいません",
+		"%s, <@%s> asked you to look at %s": "%[1]s <@%[2]s> さんが %[3]s の確認を依頼しました",
+		"Who touched this?":                 "誰が操作した？",
+		"usage: trail <instance id>":        "使い方: trail <インスタンス ID>",
+		"failed to look up CloudTrail events of %s: %v": "%s の CloudTrail イベントの検索に失敗しました: %v",
+		"no API calls on %s are found in CloudTrail":    "CloudTrail に %s への API 呼び出しはありません",
+		"by %s":                                 "%s による",
+		"from %s":                               "%s から",
+		"API calls on %s":                       "%s への API 呼び出し",
+		"Last %s of CloudTrail":                 "CloudTrail の直近 %s",
+		"SSM inventory":                         "SSM インベントリ",
+		"usage: inventory <instance id>":        "使い方: inventory <インスタンス ID>",
+		"%s is not managed by Systems Manager":  "%s は Systems Manager で管理されていません",
+		"failed to get SSM inventory of %s: %v": "%s の SSM インベントリの取得に失敗しました: %v",
+		"(update available)":                    "(更新あり)",
+		"SSM inventory of %s":                   "%s の SSM インベントリ",
+		"Platform":                              "プラットフォーム",
+		"Computer Name":                         "コンピューター名",
+		"Last Ping":                             "最終 Ping",
+		"Patch compliance":                      "パッチコンプライアンス",
+		"Installed":                             "インストール済み",
+		"Pending Reboot":                        "再起動待ち",
+		"Missing":                               "未適用",
+		"Failed":                                "失敗",
+		"%s at %s":                              "%[2]s の %[1]s",
+		"AMI":                                   "AMI",
+		"deregistered":                          "登録解除済み",
+		"created %s ago":                        "%s 前に作成",
+		"%s is open to the world by %s":         "%s が %s で全世界に公開されています",
+		"has a public IP address in the public subnet %s": "パブリックサブネット %s でパブリック IP アドレスを持っています",
+		"Security warnings": "セキュリティ警告",
+		"Export CSV":        "CSV でエクスポート",
+		"usage: export [csv|tsv] <key>:<value>[,<value>...]...":                            "使い方: export [csv|tsv] <キー>:<値>[,<値>...]...",
+		"(truncated to the first %d)":                                                      "(先頭 %d 件まで)",
+		"Instances matching %s":                                                            "%s に一致するインスタンス",
+		"%s is in the subnet %s of the VPC %s":                                             "%s は VPC %[3]s のサブネット %[2]s にあります",
+		"%s is in the VPC %s but in none of its subnets":                                   "%s は VPC %s にありますが、どのサブネットにも含まれません",
+		"Create a snapshot of %s?":                                                         "%s のスナップショットを作成しますか？",
+		"The file systems on the volume may be inconsistent unless its writes are paused.": "書き込みを停止しない限り、ボリューム上のファイルシステムの整合性は保証されません。",
+	},
+}
+
+func init() {
+	if language == "" || language == "en" {
+		return
+	}
+	if _, ok := translations[language]; !ok {
+		logWarn("unsupported $BOT_LANGUAGE, use default 'en'", logFields{"language": language})
+		language = "en"
+	}
+}
+
+// tr translates the English message into the configured language and
+// formats it with args.
+func tr(message string, args ...interface{}) string {
+	if t, ok := translations[language][message]; ok {
+		message = t
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/bgpat/ec2bot/internal/cache"
+)
+
+// EventStore remembers the IDs of the events being processed, so that an
+// event is processed once even if Slack retries it or several replicas of
+// the bot receive it.
+type EventStore interface {
+	// Claim records the ID, which expires after ttl. It reports false if
+	// the ID is recorded already.
+	Claim(id string, ttl time.Duration) (bool, error)
+	// Release forgets the ID, so that the next retry is processed.
+	Release(id string) error
+}
+
+var (
+	// eventStore is the store configured by $EVENT_STORE, which is
+	// "memory", a Redis URL such as "redis://:password@localhost:6379/0"
+	// or a DynamoDB table such as "dynamodb://ec2bot-events". The replicas
+	// have to share a Redis or DynamoDB store.
+	eventStore EventStore = newMemoryEventStore()
+	// eventStoreTTL is how long the IDs are remembered, $EVENT_STORE_TTL.
+	eventStoreTTL = time.Hour
+)
+
+// eventKeyPrefix is prepended to the IDs in the shared stores.
+const eventKeyPrefix = "ec2bot:event:"
+
+func init() {
+	s, err := newEventStore(getenv("EVENT_STORE"))
+	if err != nil {
+		logError("cannot parse $EVENT_STORE, use default 'memory'", err, nil)
+	} else if s != nil {
+		eventStore = s
+	}
+	if v := getenv("EVENT_STORE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			logWarn("cannot parse $EVENT_STORE_TTL, use default '1h'", nil)
+		} else {
+			eventStoreTTL = d
+		}
+	}
+}
+
+func newEventStore(s string) (EventStore, error) {
+	if s == "" || s == "memory" {
+		return nil, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "redis":
+		return newRedisBackend(u)
+	case "dynamodb":
+		if u.Host == "" {
+			return nil, fmt.Errorf("missing table of %q", s)
+		}
+		return &DynamoDBEventStore{Table: u.Host}, nil
+	}
+	return nil, fmt.Errorf("unknown event store %q", u.Scheme)
+}
+
+// memoryEventStore keeps the IDs in memory, which is enough for a single
+// replica.
+type memoryEventStore struct {
+	mu  sync.Mutex
+	ids *cache.LRU
+}
+
+func newMemoryEventStore() *memoryEventStore {
+	return &memoryEventStore{ids: cache.NewLRU(10000, time.Hour)}
+}
+
+func (s *memoryEventStore) Claim(id string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids.SetTTL(ttl)
+	if _, ok := s.ids.Get(id); ok {
+		return false, nil
+	}
+	s.ids.Add(id, true)
+	return true, nil
+}
+
+func (s *memoryEventStore) Release(id string) error {
+	s.ids.Remove(id)
+	return nil
+}
+
+// Claim sets the key only if it does not exist.
+func (r *RedisBackend) Claim(id string, ttl time.Duration) (bool, error) {
+	ms := strconv.FormatInt(int64(ttl/time.Millisecond), 10)
+	v, err := r.do("SET", eventKeyPrefix+id, "1", "NX", "PX", ms)
+	if err != nil {
+		return false, err
+	}
+	return v != nil, nil
+}
+
+func (r *RedisBackend) Release(id string) error {
+	_, err := r.do("DEL", eventKeyPrefix+id)
+	return err
+}
+
+// DynamoDBEventStore keeps the IDs in a table whose partition key is "id".
+// The IDs expire by the TTL of the table on the "expires" attribute, which
+// may delete them late, so expired items are overwritten as well.
+type DynamoDBEventStore struct {
+	Table string
+}
+
+func (s *DynamoDBEventStore) Claim(id string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	_, err := dynamoDBClient.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.Table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":      {S: aws.String(eventKeyPrefix + id)},
+			"expires": {N: aws.String(strconv.FormatInt(now.Add(ttl).Unix(), 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(id) OR expires < :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *DynamoDBEventStore) Release(id string) error {
+	_, err := dynamoDBClient.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(s.Table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(eventKeyPrefix + id)},
+		},
+	})
+	return err
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/labstack/echo"
+	"github.com/nlopes/slack"
+)
+
+// Identity is the runtime context of the bot reported by "whoami" and
+// /info, so that operators can tell which environment a bot serves.
+type Identity struct {
+	Account      string                    `json:"account"`
+	AccountAlias string                    `json:"account_alias,omitempty"`
+	ARN          string                    `json:"arn"`
+	Role         string                    `json:"role,omitempty"`
+	Regions      []string                  `json:"regions"`
+	Caches       map[string]*CacheIdentity `json:"caches"`
+	Build        *BuildInfo                `json:"build"`
+}
+
+// CacheIdentity is the state of a cache in the identity.
+type CacheIdentity struct {
+	Size      int       `json:"size"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Hits      uint64    `json:"hits"`
+	Misses    uint64    `json:"misses"`
+}
+
+func init() {
+	mentionCommands["whoami"] = (*Event).whoami
+}
+
+// identity describes the credentials of the bot and the state of the
+// caches.
+func identity() (*Identity, error) {
+	resp, err := stsClient.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, err
+	}
+	id := &Identity{
+		Account: aws.StringValue(resp.Account),
+		ARN:     aws.StringValue(resp.Arn),
+		Role:    roleName(aws.StringValue(resp.Arn)),
+		Regions: lookupRegions(),
+		Caches:  make(map[string]*CacheIdentity),
+		Build:   buildInfo(),
+	}
+	if account, alias := callerAccount(); account == id.Account {
+		id.AccountAlias = alias
+	}
+
+	cacheMu.RLock()
+	instances := &CacheIdentity{UpdatedAt: instanceCache.UpdatedAt}
+	if instanceCache.Instances != nil {
+		for _, r := range instanceCache.Instances.Reservations {
+			instances.Size += len(r.Instances)
+		}
+	}
+	loadBalancers := &CacheIdentity{UpdatedAt: loadBalancerCache.UpdatedAt}
+	if loadBalancerCache.LoadBalancers != nil {
+		loadBalancers.Size = len(loadBalancerCache.LoadBalancers.LoadBalancerDescriptions)
+	}
+	loadBalancersV2 := &CacheIdentity{
+		UpdatedAt: loadBalancerV2Cache.UpdatedAt,
+		Size:      len(loadBalancerV2Cache.LoadBalancers),
+	}
+	cacheMu.RUnlock()
+	id.Caches["instances"] = instances
+	id.Caches["loadbalancers"] = loadBalancers
+	id.Caches["loadbalancers_v2"] = loadBalancersV2
+
+	cacheStatsMu.Lock()
+	for name, c := range id.Caches {
+		if s, ok := cacheStatsOf[name]; ok {
+			c.Hits, c.Misses = s.Hits, s.Misses
+		}
+	}
+	cacheStatsMu.Unlock()
+	return id, nil
+}
+
+// roleName returns the name of the role of an assumed role ARN such as
+// "arn:aws:sts::123456789012:assumed-role/ec2bot/i-0123", or an empty
+// string.
+func roleName(arn string) string {
+	i := strings.Index(arn, ":assumed-role/")
+	if i < 0 {
+		return ""
+	}
+	return strings.SplitN(arn[i+len(":assumed-role/"):], "/", 2)[0]
+}
+
+// whoami posts the identity of the bot.
+func (ev *Event) whoami(args []string) error {
+	id, err := identity()
+	if err != nil {
+		return err
+	}
+	account := id.Account
+	if id.AccountAlias != "" {
+		account = id.AccountAlias + " (" + id.Account + ")"
+	}
+	fields := []slack.AttachmentField{
+		{Title: tr("Account"), Value: account, Short: true},
+		{Title: tr("Regions"), Value: strings.Join(id.Regions, ", "), Short: true},
+		{Title: tr("Identity"), Value: code(id.ARN)},
+	}
+	if id.Role != "" {
+		fields = append(fields, slack.AttachmentField{Title: tr("Role"), Value: id.Role, Short: true})
+	}
+	for _, name := range []string{"instances", "loadbalancers", "loadbalancers_v2"} {
+		c := id.Caches[name]
+		value := tr("not loaded")
+		if !c.UpdatedAt.IsZero() {
+			value = tr("%d cached at %s, %d hits, %d misses", c.Size, ev.formatTime(c.UpdatedAt), c.Hits, c.Misses)
+		}
+		fields = append(fields, slack.AttachmentField{Title: fmt.Sprintf("cache: %s", name), Value: value})
+	}
+	return ev.post("", []slack.Attachment{{
+		Title:  id.Build.String(),
+		Fields: fields,
+	}})
+}
+
+// handleInfo responds the identity of the bot.
+func handleInfo(c echo.Context) error {
+	id, err := identity()
+	if err != nil {
+		logError("failed to describe the identity", err, nil)
+		return err
+	}
+	return c.JSON(http.StatusOK, id)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/nlopes/slack"
+)
+
+var volumeIDPattern = regexp.MustCompile(`\bvol-[0-9a-f]{8,17}\b`)
+
+// maxDescriptionLength is the limit of the description of images and
+// snapshots.
+const maxDescriptionLength = 255
+
+func init() {
+	mentionCommands["snapshot"] = (*Event).snapshot
+}
+
+// snapshotButton creates an image of the instance or a snapshot of the
+// volume of the ID.
+func snapshotButton(id string) slack.AttachmentAction {
+	confirm := &slack.ConfirmationField{
+		Title:       tr("Create an image of %s?", id),
+		Text:        tr("The instance will not be rebooted, so the file systems may be inconsistent."),
+		OkText:      tr("Snapshot"),
+		DismissText: tr("Cancel"),
+	}
+	if strings.HasPrefix(id, "vol-") {
+		confirm.Title = tr("Create a snapshot of %s?", id)
+		confirm.Text = tr("The file systems on the volume may be inconsistent unless its writes are paused.")
+	}
+	return slack.AttachmentAction{
+		Name:    "snapshot",
+		Text:    tr("Snapshot"),
+		Type:    "button",
+		Value:   id,
+		Confirm: confirm,
+	}
+}
+
+// snapshot handles "@ec2bot snapshot i-xxx vol-xxx" by creating an image of
+// each instance and a snapshot of each volume.
+func (ev *Event) snapshot(args []string) error {
+	text := strings.Join(args, " ")
+	ids := append(hostIDPattern.FindAllString(text, -1), volumeIDPattern.FindAllString(text, -1)...)
+	if len(ids) == 0 {
+		return errors.New(tr("usage: snapshot <instance id|volume id>"))
+	}
+	for _, id := range ids {
+		if err := authorizeAction(ev.TeamID, ev.Event.User, "snapshot", id); err != nil {
+			ev.post(err.Error(), nil)
+			continue
+		}
+		if err := ev.createSnapshot(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createSnapshot creates an image of the instance or a snapshot of the
+// volume and posts its ID to the thread.
+func (ev *Event) createSnapshot(id string) error {
+	thread := ev.Event.ThreadTimestamp
+	if thread == "" {
+		thread = ev.Event.Timestamp
+	}
+	description := fmt.Sprintf(
+		"Requested by Slack user %s in %s",
+		ev.Event.User,
+		permalink(ev.TeamID, ev.Event.Channel, thread),
+	)
+	if len(description) > maxDescriptionLength {
+		description = description[:maxDescriptionLength]
+	}
+
+	var (
+		created string
+		err     error
+	)
+	if strings.HasPrefix(id, "vol-") {
+		created, err = createVolumeSnapshot(id, description)
+	} else {
+		created, err = createImage(id, description)
+	}
+	ev.audit("snapshot", id, err)
+	if err != nil {
+		return ev.post(tr("failed to snapshot %s: %v", id, err), nil)
+	}
+	return ev.post(tr("%s is being created from %s (requested by <@%s>)", code(created), code(id), ev.Event.User), nil)
+}
+
+func createImage(id, description string) (string, error) {
+	svc := regionEC2Client(instanceRegion(id))
+	resp, err := svc.CreateImage(&ec2.CreateImageInput{
+		InstanceId:  aws.String(id),
+		Name:        aws.String(id + "-" + time.Now().UTC().Format("20060102-150405")),
+		Description: aws.String(description),
+		NoReboot:    aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(resp.ImageId), nil
+}
+
+func createVolumeSnapshot(id, description string) (string, error) {
+	svc := regionEC2Client(volumeRegion(id))
+	resp, err := svc.CreateSnapshot(&ec2.CreateSnapshotInput{
+		VolumeId:    aws.String(id),
+		Description: aws.String(description),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(resp.SnapshotId), nil
+}
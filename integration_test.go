@@ -0,0 +1,140 @@
+//go:build integration
+// +build integration
+
+package main
+
+// The integration tests run the resolvers, the caches and the handlers
+// against LocalStack:
+//
+//	docker run -d -p 4566:4566 localstack/localstack
+//	AWS_ENDPOINT=http://localhost:4566 AWS_S3_FORCE_PATH_STYLE=true \
+//	AWS_REGION=us-east-1 AWS_ACCESS_KEY_ID=test AWS_SECRET_ACCESS_KEY=test \
+//	go test -tags integration -run Integration .
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/labstack/echo"
+)
+
+// localStack creates an instance and a Classic ELB in LocalStack, and
+// returns them with a function deleting them. It skips the test unless
+// $AWS_ENDPOINT is set.
+func localStack(t *testing.T) (*ec2.Instance, *elb.LoadBalancerDescription, func()) {
+	if getenv("AWS_ENDPOINT") == "" {
+		t.Skip("$AWS_ENDPOINT is not set")
+	}
+	images, err := ec2Client.DescribeImages(&ec2.DescribeImagesInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images.Images) == 0 {
+		t.Skip("LocalStack has no images")
+	}
+	reservation, err := ec2Client.RunInstances(&ec2.RunInstancesInput{
+		ImageId:      images.Images[0].ImageId,
+		InstanceType: aws.String(ec2.InstanceTypeT2Micro),
+		MinCount:     aws.Int64(1),
+		MaxCount:     aws.Int64(1),
+		TagSpecifications: []*ec2.TagSpecification{{
+			ResourceType: aws.String(ec2.ResourceTypeInstance),
+			Tags:         []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("ec2bot-integration")}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance := reservation.Instances[0]
+
+	name := "ec2bot-it-" + strings.TrimPrefix(aws.StringValue(instance.InstanceId), "i-")[:8]
+	created, err := elbClient.CreateLoadBalancer(&elb.CreateLoadBalancerInput{
+		LoadBalancerName:  aws.String(name),
+		AvailabilityZones: aws.StringSlice([]string{awsRegion() + "a"}),
+		Listeners: []*elb.Listener{{
+			Protocol:         aws.String("HTTP"),
+			LoadBalancerPort: aws.Int64(80),
+			InstancePort:     aws.Int64(80),
+		}},
+	})
+	if err != nil {
+		ec2Client.TerminateInstances(&ec2.TerminateInstancesInput{InstanceIds: []*string{instance.InstanceId}})
+		t.Fatal(err)
+	}
+	lb := &elb.LoadBalancerDescription{LoadBalancerName: aws.String(name), DNSName: created.DNSName}
+
+	// the caches are loaded again with the new resources
+	cacheMu.Lock()
+	instanceCache.UpdatedAt = time.Time{}
+	loadBalancerCache.UpdatedAt = time.Time{}
+	cacheMu.Unlock()
+	return instance, lb, func() {
+		elbClient.DeleteLoadBalancer(&elb.DeleteLoadBalancerInput{LoadBalancerName: aws.String(name)})
+		ec2Client.TerminateInstances(&ec2.TerminateInstancesInput{InstanceIds: []*string{instance.InstanceId}})
+	}
+}
+
+func TestIntegrationLookup(t *testing.T) {
+	instance, lb, cleanup := localStack(t)
+	defer cleanup()
+	id := aws.StringValue(instance.InstanceId)
+
+	results, err := cliLookup(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Instances) != 1 || aws.StringValue(results[0].Instances[0].InstanceId) != id {
+		t.Errorf("cliLookup(%s) = %v", id, results)
+	}
+
+	if ip := aws.StringValue(instance.PrivateIpAddress); ip != "" {
+		found, err := getInstance(ip)
+		if err != nil || found == nil || aws.StringValue(found.InstanceId) != id {
+			t.Errorf("getInstance(%s) = %v, %v, want %s", ip, found, err, id)
+		}
+	}
+	if region := instanceRegion(id); region != awsRegion() {
+		t.Errorf("instanceRegion(%s) = %q, want %q", id, region, awsRegion())
+	}
+
+	found, err := getLoadBalancer(aws.StringValue(lb.DNSName))
+	if err != nil || found == nil || aws.StringValue(found.LoadBalancerName) != aws.StringValue(lb.LoadBalancerName) {
+		t.Errorf("getLoadBalancer(%s) = %v, %v", aws.StringValue(lb.DNSName), found, err)
+	}
+}
+
+func TestIntegrationHandlers(t *testing.T) {
+	instance, _, cleanup := localStack(t)
+	defer cleanup()
+	if _, err := describeInstances(); err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	if err := handleMetrics(e.NewContext(httptest.NewRequest(http.MethodGet, "/metrics", nil), rec)); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "fleet_instances{") {
+		t.Errorf("/metrics = %d %q, want the instance of %s", rec.Code, rec.Body.String(), aws.StringValue(instance.InstanceId))
+	}
+
+	rec = httptest.NewRecorder()
+	if err := handleHealthz(e.NewContext(httptest.NewRequest(http.MethodGet, "/healthz", nil), rec)); err != nil {
+		t.Fatal(err)
+	}
+	var h Health
+	if err := json.Unmarshal(rec.Body.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	if check := h.Checks["aws"]; check == nil || !check.OK {
+		t.Errorf("aws health check = %+v", check)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/labstack/echo"
+	"github.com/nlopes/slack"
+)
+
+// ActionCallback is sent by Slack when a user clicks a button on one of
+// the bot's messages.
+type ActionCallback struct {
+	Type       string `json:"type"`
+	CallbackID string `json:"callback_id"`
+	Team       struct {
+		ID     string `json:"id"`
+		Domain string `json:"domain"`
+	} `json:"team"`
+	Channel struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channel"`
+	User struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"user"`
+	Actions         []slack.AttachmentAction `json:"actions"`
+	ActionTs        string                   `json:"action_ts"`
+	MessageTs       string                   `json:"message_ts"`
+	AttachmentID    string                   `json:"attachment_id"`
+	Token           string                   `json:"token"`
+	OriginalMessage *slack.Msg               `json:"original_message"`
+	ResponseURL     string                   `json:"response_url"`
+	TriggerID       string                   `json:"trigger_id"`
+	Submission      map[string]string        `json:"submission"`
+	State           string                   `json:"state"`
+	WorkflowStep    *WorkflowStep            `json:"workflow_step"`
+	View            *View                    `json:"view"`
+}
+
+// ActionResponse is an additional message sent in response to an action.
+type ActionResponse struct {
+	ResponseType    string `json:"response_type,omitempty"`
+	ReplaceOriginal bool   `json:"replace_original"`
+	Text            string `json:"text"`
+}
+
+// actionHandlers handle button clicks by the callback ID of the attachment.
+// A non-nil message replaces the original message.
+var actionHandlers = map[string]func(cb *ActionCallback, action slack.AttachmentAction) (*slack.Msg, error){
+	"expand": handleExpand,
+}
+
+// dialogHandlers handle dialog submissions by the callback ID of the
+// dialog. Returned errors are shown next to the invalid elements.
+var dialogHandlers = map[string]func(cb *ActionCallback) []DialogError{}
+
+func handleAction(c echo.Context) error {
+	cb := new(ActionCallback)
+	if err := json.Unmarshal([]byte(c.FormValue("payload")), cb); err != nil {
+		logError("failed to decode action payload", err, nil)
+		return c.String(http.StatusBadRequest, "invalid payload")
+	}
+
+	if cb.Token != slackVerifyToken {
+		logWarn("failed to verify token", logFields{"team_id": cb.Team.ID})
+		return c.String(http.StatusUnauthorized, "failed to verify token")
+	}
+
+	if cb.Type == "workflow_step_edit" || cb.Type == "view_submission" {
+		return handleWorkflowStep(c, cb)
+	}
+
+	if cb.Type == "dialog_submission" {
+		handler, ok := dialogHandlers[cb.CallbackID]
+		if !ok {
+			return c.String(http.StatusBadRequest, "unknown dialog")
+		}
+		if errs := handler(cb); len(errs) > 0 {
+			return c.JSON(http.StatusOK, map[string][]DialogError{"errors": errs})
+		}
+		return c.NoContent(http.StatusOK)
+	}
+
+	handler, ok := actionHandlers[cb.CallbackID]
+	if !ok || len(cb.Actions) == 0 {
+		return c.String(http.StatusBadRequest, "unknown action")
+	}
+	msg, err := handler(cb, cb.Actions[0])
+	if err != nil {
+		logError("failed to handle action", err, logFields{"callback_id": cb.CallbackID, "channel": cb.Channel.ID, "user": cb.User.ID})
+		return c.JSON(http.StatusOK, &ActionResponse{
+			ResponseType:    "ephemeral",
+			ReplaceOriginal: false,
+			Text:            err.Error(),
+		})
+	}
+	if msg != nil {
+		return c.JSON(http.StatusOK, msg)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// event returns an event replying in the thread of the clicked message on
+// behalf of the user who clicked.
+func (cb *ActionCallback) event() *Event {
+	thread := cb.MessageTs
+	if cb.OriginalMessage != nil && cb.OriginalMessage.ThreadTimestamp != "" {
+		thread = cb.OriginalMessage.ThreadTimestamp
+	}
+	return &Event{
+		TeamID: cb.Team.ID,
+		Event: &slack.Msg{
+			Channel:   cb.Channel.ID,
+			User:      cb.User.ID,
+			Timestamp: thread,
+		},
+	}
+}
@@ -0,0 +1,30 @@
+package awsclients
+
+import (
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+// InstanceDescriber is the subset of the EC2 API used to look up
+// instances. It is implemented by ec2.EC2 and FakeEC2.
+type InstanceDescriber interface {
+	DescribeInstances(*ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error)
+	DescribeInstancesPages(*ec2.DescribeInstancesInput, func(*ec2.DescribeInstancesOutput, bool) bool) error
+}
+
+// LoadBalancerDescriber is the subset of the ELB API used to look up
+// Classic ELBs. It is implemented by elb.ELB and FakeELB.
+type LoadBalancerDescriber interface {
+	DescribeLoadBalancers(*elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error)
+	DescribeLoadBalancersPages(*elb.DescribeLoadBalancersInput, func(*elb.DescribeLoadBalancersOutput, bool) bool) error
+	DescribeTags(*elb.DescribeTagsInput) (*elb.DescribeTagsOutput, error)
+}
+
+// LoadBalancerV2Describer is the subset of the ELBv2 API used to look up
+// ALBs and NLBs. It is implemented by elbv2.ELBV2 and FakeELBV2.
+type LoadBalancerV2Describer interface {
+	DescribeLoadBalancers(*elbv2.DescribeLoadBalancersInput) (*elbv2.DescribeLoadBalancersOutput, error)
+	DescribeLoadBalancersPages(*elbv2.DescribeLoadBalancersInput, func(*elbv2.DescribeLoadBalancersOutput, bool) bool) error
+	DescribeTags(*elbv2.DescribeTagsInput) (*elbv2.DescribeTagsOutput, error)
+}
@@ -0,0 +1,281 @@
+package awsclients
+
+import (
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+// FakeEC2 describes the instances of Reservations in pages of PageSize
+// reservations, or in a single page if it is zero. The filters used by the
+// bot are supported, with the wildcards of the API.
+type FakeEC2 struct {
+	Reservations []*ec2.Reservation
+	PageSize     int
+	// Calls is the number of calls, which is useful to check the caches.
+	Calls int64
+}
+
+// DescribeInstances returns a page of the reservations of the matching
+// instances.
+func (f *FakeEC2) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	atomic.AddInt64(&f.Calls, 1)
+	var reservations []*ec2.Reservation
+	for _, r := range f.Reservations {
+		var instances []*ec2.Instance
+		for _, instance := range r.Instances {
+			if instanceSelected(instance, input) {
+				instances = append(instances, instance)
+			}
+		}
+		if len(instances) > 0 {
+			reservations = append(reservations, &ec2.Reservation{
+				ReservationId: r.ReservationId,
+				OwnerId:       r.OwnerId,
+				Instances:     instances,
+			})
+		}
+	}
+	start, end, next, err := page(aws.StringValue(input.NextToken), len(reservations), f.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: reservations[start:end], NextToken: next}, nil
+}
+
+// DescribeInstancesPages calls fn with each page of DescribeInstances.
+func (f *FakeEC2) DescribeInstancesPages(input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool) error {
+	if input == nil {
+		input = new(ec2.DescribeInstancesInput)
+	}
+	in := *input
+	for {
+		out, err := f.DescribeInstances(&in)
+		if err != nil {
+			return err
+		}
+		last := out.NextToken == nil
+		if !fn(out, last) || last {
+			return nil
+		}
+		in.NextToken = out.NextToken
+	}
+}
+
+func instanceSelected(instance *ec2.Instance, input *ec2.DescribeInstancesInput) bool {
+	if len(input.InstanceIds) > 0 && !matchAny(aws.StringValueSlice(input.InstanceIds), aws.StringValue(instance.InstanceId)) {
+		return false
+	}
+	for _, filter := range input.Filters {
+		patterns := aws.StringValueSlice(filter.Values)
+		ok := false
+		for _, v := range instanceFilterValues(instance, aws.StringValue(filter.Name)) {
+			if matchAny(patterns, v) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// instanceFilterValues returns the values of the instance compared with
+// the values of the filter.
+func instanceFilterValues(instance *ec2.Instance, name string) []string {
+	switch name {
+	case "instance-id":
+		return []string{aws.StringValue(instance.InstanceId)}
+	case "private-ip-address":
+		return []string{aws.StringValue(instance.PrivateIpAddress)}
+	case "private-dns-name":
+		return []string{aws.StringValue(instance.PrivateDnsName)}
+	case "ip-address":
+		return []string{aws.StringValue(instance.PublicIpAddress)}
+	case "dns-name":
+		return []string{aws.StringValue(instance.PublicDnsName)}
+	case "instance-state-name":
+		if instance.State != nil {
+			return []string{aws.StringValue(instance.State.Name)}
+		}
+	case "tag-key":
+		var keys []string
+		for _, tag := range instance.Tags {
+			keys = append(keys, aws.StringValue(tag.Key))
+		}
+		return keys
+	}
+	if strings.HasPrefix(name, "tag:") {
+		for _, tag := range instance.Tags {
+			if aws.StringValue(tag.Key) == name[len("tag:"):] {
+				return []string{aws.StringValue(tag.Value)}
+			}
+		}
+	}
+	return nil
+}
+
+// FakeELB describes the Classic ELBs of LoadBalancers and their Tags by
+// name in pages of PageSize load balancers.
+type FakeELB struct {
+	LoadBalancers []*elb.LoadBalancerDescription
+	Tags          map[string][]*elb.Tag
+	PageSize      int
+	Calls         int64
+}
+
+// DescribeLoadBalancers returns a page of the load balancers of the names.
+func (f *FakeELB) DescribeLoadBalancers(input *elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error) {
+	atomic.AddInt64(&f.Calls, 1)
+	names := aws.StringValueSlice(input.LoadBalancerNames)
+	var lbs []*elb.LoadBalancerDescription
+	for _, lb := range f.LoadBalancers {
+		if len(names) == 0 || matchAny(names, aws.StringValue(lb.LoadBalancerName)) {
+			lbs = append(lbs, lb)
+		}
+	}
+	if len(lbs) < len(names) {
+		return nil, awserr.New(elb.ErrCodeAccessPointNotFoundException, "There is no ACTIVE Load Balancer named '"+strings.Join(names, ",")+"'", nil)
+	}
+	start, end, next, err := page(aws.StringValue(input.Marker), len(lbs), f.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	return &elb.DescribeLoadBalancersOutput{LoadBalancerDescriptions: lbs[start:end], NextMarker: next}, nil
+}
+
+// DescribeLoadBalancersPages calls fn with each page of
+// DescribeLoadBalancers.
+func (f *FakeELB) DescribeLoadBalancersPages(input *elb.DescribeLoadBalancersInput, fn func(*elb.DescribeLoadBalancersOutput, bool) bool) error {
+	if input == nil {
+		input = new(elb.DescribeLoadBalancersInput)
+	}
+	in := *input
+	for {
+		out, err := f.DescribeLoadBalancers(&in)
+		if err != nil {
+			return err
+		}
+		last := out.NextMarker == nil
+		if !fn(out, last) || last {
+			return nil
+		}
+		in.Marker = out.NextMarker
+	}
+}
+
+// DescribeTags returns the tags of the load balancers.
+func (f *FakeELB) DescribeTags(input *elb.DescribeTagsInput) (*elb.DescribeTagsOutput, error) {
+	atomic.AddInt64(&f.Calls, 1)
+	out := new(elb.DescribeTagsOutput)
+	for _, name := range input.LoadBalancerNames {
+		out.TagDescriptions = append(out.TagDescriptions, &elb.TagDescription{
+			LoadBalancerName: name,
+			Tags:             f.Tags[aws.StringValue(name)],
+		})
+	}
+	return out, nil
+}
+
+// FakeELBV2 describes the ALBs and NLBs of LoadBalancers and their Tags by
+// ARN in pages of PageSize load balancers.
+type FakeELBV2 struct {
+	LoadBalancers []*elbv2.LoadBalancer
+	Tags          map[string][]*elbv2.Tag
+	PageSize      int
+	Calls         int64
+}
+
+// DescribeLoadBalancers returns a page of the load balancers of the ARNs or
+// names.
+func (f *FakeELBV2) DescribeLoadBalancers(input *elbv2.DescribeLoadBalancersInput) (*elbv2.DescribeLoadBalancersOutput, error) {
+	atomic.AddInt64(&f.Calls, 1)
+	arns := aws.StringValueSlice(input.LoadBalancerArns)
+	names := aws.StringValueSlice(input.Names)
+	var lbs []*elbv2.LoadBalancer
+	for _, lb := range f.LoadBalancers {
+		switch {
+		case len(arns) > 0 && !matchAny(arns, aws.StringValue(lb.LoadBalancerArn)):
+		case len(names) > 0 && !matchAny(names, aws.StringValue(lb.LoadBalancerName)):
+		default:
+			lbs = append(lbs, lb)
+		}
+	}
+	if len(lbs) < len(arns)+len(names) {
+		return nil, awserr.New(elbv2.ErrCodeLoadBalancerNotFoundException, "One or more load balancers not found", nil)
+	}
+	start, end, next, err := page(aws.StringValue(input.Marker), len(lbs), f.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	return &elbv2.DescribeLoadBalancersOutput{LoadBalancers: lbs[start:end], NextMarker: next}, nil
+}
+
+// DescribeLoadBalancersPages calls fn with each page of
+// DescribeLoadBalancers.
+func (f *FakeELBV2) DescribeLoadBalancersPages(input *elbv2.DescribeLoadBalancersInput, fn func(*elbv2.DescribeLoadBalancersOutput, bool) bool) error {
+	if input == nil {
+		input = new(elbv2.DescribeLoadBalancersInput)
+	}
+	in := *input
+	for {
+		out, err := f.DescribeLoadBalancers(&in)
+		if err != nil {
+			return err
+		}
+		last := out.NextMarker == nil
+		if !fn(out, last) || last {
+			return nil
+		}
+		in.Marker = out.NextMarker
+	}
+}
+
+// DescribeTags returns the tags of the load balancers.
+func (f *FakeELBV2) DescribeTags(input *elbv2.DescribeTagsInput) (*elbv2.DescribeTagsOutput, error) {
+	atomic.AddInt64(&f.Calls, 1)
+	out := new(elbv2.DescribeTagsOutput)
+	for _, arn := range input.ResourceArns {
+		out.TagDescriptions = append(out.TagDescriptions, &elbv2.TagDescription{
+			ResourceArn: arn,
+			Tags:        f.Tags[aws.StringValue(arn)],
+		})
+	}
+	return out, nil
+}
+
+// page returns the range of the page starting at the token and the token
+// of the next page, which is nil for the last page.
+func page(token string, n, size int) (int, int, *string, error) {
+	start := 0
+	if token != "" {
+		var err error
+		if start, err = strconv.Atoi(token); err != nil || start > n {
+			return 0, 0, nil, awserr.New("InvalidParameterValue", "invalid token "+token, nil)
+		}
+	}
+	if size <= 0 || start+size >= n {
+		return start, n, nil, nil
+	}
+	return start, start + size, aws.String(strconv.Itoa(start + size)), nil
+}
+
+// matchAny reports whether the value matches any of the patterns, in which
+// "*" and "?" are wildcards.
+func matchAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
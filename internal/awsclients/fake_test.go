@@ -0,0 +1,158 @@
+package awsclients
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+func testInstance(id, ip, state, name string) *ec2.Instance {
+	return &ec2.Instance{
+		InstanceId:       aws.String(id),
+		PrivateIpAddress: aws.String(ip),
+		State:            &ec2.InstanceState{Name: aws.String(state)},
+		Tags:             []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String(name)}},
+	}
+}
+
+func describedIDs(f *FakeEC2, input *ec2.DescribeInstancesInput) ([]string, int, error) {
+	var ids []string
+	pages := 0
+	err := f.DescribeInstancesPages(input, func(out *ec2.DescribeInstancesOutput, last bool) bool {
+		pages++
+		for _, r := range out.Reservations {
+			for _, instance := range r.Instances {
+				ids = append(ids, aws.StringValue(instance.InstanceId))
+			}
+		}
+		return true
+	})
+	return ids, pages, err
+}
+
+func TestFakeEC2Pagination(t *testing.T) {
+	f := &FakeEC2{PageSize: 2}
+	for _, id := range []string{"i-1", "i-2", "i-3", "i-4", "i-5"} {
+		f.Reservations = append(f.Reservations, &ec2.Reservation{
+			Instances: []*ec2.Instance{testInstance(id, "10.0.0.1", "running", id)},
+		})
+	}
+	ids, pages, err := describedIDs(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"i-1", "i-2", "i-3", "i-4", "i-5"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+	if pages != 3 || f.Calls != 3 {
+		t.Errorf("got %d pages in %d calls, want 3", pages, f.Calls)
+	}
+
+	out, err := f.DescribeInstances(&ec2.DescribeInstancesInput{NextToken: aws.String("4")})
+	if err != nil || len(out.Reservations) != 1 || out.NextToken != nil {
+		t.Errorf("last page = %v, %v", out, err)
+	}
+	if _, err := f.DescribeInstances(&ec2.DescribeInstancesInput{NextToken: aws.String("x")}); err == nil {
+		t.Error("expected an error of an invalid token")
+	}
+}
+
+func TestFakeEC2Filters(t *testing.T) {
+	f := &FakeEC2{
+		Reservations: []*ec2.Reservation{
+			{Instances: []*ec2.Instance{
+				testInstance("i-1", "10.0.0.1", "running", "web-1"),
+				testInstance("i-2", "10.0.0.2", "stopped", "web-2"),
+			}},
+			{Instances: []*ec2.Instance{
+				testInstance("i-3", "10.0.1.3", "running", "db-1"),
+			}},
+		},
+	}
+	filter := func(name string, values ...string) *ec2.Filter {
+		return &ec2.Filter{Name: aws.String(name), Values: aws.StringSlice(values)}
+	}
+	tests := []struct {
+		input *ec2.DescribeInstancesInput
+		want  []string
+	}{
+		{&ec2.DescribeInstancesInput{InstanceIds: aws.StringSlice([]string{"i-2", "i-3"})}, []string{"i-2", "i-3"}},
+		{&ec2.DescribeInstancesInput{Filters: []*ec2.Filter{filter("private-ip-address", "10.0.0.*")}}, []string{"i-1", "i-2"}},
+		{&ec2.DescribeInstancesInput{Filters: []*ec2.Filter{filter("instance-state-name", "running")}}, []string{"i-1", "i-3"}},
+		{&ec2.DescribeInstancesInput{Filters: []*ec2.Filter{filter("tag:Name", "web-?")}}, []string{"i-1", "i-2"}},
+		{&ec2.DescribeInstancesInput{Filters: []*ec2.Filter{filter("tag-key", "Name")}}, []string{"i-1", "i-2", "i-3"}},
+		{&ec2.DescribeInstancesInput{Filters: []*ec2.Filter{
+			filter("tag:Name", "web-*"),
+			filter("instance-state-name", "running"),
+		}}, []string{"i-1"}},
+		{&ec2.DescribeInstancesInput{Filters: []*ec2.Filter{filter("tag:Name", "cache")}}, nil},
+	}
+	for i, tt := range tests {
+		ids, _, err := describedIDs(f, tt.input)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("%d: got %v, want %v", i, ids, tt.want)
+		}
+	}
+}
+
+func TestFakeELB(t *testing.T) {
+	f := &FakeELB{
+		LoadBalancers: []*elb.LoadBalancerDescription{
+			{LoadBalancerName: aws.String("web")},
+			{LoadBalancerName: aws.String("api")},
+			{LoadBalancerName: aws.String("db")},
+		},
+		Tags:     map[string][]*elb.Tag{"api": {{Key: aws.String("team"), Value: aws.String("core")}}},
+		PageSize: 2,
+	}
+	var names []string
+	err := f.DescribeLoadBalancersPages(nil, func(out *elb.DescribeLoadBalancersOutput, last bool) bool {
+		for _, lb := range out.LoadBalancerDescriptions {
+			names = append(names, aws.StringValue(lb.LoadBalancerName))
+		}
+		return true
+	})
+	if err != nil || !reflect.DeepEqual(names, []string{"web", "api", "db"}) || f.Calls != 2 {
+		t.Errorf("got %v in %d calls, %v", names, f.Calls, err)
+	}
+
+	_, err = f.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{LoadBalancerNames: aws.StringSlice([]string{"api", "cache"})})
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != elb.ErrCodeAccessPointNotFoundException {
+		t.Errorf("got %v, want %s", err, elb.ErrCodeAccessPointNotFoundException)
+	}
+
+	out, err := f.DescribeTags(&elb.DescribeTagsInput{LoadBalancerNames: aws.StringSlice([]string{"api", "web"})})
+	if err != nil || len(out.TagDescriptions) != 2 || len(out.TagDescriptions[0].Tags) != 1 || len(out.TagDescriptions[1].Tags) != 0 {
+		t.Errorf("DescribeTags = %v, %v", out, err)
+	}
+}
+
+func TestFakeELBV2(t *testing.T) {
+	f := &FakeELBV2{
+		LoadBalancers: []*elbv2.LoadBalancer{
+			{LoadBalancerName: aws.String("web"), LoadBalancerArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/web/1")},
+			{LoadBalancerName: aws.String("api"), LoadBalancerArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/net/api/2")},
+		},
+	}
+	out, err := f.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{Names: aws.StringSlice([]string{"api"})})
+	if err != nil || len(out.LoadBalancers) != 1 || aws.StringValue(out.LoadBalancers[0].LoadBalancerName) != "api" {
+		t.Errorf("by name = %v, %v", out, err)
+	}
+	out, err = f.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{LoadBalancerArns: aws.StringSlice([]string{"arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/web/1"})})
+	if err != nil || len(out.LoadBalancers) != 1 || aws.StringValue(out.LoadBalancers[0].LoadBalancerName) != "web" {
+		t.Errorf("by ARN = %v, %v", out, err)
+	}
+	_, err = f.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{Names: aws.StringSlice([]string{"cache"})})
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != elbv2.ErrCodeLoadBalancerNotFoundException {
+		t.Errorf("got %v, want %s", err, elbv2.ErrCodeLoadBalancerNotFoundException)
+	}
+}
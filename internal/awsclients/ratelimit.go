@@ -0,0 +1,60 @@
+// Package awsclients provides the helpers of the AWS clients which do not
+// depend on the configuration of the bot.
+package awsclients
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket allowing Rate calls per second on average
+// and bursts of Burst calls.
+type RateLimiter struct {
+	Rate  float64
+	Burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter whose bucket is full.
+func NewRateLimiter(rate, burst float64) *RateLimiter {
+	return &RateLimiter{Rate: rate, Burst: burst, tokens: burst, last: time.Now()}
+}
+
+// refill adds the tokens accumulated since the last call. It has to be
+// called with mu held.
+func (l *RateLimiter) refill() {
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.Rate
+	if l.tokens > l.Burst {
+		l.tokens = l.Burst
+	}
+	l.last = now
+}
+
+// Allow reports whether a call is allowed now, taking a token if it is.
+func (l *RateLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Wait blocks until a call is allowed.
+func (l *RateLimiter) Wait() {
+	l.mu.Lock()
+	l.refill()
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.Rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	time.Sleep(wait)
+}
@@ -0,0 +1,113 @@
+// Package cache provides the in-memory caches of the bot which do not depend
+// on the AWS or Slack clients.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is a cache of at most Size entries which expire after TTL. The
+// least recently used entry is evicted when it is full.
+type LRU struct {
+	Size int
+	TTL  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type lruEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// NewLRU returns an empty cache of at most size entries which expire after
+// ttl.
+func NewLRU(size int, ttl time.Duration) *LRU {
+	return &LRU{
+		Size:    size,
+		TTL:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Get returns the value of the key unless it is missing or expired.
+func (c *LRU) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(e)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return entry.value, true
+}
+
+// Add sets the value of the key, evicting the least recently used entry if
+// the cache is full.
+func (c *LRU) Add(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &lruEntry{key: key, value: value, expiresAt: time.Now().Add(c.TTL)}
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.Size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.entries, e.Value.(*lruEntry).key)
+	}
+}
+
+// Remove deletes the key.
+func (c *LRU) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.order.Remove(e)
+		delete(c.entries, key)
+	}
+}
+
+// Purge deletes all entries.
+func (c *LRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// Items returns the values of the entries which have not expired.
+func (c *LRU) Items() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	items := make(map[string]interface{}, len(c.entries))
+	for key, e := range c.entries {
+		if entry := e.Value.(*lruEntry); now.Before(entry.expiresAt) {
+			items[key] = entry.value
+		}
+	}
+	return items
+}
+
+// SetTTL changes the TTL of the entries added later.
+func (c *LRU) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.TTL = ttl
+}
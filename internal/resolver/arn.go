@@ -0,0 +1,63 @@
+// Package resolver parses the identifiers of AWS resources found in
+// messages.
+package resolver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ARNPattern matches the ARNs of EC2 and ELB resources.
+var ARNPattern = regexp.MustCompile(`arn:aws[a-z-]*:(?:ec2|elasticloadbalancing):[a-z0-9-]*:[0-9]{12}:[A-Za-z0-9/_.:-]+`)
+
+// ARN is a parsed Amazon Resource Name of an EC2 or ELB resource.
+type ARN struct {
+	Partition    string
+	Service      string
+	Region       string
+	Account      string
+	ResourceType string
+	// Resource is the rest of the resource part, such as the instance ID
+	// or the name of a load balancer.
+	Resource string
+}
+
+// ParseARN parses the ARN of a resource with a resource type.
+func ParseARN(s string) (*ARN, error) {
+	parts := strings.SplitN(s, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" {
+		return nil, fmt.Errorf("invalid ARN %q", s)
+	}
+	resource := strings.SplitN(parts[5], "/", 2)
+	if len(resource) != 2 {
+		return nil, fmt.Errorf("invalid ARN %q", s)
+	}
+	return &ARN{
+		Partition:    parts[1],
+		Service:      parts[2],
+		Region:       parts[3],
+		Account:      parts[4],
+		ResourceType: resource[0],
+		Resource:     resource[1],
+	}, nil
+}
+
+// IsInstance reports whether the ARN identifies an instance.
+func (a *ARN) IsInstance() bool {
+	return a.Service == "ec2" && a.ResourceType == "instance"
+}
+
+// IsClassicLoadBalancer reports whether the ARN identifies a Classic ELB,
+// whose resource is the name without the type prefix of ALBs and NLBs.
+func (a *ARN) IsClassicLoadBalancer() bool {
+	return a.Service == "elasticloadbalancing" &&
+		a.ResourceType == "loadbalancer" &&
+		!strings.Contains(a.Resource, "/")
+}
+
+// IsLoadBalancer reports whether the ARN identifies a load balancer of any
+// type.
+func (a *ARN) IsLoadBalancer() bool {
+	return a.Service == "elasticloadbalancing" && a.ResourceType == "loadbalancer"
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/nlopes/slack"
+)
+
+func init() {
+	mentionCommands["inventory"] = (*Event).inventory
+}
+
+func inventoryButton(id string) slack.AttachmentAction {
+	return slack.AttachmentAction{
+		Name:  "ssm_inventory",
+		Text:  tr("SSM inventory"),
+		Type:  "button",
+		Value: id,
+	}
+}
+
+// inventory handles "@ec2bot inventory i-xxx" by posting the SSM agent
+// information and the patch compliance of each instance.
+func (ev *Event) inventory(args []string) error {
+	ids := hostIDPattern.FindAllString(strings.Join(args, " "), -1)
+	if len(ids) == 0 {
+		return errors.New(tr("usage: inventory <instance id>"))
+	}
+	for _, id := range ids {
+		if err := authorizeAction(ev.TeamID, ev.Event.User, "ssm_inventory", id); err != nil {
+			ev.post(err.Error(), nil)
+			continue
+		}
+		if err := ev.postInventory(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postInventory posts the platform and the agent of the instance reported
+// to Systems Manager, and the result of the latest patch operation.
+func (ev *Event) postInventory(id string) error {
+	info, err := getAgentInformation(id)
+	if err == nil && info == nil {
+		return ev.post(tr("%s is not managed by Systems Manager", code(id)), nil)
+	}
+	var patches *ssm.InstancePatchState
+	if err == nil {
+		patches, err = getPatchState(id)
+	}
+	ev.audit("ssm_inventory", id, err)
+	if err != nil {
+		return ev.post(tr("failed to get SSM inventory of %s: %v", id, err), nil)
+	}
+	agent := aws.StringValue(info.AgentVersion)
+	if !aws.BoolValue(info.IsLatestVersion) {
+		agent += " " + tr("(update available)")
+	}
+	attachments := []slack.Attachment{{
+		Title:      tr("SSM inventory of %s", id),
+		MarkdownIn: []string{"fields"},
+		Fields: []slack.AttachmentField{
+			{Title: tr("Platform"), Value: fmt.Sprintf("%s %s (%s)", aws.StringValue(info.PlatformName), aws.StringValue(info.PlatformVersion), aws.StringValue(info.PlatformType)), Short: true},
+			{Title: tr("Computer Name"), Value: optional(info.ComputerName), Short: true},
+			{Title: tr("SSM Agent"), Value: fmt.Sprintf("%s (%s)", aws.StringValue(info.PingStatus), agent), Short: true},
+			{Title: tr("Last Ping"), Value: ev.formatTime(aws.TimeValue(info.LastPingDateTime)), Short: true},
+		},
+	}}
+	if a := ev.patchAttachment(patches); a != nil {
+		attachments = append(attachments, *a)
+	}
+	return ev.post("", attachments)
+}
+
+// getPatchState returns the patch state of the instance, or nil if it was
+// never scanned.
+func getPatchState(id string) (*ssm.InstancePatchState, error) {
+	svc := regionSSMClient(instanceRegion(id))
+	resp, err := svc.DescribeInstancePatchStates(&ssm.DescribeInstancePatchStatesInput{
+		InstanceIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.InstancePatchStates) == 0 {
+		return nil, nil
+	}
+	return resp.InstancePatchStates[0], nil
+}
+
+// patchAttachment shows the patch compliance, which is colored "danger" if
+// patches are missing or failed.
+func (ev *Event) patchAttachment(s *ssm.InstancePatchState) *slack.Attachment {
+	if s == nil {
+		return nil
+	}
+	missing := aws.Int64Value(s.MissingCount)
+	failed := aws.Int64Value(s.FailedCount)
+	color := "good"
+	if missing > 0 || failed > 0 {
+		color = "danger"
+	}
+	return &slack.Attachment{
+		Title: tr("Patch compliance"),
+		Color: color,
+		Fields: []slack.AttachmentField{
+			{Title: tr("Installed"), Value: fmt.Sprint(aws.Int64Value(s.InstalledCount)), Short: true},
+			{Title: tr("Pending Reboot"), Value: fmt.Sprint(aws.Int64Value(s.InstalledPendingRebootCount)), Short: true},
+			{Title: tr("Missing"), Value: fmt.Sprint(missing), Short: true},
+			{Title: tr("Failed"), Value: fmt.Sprint(failed), Short: true},
+		},
+		Footer: tr("%s at %s", aws.StringValue(s.Operation), ev.formatTime(aws.TimeValue(s.OperationEndTime))),
+	}
+}
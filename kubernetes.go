@@ -0,0 +1,364 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/ghodss/yaml"
+	"github.com/nlopes/slack"
+)
+
+// kubeClient is the client of the Kubernetes API configured by
+// $KUBERNETES_CONFIG, which is "in-cluster" or the path of a kubeconfig
+// file. It is nil if the integration is disabled.
+var kubeClient *KubeClient
+
+// podPattern matches pods in the form of kubectl output ("pod/web-1" or
+// "pod/default/web-1") and queries such as "pod:default/web-1".
+var podPattern = regexp.MustCompile(`\bpod[/:](?:[a-z0-9][a-z0-9-]*/)?[a-z0-9](?:[a-z0-9.-]*[a-z0-9])?`)
+
+// maxPods is the maximum number of pods listed on an instance card.
+const maxPods = 20
+
+// serviceAccountDir is where the credentials of the service account are
+// mounted in pods.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubeClient is a minimal read-only client of the Kubernetes API.
+type KubeClient struct {
+	Server string
+	Token  string
+	client *http.Client
+}
+
+type kubeMetadata struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type kubePod struct {
+	Metadata kubeMetadata `json:"metadata"`
+	Spec     struct {
+		NodeName string `json:"nodeName"`
+	} `json:"spec"`
+	Status struct {
+		Phase string `json:"phase"`
+	} `json:"status"`
+}
+
+type kubeNode struct {
+	Metadata kubeMetadata `json:"metadata"`
+	Spec     struct {
+		ProviderID string `json:"providerID"`
+	} `json:"spec"`
+}
+
+// kubeconfig is the subset of a kubeconfig file used by the client. Exec
+// and auth provider plugins are not supported.
+type kubeconfig struct {
+	CurrentContext string `json:"current-context"`
+	Contexts       []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster string `json:"cluster"`
+			User    string `json:"user"`
+		} `json:"context"`
+	} `json:"contexts"`
+	Clusters []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server                   string `json:"server"`
+			CertificateAuthority     string `json:"certificate-authority"`
+			CertificateAuthorityData string `json:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Users []struct {
+		Name string `json:"name"`
+		User struct {
+			Token                 string `json:"token"`
+			ClientCertificateData string `json:"client-certificate-data"`
+			ClientKeyData         string `json:"client-key-data"`
+		} `json:"user"`
+	} `json:"users"`
+}
+
+func init() {
+	var err error
+	kubeClient, err = newKubeClient(getenv("KUBERNETES_CONFIG"))
+	if err != nil {
+		logError("cannot configure Kubernetes client, disable the integration", err, nil)
+	}
+}
+
+func newKubeClient(config string) (*KubeClient, error) {
+	switch config {
+	case "":
+		return nil, nil
+	case "in-cluster":
+		return inClusterKubeClient()
+	}
+	return kubeconfigClient(config)
+}
+
+func inClusterKubeClient() (*KubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster")
+	}
+	token, err := ioutil.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, err
+	}
+	ca, err := ioutil.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{RootCAs: x509.NewCertPool()}
+	tlsConfig.RootCAs.AppendCertsFromPEM(ca)
+	return &KubeClient{
+		Server: "https://" + host + ":" + port,
+		Token:  strings.TrimSpace(string(token)),
+		client: newKubeHTTPClient(tlsConfig),
+	}, nil
+}
+
+func kubeconfigClient(path string) (*KubeClient, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c kubeconfig
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	var clusterName, userName string
+	for _, ctx := range c.Contexts {
+		if ctx.Name == c.CurrentContext {
+			clusterName, userName = ctx.Context.Cluster, ctx.Context.User
+		}
+	}
+	k := new(KubeClient)
+	tlsConfig := new(tls.Config)
+	for _, cluster := range c.Clusters {
+		if cluster.Name != clusterName {
+			continue
+		}
+		k.Server = cluster.Cluster.Server
+		tlsConfig.InsecureSkipVerify = cluster.Cluster.InsecureSkipTLSVerify
+		ca, err := base64.StdEncoding.DecodeString(cluster.Cluster.CertificateAuthorityData)
+		if err != nil {
+			return nil, err
+		}
+		if cluster.Cluster.CertificateAuthority != "" {
+			if ca, err = ioutil.ReadFile(cluster.Cluster.CertificateAuthority); err != nil {
+				return nil, err
+			}
+		}
+		if len(ca) > 0 {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			tlsConfig.RootCAs.AppendCertsFromPEM(ca)
+		}
+	}
+	if k.Server == "" {
+		return nil, fmt.Errorf("cluster of context %q not found in %s", c.CurrentContext, path)
+	}
+	for _, user := range c.Users {
+		if user.Name != userName {
+			continue
+		}
+		k.Token = user.User.Token
+		if user.User.ClientCertificateData == "" {
+			continue
+		}
+		cert, err := base64.StdEncoding.DecodeString(user.User.ClientCertificateData)
+		if err != nil {
+			return nil, err
+		}
+		key, err := base64.StdEncoding.DecodeString(user.User.ClientKeyData)
+		if err != nil {
+			return nil, err
+		}
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+	k.client = newKubeHTTPClient(tlsConfig)
+	return k, nil
+}
+
+func newKubeHTTPClient(tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+}
+
+// get decodes the resource at the path of the API into v.
+func (k *KubeClient) get(path string, query url.Values, v interface{}) error {
+	u := strings.TrimSuffix(k.Server, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if k.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+k.Token)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// pods returns the pods matching the field selector in the namespace, or
+// in all namespaces if it is empty.
+func (k *KubeClient) pods(namespace, fieldSelector string) ([]kubePod, error) {
+	path := "/api/v1/pods"
+	if namespace != "" {
+		path = "/api/v1/namespaces/" + url.PathEscape(namespace) + "/pods"
+	}
+	var list struct {
+		Items []kubePod `json:"items"`
+	}
+	err := k.get(path, url.Values{"fieldSelector": {fieldSelector}}, &list)
+	return list.Items, err
+}
+
+// nodes returns all nodes of the cluster.
+func (k *KubeClient) nodes() ([]kubeNode, error) {
+	var list struct {
+		Items []kubeNode `json:"items"`
+	}
+	err := k.get("/api/v1/nodes", nil, &list)
+	return list.Items, err
+}
+
+// providerInstanceID returns the instance ID of a provider ID such as
+// "aws:///eu-west-1a/i-0123456789abcdef0", or an empty string.
+func providerInstanceID(providerID string) string {
+	if !strings.HasPrefix(providerID, "aws://") {
+		return ""
+	}
+	id := providerID[strings.LastIndex(providerID, "/")+1:]
+	if !strings.HasPrefix(id, "i-") {
+		return ""
+	}
+	return id
+}
+
+// findPodQueries returns the pods in the event as "pod:<name>" or
+// "pod:<namespace>/<name>" queries.
+func (ev *Event) findPodQueries() []string {
+	if kubeClient == nil {
+		return nil
+	}
+	queries := ev.findQuery(podPattern)
+	for i, q := range queries {
+		queries[i] = "pod:" + q[len("pod/"):]
+	}
+	return queries
+}
+
+// getInstancesByPod returns the instance of the node on which the pod is
+// scheduled. The pod is searched in all namespaces unless the query has
+// one, so it may match several instances.
+func getInstancesByPod(query string) ([]*ec2.Instance, error) {
+	if kubeClient == nil {
+		return nil, nil
+	}
+	var namespace string
+	name := query
+	if i := strings.Index(query, "/"); i >= 0 {
+		namespace, name = query[:i], query[i+1:]
+	}
+	pods, err := kubeClient.pods(namespace, "metadata.name="+name)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]struct{})
+	for _, pod := range pods {
+		if pod.Spec.NodeName != "" {
+			nodes[pod.Spec.NodeName] = struct{}{}
+		}
+	}
+	var instances []*ec2.Instance
+	for node := range nodes {
+		var n kubeNode
+		if err := kubeClient.get("/api/v1/nodes/"+url.PathEscape(node), nil, &n); err != nil {
+			return nil, err
+		}
+		id := providerInstanceID(n.Spec.ProviderID)
+		if id == "" {
+			continue
+		}
+		instance, err := getInstance(id)
+		if err != nil {
+			return nil, err
+		}
+		if instance != nil {
+			instances = append(instances, instance)
+		}
+	}
+	return instances, nil
+}
+
+// podsAttachment lists the pods scheduled on the node of the instance. It
+// returns nil if the integration is disabled or the instance is not a node.
+func podsAttachment(instanceID string) *slack.Attachment {
+	if kubeClient == nil {
+		return nil
+	}
+	nodes, err := kubeClient.nodes()
+	if err != nil {
+		logError("failed to list Kubernetes nodes", err, nil)
+		return nil
+	}
+	var node string
+	for _, n := range nodes {
+		if providerInstanceID(n.Spec.ProviderID) == instanceID {
+			node = n.Metadata.Name
+		}
+	}
+	if node == "" {
+		return nil
+	}
+	pods, err := kubeClient.pods("", "spec.nodeName="+node)
+	if err != nil {
+		logError("failed to list Kubernetes pods", err, logFields{"node": node})
+		return nil
+	}
+	lines := make([]string, 0, maxPods+1)
+	for i, pod := range pods {
+		if i == maxPods {
+			lines = append(lines, tr("and %d more", len(pods)-maxPods))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%s/%s (%s)", pod.Metadata.Namespace, pod.Metadata.Name, pod.Status.Phase))
+	}
+	return &slack.Attachment{
+		Title: tr("Pods on %s", node),
+		Text:  strings.Join(lines, "\n"),
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/bgpat/ec2bot/internal/resolver"
+	"github.com/ghodss/yaml"
+	"github.com/nlopes/slack"
+)
+
+// LoadBalancerV2Cache caches Application and Network Load Balancers.
+type LoadBalancerV2Cache struct {
+	UpdatedAt     time.Time
+	LoadBalancers []*elbv2.LoadBalancer
+}
+
+var loadBalancerV2Cache LoadBalancerV2Cache
+
+// describeLoadBalancersV2 returns all ALBs and NLBs, refreshing the cache
+// if it is stale.
+func describeLoadBalancersV2() ([]*elbv2.LoadBalancer, error) {
+	cacheMu.RLock()
+	cache := loadBalancerV2Cache
+	cacheMu.RUnlock()
+	stale := cacheStale(cache.UpdatedAt, loadBalancerCacheTTL)
+	recordCacheLookup("loadbalancers_v2", !stale)
+	if !stale {
+		return cache.LoadBalancers, nil
+	}
+	if cache.LoadBalancers != nil && breakerFor("loadbalancers_v2").open() {
+		return cache.LoadBalancers, nil
+	}
+	lbs, err := refreshLoadBalancersV2()
+	if err != nil && cache.LoadBalancers != nil {
+		logError("failed to refresh load balancers v2, use the stale cache", err, nil)
+		return cache.LoadBalancers, nil
+	}
+	return lbs, err
+}
+
+// refreshLoadBalancersV2 loads all ALBs and NLBs into the cache.
+// Concurrent calls share a single load.
+func refreshLoadBalancersV2() ([]*elbv2.LoadBalancer, error) {
+	v, err := coalesce("loadbalancers_v2", func() (interface{}, error) {
+		return loadLoadBalancersV2()
+	})
+	lbs, _ := v.([]*elbv2.LoadBalancer)
+	return lbs, err
+}
+
+func loadLoadBalancersV2() ([]*elbv2.LoadBalancer, error) {
+	var cache LoadBalancerV2Cache
+	if !loadShared("loadbalancers_v2", &cache) {
+		var lbs []*elbv2.LoadBalancer
+		for _, region := range lookupRegions() {
+			svc := loadBalancerV2Describer(region)
+			err := svc.DescribeLoadBalancersPages(nil, func(resp *elbv2.DescribeLoadBalancersOutput, last bool) bool {
+				lbs = append(lbs, resp.LoadBalancers...)
+				return true
+			})
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", region, err)
+			}
+		}
+		cache = LoadBalancerV2Cache{
+			UpdatedAt:     time.Now(),
+			LoadBalancers: lbs,
+		}
+		storeShared("loadbalancers_v2", cache, loadBalancerCacheTTL)
+	}
+	cacheMu.Lock()
+	loadBalancerV2Cache = cache
+	cacheMu.Unlock()
+	// tags are loaded lazily by each replica
+	loadBalancerV2Tags.Purge()
+	persistCaches()
+	return cache.LoadBalancers, nil
+}
+
+// getLoadBalancerV2 returns the ALB or NLB with the DNS name or the ARN.
+func getLoadBalancerV2(query string) (*elbv2.LoadBalancer, error) {
+	if strings.HasPrefix(query, "arn:") {
+		return getLoadBalancerV2ByARN(query)
+	}
+	lbs, err := describeLoadBalancersV2()
+	if err != nil {
+		return nil, err
+	}
+	for _, lb := range lbs {
+		if strings.EqualFold(aws.StringValue(lb.DNSName), query) {
+			return lb, nil
+		}
+	}
+	return nil, nil
+}
+
+// getLoadBalancerV2ByARN returns the ALB or NLB in the region of the ARN.
+func getLoadBalancerV2ByARN(arn string) (*elbv2.LoadBalancer, error) {
+	a, err := resolver.ParseARN(arn)
+	if err != nil {
+		return nil, err
+	}
+	if a.Region == "" || searchedRegion(a.Region) {
+		lbs, err := describeLoadBalancersV2()
+		if err != nil {
+			return nil, err
+		}
+		for _, lb := range lbs {
+			if aws.StringValue(lb.LoadBalancerArn) == arn {
+				return lb, nil
+			}
+		}
+		return nil, nil
+	}
+	svc := loadBalancerV2Describer(a.Region)
+	resp, err := svc.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{
+		LoadBalancerArns: []*string{aws.String(arn)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.LoadBalancers) == 0 {
+		return nil, nil
+	}
+	return resp.LoadBalancers[0], nil
+}
+
+func getLoadBalancerV2Tags(arn string) ([]*elbv2.Tag, error) {
+	t, ok := loadBalancerV2Tags.Get(arn)
+	recordCacheLookup("loadbalancer_v2_tags", ok)
+	if ok {
+		return t.([]*elbv2.Tag), nil
+	}
+	svc := loadBalancerV2Describer(arnRegion(arn))
+	resp, err := svc.DescribeTags(&elbv2.DescribeTagsInput{
+		ResourceArns: []*string{aws.String(arn)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var tags []*elbv2.Tag
+	for _, d := range resp.TagDescriptions {
+		loadBalancerV2Tags.Add(aws.StringValue(d.ResourceArn), d.Tags)
+		if aws.StringValue(d.ResourceArn) == arn {
+			tags = d.Tags
+		}
+	}
+	return tags, nil
+}
+
+func (ev *Event) postLoadBalancerV2(lb *elbv2.LoadBalancer) error {
+	attachments, err := ev.loadBalancerV2Attachments(lb)
+	if err != nil {
+		return err
+	}
+	if err := ev.post(aws.StringValue(lb.LoadBalancerName), attachments); err != nil {
+		return err
+	}
+	ev.postDetailedCopy(aws.StringValue(lb.LoadBalancerName), func(p *Event) ([]slack.Attachment, error) {
+		return p.loadBalancerV2Attachments(lb)
+	})
+	return nil
+}
+
+// loadBalancerV2Attachments renders the card of the ALB or NLB.
+func (ev *Event) loadBalancerV2Attachments(lb *elbv2.LoadBalancer) ([]slack.Attachment, error) {
+	details, err := marshalDetails(lb, ev.detailsFormat())
+	if err != nil {
+		logError("failed to encode load balancer", err, nil)
+		return nil, err
+	}
+
+	tags, err := getLoadBalancerV2Tags(aws.StringValue(lb.LoadBalancerArn))
+	if err != nil {
+		return nil, err
+	}
+	tagFields := make([]slack.AttachmentField, len(tags))
+	for i, tag := range tags {
+		tagFields[i] = slack.AttachmentField{
+			Title: aws.StringValue(tag.Key),
+			Value: currentConfig().redactTag(aws.StringValue(tag.Key), aws.StringValue(tag.Value)),
+		}
+	}
+
+	state := ""
+	if lb.State != nil {
+		state = aws.StringValue(lb.State.Code)
+	}
+	attachments := []slack.Attachment{
+		slack.Attachment{
+			Fields: []slack.AttachmentField{
+				slack.AttachmentField{
+					Title: tr("Name"),
+					Value: aws.StringValue(lb.LoadBalancerName),
+				},
+				slack.AttachmentField{
+					Title: tr("DNS Name"),
+					Value: code(aws.StringValue(lb.DNSName)),
+				},
+				slack.AttachmentField{
+					Title: tr("Type"),
+					Value: aws.StringValue(lb.Type),
+					Short: true,
+				},
+				slack.AttachmentField{
+					Title: tr("Scheme"),
+					Value: aws.StringValue(lb.Scheme),
+					Short: true,
+				},
+				slack.AttachmentField{
+					Title: tr("State"),
+					Value: state,
+					Short: true,
+				},
+				slack.AttachmentField{
+					Title: tr("Region"),
+					Value: arnRegion(aws.StringValue(lb.LoadBalancerArn)),
+					Short: true,
+				},
+			},
+			MarkdownIn: []string{"fields"},
+			Footer:     accountContext(arnAccount(aws.StringValue(lb.LoadBalancerArn)), arnRegion(aws.StringValue(lb.LoadBalancerArn))),
+		},
+	}
+	if f, ok := ev.configuredFields("loadbalancer_v2", lb, ev.detailedCards()); ok {
+		attachments[0].Fields = f
+	}
+	tagMap := make(map[string]string, len(tags))
+	for _, tag := range tagFields {
+		tagMap[tag.Title] = tag.Value
+	}
+	if a, ok := ev.renderCard("loadbalancer_v2", &CardData{
+		Resource: lb,
+		Tags:     tagMap,
+		Region:   arnRegion(aws.StringValue(lb.LoadBalancerArn)),
+		Account:  arnAccount(aws.StringValue(lb.LoadBalancerArn)),
+		Detailed: ev.detailedCards(),
+	}); ok {
+		attachments[0] = a
+	}
+	attachments[0].Color = loadBalancerV2Health(lb).color()
+	if ev.detailedCards() && !ev.userPrefs().Compact {
+		attachments = append(attachments,
+			slack.Attachment{
+				Title:  tr("Tags"),
+				Fields: tagFields,
+			},
+			detailsAttachment("loadbalancer_v2", aws.StringValue(lb.LoadBalancerArn), ev.detailsFormat(), details),
+		)
+	}
+	if a := alarmsAttachment(arnRegion(aws.StringValue(lb.LoadBalancerArn)), "LoadBalancer", loadBalancerV2Dimension(aws.StringValue(lb.LoadBalancerArn))); a != nil {
+		attachments = append(attachments, *a)
+	}
+	attachments = append(attachments, consoleAttachment(
+		loadBalancerConsoleURL(arnRegion(aws.StringValue(lb.LoadBalancerArn)), aws.StringValue(lb.LoadBalancerName)),
+		link(cloudWatchMetricsURL(arnRegion(aws.StringValue(lb.LoadBalancerArn)), aws.StringValue(lb.LoadBalancerName)), tr("CloudWatch Metrics")),
+	))
+	return attachments, nil
+}
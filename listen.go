@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+var (
+	// listenAddress is the address the bot listens on, which is
+	// $LISTEN_ADDRESS, or ":$PORT" if it is empty.
+	listenAddress = getenv("LISTEN_ADDRESS")
+	// tlsCertFile and tlsKeyFile enable HTTPS if both are set.
+	tlsCertFile = getenv("TLS_CERT_FILE")
+	tlsKeyFile  = getenv("TLS_KEY_FILE")
+	// trustedProxies are the networks of the reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers are trusted. The headers are
+	// removed from requests of other addresses.
+	trustedProxies []*net.IPNet
+)
+
+func init() {
+	if listenAddress == "" {
+		port := getenv("PORT")
+		if port == "" {
+			port = "3000"
+		}
+		listenAddress = ":" + port
+	}
+	for _, s := range strings.FieldsFunc(getenv("TRUSTED_PROXIES"), isComma) {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			if strings.Contains(s, ":") {
+				s += "/128"
+			} else {
+				s += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			logWarn("cannot parse $TRUSTED_PROXIES, ignore the network", logFields{"network": s})
+			continue
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+}
+
+// checkTLSConfig returns an error unless both or neither of the certificate
+// and the key are set.
+func checkTLSConfig() error {
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		return errors.New("both $TLS_CERT_FILE and $TLS_KEY_FILE are required for TLS")
+	}
+	return nil
+}
+
+// start starts the server on listenAddress, with TLS if it is configured.
+func start(e *echo.Echo) error {
+	if tlsCertFile != "" {
+		return e.StartTLS(listenAddress, tlsCertFile, tlsKeyFile)
+	}
+	return e.Start(listenAddress)
+}
+
+// trustProxies is the middleware removing the headers of the client address
+// set by untrusted clients, so that c.RealIP() cannot be spoofed.
+func trustProxies(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if !trustedProxy(req) {
+			req.Header.Del(echo.HeaderXForwardedFor)
+			req.Header.Del(echo.HeaderXRealIP)
+		}
+		return next(c)
+	}
+}
+
+func trustedProxy(req *http.Request) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/labstack/echo"
+)
+
+// Levels of logs in increasing severity.
+const (
+	levelDebug int32 = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+// logFields are the structured fields of a log entry.
+type logFields map[string]interface{}
+
+var (
+	// logLevel is the minimum level of logs written, which is set by
+	// $LOG_LEVEL and may be changed at runtime by "/ec2 admin loglevel".
+	logLevel = levelInfo
+	logMu    sync.Mutex
+	// logOutput is where the entries are written, which is stderr in the
+	// command line mode so that logs do not mix with results.
+	logOutput io.Writer = os.Stdout
+)
+
+// stdLogRedirected makes the standard logger, which is still used by
+// libraries, write info entries. It is a variable rather than in init so
+// that logs of other init functions are redirected too.
+var stdLogRedirected = redirectStdLog()
+
+func redirectStdLog() bool {
+	log.SetFlags(0)
+	log.SetOutput(logWriter{level: levelInfo})
+	if err := setLogLevel(getenv("LOG_LEVEL")); err != nil {
+		logWarn("cannot parse $LOG_LEVEL, use default 'info'", nil)
+	}
+	return true
+}
+
+// setLogLevel sets the minimum level of logs by name. An empty name is
+// "info".
+func setLogLevel(name string) error {
+	if name == "" {
+		name = "info"
+	}
+	for i, n := range levelNames {
+		if strings.EqualFold(n, name) {
+			atomic.StoreInt32(&logLevel, int32(i))
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log level %q, use one of %s", name, strings.Join(levelNames, ", "))
+}
+
+func currentLogLevel() string {
+	return levelNames[atomic.LoadInt32(&logLevel)]
+}
+
+// logEntry writes a JSON log entry if the level is enabled. An error in the
+// "error" field is written with its AWS error code and request ID.
+func logEntry(level int32, msg string, fields logFields) {
+	if level < atomic.LoadInt32(&logLevel) {
+		return
+	}
+	entry := make(map[string]interface{}, len(fields)+3)
+	for k, v := range fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+			if aerr, ok := err.(awserr.Error); ok {
+				entry["aws_error_code"] = aerr.Code()
+			}
+			if rerr, ok := err.(awserr.RequestFailure); ok {
+				entry["aws_request_id"] = rerr.RequestID()
+			}
+		}
+		entry[k] = v
+	}
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = levelNames[level]
+	entry["msg"] = msg
+	b, err := json.Marshal(entry)
+	if err != nil {
+		b = []byte(fmt.Sprintf(`{"level":"error","msg":%q}`, "cannot encode log entry: "+err.Error()))
+	}
+	logMu.Lock()
+	defer logMu.Unlock()
+	logOutput.Write(append(b, '\n'))
+}
+
+func logDebug(msg string, fields logFields) { logEntry(levelDebug, msg, fields) }
+func logInfo(msg string, fields logFields)  { logEntry(levelInfo, msg, fields) }
+func logWarn(msg string, fields logFields)  { logEntry(levelWarn, msg, fields) }
+
+// logError writes an error entry with the error in the "error" field, and
+// reports it to Sentry if it is configured.
+func logError(msg string, err error, fields logFields) {
+	f := logFields{"error": err}
+	for k, v := range fields {
+		f[k] = v
+	}
+	logEntry(levelError, msg, f)
+	if sentry != nil {
+		sentry.capture(msg, err, fields)
+	}
+}
+
+// logWriter writes the lines of a standard logger as entries of the level.
+type logWriter struct {
+	level int32
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	logEntry(w.level, redact(strings.TrimSpace(string(p))), nil)
+	return len(p), nil
+}
+
+// logFields returns the fields identifying the event in logs.
+func (ev *Event) logFields() logFields {
+	f := logFields{"event_id": ev.EventID, "team_id": ev.TeamID}
+	if ev.Event != nil {
+		f["channel"] = ev.Event.Channel
+		f["user"] = ev.Event.User
+	}
+	return f
+}
+
+// logRequests is the middleware logging the requests to the bot.
+func logRequests(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+		if err != nil {
+			c.Error(err)
+		}
+		req, res := c.Request(), c.Response()
+		logInfo("request", logFields{
+			"method":     req.Method,
+			"path":       req.URL.Path,
+			"status":     res.Status,
+			"latency_ms": time.Since(start).Seconds() * 1000,
+			"remote_ip":  c.RealIP(),
+		})
+		return nil
+	}
+}
+
+var (
+	// logBodies enables the logs of the bodies of requests and responses,
+	// which are redacted.
+	logBodies = boolEnv("LOG_BODIES", false)
+	// slackDebug enables the debug output of the Slack client.
+	slackDebug = boolEnv("SLACK_DEBUG", false)
+)
+
+// secretPatterns match the values of tokens and secrets in JSON, form
+// encoded, including the JSON payloads of interactions, and plain text
+// bodies. The first group is kept.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`("(?:[a-z_]*token|[a-z_]*secret|signature|password)"\s*:\s*")[^"]*`),
+	regexp.MustCompile(`((?:^|[?&\s])(?:[a-z_]*token|[a-z_]*secret|signature|password)=)[^&\s]*`),
+	regexp.MustCompile(`(%22(?:[a-z_]*token|[a-z_]*secret|signature|password)%22%3A%22)[^%]*`),
+	regexp.MustCompile(`()xox[a-z]-[A-Za-z0-9-]+`),
+}
+
+// redact replaces tokens and secrets in s.
+func redact(s string) string {
+	for _, re := range secretPatterns {
+		s = re.ReplaceAllString(s, "${1}[REDACTED]")
+	}
+	return s
+}
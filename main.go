@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/bgpat/ec2bot/internal/cache"
+	"github.com/bgpat/ec2bot/internal/resolver"
 	"github.com/ghodss/yaml"
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
@@ -27,70 +37,209 @@ type Event struct {
 	TeamID      string     `json:"team_id"`
 	Token       string     `json:"token"`
 	Type        string     `json:"type"`
+
+	prefs *UserPrefs
+	// format is the format of the details set by the command, which
+	// overrides that of the channel.
+	format string
+	// backend is the chat service the event was received from, which is
+	// the workspace of TeamID unless it is set.
+	backend ChatBackend
+	// private is set while rendering the cards sent only to the user, which
+	// may show the details restricted by detailsUserGroup.
+	private bool
 }
 
 type InstanceCache struct {
 	UpdatedAt time.Time
 	Instances *ec2.DescribeInstancesOutput
+	// Index maps the IDs, host names and addresses of the instances to
+	// them.
+	Index map[string]*ec2.Instance `json:"-"`
+	// Regions maps the IDs of the instances to their regions.
+	Regions map[string]string
 }
 
 type LoadBalancerCache struct {
 	UpdatedAt     time.Time
 	LoadBalancers *elb.DescribeLoadBalancersOutput
-	Tags          map[string][]*elb.Tag
+	// Regions maps the names of the load balancers to their regions.
+	Regions map[string]string
 }
 
 var (
 	api               *slack.Client
+	store             *Store
+	botUserID         string
+	username          string
 	instanceCache     InstanceCache
 	loadBalancerCache LoadBalancerCache
+	// cacheMu guards instanceCache, loadBalancerCache and
+	// loadBalancerV2Cache. The cached responses are replaced rather than
+	// modified so that they may be read after the lock is released.
+	cacheMu sync.RWMutex
+
+	// loadBalancerTags and loadBalancerV2Tags cache the tags of Classic
+	// ELBs by name and of ALBs and NLBs by ARN. They are purged when the
+	// load balancers are refreshed.
+	loadBalancerTags   *cache.LRU
+	loadBalancerV2Tags *cache.LRU
 
-	interval time.Duration
+	// instancePageSize is the number of instances requested per page of
+	// DescribeInstances.
+	instancePageSize int64 = 1000
+	// maxInstances stops loading pages of DescribeInstances once the
+	// cache holds as many instances.
+	maxInstances = 50000
 
-	slackAccessToken = os.Getenv("SLACK_ACCESS_TOKEN")
-	slackVerifyToken = os.Getenv("SLACK_VERIFY_TOKEN")
+	slackAccessToken = getenv("SLACK_ACCESS_TOKEN")
+	slackVerifyToken = getenv("SLACK_VERIFY_TOKEN")
+	storePath        = getenv("STORE_PATH")
+	adminUsers       = strings.FieldsFunc(getenv("SLACK_ADMIN_USERS"), isComma)
 
 	hostIDPattern         = regexp.MustCompile("i-[0-9a-f]{5,}")
-	privateDnsNamePattern = regexp.MustCompile(`ip-[0-9-]+\.[a-z]{2}-[a-z]+-[0-9]+\.compute\.internal`)
+	fullInstanceIDPattern = regexp.MustCompile("^i-(?:[0-9a-f]{8}|[0-9a-f]{17})$")
+	privateDnsNamePattern = privateDNSNameRegexp(privateDNSDomains)
+	// privateIPPattern matches RFC 1918 addresses.
+	privateIPPattern = regexp.MustCompile(`\b(?:10(?:\.` + ipv4Octet + `){3}|172\.(?:1[6-9]|2[0-9]|3[01])(?:\.` + ipv4Octet + `){2}|192\.168(?:\.` + ipv4Octet + `){2})\b`)
+	ipv4Pattern      = regexp.MustCompile(`\b(?:` + ipv4Octet + `\.){3}` + ipv4Octet + `\b`)
+	// ipv6Pattern matches candidates of IPv6 addresses, which have to be
+	// validated by parseIPv6.
+	ipv6Pattern = regexp.MustCompile(`[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`)
+	// macPattern matches MAC addresses separated by colons or hyphens.
+	macPattern = regexp.MustCompile(`\b(?:[0-9A-Fa-f]{2}[:-]){5}[0-9A-Fa-f]{2}\b`)
 
-	elbPattern = regexp.MustCompile(`[0-9a-f]+-[0-9a-f]+\.[a-z]{2}-[a-z]+-[0-9]+\.elb\.amazonaws\.com`)
+	// elbPattern matches DNS names of Classic ELBs and ALBs
+	// ("<name>-<id>.<region>.elb.amazonaws.com") and NLBs
+	// ("<name>-<id>.elb.<region>.amazonaws.com") with the optional
+	// "dualstack." prefix, and those ending with "amazonaws.com.cn" in
+	// China.
+	elbPattern = regexp.MustCompile(`\b(?:dualstack\.)?[A-Za-z0-9-]+\.(?:` + regionPattern + `\.elb|elb\.` + regionPattern + `)\.amazonaws\.com(?:\.cn)?\b`)
 )
 
+const ipv4Octet = `(?:25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])`
+
 func init() {
-	var err error
-	interval, err = time.ParseDuration(os.Getenv("INSTANCE_CACHE_TTL"))
-	if err != nil {
-		log.Println("cannot parse $INSTANE_CACHE_TTL, use default '5m'")
-		interval = 5 * time.Minute
+	if v := getenv("INSTANCE_PAGE_SIZE"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 5 || n > 1000 {
+			logWarn("cannot parse $INSTANCE_PAGE_SIZE, use default '1000'", nil)
+		} else {
+			instancePageSize = n
+		}
 	}
+	if v := getenv("MAX_INSTANCES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			logWarn("cannot parse $MAX_INSTANCES, use default '50000'", nil)
+		} else {
+			maxInstances = n
+		}
+	}
+	tagCacheSize := 1000
+	if v := getenv("TAG_CACHE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			logWarn("cannot parse $TAG_CACHE_SIZE, use default '1000'", nil)
+		} else {
+			tagCacheSize = n
+		}
+	}
+	loadBalancerTags = cache.NewLRU(tagCacheSize, tagCacheTTL)
+	loadBalancerV2Tags = cache.NewLRU(tagCacheSize, tagCacheTTL)
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	api = slack.New(slackAccessToken)
-	logger := log.New(os.Stdout, "slack-bot: ", log.Lshortfile|log.LstdFlags)
-	slack.SetLogger(logger)
-	api.SetDebug(true)
+	slack.SetLogger(log.New(logWriter{level: levelDebug}, "slack: ", 0))
+	api.SetDebug(slackDebug)
+
+	var err error
+	if slackAccessToken != "" {
+		username, botUserID, err = getIdentity()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	atomic.StoreInt32(&slackAuthenticated, 1)
+
+	store, err = openStore(storePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	c, err := loadedConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	setConfig(c)
 
-	username, err := getUsername()
+	auditSinks, err = parseSinks(getenv("AUDIT_SINKS"))
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := checkTLSConfig(); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkCacheTTLs(); err != nil {
+		log.Fatal(err)
+	}
+	if err := c.checkSettings(); err != nil {
+		log.Fatal(err)
+	}
+	go watchReload()
+
+	if err := restoreCaches(cachePath); err != nil {
+		logError("failed to restore caches", err, nil)
+	}
+	startEventWorkers()
+	if discord != nil {
+		go discord.run()
+	}
+
+	if backgroundRefresh {
+		go refreshCaches()
+	} else {
+		go warmUpCaches()
+	}
+
+	if cloudWatchNamespace != "" {
+		go exportCloudWatch(time.Minute)
+	}
+	go flushStatsEvery(statsFlushInterval)
+	if targetHealthInterval > 0 {
+		go collectTargetHealth(targetHealthInterval)
+	}
 
 	e := echo.New()
-	e.Use(middleware.Logger())
-	e.Use(middleware.BodyDump(func(c echo.Context, reqBody, resBody []byte) {
-		log.Println(string(resBody))
-	}))
+	e.Logger.SetOutput(logWriter{level: levelInfo})
+	e.Use(recoverPanics)
+	e.Use(trustProxies)
+	e.Use(logRequests)
+	e.Use(withRequestTimeout)
+	if logBodies {
+		e.Use(middleware.BodyDump(func(c echo.Context, reqBody, resBody []byte) {
+			logDebug("body", logFields{
+				"path":     c.Path(),
+				"request":  redact(string(reqBody)),
+				"response": redact(string(resBody)),
+			})
+		}))
+	}
 
 	e.POST("/", func(c echo.Context) error {
 		ev := new(Event)
 		if err := c.Bind(ev); err != nil {
-			log.Println(err)
-			return err
+			logError("failed to bind event", err, nil)
+			return c.String(http.StatusBadRequest, "invalid event")
 		}
 
 		if ev.Token != slackVerifyToken {
-			log.Println("failed to verify token:", ev.Token)
+			logWarn("failed to verify token", ev.logFields())
 			return c.String(http.StatusUnauthorized, "failed to verify token")
 		}
 
@@ -98,109 +247,565 @@ func main() {
 			return c.String(http.StatusOK, ev.Challenge)
 		}
 
-		if ev.Event.Username == username {
-			return c.String(http.StatusOK, "ignore own post")
+		// Slack retries events unless they are answered in 3 seconds, so
+		// they are processed after the response, and retries of events
+		// queued already are only acknowledged
+		if ev.seen() {
+			f := ev.logFields()
+			f["retry_num"] = c.Request().Header.Get("X-Slack-Retry-Num")
+			f["retry_reason"] = c.Request().Header.Get("X-Slack-Retry-Reason")
+			logInfo("ignore retried event", f)
+			return c.String(http.StatusOK, "ignore retry")
 		}
-
-		instances, err := ev.findInstances()
-		if err != nil {
-			log.Println(err)
-			return err
-		}
-		if len(instances) > 0 {
-			for _, i := range instances {
-				ev.postInstance(i)
-			}
-			return c.String(http.StatusOK, "post instance details")
+		if !ev.enqueue() {
+			return c.String(http.StatusServiceUnavailable, "queue is full")
 		}
+		return c.String(http.StatusOK, "queued")
+	}, interceptWorkflowStep)
 
-		loadBalancers, err := ev.findLoadBalancers()
-		if err != nil {
-			log.Println(err)
-			return err
-		}
-		if len(loadBalancers) > 0 {
-			for _, lb := range loadBalancers {
-				ev.postLoadBalancer(lb)
-			}
-			return c.String(http.StatusOK, "post load balancer details")
-		}
+	e.POST("/command", handleCommand)
+	e.POST("/actions", handleAction)
+	e.GET("/oauth/install", handleInstall)
+	e.GET("/oauth/redirect", handleOAuthRedirect)
 
-		return c.String(http.StatusOK, "query not found")
-	})
+	e.GET("/approvals", handleApproval)
+	e.POST("/approvals", handleApproval)
+
+	e.GET("/metrics", handleMetrics)
+	registerAPI(e)
+
+	if mattermost != nil {
+		e.POST("/mattermost", handleMattermost)
+	}
+	if teams != nil {
+		e.POST("/teams", handleTeams)
+	}
+
+	if eventsToken != "" {
+		e.POST("/events", handleEC2Event)
+	}
+	if pagerDutySecret != "" {
+		e.POST("/pagerduty", handlePagerDuty)
+	}
+	if opsgenieToken != "" {
+		e.POST("/opsgenie", handleOpsgenie)
+	}
+	if datadogToken != "" {
+		e.POST("/datadog", handleDatadog)
+	}
+	if len(alarmTopics) > 0 {
+		e.POST("/alarms", handleAlarm)
+	}
+	if gitHubSecret != "" {
+		e.POST("/github", handleGitHub)
+	}
+	if grafanaToken != "" {
+		e.POST("/grafana", handleGrafana)
+	}
 
 	e.GET("/ping", func(c echo.Context) error {
 		return c.String(http.StatusOK, "pong")
 	})
+	e.GET("/info", handleInfo)
+	e.GET("/version", handleVersion)
+	e.GET("/healthz", handleHealthz)
+	e.GET("/livez", handleLivez)
+	e.GET("/readyz", handleReadyz)
 
-	e.Logger.Fatal(e.Start(":3000"))
+	if err := serve(e); err != nil {
+		log.Fatal(err)
+	}
 }
 
-func getUsername() (string, error) {
+// handleMessage looks up the resources in the message of the event and
+// returns the status of the handling, which is the step failed on errors.
+func (ev *Event) handleMessage(ctx context.Context) (string, error) {
+	if ev.Event.Username == username || ev.isOwnPost() {
+		return "ignore own post", nil
+	}
+
+	if ev.throttled() {
+		return "throttled", nil
+	}
+
+	if ok, err := ev.handleMention(); ok {
+		if err != nil {
+			logError("failed to handle mention", err, ev.logFields())
+			ev.post(err.Error(), nil)
+		}
+		return "handle command", nil
+	}
+
+	ev.resolveWebhooks()
+
+	name, err := ev.resolve(ctx)
+	if err != nil {
+		return "resolve " + name, err
+	}
+	if name != "" {
+		return "post " + name, nil
+	}
+	return "query not found", nil
+}
+
+func getIdentity() (string, string, error) {
 	resp, err := api.AuthTest()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	return resp.User, err
+	return resp.User, resp.UserID, err
 }
 
 func getInstance(query string) (*ec2.Instance, error) {
-	svc := ec2.New(session.New())
-
-	var (
-		resp *ec2.DescribeInstancesOutput
-		err  error
-	)
-	if instanceCache.UpdatedAt.Add(interval).Before(time.Now()) {
-		resp, err = svc.DescribeInstances(nil)
-		if err != nil {
-			return nil, err
+	resp, err := describeInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	cacheMu.RLock()
+	instance, ok := instanceCache.Index[query]
+	cacheMu.RUnlock()
+	if ok {
+		return instance, nil
+	}
+	// host names in other domains and IPv6 addresses in other notations
+	// are not indexed
+	if !privateDnsNamePattern.MatchString(query) && !publicDNSNamePattern.MatchString(query) && !strings.Contains(query, ":") {
+		return nil, nil
+	}
+	for _, reservation := range resp.Reservations {
+		for _, instance := range reservation.Instances {
+			if instanceMatches(instance, query) {
+				return instance, nil
+			}
 		}
+	}
+
+	return nil, nil
+}
+
+// describeInstances returns all instances, refreshing the cache if it is
+// stale.
+func describeInstances() (*ec2.DescribeInstancesOutput, error) {
+	cacheMu.RLock()
+	cache := instanceCache
+	cacheMu.RUnlock()
+	stale := cacheStale(cache.UpdatedAt, instanceCacheTTL)
+	recordCacheLookup("instances", !stale)
+	if !stale {
+		return cache.Instances, nil
+	}
+	if cache.Instances != nil && breakerFor("instances").open() {
+		return cache.Instances, nil
+	}
+	resp, err := refreshInstances()
+	if err != nil && cache.Instances != nil {
+		logError("failed to refresh instances, use the stale cache", err, nil)
+		return cache.Instances, nil
+	}
+	return resp, err
+}
+
+// refreshInstances loads all instances into the cache. Concurrent calls
+// share a single load.
+func refreshInstances() (*ec2.DescribeInstancesOutput, error) {
+	v, err := coalesce("instances", func() (interface{}, error) {
+		return loadInstances()
+	})
+	resp, _ := v.(*ec2.DescribeInstancesOutput)
+	return resp, err
+}
+
+func loadInstances() (*ec2.DescribeInstancesOutput, error) {
+	// another replica may have loaded the instances in this interval
+	var shared InstanceCache
+	if loadShared("instances", &shared) && shared.Instances != nil {
+		cacheMu.Lock()
 		instanceCache = InstanceCache{
-			UpdatedAt: time.Now(),
-			Instances: resp,
+			UpdatedAt: shared.UpdatedAt,
+			Instances: shared.Instances,
+			Index:     indexInstances(shared.Instances),
+			Regions:   shared.Regions,
 		}
-	} else {
-		resp = instanceCache.Instances
+		takeSnapshot()
+		cacheMu.Unlock()
+		forgetNotFound()
+		persistCaches()
+		return shared.Instances, nil
+	}
+	resp := new(ec2.DescribeInstancesOutput)
+	regions := make(map[string]string)
+	count, size := 0, 0
+	full := false
+	for _, region := range lookupRegions() {
+		svc := instanceDescriber(region)
+		err := svc.DescribeInstancesPages(&ec2.DescribeInstancesInput{
+			MaxResults: aws.Int64(instancePageSize),
+		}, func(page *ec2.DescribeInstancesOutput, last bool) bool {
+			reservations, n := slimReservations(page.Reservations)
+			resp.Reservations = append(resp.Reservations, reservations...)
+			for _, reservation := range reservations {
+				count += len(reservation.Instances)
+				for _, instance := range reservation.Instances {
+					regions[aws.StringValue(instance.InstanceId)] = region
+				}
+			}
+			size += n
+			if count >= maxInstances {
+				logWarn("stop loading instances, raise $MAX_INSTANCES to load more", logFields{"count": count})
+				full = true
+			}
+			if maxInstanceCacheBytes > 0 && size >= maxInstanceCacheBytes {
+				logWarn("stop loading instances, raise $INSTANCE_CACHE_MAX_BYTES to load more", logFields{"bytes": size})
+				full = true
+			}
+			return !full
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", region, err)
+		}
+		if full {
+			break
+		}
+	}
+	cache := InstanceCache{
+		UpdatedAt: time.Now(),
+		Instances: resp,
+		Index:     indexInstances(resp),
+		Regions:   regions,
 	}
+	storeShared("instances", cache, instanceCacheTTL)
+	cacheMu.Lock()
+	instanceCache = cache
+	takeSnapshot()
+	cacheMu.Unlock()
+	forgetNotFound()
+	persistCaches()
+	return resp, nil
+}
 
+// indexInstances maps the IDs, host names and addresses of the instances,
+// including those of all network interfaces, to them so that lookups do not
+// scan every instance. The first instance wins if they share an address.
+func indexInstances(resp *ec2.DescribeInstancesOutput) map[string]*ec2.Instance {
+	index := make(map[string]*ec2.Instance)
+	add := func(key *string, instance *ec2.Instance) {
+		if k := aws.StringValue(key); k != "" {
+			if _, ok := index[k]; !ok {
+				index[k] = instance
+			}
+		}
+	}
 	for _, reservation := range resp.Reservations {
 		for _, instance := range reservation.Instances {
-			if instance.PrivateDnsName != nil && *instance.PrivateDnsName == query {
-				return instance, nil
+			add(instance.InstanceId, instance)
+			add(instance.PrivateDnsName, instance)
+			add(instance.PrivateIpAddress, instance)
+			add(instance.PublicDnsName, instance)
+			add(instance.PublicIpAddress, instance)
+			for _, ni := range instance.NetworkInterfaces {
+				add(ni.PrivateDnsName, instance)
+				add(ni.MacAddress, instance)
+				if ni.Association != nil {
+					add(ni.Association.PublicIp, instance)
+				}
+				for _, addr := range ni.PrivateIpAddresses {
+					add(addr.PrivateDnsName, instance)
+					add(addr.PrivateIpAddress, instance)
+					if addr.Association != nil {
+						add(addr.Association.PublicIp, instance)
+					}
+				}
+				for _, addr := range ni.Ipv6Addresses {
+					add(addr.Ipv6Address, instance)
+				}
 			}
-			if instance.InstanceId != nil && *instance.InstanceId == query {
-				return instance, nil
+		}
+	}
+	return index
+}
+
+// maxFilterValues is the maximum number of values of a DescribeInstances
+// filter.
+const maxFilterValues = 200
+
+// fetchInstances adds the instances launched after the cache was refreshed
+// to the cache, so that they are found before the next refresh. Queries
+// missing in the cache are looked up with a single DescribeInstances call
+// per filter and chunk of values instead of one call for each query.
+func fetchInstances(queries []string) error {
+	filters := make(map[string][]string)
+	seen := make(map[string]struct{})
+	for _, q := range queries {
+		instance, err := getInstance(q)
+		if err != nil {
+			return err
+		}
+		if instance != nil {
+			continue
+		}
+		name, value := instanceFilter(q)
+		if _, ok := seen[name+"="+value]; name == "" || ok {
+			continue
+		}
+		seen[name+"="+value] = struct{}{}
+		filters[name] = append(filters[name], value)
+	}
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fetched []*ec2.Reservation
+	regions := make(map[string]string)
+	reservations := make(map[string]struct{})
+	for _, region := range lookupRegions() {
+		svc := instanceDescriber(region)
+		for _, name := range names {
+			values := filters[name]
+			for len(values) > 0 {
+				n := len(values)
+				if n > maxFilterValues {
+					n = maxFilterValues
+				}
+				// filters do not fail for unknown IDs unlike InstanceIds
+				out, err := svc.DescribeInstances(&ec2.DescribeInstancesInput{
+					Filters: []*ec2.Filter{{
+						Name:   aws.String(name),
+						Values: aws.StringSlice(values[:n]),
+					}},
+				})
+				if err != nil {
+					return err
+				}
+				slimmed, _ := slimReservations(out.Reservations)
+				for _, reservation := range slimmed {
+					if _, ok := reservations[aws.StringValue(reservation.ReservationId)]; !ok {
+						reservations[aws.StringValue(reservation.ReservationId)] = struct{}{}
+						fetched = append(fetched, reservation)
+					}
+					for _, instance := range reservation.Instances {
+						regions[aws.StringValue(instance.InstanceId)] = region
+					}
+				}
+				values = values[n:]
 			}
 		}
 	}
+	if len(fetched) == 0 {
+		return nil
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cached := append([]*ec2.Reservation{}, instanceCache.Instances.Reservations...)
+	instanceCache.Instances = &ec2.DescribeInstancesOutput{
+		Reservations: append(cached, fetched...),
+	}
+	instanceCache.Index = indexInstances(instanceCache.Instances)
+	instanceCache.Regions = mergeRegions(instanceCache.Regions, regions)
+	takeSnapshot()
+	return nil
+}
 
-	return nil, nil
+// instanceFilter returns the DescribeInstances filter looking up the
+// instance identified by the query, or empty strings if the query cannot
+// be looked up by a filter.
+func instanceFilter(query string) (string, string) {
+	switch {
+	case privateDnsNamePattern.MatchString(query):
+		if id := hostInstanceID(query); id != "" {
+			return "instance-id", id
+		}
+		return "private-ip-address", hostAddress(query)
+	case publicDNSNamePattern.MatchString(query):
+		return "ip-address", publicHostAddress(query)
+	case fullInstanceIDPattern.MatchString(query):
+		return "instance-id", query
+	case ipv4Pattern.FindString(query) == query:
+		if isPublicIP(query) {
+			return "ip-address", query
+		}
+		return "private-ip-address", query
+	}
+	return "", ""
 }
 
-func getLoadBalancer(query string) (*elb.LoadBalancerDescription, error) {
-	svc := elb.New(session.New())
-
-	var (
-		resp *elb.DescribeLoadBalancersOutput
-		err  error
-	)
-	if loadBalancerCache.UpdatedAt.Add(interval).Before(time.Now()) {
-		resp, err = svc.DescribeLoadBalancers(nil)
-		if err != nil {
-			return nil, err
+// instanceMatches reports whether the query identifies the instance.
+func instanceMatches(instance *ec2.Instance, query string) bool {
+	if instance.PrivateDnsName != nil && *instance.PrivateDnsName == query {
+		return true
+	}
+	// host names of secondary network interfaces and addresses
+	for _, ni := range instance.NetworkInterfaces {
+		if ni.PrivateDnsName != nil && *ni.PrivateDnsName == query {
+			return true
+		}
+		for _, addr := range ni.PrivateIpAddresses {
+			if addr.PrivateDnsName != nil && *addr.PrivateDnsName == query {
+				return true
+			}
+		}
+	}
+	// host names in other domains are matched by the address or the ID
+	// they are based on
+	if privateDnsNamePattern.MatchString(query) {
+		if addr := hostAddress(query); addr != "" {
+			return instanceMatches(instance, addr)
+		}
+		if id := hostInstanceID(query); id != "" {
+			return instance.InstanceId != nil && *instance.InstanceId == id
 		}
-		loadBalancerCache = LoadBalancerCache{
+	}
+	// public host names are also matched by the address, which may be an
+	// Elastic IP associated after the instance was launched
+	if publicDNSNamePattern.MatchString(query) {
+		if instance.PublicDnsName != nil && *instance.PublicDnsName == query {
+			return true
+		}
+		return instanceMatches(instance, publicHostAddress(query))
+	}
+	if instance.InstanceId != nil && *instance.InstanceId == query {
+		return true
+	}
+	if instance.PrivateIpAddress != nil && *instance.PrivateIpAddress == query {
+		return true
+	}
+	if instance.PublicIpAddress != nil && *instance.PublicIpAddress == query {
+		return true
+	}
+	// Elastic IPs are associated with the private addresses of the
+	// network interfaces.
+	for _, ni := range instance.NetworkInterfaces {
+		if ni.MacAddress != nil && *ni.MacAddress == query {
+			return true
+		}
+		if ni.Association != nil && ni.Association.PublicIp != nil && *ni.Association.PublicIp == query {
+			return true
+		}
+		for _, addr := range ni.Ipv6Addresses {
+			if addr.Ipv6Address != nil && sameIPv6(*addr.Ipv6Address, query) {
+				return true
+			}
+		}
+		for _, addr := range ni.PrivateIpAddresses {
+			if addr.PrivateIpAddress != nil && *addr.PrivateIpAddress == query {
+				return true
+			}
+			if addr.Association != nil && addr.Association.PublicIp != nil && *addr.Association.PublicIp == query {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseIPv6 returns the global unicast IPv6 address s, or nil if s is not
+// one.
+func parseIPv6(s string) net.IP {
+	ip := net.ParseIP(s)
+	if ip == nil || ip.To4() != nil || !ip.IsGlobalUnicast() {
+		return nil
+	}
+	return ip
+}
+
+// sameIPv6 reports whether a and b are the same IPv6 address regardless of
+// their notation.
+func sameIPv6(a, b string) bool {
+	ipA, ipB := parseIPv6(a), parseIPv6(b)
+	return ipA != nil && ipB != nil && ipA.Equal(ipB)
+}
+
+// isPublicIP reports whether s is a globally routable IPv4 address.
+func isPublicIP(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.IsGlobalUnicast() && !privateIPPattern.MatchString(s)
+}
+
+// describeLoadBalancers returns all Classic ELBs, refreshing the cache if
+// it is stale.
+func describeLoadBalancers() (*elb.DescribeLoadBalancersOutput, error) {
+	cacheMu.RLock()
+	cache := loadBalancerCache
+	cacheMu.RUnlock()
+	stale := cacheStale(cache.UpdatedAt, loadBalancerCacheTTL)
+	recordCacheLookup("loadbalancers", !stale)
+	if !stale {
+		return cache.LoadBalancers, nil
+	}
+	if cache.LoadBalancers != nil && breakerFor("loadbalancers").open() {
+		return cache.LoadBalancers, nil
+	}
+	resp, err := refreshLoadBalancers()
+	if err != nil && cache.LoadBalancers != nil {
+		logError("failed to refresh load balancers, use the stale cache", err, nil)
+		return cache.LoadBalancers, nil
+	}
+	return resp, err
+}
+
+// refreshLoadBalancers loads all Classic ELBs into the cache. Concurrent
+// calls share a single load.
+func refreshLoadBalancers() (*elb.DescribeLoadBalancersOutput, error) {
+	v, err := coalesce("loadbalancers", func() (interface{}, error) {
+		return loadLoadBalancers()
+	})
+	resp, _ := v.(*elb.DescribeLoadBalancersOutput)
+	return resp, err
+}
+
+func loadLoadBalancers() (*elb.DescribeLoadBalancersOutput, error) {
+	var cache LoadBalancerCache
+	if !loadShared("loadbalancers", &cache) || cache.LoadBalancers == nil {
+		resp := new(elb.DescribeLoadBalancersOutput)
+		regions := make(map[string]string)
+		for _, region := range lookupRegions() {
+			svc := loadBalancerDescriber(region)
+			err := svc.DescribeLoadBalancersPages(nil, func(page *elb.DescribeLoadBalancersOutput, last bool) bool {
+				resp.LoadBalancerDescriptions = append(resp.LoadBalancerDescriptions, page.LoadBalancerDescriptions...)
+				for _, lb := range page.LoadBalancerDescriptions {
+					regions[aws.StringValue(lb.LoadBalancerName)] = region
+				}
+				return true
+			})
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", region, err)
+			}
+		}
+		cache = LoadBalancerCache{
 			UpdatedAt:     time.Now(),
 			LoadBalancers: resp,
-			Tags:          make(map[string][]*elb.Tag),
+			Regions:       regions,
 		}
-	} else {
-		resp = loadBalancerCache.LoadBalancers
+		storeShared("loadbalancers", cache, loadBalancerCacheTTL)
+	}
+	cacheMu.Lock()
+	loadBalancerCache = cache
+	takeSnapshot()
+	cacheMu.Unlock()
+	// tags are loaded lazily by each replica
+	loadBalancerTags.Purge()
+	persistCaches()
+	return cache.LoadBalancers, nil
+}
+
+func getLoadBalancer(query string) (*elb.LoadBalancerDescription, error) {
+	resp, err := describeLoadBalancers()
+	if err != nil {
+		return nil, err
 	}
 
+	// prefer exact matches so that a public ELB never shadows an
+	// internal one whose DNS name ends with the same string
+	query = normalizeELBDNSName(query)
 	for _, lb := range resp.LoadBalancerDescriptions {
-		if lb.DNSName != nil && strings.HasSuffix(*lb.DNSName, query) {
+		if lb.DNSName != nil && normalizeELBDNSName(*lb.DNSName) == query {
+			return lb, nil
+		}
+	}
+	for _, lb := range resp.LoadBalancerDescriptions {
+		if lb.DNSName != nil && strings.HasSuffix(normalizeELBDNSName(*lb.DNSName), query) {
 			return lb, nil
 		}
 	}
@@ -208,42 +813,92 @@ func getLoadBalancer(query string) (*elb.LoadBalancerDescription, error) {
 	return nil, nil
 }
 
+// normalizeELBDNSName returns the DNS name of a Classic ELB without the
+// "dualstack." and "internal-" prefixes, which names of load balancers
+// cannot start with, so that both forms compare equal.
+func normalizeELBDNSName(name string) string {
+	name = strings.ToLower(name)
+	name = strings.TrimPrefix(name, "dualstack.")
+	return strings.TrimPrefix(name, "internal-")
+}
+
+// resolveLoadBalancer returns the Classic ELB identified by the DNS name or
+// the ARN.
+func resolveLoadBalancer(query string) (*elb.LoadBalancerDescription, error) {
+	if strings.HasPrefix(query, "arn:") {
+		a, err := resolver.ParseARN(query)
+		if err != nil || !a.IsClassicLoadBalancer() {
+			return nil, err
+		}
+		return getLoadBalancerByARN(query)
+	}
+	return getLoadBalancer(query)
+}
+
+// maxTagNames is the maximum number of load balancers of a single
+// DescribeTags call of Classic ELB.
+const maxTagNames = 20
+
+// getLoadBalancerTags returns the tags of the Classic ELB. The tags of other
+// cached load balancers without tags are fetched in the same call.
 func getLoadBalancerTags(name string) ([]*elb.Tag, error) {
-	svc := elb.New(session.New())
+	region := loadBalancerRegion(name)
+	svc := loadBalancerDescriber(region)
 	tags := make([]*elb.Tag, 0)
-	if t, ok := loadBalancerCache.Tags[name]; ok {
-		tags = t
+	t, ok := loadBalancerTags.Get(name)
+	recordCacheLookup("loadbalancer_tags", ok)
+	names := []*string{aws.String(name)}
+	cacheMu.RLock()
+	if !ok && loadBalancerCache.LoadBalancers != nil {
+		for _, lb := range loadBalancerCache.LoadBalancers.LoadBalancerDescriptions {
+			if len(names) == maxTagNames {
+				break
+			}
+			n := aws.StringValue(lb.LoadBalancerName)
+			// a single call describes load balancers of a single region
+			if r := loadBalancerCache.Regions[n]; r != "" && r != region {
+				continue
+			}
+			if _, cached := loadBalancerTags.Get(n); !cached && n != name {
+				names = append(names, aws.String(n))
+			}
+		}
+	}
+	cacheMu.RUnlock()
+	if ok {
+		tags = t.([]*elb.Tag)
 	} else {
 		resp, err := svc.DescribeTags(&elb.DescribeTagsInput{
-			LoadBalancerNames: []*string{&name},
+			LoadBalancerNames: names,
 		})
+		// other load balancers may have been deleted since the cache was
+		// refreshed
+		if err != nil && len(names) > 1 {
+			resp, err = svc.DescribeTags(&elb.DescribeTagsInput{
+				LoadBalancerNames: names[:1],
+			})
+		}
 		if err != nil {
 			return nil, err
 		}
 		for _, d := range resp.TagDescriptions {
-			loadBalancerCache.Tags[*d.LoadBalancerName] = d.Tags
+			loadBalancerTags.Add(*d.LoadBalancerName, d.Tags)
 			if *d.LoadBalancerName == name {
 				tags = d.Tags
 			}
 		}
+		cacheMu.Lock()
+		takeSnapshot()
+		cacheMu.Unlock()
 	}
 	return tags, nil
 }
 
 func (ev *Event) findQuery(pattern *regexp.Regexp) []string {
 	queries := make(map[string]struct{})
-	for _, s := range pattern.FindAllString(ev.Event.Text, -1) {
-		queries[s] = struct{}{}
-	}
-	for _, a := range ev.Event.Attachments {
-		for _, s := range pattern.FindAllString(a.Text, -1) {
-			queries[s] = struct{}{}
-		}
-		for _, s := range pattern.FindAllString(a.Title, -1) {
-			queries[s] = struct{}{}
-		}
-		for _, f := range a.Fields {
-			for _, s := range pattern.FindAllString(f.Value, -1) {
+	for _, text := range ev.texts() {
+		for _, s := range pattern.FindAllString(text, -1) {
+			if !currentConfig().excluded(s) {
 				queries[s] = struct{}{}
 			}
 		}
@@ -252,232 +907,535 @@ func (ev *Event) findQuery(pattern *regexp.Regexp) []string {
 	for q, _ := range queries {
 		result = append(result, q)
 	}
+	sort.Strings(result)
 	return result
 }
 
+// texts returns the text of the message and its attachments in which
+// queries are searched, without Slack formatting.
+func (ev *Event) texts() []string {
+	texts := []string{normalizeText(ev.Event.Text)}
+	for _, a := range ev.Event.Attachments {
+		texts = append(texts, normalizeText(a.Text), normalizeText(a.Title))
+		for _, f := range a.Fields {
+			texts = append(texts, normalizeText(f.Value))
+		}
+	}
+	return texts
+}
+
 func (ev *Event) findInstanceQueries() []string {
-	return append(
-		ev.findQuery(hostIDPattern),
-		ev.findQuery(privateDnsNamePattern)...,
-	)
+	arns := ev.findARNQueries((*resolver.ARN).IsInstance)
+	queries := append([]string{}, arns...)
+	for _, q := range ev.findQuery(hostIDPattern) {
+		// IDs in ARNs are resolved in the region of the ARN
+		if !containsSuffix(arns, "/"+q) {
+			queries = append(queries, q)
+		}
+	}
+	queries = append(queries, ev.findQuery(privateDnsNamePattern)...)
+	queries = append(queries, ev.findQuery(privateIPPattern)...)
+	queries = append(queries, ev.findQuery(publicDNSNamePattern)...)
+	queries = append(queries, ev.findQuery(namePattern)...)
+	queries = append(queries, ev.findQuery(truncatedIDPattern)...)
+	queries = append(queries, ev.findPodQueries()...)
+	queries = append(queries, ev.findPatternQueries("instance")...)
+	for _, q := range ev.findQuery(ipv4Pattern) {
+		if isPublicIP(q) {
+			queries = append(queries, q)
+		}
+	}
+	for _, q := range ev.findQuery(macPattern) {
+		queries = append(queries, strings.ToLower(strings.Replace(q, "-", ":", -1)))
+	}
+	for _, q := range ev.findQuery(ipv6Pattern) {
+		if ip := parseIPv6(q); ip != nil {
+			queries = append(queries, ip.String())
+		}
+	}
+	return queries
 }
 
 func (ev *Event) findLoadBalancerQueries() []string {
-	return ev.findQuery(elbPattern)
+	var queries []string
+	for _, q := range ev.findQuery(elbPattern) {
+		queries = append(queries, strings.TrimPrefix(q, "dualstack."))
+	}
+	queries = append(queries, ev.findARNQueries((*resolver.ARN).IsLoadBalancer)...)
+	queries = append(queries, ev.findPatternQueries("loadbalancer")...)
+	return queries
 }
 
-func (ev *Event) findInstances() (result []*ec2.Instance, err error) {
-	queries := ev.findInstanceQueries()
-	if len(queries) == 0 {
-		return
+func (ev *Event) findInstances(queries []string) (result []*ec2.Instance, err error) {
+	if err := fetchInstances(queries); err != nil {
+		return nil, err
+	}
+	matches := make([][]*ec2.Instance, len(queries))
+	candidates := make([][]*ec2.Instance, len(queries))
+	err = parallel(len(queries), func(i int) error {
+		var err error
+		matches[i], err = resolveInstances(queries[i])
+		if err != nil || len(matches[i]) > 0 || !isFuzzyQuery(queries[i]) {
+			return err
+		}
+		candidates[i], err = closestInstances(queries[i])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{})
+	add := func(instance *ec2.Instance) {
+		if _, ok := seen[*instance.InstanceId]; !ok {
+			seen[*instance.InstanceId] = struct{}{}
+			result = append(result, instance)
+		}
 	}
-	instances := make(map[string]*ec2.Instance)
 	notFound := make([]string, 0)
-	for _, q := range queries {
-		instance, err := getInstance(q)
+	for i, q := range queries {
+		if len(candidates[i]) > 0 {
+			defer ev.postCandidates(q, candidates[i])
+			continue
+		}
+		switch len(matches[i]) {
+		case 0:
+			notFound = append(notFound, q)
+		case 1:
+			add(matches[i][0])
+		default:
+			defer ev.postAmbiguousInstances(q, matches[i])
+		}
+	}
+	if len(notFound) > 0 {
+		// the instances may have been launched after the last refresh
+		var found []*ec2.Instance
+		found, notFound, err = ev.retryInstances(notFound)
 		if err != nil {
 			return nil, err
 		}
-		if instance == nil {
-			notFound = append(notFound, q)
-			continue
+		for _, instance := range found {
+			add(instance)
 		}
-		instances[*instance.InstanceId] = instance
 	}
 	if len(notFound) > 0 {
+		rememberNotFound(notFound)
 		defer ev.postNoInstance(notFound)
 	}
-	result = make([]*ec2.Instance, 0, len(instances))
-	for _, i := range instances {
-		result = append(result, i)
-	}
 	return
 }
 
-func (ev *Event) findLoadBalancers() (result []*elb.LoadBalancerDescription, err error) {
-	queries := ev.findLoadBalancerQueries()
-	if len(queries) == 0 {
-		return
+func (ev *Event) findLoadBalancers(queries []string) (result []*elb.LoadBalancerDescription, resultV2 []*elbv2.LoadBalancer, err error) {
+	matches := make([]*elb.LoadBalancerDescription, len(queries))
+	matchesV2 := make([]*elbv2.LoadBalancer, len(queries))
+	err = parallel(len(queries), func(i int) error {
+		var err error
+		if resolverEnabled("loadbalancer") {
+			matches[i], err = resolveLoadBalancer(queries[i])
+			if err != nil || matches[i] != nil {
+				return err
+			}
+		}
+		if resolverEnabled("loadbalancer_v2") {
+			matchesV2[i], err = getLoadBalancerV2(queries[i])
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-	lbs := make(map[string]*elb.LoadBalancerDescription)
+	seen := make(map[string]struct{})
 	notFound := make([]string, 0)
-	for _, q := range queries {
-		lb, err := getLoadBalancer(q)
-		if err != nil {
-			return nil, err
-		}
-		if lb == nil {
+	for i, q := range queries {
+		switch {
+		case matches[i] != nil:
+			if _, ok := seen[*matches[i].DNSName]; !ok {
+				seen[*matches[i].DNSName] = struct{}{}
+				result = append(result, matches[i])
+			}
+		case matchesV2[i] != nil:
+			if _, ok := seen[*matchesV2[i].DNSName]; !ok {
+				seen[*matchesV2[i].DNSName] = struct{}{}
+				resultV2 = append(resultV2, matchesV2[i])
+			}
+		default:
 			notFound = append(notFound, q)
-			continue
 		}
-		lbs[*lb.DNSName] = lb
 	}
 	if len(notFound) > 0 {
 		defer ev.postNoLoadBalancer(notFound)
 	}
-	result = make([]*elb.LoadBalancerDescription, 0, len(lbs))
-	for _, lb := range lbs {
-		result = append(result, lb)
-	}
 	return
 }
 
 func (ev *Event) postInstance(instance *ec2.Instance) error {
-	yamlInstance, err := yaml.Marshal(instance)
+	attachments, err := ev.instanceAttachments(instance)
 	if err != nil {
-		log.Println(err)
 		return err
 	}
+	if err := ev.post(*instance.InstanceId, attachments); err != nil {
+		return err
+	}
+	ev.postDetailedCopy(*instance.InstanceId, func(p *Event) ([]slack.Attachment, error) {
+		return p.instanceAttachments(instance)
+	})
+	return nil
+}
 
+// instanceAttachments renders the card of the instance.
+func (ev *Event) instanceAttachments(instance *ec2.Instance) ([]slack.Attachment, error) {
+	instance = redactedInstance(instance)
 	tagFields := make([]slack.AttachmentField, len(instance.Tags))
 	for i, tag := range instance.Tags {
 		tagFields[i] = slack.AttachmentField{
-			Title: *tag.Key,
-			Value: *tag.Value,
-		}
-	}
-
-	_, _, err = api.PostMessage(
-		ev.Event.Channel,
-		*instance.InstanceId,
-		slack.PostMessageParameters{
-			Attachments: []slack.Attachment{
-				slack.Attachment{
-					Fields: []slack.AttachmentField{
-						slack.AttachmentField{
-							Title: "Instance ID",
-							Value: *instance.InstanceId,
-						},
-						slack.AttachmentField{
-							Title: "Instance Type",
-							Value: *instance.InstanceType,
-						},
-						slack.AttachmentField{
-							Title: "Private DNS Name",
-							Value: *instance.PrivateDnsName,
-						},
-						slack.AttachmentField{
-							Title: "Private IP Address",
-							Value: *instance.PrivateIpAddress,
-						},
-						slack.AttachmentField{
-							Title: "Public DNS Name",
-							Value: *instance.PublicDnsName,
-						},
-						slack.AttachmentField{
-							Title: "Public IP Address",
-							Value: *instance.PublicIpAddress,
-						},
-						slack.AttachmentField{
-							Title: "State",
-							Value: *instance.State.Name,
-						},
-					},
-				},
-				slack.Attachment{
-					Title:  "Tags",
-					Fields: tagFields,
-				},
-				slack.Attachment{
-					Title: "Details",
-					Text:  string(yamlInstance),
-				},
+			Title: aws.StringValue(tag.Key),
+			Value: optional(tag.Value),
+		}
+	}
+
+	detailed := ev.detailedCards()
+
+	fields := []slack.AttachmentField{
+		slack.AttachmentField{
+			Title: tr("Instance ID"),
+			Value: *instance.InstanceId,
+		},
+		slack.AttachmentField{
+			Title: tr("Instance Type"),
+			Value: optional(instance.InstanceType),
+		},
+		slack.AttachmentField{
+			Title: tr("Region"),
+			Value: instanceRegion(*instance.InstanceId),
+		},
+	}
+	if detailed {
+		fields = append(fields,
+			slack.AttachmentField{
+				Title: tr("Private DNS Name"),
+				Value: optionalCode(instance.PrivateDnsName),
+			},
+			slack.AttachmentField{
+				Title: tr("Private IP Address"),
+				Value: optional(instance.PrivateIpAddress),
 			},
-			ThreadTimestamp: ev.Event.Timestamp,
+			slack.AttachmentField{
+				Title: tr("Public DNS Name"),
+				Value: optionalCode(instance.PublicDnsName),
+			},
+			slack.AttachmentField{
+				Title: tr("Public IP Address"),
+				Value: optional(instance.PublicIpAddress),
+			},
+		)
+	}
+	state := placeholder
+	if instance.State != nil {
+		state = optional(instance.State.Name)
+	}
+	fields = append(fields, slack.AttachmentField{
+		Title: tr("State"),
+		Value: state,
+	})
+	lifecycle, interrupted := ev.lifecycleField(instance)
+	fields = append(fields, lifecycle)
+	if f := autoScalingGroupField(instance); f != nil {
+		fields = append(fields, *f)
+	}
+	if f := ownerField(instance); f != nil {
+		fields = append(fields, *f)
+	}
+	if f := imageField(instance); f != nil {
+		fields = append(fields, *f)
+	}
+	if f := statusCheckField(instance); f != nil {
+		fields = append(fields, *f)
+	}
+	fields = append(fields, ev.uptimeFields(instance)...)
+	fields = append(fields, costFields(instance)...)
+	if f, ok := ev.configuredFields("instance", instance, detailed); ok {
+		fields = f
+	}
+
+	attachments := []slack.Attachment{
+		slack.Attachment{
+			Fields:     fields,
+			MarkdownIn: []string{"fields"},
+			Footer: accountContext(
+				cacheSnapshot().InstanceAccounts[*instance.InstanceId],
+				instanceRegion(*instance.InstanceId),
+			),
 		},
-	)
-	return err
+	}
+	tags := make(map[string]string, len(instance.Tags))
+	for _, tag := range instance.Tags {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	if a, ok := ev.renderCard("instance", &CardData{
+		Resource: instance,
+		Tags:     tags,
+		Region:   instanceRegion(*instance.InstanceId),
+		Account:  cacheSnapshot().InstanceAccounts[*instance.InstanceId],
+		Detailed: detailed,
+	}); ok {
+		attachments[0] = a
+	}
+	if detailed && !ev.userPrefs().Compact {
+		details, err := marshalDetails(redactedInstance(rawInstance(instance)), ev.detailsFormat())
+		if err != nil {
+			logError("failed to encode instance", err, nil)
+			return nil, err
+		}
+		attachments = append(attachments,
+			slack.Attachment{
+				Title:  tr("Tags"),
+				Fields: tagFields,
+			},
+			detailsAttachment("instance", *instance.InstanceId, ev.detailsFormat(), details),
+		)
+	}
+	attachments = append(attachments, instanceActions(instance)...)
+	attachments = append(attachments, editTagsAttachment("instance", *instance.InstanceId))
+	if a := notifyOwnerAttachment(instance); a != nil {
+		attachments = append(attachments, *a)
+	}
+	attachments = append(attachments, consoleAttachment(
+		instanceConsoleURL(instanceRegion(*instance.InstanceId), *instance.InstanceId),
+		instanceLinks(instanceRegion(*instance.InstanceId), *instance.InstanceId)...,
+	))
+	registrations, registrationHealth := registrationAttachments(instance)
+	attachments = append(attachments, registrations...)
+	health := instanceHealth(instance).worse(registrationHealth)
+	if interrupted {
+		health = health.worse(Degraded)
+	}
+	attachments[0].Color = health.color()
+	if a := ev.postureAttachment(instance); a != nil {
+		attachments = append(attachments, *a)
+	}
+	attachments = append(attachments, securityGroupAttachments(instance)...)
+	attachments = append(attachments, volumeAttachments(instance)...)
+	if a := ev.scheduledEventsAttachment(instance); a != nil {
+		attachments = append(attachments, *a)
+	}
+	if a := alarmsAttachment(instanceRegion(*instance.InstanceId), "InstanceId", *instance.InstanceId); a != nil {
+		attachments = append(attachments, *a)
+	}
+	if a := metricsAttachment(instance); a != nil {
+		attachments = append(attachments, *a)
+	}
+	if a := sessionAttachment(*instance.InstanceId); a != nil {
+		attachments = append(attachments, *a)
+	}
+	if a := podsAttachment(*instance.InstanceId); a != nil {
+		attachments = append(attachments, *a)
+	}
+	return attachments, nil
 }
 
 func (ev *Event) postLoadBalancer(loadBalancer *elb.LoadBalancerDescription) error {
-	yamlLoadBalancer, err := yaml.Marshal(loadBalancer)
+	attachments, err := ev.loadBalancerAttachments(loadBalancer)
 	if err != nil {
-		log.Println(err)
 		return err
 	}
+	if err := ev.post(*loadBalancer.LoadBalancerName, attachments); err != nil {
+		return err
+	}
+	ev.postDetailedCopy(*loadBalancer.LoadBalancerName, func(p *Event) ([]slack.Attachment, error) {
+		return p.loadBalancerAttachments(loadBalancer)
+	})
+	return nil
+}
+
+// loadBalancerAttachments renders the card of the load balancer.
+func (ev *Event) loadBalancerAttachments(loadBalancer *elb.LoadBalancerDescription) ([]slack.Attachment, error) {
+	details, err := marshalDetails(loadBalancer, ev.detailsFormat())
+	if err != nil {
+		logError("failed to encode load balancer", err, nil)
+		return nil, err
+	}
 
 	tags, err := getLoadBalancerTags(*loadBalancer.LoadBalancerName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	tagFields := make([]slack.AttachmentField, len(tags))
 	for lb, tag := range tags {
 		tagFields[lb] = slack.AttachmentField{
-			Title: *tag.Key,
-			Value: *tag.Value,
-		}
-	}
-
-	_, _, err = api.PostMessage(
-		ev.Event.Channel,
-		*loadBalancer.LoadBalancerName,
-		slack.PostMessageParameters{
-			Attachments: []slack.Attachment{
-				slack.Attachment{
-					Fields: []slack.AttachmentField{
-						slack.AttachmentField{
-							Title: "Name",
-							Value: *loadBalancer.LoadBalancerName,
-						},
-						slack.AttachmentField{
-							Title: "DNS Name",
-							Value: *loadBalancer.DNSName,
-						},
-						slack.AttachmentField{
-							Title: "Scheme",
-							Value: *loadBalancer.Scheme,
-						},
-					},
+			Title: aws.StringValue(tag.Key),
+			Value: currentConfig().redactTag(aws.StringValue(tag.Key), optional(tag.Value)),
+		}
+	}
+
+	attachments := []slack.Attachment{
+		slack.Attachment{
+			Fields: []slack.AttachmentField{
+				slack.AttachmentField{
+					Title: tr("Name"),
+					Value: *loadBalancer.LoadBalancerName,
+				},
+				slack.AttachmentField{
+					Title: tr("DNS Name"),
+					Value: optionalCode(loadBalancer.DNSName),
 				},
-				slack.Attachment{
-					Title:  "Tags",
-					Fields: tagFields,
+				slack.AttachmentField{
+					Title: tr("Scheme"),
+					Value: optional(loadBalancer.Scheme),
 				},
-				slack.Attachment{
-					Title: "Details",
-					Text:  string(yamlLoadBalancer),
+				slack.AttachmentField{
+					Title: tr("Region"),
+					Value: loadBalancerRegion(*loadBalancer.LoadBalancerName),
 				},
 			},
-			ThreadTimestamp: ev.Event.Timestamp,
+			MarkdownIn: []string{"fields"},
+			Footer:     accountContext("", loadBalancerRegion(*loadBalancer.LoadBalancerName)),
 		},
-	)
-	return err
+	}
+	attachments[0].Fields = append(attachments[0].Fields, listenerFields(loadBalancer)...)
+	if f, ok := ev.configuredFields("loadbalancer", loadBalancer, ev.detailedCards()); ok {
+		attachments[0].Fields = f
+	}
+	tagMap := make(map[string]string, len(tags))
+	for _, tag := range tagFields {
+		tagMap[tag.Title] = tag.Value
+	}
+	if a, ok := ev.renderCard("loadbalancer", &CardData{
+		Resource: loadBalancer,
+		Tags:     tagMap,
+		Region:   loadBalancerRegion(*loadBalancer.LoadBalancerName),
+		Detailed: ev.detailedCards(),
+	}); ok {
+		attachments[0] = a
+	}
+	if ev.detailedCards() && !ev.userPrefs().Compact {
+		attachments = append(attachments,
+			slack.Attachment{
+				Title:  tr("Tags"),
+				Fields: tagFields,
+			},
+			detailsAttachment("loadbalancer", *loadBalancer.DNSName, ev.detailsFormat(), details),
+		)
+	}
+	backends, health := backendAttachments(loadBalancer)
+	attachments = append(attachments, backends...)
+	attachments[0].Color = health.color()
+	if a := alarmsAttachment(loadBalancerRegion(*loadBalancer.LoadBalancerName), "LoadBalancerName", *loadBalancer.LoadBalancerName); a != nil {
+		attachments = append(attachments, *a)
+	}
+	attachments = append(attachments, editTagsAttachment("loadbalancer", *loadBalancer.LoadBalancerName))
+	attachments = append(attachments, consoleAttachment(
+		loadBalancerConsoleURL(loadBalancerRegion(*loadBalancer.LoadBalancerName), *loadBalancer.LoadBalancerName),
+		link(cloudWatchMetricsURL(loadBalancerRegion(*loadBalancer.LoadBalancerName), *loadBalancer.LoadBalancerName), tr("CloudWatch Metrics")),
+	))
+	return attachments, nil
 }
 
 func (ev *Event) postNoInstance(queries []string) error {
 	a := make([]slack.Attachment, len(queries))
 	for i, q := range queries {
 		a[i] = slack.Attachment{
-			Text:  q,
-			Color: "#daa038",
+			Text:       code(q),
+			Color:      "#daa038",
+			MarkdownIn: []string{"text"},
+			CallbackID: "retry_lookup",
+			Actions:    []slack.AttachmentAction{retryAction(q)},
+		}
+		if owner, context := addressOwner(q); owner != "" {
+			a[i].Text = owner
+			a[i].Footer = context
 		}
 	}
-	_, _, err := api.PostMessage(
-		ev.Event.Channel,
-		"failed to get instance",
-		slack.PostMessageParameters{
-			Attachments:     a,
-			ThreadTimestamp: ev.Event.Timestamp,
-		},
-	)
-	return err
+	return ev.post(tr("failed to get instance"), a)
 }
 
 func (ev *Event) postNoLoadBalancer(queries []string) error {
 	a := make([]slack.Attachment, len(queries))
 	for i, q := range queries {
 		a[i] = slack.Attachment{
-			Text:  q,
-			Color: "#daa038",
+			Text:       code(q),
+			Color:      "#daa038",
+			MarkdownIn: []string{"text"},
 		}
 	}
-	_, _, err := api.PostMessage(
-		ev.Event.Channel,
-		"failed to get load balancer",
-		slack.PostMessageParameters{
-			Attachments:     a,
-			ThreadTimestamp: ev.Event.Timestamp,
-		},
-	)
-	return err
+	return ev.post(tr("failed to get load balancer"), a)
+}
+
+// isOwnPost reports whether the event was posted by the bot user of an
+// installed workspace.
+func (ev *Event) isOwnPost() bool {
+	team := getTeam(ev.TeamID)
+	return team != nil && ev.Event.User == team.BotUserID
+}
+
+// userPrefs returns the preferences of the user who posted the event.
+func (ev *Event) userPrefs() *UserPrefs {
+	if ev.prefs == nil {
+		ev.prefs = getUserPrefs(ev.Event.User)
+	}
+	return ev.prefs
+}
+
+// post replies in the thread of the event, or by direct message if the
+// user prefers it.
+func (ev *Event) post(text string, attachments []slack.Attachment) error {
+	channel, thread, err := ev.destination()
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		ev.logDryRun("dry run: would post message", text, attachments)
+		return nil
+	}
+	return ev.chat().PostMessage(channel, thread, text, attachments)
+}
+
+// upload shares a file where post would reply.
+func (ev *Event) upload(file *File) error {
+	channel, thread, err := ev.destination()
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		ev.logDryRun("dry run: would upload file", file.Title, nil)
+		return nil
+	}
+	return ev.chat().UploadFile(channel, thread, file)
+}
+
+// destination returns the channel and thread replies to the event go to.
+func (ev *Event) destination() (string, string, error) {
+	if ev.userPrefs().DM {
+		im, err := ev.chat().OpenDM(ev.Event.User)
+		if err != nil {
+			return "", "", err
+		}
+		return im, "", nil
+	}
+	return ev.Event.Channel, ev.Event.Timestamp, nil
+}
+
+func (ev *Event) recordLookup(resolver string, start time.Time) {
+	latency := time.Since(start)
+	f := ev.logFields()
+	f["resolver"] = resolver
+	f["latency_ms"] = latency.Seconds() * 1000
+	f["version"] = version
+	logInfo("lookup", f)
+	recordLookup(ev.Event.User, ev.Event.Channel, resolver, latency)
+}
+
+func (ev *Event) formatTime(t time.Time) string {
+	return t.In(ev.userPrefs().location()).Format("2006-01-02 15:04:05 MST")
+}
+
+func containsSuffix(list []string, suffix string) bool {
+	for _, s := range list {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isComma(r rune) bool {
+	return r == ','
 }
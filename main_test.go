@@ -0,0 +1,330 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/bgpat/ec2bot/internal/awsclients"
+	"github.com/nlopes/slack"
+)
+
+func TestDescribeInstancesPaginates(t *testing.T) {
+	east := &awsclients.FakeEC2{
+		Reservations: fakeReservations(
+			fakeInstance("i-00000001", "10.0.0.1"),
+			fakeInstance("i-00000002", "10.0.0.2"),
+			fakeInstance("i-00000003", "10.0.0.3"),
+			fakeInstance("i-00000004", "10.0.0.4"),
+			fakeInstance("i-00000005", "10.0.0.5"),
+		),
+		PageSize: 2,
+	}
+	west := &awsclients.FakeEC2{
+		Reservations: fakeReservations(fakeInstance("i-00000006", "10.1.0.6")),
+	}
+	defer useFakeAWS(fakeAWS{
+		EC2: map[string]*awsclients.FakeEC2{"us-east-1": east, "us-west-2": west},
+	})()
+
+	resp, err := describeInstances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, r := range resp.Reservations {
+		for _, instance := range r.Instances {
+			ids = append(ids, aws.StringValue(instance.InstanceId))
+		}
+	}
+	if len(ids) != 6 {
+		t.Errorf("got instances %v, want 6", ids)
+	}
+	if n := atomic.LoadInt64(&east.Calls); n != 3 {
+		t.Errorf("described us-east-1 %d times, want 3 pages", n)
+	}
+	if n := atomic.LoadInt64(&west.Calls); n != 1 {
+		t.Errorf("described us-west-2 %d times, want 1 page", n)
+	}
+	for id, want := range map[string]string{"i-00000005": "us-east-1", "i-00000006": "us-west-2"} {
+		if region := instanceRegion(id); region != want {
+			t.Errorf("instanceRegion(%s) = %q, want %q", id, region, want)
+		}
+	}
+}
+
+func TestDescribeInstancesCacheExpiry(t *testing.T) {
+	fake := &awsclients.FakeEC2{
+		Reservations: fakeReservations(fakeInstance("i-00000001", "10.0.0.1")),
+	}
+	defer useFakeAWS(fakeAWS{
+		EC2: map[string]*awsclients.FakeEC2{"us-east-1": fake},
+	})()
+
+	if instance, err := getInstance("10.0.0.1"); err != nil || instance == nil {
+		t.Fatalf("getInstance(10.0.0.1) = %v, %v", instance, err)
+	}
+	fake.Reservations = append(fake.Reservations, fakeReservations(fakeInstance("i-00000002", "10.0.0.2"))...)
+
+	// the cache is used until it expires
+	instance, err := getInstance("10.0.0.2")
+	if err != nil || instance != nil {
+		t.Errorf("getInstance(10.0.0.2) before expiry = %v, %v, want nil", instance, err)
+	}
+	if n := atomic.LoadInt64(&fake.Calls); n != 1 {
+		t.Errorf("described %d times before expiry, want 1", n)
+	}
+
+	cacheMu.Lock()
+	instanceCache.UpdatedAt = instanceCache.UpdatedAt.Add(-instanceCacheTTL - time.Second)
+	cacheMu.Unlock()
+	instance, err = getInstance("10.0.0.2")
+	if err != nil || instance == nil || aws.StringValue(instance.InstanceId) != "i-00000002" {
+		t.Errorf("getInstance(10.0.0.2) after expiry = %v, %v, want i-00000002", instance, err)
+	}
+	if n := atomic.LoadInt64(&fake.Calls); n != 2 {
+		t.Errorf("described %d times after expiry, want 2", n)
+	}
+}
+
+func TestGetInstanceMatches(t *testing.T) {
+	primary := fakeInstance("i-0123456789abcdef0", "10.0.0.1")
+	primary.PublicIpAddress = aws.String("54.0.0.1")
+	primary.PublicDnsName = aws.String("ec2-54-0-0-1.compute-1.amazonaws.com")
+	primary.NetworkInterfaces = []*ec2.InstanceNetworkInterface{{
+		MacAddress: aws.String("0a:00:00:00:00:01"),
+		PrivateIpAddresses: []*ec2.InstancePrivateIpAddress{{
+			PrivateIpAddress: aws.String("10.0.1.5"),
+			Association:      &ec2.InstanceNetworkInterfaceAssociation{PublicIp: aws.String("54.0.0.9")},
+		}},
+		Ipv6Addresses: []*ec2.InstanceIpv6Address{{Ipv6Address: aws.String("2001:db8::1")}},
+	}}
+	other := fakeInstance("i-00000002", "10.0.0.2")
+	other.PrivateDnsName = aws.String("ip-10-0-0-2.us-west-2.compute.internal")
+	defer useFakeAWS(fakeAWS{
+		EC2: map[string]*awsclients.FakeEC2{
+			"us-east-1": {Reservations: fakeReservations(primary)},
+			"us-west-2": {Reservations: fakeReservations(other)},
+		},
+	})()
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"i-0123456789abcdef0", "i-0123456789abcdef0"},
+		{"10.0.0.1", "i-0123456789abcdef0"},
+		{"ip-10-0-0-1.ec2.internal", "i-0123456789abcdef0"},
+		{"54.0.0.1", "i-0123456789abcdef0"},
+		{"ec2-54-0-0-1.compute-1.amazonaws.com", "i-0123456789abcdef0"},
+		{"10.0.1.5", "i-0123456789abcdef0"},
+		{"54.0.0.9", "i-0123456789abcdef0"},
+		{"0a:00:00:00:00:01", "i-0123456789abcdef0"},
+		{"2001:db8::1", "i-0123456789abcdef0"},
+		{"2001:0db8:0:0:0:0:0:1", "i-0123456789abcdef0"},
+		{"i-00000002", "i-00000002"},
+		// host names in other domains are matched by the address
+		{"ip-10-0-0-2.ec2.internal", "i-00000002"},
+		{"10.0.0.99", ""},
+		{"i-0123456789abcdef1", ""},
+	}
+	for _, tt := range tests {
+		instance, err := getInstance(tt.query)
+		if err != nil {
+			t.Errorf("getInstance(%q): %v", tt.query, err)
+			continue
+		}
+		if got := aws.StringValue(instanceID(instance)); got != tt.want {
+			t.Errorf("getInstance(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+	if region := instanceRegion("i-00000002"); region != "us-west-2" {
+		t.Errorf("instanceRegion(i-00000002) = %q, want us-west-2", region)
+	}
+}
+
+// instanceID returns the ID of the instance, or nil if it is nil.
+func instanceID(instance *ec2.Instance) *string {
+	if instance == nil {
+		return nil
+	}
+	return instance.InstanceId
+}
+
+func TestGetLoadBalancerMatches(t *testing.T) {
+	internal := fakeLoadBalancer("web")
+	internal.DNSName = aws.String("internal-web-1234567890.us-east-1.elb.amazonaws.com")
+	fake := &awsclients.FakeELB{
+		LoadBalancers: []*elb.LoadBalancerDescription{
+			// its DNS name ends with that of the internal one
+			fakeLoadBalancer("b-web"),
+			internal,
+			fakeLoadBalancer("api"),
+		},
+		Tags: map[string][]*elb.Tag{
+			"web": {{Key: aws.String("Name"), Value: aws.String("web")}},
+			"api": {{Key: aws.String("Name"), Value: aws.String("api")}},
+		},
+		PageSize: 1,
+	}
+	defer useFakeAWS(fakeAWS{
+		ELB: map[string]*awsclients.FakeELB{"us-east-1": fake},
+	})()
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"web-1234567890.us-east-1.elb.amazonaws.com", "web"},
+		{"internal-web-1234567890.us-east-1.elb.amazonaws.com", "web"},
+		{"dualstack.web-1234567890.us-east-1.elb.amazonaws.com", "web"},
+		{"b-web-1234567890.us-east-1.elb.amazonaws.com", "b-web"},
+		{"API-1234567890.us-east-1.elb.amazonaws.com", "api"},
+		{"db-1234567890.us-east-1.elb.amazonaws.com", ""},
+	}
+	for _, tt := range tests {
+		lb, err := getLoadBalancer(tt.query)
+		if err != nil {
+			t.Errorf("getLoadBalancer(%q): %v", tt.query, err)
+			continue
+		}
+		var got string
+		if lb != nil {
+			got = aws.StringValue(lb.LoadBalancerName)
+		}
+		if got != tt.want {
+			t.Errorf("getLoadBalancer(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+	// the pages are described once while the cache is fresh
+	if n := atomic.LoadInt64(&fake.Calls); n != 3 {
+		t.Errorf("described %d times, want 3 pages", n)
+	}
+
+	// the tags of the other cached load balancers are described together
+	tags, err := getLoadBalancerTags("web")
+	if err != nil || len(tags) != 1 || aws.StringValue(tags[0].Value) != "web" {
+		t.Errorf("getLoadBalancerTags(web) = %v, %v", tags, err)
+	}
+	tags, err = getLoadBalancerTags("api")
+	if err != nil || len(tags) != 1 || aws.StringValue(tags[0].Value) != "api" {
+		t.Errorf("getLoadBalancerTags(api) = %v, %v", tags, err)
+	}
+	if n := atomic.LoadInt64(&fake.Calls); n != 4 {
+		t.Errorf("described %d times with the tags, want 4", n)
+	}
+}
+
+// fakeSSM manages no instances.
+type fakeSSM struct {
+	ssmiface.SSMAPI
+}
+
+func (fakeSSM) DescribeInstanceInformation(*ssm.DescribeInstanceInformationInput) (*ssm.DescribeInstanceInformationOutput, error) {
+	return new(ssm.DescribeInstanceInformationOutput), nil
+}
+
+// fieldValues returns the values of the fields by title.
+func fieldValues(fields []slack.AttachmentField) map[string]string {
+	values := make(map[string]string, len(fields))
+	for _, f := range fields {
+		values[f.Title] = f.Value
+	}
+	return values
+}
+
+func TestInstanceAttachmentsOptionalFields(t *testing.T) {
+	defer useFakeAWS(fakeAWS{})()
+	origSSM, origStatusChecks, origVolumes, origGroup := ssmClient, showStatusChecks, showVolumes, detailsUserGroup
+	ssmClient, showStatusChecks, showVolumes, detailsUserGroup = fakeSSM{}, false, false, ""
+	defer func() {
+		ssmClient, showStatusChecks, showVolumes, detailsUserGroup = origSSM, origStatusChecks, origVolumes, origGroup
+	}()
+
+	stopped := &ec2.Instance{
+		InstanceId:       aws.String("i-00000002"),
+		InstanceType:     aws.String("t3.micro"),
+		PrivateIpAddress: aws.String("10.0.0.2"),
+		State:            &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameStopped)},
+		Tags:             []*ec2.Tag{{Key: aws.String("Name")}},
+	}
+	tests := []struct {
+		name     string
+		instance *ec2.Instance
+		want     map[string]string
+		actions  bool
+	}{
+		{
+			name:     "minimal",
+			instance: &ec2.Instance{InstanceId: aws.String("i-00000001")},
+			want: map[string]string{
+				"Instance ID":        "i-00000001",
+				"Instance Type":      placeholder,
+				"Private DNS Name":   placeholder,
+				"Private IP Address": placeholder,
+				"Public DNS Name":    placeholder,
+				"Public IP Address":  placeholder,
+				"State":              placeholder,
+				"Lifecycle":          "on-demand",
+			},
+		},
+		{
+			name:     "stopped private-only",
+			instance: stopped,
+			want: map[string]string{
+				"Instance ID":        "i-00000002",
+				"Instance Type":      "t3.micro",
+				"Private DNS Name":   placeholder,
+				"Private IP Address": "10.0.0.2",
+				"Public DNS Name":    placeholder,
+				"Public IP Address":  placeholder,
+				"State":              ec2.InstanceStateNameStopped,
+			},
+			actions: true,
+		},
+	}
+	for _, tt := range tests {
+		ev := &Event{Event: &slack.Msg{}, prefs: new(UserPrefs)}
+		attachments, err := ev.instanceAttachments(tt.instance)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(attachments) == 0 {
+			t.Errorf("%s: no attachments", tt.name)
+			continue
+		}
+		got := fieldValues(attachments[0].Fields)
+		for title, want := range tt.want {
+			if title, want = tr(title), tr(want); got[title] != want {
+				t.Errorf("%s: field %q = %q, want %q", tt.name, title, got[title], want)
+			}
+		}
+		var tags map[string]string
+		hasActions := false
+		for _, a := range attachments {
+			if a.Title == tr("Tags") {
+				tags = fieldValues(a.Fields)
+			}
+			for _, action := range a.Actions {
+				if action.Value == aws.StringValue(tt.instance.InstanceId) && action.Name == "start" {
+					hasActions = true
+				}
+			}
+		}
+		if hasActions != tt.actions {
+			t.Errorf("%s: got start button %v, want %v", tt.name, hasActions, tt.actions)
+		}
+		if len(tt.instance.Tags) == 0 && len(tags) != 0 {
+			t.Errorf("%s: got tags %v, want none", tt.name, tags)
+		}
+		if len(tt.instance.Tags) > 0 && tags["Name"] != placeholder {
+			t.Errorf("%s: got tags %v, want Name %q", tt.name, tags, placeholder)
+		}
+	}
+}
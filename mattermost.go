@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/nlopes/slack"
+)
+
+// mattermost is the client of the Mattermost server of $MATTERMOST_URL,
+// which posts as the bot account of $MATTERMOST_TOKEN. Messages are received
+// by an outgoing webhook posting to /mattermost with the token of
+// $MATTERMOST_WEBHOOK_TOKEN. It is nil if the integration is disabled.
+//
+// Lookups and mention commands such as "@ec2bot list" work as on Slack,
+// while interactive buttons, slash commands and dialogs are Slack only, so
+// the buttons are dropped.
+var mattermost *MattermostClient
+
+var mattermostWebhookToken = getenv("MATTERMOST_WEBHOOK_TOKEN")
+
+func init() {
+	u, token := getenv("MATTERMOST_URL"), getenv("MATTERMOST_TOKEN")
+	if u == "" || token == "" {
+		return
+	}
+	if mattermostWebhookToken == "" {
+		logWarn("$MATTERMOST_WEBHOOK_TOKEN is not set, disable the Mattermost integration", nil)
+		return
+	}
+	mattermost = &MattermostClient{
+		URL:    strings.TrimSuffix(u, "/"),
+		Token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// MattermostClient is a minimal client of the Mattermost API v4.
+type MattermostClient struct {
+	URL   string
+	Token string
+
+	client *http.Client
+
+	userOnce sync.Once
+	userID   string
+	userName string
+	userErr  error
+}
+
+// MattermostWebhook is the payload of the outgoing webhooks, which are
+// posted either as a form or as JSON.
+type MattermostWebhook struct {
+	Token     string `json:"token" form:"token"`
+	TeamID    string `json:"team_id" form:"team_id"`
+	ChannelID string `json:"channel_id" form:"channel_id"`
+	UserID    string `json:"user_id" form:"user_id"`
+	UserName  string `json:"user_name" form:"user_name"`
+	PostID    string `json:"post_id" form:"post_id"`
+	Text      string `json:"text" form:"text"`
+}
+
+type mattermostPost struct {
+	ChannelID string                 `json:"channel_id"`
+	RootID    string                 `json:"root_id,omitempty"`
+	Message   string                 `json:"message"`
+	FileIDs   []string               `json:"file_ids,omitempty"`
+	Props     map[string]interface{} `json:"props,omitempty"`
+}
+
+// call sends the request to the path of the API and decodes the response
+// into v unless it is nil.
+func (m *MattermostClient) call(method, path, contentType string, body io.Reader, v interface{}) error {
+	req, err := http.NewRequest(method, m.URL+"/api/v4"+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.Token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, e.Message)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (m *MattermostClient) callJSON(method, path string, in, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return m.call(method, path, "application/json", bytes.NewReader(b), out)
+}
+
+// botUser returns the ID and the name of the user of the token.
+func (m *MattermostClient) botUser() (string, string, error) {
+	m.userOnce.Do(func() {
+		var user struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		}
+		m.userErr = m.call(http.MethodGet, "/users/me", "", nil, &user)
+		m.userID, m.userName = user.ID, user.Username
+	})
+	return m.userID, m.userName, m.userErr
+}
+
+// rootID returns the root of the thread of the post, since replies have to
+// be posted to the root.
+func (m *MattermostClient) rootID(postID string) (string, error) {
+	if postID == "" {
+		return "", nil
+	}
+	var post mattermostPost
+	if err := m.call(http.MethodGet, "/posts/"+postID, "", nil, &post); err != nil {
+		return "", err
+	}
+	if post.RootID != "" {
+		return post.RootID, nil
+	}
+	return postID, nil
+}
+
+func (m *MattermostClient) PostMessage(channel, thread, text string, attachments []slack.Attachment) error {
+	root, err := m.rootID(thread)
+	if err != nil {
+		return err
+	}
+	post := &mattermostPost{ChannelID: channel, RootID: root, Message: text}
+	if len(attachments) > 0 {
+		converted := make([]slack.Attachment, len(attachments))
+		for i, a := range attachments {
+			a.Actions = nil
+			a.CallbackID = ""
+			converted[i] = a
+		}
+		post.Props = map[string]interface{}{"attachments": converted}
+	}
+	return m.callJSON(http.MethodPost, "/posts", post, nil)
+}
+
+func (m *MattermostClient) UploadFile(channel, thread string, file *File) error {
+	root, err := m.rootID(thread)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if err := w.WriteField("channel_id", channel); err != nil {
+		return err
+	}
+	part, err := w.CreateFormFile("files", file.Name)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(file.Content); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	var uploaded struct {
+		FileInfos []struct {
+			ID string `json:"id"`
+		} `json:"file_infos"`
+	}
+	if err := m.call(http.MethodPost, "/files", w.FormDataContentType(), &body, &uploaded); err != nil {
+		return err
+	}
+	post := &mattermostPost{ChannelID: channel, RootID: root, Message: file.Comment}
+	if post.Message == "" {
+		post.Message = file.Title
+	}
+	for _, f := range uploaded.FileInfos {
+		post.FileIDs = append(post.FileIDs, f.ID)
+	}
+	return m.callJSON(http.MethodPost, "/posts", post, nil)
+}
+
+func (m *MattermostClient) OpenDM(user string) (string, error) {
+	bot, _, err := m.botUser()
+	if err != nil {
+		return "", err
+	}
+	var channel struct {
+		ID string `json:"id"`
+	}
+	if err := m.callJSON(http.MethodPost, "/channels/direct", []string{bot, user}, &channel); err != nil {
+		return "", err
+	}
+	return channel.ID, nil
+}
+
+func (m *MattermostClient) Mention() string {
+	_, name, err := m.botUser()
+	if err != nil || name == "" {
+		return ""
+	}
+	return "@" + name
+}
+
+// handleMattermost queues the messages of the outgoing webhook of
+// Mattermost as events, like those of Slack.
+func handleMattermost(c echo.Context) error {
+	w := new(MattermostWebhook)
+	if err := c.Bind(w); err != nil {
+		logError("failed to bind Mattermost webhook", err, nil)
+		return c.String(http.StatusBadRequest, "invalid webhook")
+	}
+	if subtle.ConstantTimeCompare([]byte(w.Token), []byte(mattermostWebhookToken)) != 1 {
+		return c.String(http.StatusUnauthorized, "failed to verify token")
+	}
+	bot, _, err := mattermost.botUser()
+	if err != nil {
+		logError("failed to get Mattermost bot user", err, nil)
+		return c.String(http.StatusInternalServerError, "failed to get bot user")
+	}
+	if w.UserID == bot {
+		return c.JSON(http.StatusOK, struct{}{})
+	}
+	ev := &Event{
+		EventID: "mattermost:" + w.PostID,
+		TeamID:  w.TeamID,
+		Event: &slack.Msg{
+			Type:      "message",
+			Channel:   w.ChannelID,
+			User:      w.UserID,
+			Text:      w.Text,
+			Timestamp: w.PostID,
+		},
+		backend: mattermost,
+	}
+	if ev.seen() {
+		return c.JSON(http.StatusOK, struct{}{})
+	}
+	if !ev.enqueue() {
+		return c.String(http.StatusServiceUnavailable, "queue is full")
+	}
+	// an empty response posts nothing
+	return c.JSON(http.StatusOK, struct{}{})
+}
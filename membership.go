@@ -0,0 +1,115 @@
+package main
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/bgpat/ec2bot/internal/cache"
+	"github.com/nlopes/slack"
+)
+
+// autoScalingGroupTag is the tag of the instances of Auto Scaling Groups
+// naming their group.
+const autoScalingGroupTag = "aws:autoscaling:groupName"
+
+var (
+	// showRegistrations enables the lists of the load balancers and the
+	// target groups instances are registered to on their cards,
+	// $SHOW_REGISTRATIONS. It needs elasticloadbalancing:Describe* and
+	// describes the health of every target group, so it is disabled by
+	// default.
+	showRegistrations = boolEnv("SHOW_REGISTRATIONS", false)
+
+	// instanceRegistrations are the recent registrations by instance ID.
+	instanceRegistrations = cache.NewLRU(1000, time.Minute)
+)
+
+// autoScalingGroupField renders the Auto Scaling Group of the instance. It
+// returns nil if the instance is not in a group.
+func autoScalingGroupField(instance *ec2.Instance) *slack.AttachmentField {
+	group := tagValue(instance.Tags, autoScalingGroupTag)
+	if group == "" {
+		return nil
+	}
+	region := instanceRegion(aws.StringValue(instance.InstanceId))
+	return &slack.AttachmentField{
+		Title: tr("Auto Scaling Group"),
+		Value: link(consoleURL(region, "AutoScalingGroupDetails:id="+group), group),
+		Short: true,
+	}
+}
+
+// getRegistrations returns the registrations of the instance.
+func getRegistrations(id string) ([]Registration, error) {
+	if v, ok := instanceRegistrations.Get(id); ok {
+		return v.([]Registration), nil
+	}
+	registrations, err := findRegistrations(id)
+	if err != nil {
+		return nil, err
+	}
+	instanceRegistrations.Add(id, registrations)
+	return registrations, nil
+}
+
+// classicHealth returns the state of the instance in the Classic ELB.
+func classicHealth(id, name string) (string, error) {
+	svc := regionELBClient(instanceRegion(id))
+	resp, err := svc.DescribeInstanceHealth(&elb.DescribeInstanceHealthInput{
+		LoadBalancerName: aws.String(name),
+		Instances:        []*elb.Instance{{InstanceId: aws.String(id)}},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.InstanceStates) == 0 {
+		return "", nil
+	}
+	return aws.StringValue(resp.InstanceStates[0].State), nil
+}
+
+// registrationAttachments lists the Classic ELBs and the target groups the
+// instance is registered to with its health there, each with a button
+// expanding the load balancer, and the worst health of the instance there.
+// It returns nil if it is disabled.
+func registrationAttachments(instance *ec2.Instance) ([]slack.Attachment, ResourceHealth) {
+	if !showRegistrations {
+		return nil, HealthUnknown
+	}
+	id := aws.StringValue(instance.InstanceId)
+	registrations, err := getRegistrations(id)
+	if err != nil {
+		logError("failed to find registrations", err, logFields{"instance_id": id})
+		return nil, HealthUnknown
+	}
+	var attachments []slack.Attachment
+	health := HealthUnknown
+	for _, r := range registrations {
+		if r.LoadBalancerName != "" {
+			state, err := classicHealth(id, r.LoadBalancerName)
+			if err != nil {
+				logError("failed to describe instance health", err, logFields{"instance_id": id, "load_balancer": r.LoadBalancerName})
+			}
+			health = health.worse(targetHealth(state))
+			attachments = append(attachments, summaryRow("loadbalancer", r.LoadBalancerDNSName, r.LoadBalancerName, state))
+			continue
+		}
+		health = health.worse(targetHealth(r.State))
+		if len(r.LoadBalancerARNs) == 0 {
+			attachments = append(attachments, slack.Attachment{
+				Text:       code(r.String()) + "  " + r.State,
+				MarkdownIn: []string{"text"},
+			})
+			continue
+		}
+		for _, arn := range r.LoadBalancerARNs {
+			attachments = append(attachments, summaryRow("loadbalancer", arn, r.String(), r.State))
+		}
+	}
+	if len(attachments) > 0 {
+		attachments[0].Title = tr("Load Balancers")
+	}
+	return attachments, health
+}
@@ -0,0 +1,34 @@
+package main
+
+import "strings"
+
+// mentionCommands are run by messages starting with a mention of the bot,
+// such as "@ec2bot reboot i-0123456789abcdef0".
+var mentionCommands = map[string]func(ev *Event, args []string) error{
+	"reboot": (*Event).confirmReboot,
+}
+
+// mention returns the words following a mention of the bot at the start of
+// the message.
+func (ev *Event) mention() ([]string, bool) {
+	mention := ev.chat().Mention()
+	fields := strings.Fields(ev.Event.Text)
+	if mention == "" || len(fields) == 0 || fields[0] != mention {
+		return nil, false
+	}
+	return fields[1:], true
+}
+
+// handleMention runs the command mentioned in the event. It reports whether
+// the event was a command.
+func (ev *Event) handleMention() (bool, error) {
+	args, ok := ev.mention()
+	if !ok || len(args) == 0 {
+		return false, nil
+	}
+	cmd, ok := mentionCommands[strings.ToLower(args[0])]
+	if !ok {
+		return false, nil
+	}
+	return true, cmd(ev, args[1:])
+}
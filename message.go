@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nlopes/slack"
+)
+
+var (
+	unfurlLinks = boolEnv("SLACK_UNFURL_LINKS", false)
+	unfurlMedia = boolEnv("SLACK_UNFURL_MEDIA", false)
+)
+
+// messageParameters returns the parameters used for every message the bot
+// posts.
+func messageParameters(thread string, attachments []slack.Attachment) slack.PostMessageParameters {
+	return slack.PostMessageParameters{
+		Attachments:     attachments,
+		ThreadTimestamp: thread,
+		UnfurlLinks:     unfurlLinks,
+		UnfurlMedia:     unfurlMedia,
+	}
+}
+
+// code formats s as inline code so that Slack neither linkifies nor
+// unfurls identifiers such as private DNS names.
+func code(s string) string {
+	if s == "" {
+		return s
+	}
+	return "`" + strings.Replace(s, "`", "'", -1) + "`"
+}
+
+// placeholder is rendered for the optional fields of resources which are
+// not set, such as the public IP address of a private instance.
+const placeholder = "-"
+
+// optional returns the value of the field, or placeholder if it is nil or
+// empty.
+func optional(s *string) string {
+	if s == nil || *s == "" {
+		return placeholder
+	}
+	return *s
+}
+
+// optionalCode returns the value of the field as inline code, or
+// placeholder if it is nil or empty.
+func optionalCode(s *string) string {
+	if s == nil || *s == "" {
+		return placeholder
+	}
+	return code(*s)
+}
+
+// slackLinkPattern matches links, mentions and channel references in the
+// Slack message format such as "<http://example.com|example.com>".
+var slackLinkPattern = regexp.MustCompile(`<([^<>|]*)(?:\|([^<>]*))?>`)
+
+// slackUnescaper unescapes the characters escaped in the Slack message
+// format.
+var slackUnescaper = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+
+// normalizeText removes Slack formatting so that identifiers wrapped in
+// links, code blocks or inline code are found by the patterns. Links are
+// replaced with their URL without the scheme followed by the label.
+func normalizeText(text string) string {
+	text = slackLinkPattern.ReplaceAllStringFunc(text, func(s string) string {
+		m := slackLinkPattern.FindStringSubmatch(s)
+		url := m[1]
+		if i := strings.Index(url, "://"); i >= 0 {
+			url = url[i+3:]
+		}
+		url = strings.TrimPrefix(url, "mailto:")
+		if m[2] == "" || m[2] == url {
+			return " " + url + " "
+		}
+		return " " + url + " " + m[2] + " "
+	})
+	text = strings.Replace(text, "`", " ", -1)
+	return slackUnescaper.Replace(text)
+}
+
+func boolEnv(key string, def bool) bool {
+	v := getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		logWarn(fmt.Sprintf("cannot parse $%s, use default '%t'", key, def), nil)
+		return def
+	}
+	return b
+}
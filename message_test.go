@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "auto-link with the same label",
+			text: "<http://ip-10-0-0-1.eu-west-1.compute.internal|ip-10-0-0-1.eu-west-1.compute.internal>",
+			want: " ip-10-0-0-1.eu-west-1.compute.internal ",
+		},
+		{
+			name: "auto-link without label",
+			text: "<http://ip-10-0-0-1.eu-west-1.compute.internal>",
+			want: " ip-10-0-0-1.eu-west-1.compute.internal ",
+		},
+		{
+			name: "auto-linked address",
+			text: "ping <http://10.0.0.1|10.0.0.1> please",
+			want: "ping  10.0.0.1  please",
+		},
+		{
+			name: "link with another label",
+			text: "<https://console.aws.amazon.com/ec2/|console>",
+			want: " console.aws.amazon.com/ec2/ console ",
+		},
+		{
+			name: "mailto link",
+			text: "<mailto:ops@example.com|ops@example.com>",
+			want: " ops@example.com ",
+		},
+		{
+			name: "mentions and channels",
+			text: "<@U012345> <#C012345|general>",
+			want: " @U012345   #C012345 general ",
+		},
+		{
+			name: "inline code",
+			text: "check `i-0123456789abcdef0`",
+			want: "check  i-0123456789abcdef0 ",
+		},
+		{
+			name: "code block",
+			text: "```\ni-0123456789abcdef0\n10.0.0.1\n```",
+			want: "   \ni-0123456789abcdef0\n10.0.0.1\n   ",
+		},
+		{
+			name: "escaped characters",
+			text: "a &lt;b&gt; &amp;amp; c",
+			want: "a <b> &amp; c",
+		},
+		{
+			name: "plain text",
+			text: "plain i-01234567",
+			want: "plain i-01234567",
+		},
+	}
+	for _, tt := range tests {
+		if got := normalizeText(tt.text); got != tt.want {
+			t.Errorf("%s: normalizeText(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/labstack/echo"
+)
+
+// Metric is a fleet-level gauge computed from the cache snapshot, or a
+// metric of the bot itself.
+type Metric struct {
+	Name string
+	Help string
+	// Type is the Prometheus type of the metric, which defaults to
+	// "gauge".
+	Type   string
+	Labels map[string]string
+	Value  float64
+}
+
+var cloudWatchNamespace = getenv("CLOUDWATCH_NAMESPACE")
+
+// fleetMetrics computes gauges describing the fleet known to the bot.
+func (s *CacheSnapshot) fleetMetrics() []Metric {
+	type instanceKey struct{ state, instanceType, account string }
+	instances := make(map[instanceKey]int)
+	untagged := 0
+	for _, instance := range s.Instances {
+		key := instanceKey{
+			instanceType: aws.StringValue(instance.InstanceType),
+			account:      s.InstanceAccounts[aws.StringValue(instance.InstanceId)],
+		}
+		if instance.State != nil {
+			key.state = aws.StringValue(instance.State.Name)
+		}
+		instances[key]++
+		if len(instance.Tags) == 0 {
+			untagged++
+		}
+	}
+
+	metrics := make([]Metric, 0, len(instances)+3)
+	for key, n := range instances {
+		metrics = append(metrics, Metric{
+			Name: "fleet_instances",
+			Help: "Number of instances by state, type and account.",
+			Labels: map[string]string{
+				"state":   key.state,
+				"type":    key.instanceType,
+				"account": key.account,
+			},
+			Value: float64(n),
+		})
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].labelString() < metrics[j].labelString()
+	})
+	metrics = append(metrics,
+		Metric{
+			Name:  "fleet_untagged_instances",
+			Help:  "Number of instances without any tags.",
+			Value: float64(untagged),
+		},
+		Metric{
+			Name:  "fleet_load_balancers",
+			Help:  "Number of classic load balancers.",
+			Value: float64(len(s.LoadBalancers)),
+		},
+	)
+	if !s.TakenAt.IsZero() {
+		metrics = append(metrics, Metric{
+			Name:  "fleet_snapshot_timestamp_seconds",
+			Help:  "Time the fleet data was last refreshed.",
+			Value: float64(s.TakenAt.Unix()),
+		})
+	}
+	return metrics
+}
+
+// labelEscaper escapes label values in the Prometheus text format, which
+// only escapes backslashes, double quotes and line feeds.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (m Metric) labelString() string {
+	if len(m.Labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(m.Labels))
+	for k := range m.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + `="` + labelEscaper.Replace(m.Labels[k]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// handleMetrics exposes the fleet gauges and the cache metrics in the
+// Prometheus text format.
+func handleMetrics(c echo.Context) error {
+	var b strings.Builder
+	described := make(map[string]bool)
+	metrics := cacheSnapshot().fleetMetrics()
+	metrics = append(metrics, targetHealthMetrics()...)
+	metrics = append(metrics, cacheMetrics()...)
+	metrics = append(metrics, throttleMetrics()...)
+	metrics = append(metrics, queueMetrics()...)
+	for _, m := range metrics {
+		name := "ec2bot_" + m.Name
+		if !described[name] {
+			typ := m.Type
+			if typ == "" {
+				typ = "gauge"
+			}
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, m.Help, name, typ)
+			described[name] = true
+		}
+		fmt.Fprintf(&b, "%s%s %g\n", name, m.labelString(), m.Value)
+	}
+	return c.Blob(http.StatusOK, "text/plain; version=0.0.4", []byte(b.String()))
+}
+
+// exportCloudWatch publishes the fleet gauges to $CLOUDWATCH_NAMESPACE
+// every interval.
+func exportCloudWatch(interval time.Duration) {
+	svc := cloudWatchClient
+	for range time.Tick(interval) {
+		s := cacheSnapshot()
+		if s.TakenAt.IsZero() {
+			continue
+		}
+		var data []*cloudwatch.MetricDatum
+		for _, m := range append(s.fleetMetrics(), targetHealthMetrics()...) {
+			dimensions := make([]*cloudwatch.Dimension, 0, len(m.Labels))
+			for k, v := range m.Labels {
+				dimensions = append(dimensions, &cloudwatch.Dimension{
+					Name:  aws.String(k),
+					Value: aws.String(v),
+				})
+			}
+			data = append(data, &cloudwatch.MetricDatum{
+				MetricName: aws.String(m.Name),
+				Dimensions: dimensions,
+				Value:      aws.Float64(m.Value),
+				Unit:       aws.String(cloudwatch.StandardUnitCount),
+				Timestamp:  aws.Time(s.TakenAt),
+			})
+		}
+		// PutMetricData accepts at most 20 datums per request.
+		for len(data) > 0 {
+			n := len(data)
+			if n > 20 {
+				n = 20
+			}
+			_, err := svc.PutMetricData(&cloudwatch.PutMetricDataInput{
+				Namespace:  aws.String(cloudWatchNamespace),
+				MetricData: data[:n],
+			})
+			if err != nil {
+				logError("failed to put metrics to CloudWatch", err, nil)
+				break
+			}
+			data = data[n:]
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// namePattern matches queries such as "name:web-prod-01" resolved by the
+// Name tag of instances.
+var namePattern = regexp.MustCompile("\\bname:[^\\s,;'\"<>|`]+")
+
+func init() {
+	mentionCommands["lookup"] = (*Event).lookup
+}
+
+// resolveInstances returns the instances identified by the query. Queries
+// by name, wildcards, partial instance IDs and pods may match several
+// instances.
+func resolveInstances(query string) ([]*ec2.Instance, error) {
+	if strings.HasPrefix(query, "name:") {
+		return getInstancesByName(strings.TrimPrefix(query, "name:"))
+	}
+	if strings.HasPrefix(query, "pod:") {
+		return getInstancesByPod(strings.TrimPrefix(query, "pod:"))
+	}
+	if truncatedIDPattern.MatchString(query) {
+		return getInstancesByPartialID(query)
+	}
+	if isWildcard(query) {
+		return getInstancesByDNSName(query)
+	}
+	get := getInstance
+	if strings.HasPrefix(query, "arn:") {
+		get = getInstanceByARN
+	}
+	instance, err := get(query)
+	if err != nil {
+		return nil, err
+	}
+	if instance == nil {
+		if isPartialID(query) {
+			return getInstancesByPartialID(query)
+		}
+		return nil, nil
+	}
+	return []*ec2.Instance{instance}, nil
+}
+
+// getInstancesByName returns the instances whose Name tag is name, which
+// may contain wildcards.
+func getInstancesByName(name string) ([]*ec2.Instance, error) {
+	resp, err := describeInstances()
+	if err != nil {
+		return nil, err
+	}
+	match := func(s string) bool { return s == name }
+	if isWildcard(name) {
+		match = wildcardRegexp(name).MatchString
+	}
+	var result []*ec2.Instance
+	for _, reservation := range resp.Reservations {
+		for _, instance := range reservation.Instances {
+			if match(tagValue(instance.Tags, "Name")) {
+				result = append(result, instance)
+			}
+		}
+	}
+	return result, nil
+}
+
+// getInstancesByDNSName returns the instances whose private or public DNS
+// name matches the wildcard pattern.
+func getInstancesByDNSName(pattern string) ([]*ec2.Instance, error) {
+	resp, err := describeInstances()
+	if err != nil {
+		return nil, err
+	}
+	re := wildcardRegexp(pattern)
+	var result []*ec2.Instance
+	for _, reservation := range resp.Reservations {
+		for _, instance := range reservation.Instances {
+			if re.MatchString(aws.StringValue(instance.PrivateDnsName)) ||
+				re.MatchString(aws.StringValue(instance.PublicDnsName)) {
+				result = append(result, instance)
+			}
+		}
+	}
+	return result, nil
+}
+
+// lookup handles "@ec2bot lookup <query>..." where a query is an instance
+// ID, an address or a Name tag.
+func (ev *Event) lookup(args []string) error {
+	if len(args) == 0 {
+		return errors.New(tr("usage: lookup <instance id|address|name>"))
+	}
+	for _, q := range args {
+		matches, err := resolveInstances(q)
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 && !strings.HasPrefix(q, "name:") {
+			matches, err = getInstancesByName(q)
+			if err != nil {
+				return err
+			}
+		}
+		if len(matches) == 0 && !isWildcard(q) {
+			candidates, err := closestInstances(q)
+			if err != nil {
+				return err
+			}
+			if len(candidates) > 0 {
+				if err := ev.postCandidates(q, candidates); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		switch len(matches) {
+		case 0:
+			err = ev.postNoInstance([]string{q})
+		case 1:
+			err = ev.postInstance(matches[0])
+		default:
+			err = ev.postAmbiguousInstances(q, matches)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postAmbiguousInstances lists the instances matching the query with
+// buttons to expand the intended one.
+func (ev *Event) postAmbiguousInstances(query string, instances []*ec2.Instance) error {
+	return ev.postSummary(
+		tr("%d instances match %s", len(instances), code(query)),
+		ev.instanceSummaryRows(instances),
+	)
+}
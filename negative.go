@@ -0,0 +1,62 @@
+package main
+
+import (
+	"time"
+
+	"github.com/bgpat/ec2bot/internal/cache"
+)
+
+// notFoundInstances remembers the queries which matched no instance for
+// $NEGATIVE_CACHE_TTL, so that identifiers of terminated instances pasted
+// again and again are neither looked up nor reported each time. It is nil
+// if the TTL is zero. It is purged whenever the instances are refreshed.
+var notFoundInstances = cache.NewLRU(1000, 5*time.Minute)
+
+func init() {
+	if v := getenv("NEGATIVE_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			invalidCacheTTL("NEGATIVE_CACHE_TTL", v)
+			return
+		}
+		if d <= 0 {
+			notFoundInstances = nil
+			return
+		}
+		notFoundInstances.TTL = d
+	}
+}
+
+// withoutNotFound returns the queries which were not found recently.
+func withoutNotFound(queries []string) []string {
+	if notFoundInstances == nil {
+		return queries
+	}
+	result := make([]string, 0, len(queries))
+	for _, q := range queries {
+		_, ok := notFoundInstances.Get(q)
+		recordCacheLookup("not_found_instances", ok)
+		if !ok {
+			result = append(result, q)
+		}
+	}
+	return result
+}
+
+// rememberNotFound adds the queries which matched no instance.
+func rememberNotFound(queries []string) {
+	if notFoundInstances == nil {
+		return
+	}
+	for _, q := range queries {
+		notFoundInstances.Add(q, struct{}{})
+	}
+}
+
+// forgetNotFound clears the queries which were not found, as they may match
+// instances after a refresh.
+func forgetNotFound() {
+	if notFoundInstances != nil {
+		notFoundInstances.Purge()
+	}
+}
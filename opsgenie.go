@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+var (
+	// opsgenieToken is the token of the webhooks and the callbacks of the
+	// custom actions of Opsgenie, which is passed as the "token" query
+	// parameter, $OPSGENIE_WEBHOOK_TOKEN. It enables the /opsgenie
+	// endpoint.
+	opsgenieToken = getenv("OPSGENIE_WEBHOOK_TOKEN")
+	// opsgenieAPIKey is the key of the API integration adding the notes to
+	// the alerts, $OPSGENIE_API_KEY. Notes are not added without it.
+	opsgenieAPIKey = getenv("OPSGENIE_API_KEY")
+	// opsgenieAPIURL is the endpoint of the API, $OPSGENIE_API_URL, which
+	// is "https://api.eu.opsgenie.com" for the EU instance.
+	opsgenieAPIURL = "https://api.opsgenie.com"
+	// opsgenieActions are the actions enriching the alerts,
+	// $OPSGENIE_ACTIONS, such as custom actions run by the responders.
+	opsgenieActions = []string{"Create"}
+	// opsgenieChannels are the Slack channels of the alerts by the name of
+	// their integration, $OPSGENIE_CHANNELS, such as
+	// "CloudWatch=C0123456,*=C0654321" where "*" is the default.
+	opsgenieChannels = parseChannelMap("OPSGENIE_CHANNELS")
+)
+
+// opsgenieNoteLimit is the maximum length of the notes of alerts.
+const opsgenieNoteLimit = 25000
+
+var opsgenieClient = &http.Client{Timeout: 10 * time.Second}
+
+func init() {
+	if v := getenv("OPSGENIE_API_URL"); v != "" {
+		opsgenieAPIURL = strings.TrimSuffix(v, "/")
+	}
+	if v := getenv("OPSGENIE_ACTIONS"); v != "" {
+		opsgenieActions = strings.FieldsFunc(v, isComma)
+	}
+}
+
+// opsgenieWebhook is the body of the webhooks and the callbacks of the
+// custom actions.
+type opsgenieWebhook struct {
+	Action          string `json:"action"`
+	IntegrationName string `json:"integrationName"`
+	Alert           struct {
+		AlertID     string            `json:"alertId"`
+		TinyID      string            `json:"tinyId"`
+		Message     string            `json:"message"`
+		Description string            `json:"description"`
+		Entity      string            `json:"entity"`
+		Tags        []string          `json:"tags"`
+		Details     map[string]string `json:"details"`
+	} `json:"alert"`
+}
+
+// text returns the texts of the alert scanned for resources.
+func (w *opsgenieWebhook) text() string {
+	texts := []string{w.Alert.Message, w.Alert.Description, w.Alert.Entity}
+	texts = append(texts, w.Alert.Tags...)
+	for k, v := range w.Alert.Details {
+		texts = append(texts, k+": "+v)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// enriched reports whether the action of the webhook enriches the alert.
+func (w *opsgenieWebhook) enriched() bool {
+	for _, a := range opsgenieActions {
+		if a == w.Action {
+			return true
+		}
+	}
+	return false
+}
+
+// handleOpsgenie posts the cards of the resources in the alerts of
+// Opsgenie to the channel of their integration, and adds them to the alerts
+// as a note.
+func handleOpsgenie(c echo.Context) error {
+	if subtle.ConstantTimeCompare([]byte(c.QueryParam("token")), []byte(opsgenieToken)) != 1 {
+		return c.String(http.StatusUnauthorized, "failed to verify token")
+	}
+	w := new(opsgenieWebhook)
+	if err := c.Bind(w); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if !w.enriched() {
+		return c.String(http.StatusOK, "ignore "+w.Action)
+	}
+	channel := mappedChannel(opsgenieChannels, w.IntegrationName)
+	if channel == "" {
+		return c.String(http.StatusOK, "no channel of integration "+w.IntegrationName)
+	}
+	// alerts are enriched once on creation, and every time by custom actions
+	eventID := ""
+	if w.Action == "Create" {
+		eventID = "opsgenie:" + w.Alert.AlertID
+	}
+	goBackground(func() {
+		header := tr("Opsgenie alert %s", code("#"+w.Alert.TinyID)+" "+w.Alert.Message)
+		attachments := enrich(eventID, channel, header, nil, w.text())
+		if len(attachments) == 0 || opsgenieAPIKey == "" {
+			return
+		}
+		note := (&Notification{Title: tr("Resources of the alert"), Attachments: attachments}).plainText()
+		if err := addOpsgenieNote(w.Alert.AlertID, note); err != nil {
+			logError("failed to add Opsgenie note", err, logFields{"alert": w.Alert.AlertID})
+		}
+	})
+	return c.String(http.StatusOK, "queued")
+}
+
+// addOpsgenieNote adds the note to the alert, truncated to
+// opsgenieNoteLimit.
+func addOpsgenieNote(alertID, note string) error {
+	note = truncateRunes(note, opsgenieNoteLimit)
+	body, err := json.Marshal(map[string]string{"note": note, "user": "ec2bot"})
+	if err != nil {
+		return err
+	}
+	u := opsgenieAPIURL + "/v2/alerts/" + url.PathEscape(alertID) + "/notes?identifierType=id"
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "GenieKey "+opsgenieAPIKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := opsgenieClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("POST notes of alert %s: %s", alertID, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/nlopes/slack"
+)
+
+// notifyOwnerButton adds a button mentioning the owners of instances in the
+// thread to their cards, $NOTIFY_OWNER_BUTTON.
+var notifyOwnerButton = boolEnv("NOTIFY_OWNER_BUTTON", false)
+
+// slackOwnerIDPattern matches the IDs of the users, the user groups and the
+// channels owners are mapped to.
+var slackOwnerIDPattern = regexp.MustCompile(`^[UWSC][A-Z0-9]+$`)
+
+func init() {
+	actionHandlers["notify_owner"] = handleNotifyOwner
+}
+
+// ownerMention formats the user, the user group or the channel of the ID.
+func ownerMention(id string) string {
+	switch id[0] {
+	case 'S':
+		return "<!subteam^" + id + ">"
+	case 'C':
+		return "<#" + id + ">"
+	}
+	return "<@" + id + ">"
+}
+
+// ownerMentions returns the mentions of the owners of the tags by the owners
+// of the config file in the order of the tag keys.
+func ownerMentions(tags []*ec2.Tag) []string {
+	owners := currentConfig().Owners
+	if len(owners) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(owners))
+	for key := range owners {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var mentions []string
+	for _, key := range keys {
+		if id, ok := owners[key][tagValue(tags, key)]; ok {
+			mentions = append(mentions, ownerMention(id))
+		}
+	}
+	return mentions
+}
+
+// ownerField shows the owners of the instance, or returns nil if it has
+// none.
+func ownerField(instance *ec2.Instance) *slack.AttachmentField {
+	mentions := ownerMentions(instance.Tags)
+	if len(mentions) == 0 {
+		return nil
+	}
+	return &slack.AttachmentField{
+		Title: tr("Owner"),
+		Value: strings.Join(mentions, " / "),
+	}
+}
+
+// notifyOwnerAttachment returns a button to mention the owners of the
+// instance, or nil if it is disabled or the instance has no owners.
+func notifyOwnerAttachment(instance *ec2.Instance) *slack.Attachment {
+	if !notifyOwnerButton || len(ownerMentions(instance.Tags)) == 0 {
+		return nil
+	}
+	return &slack.Attachment{
+		Fallback:   aws.StringValue(instance.InstanceId),
+		CallbackID: "notify_owner",
+		Actions: []slack.AttachmentAction{
+			slack.AttachmentAction{
+				Name:  "instance",
+				Text:  tr("Notify owner"),
+				Type:  "button",
+				Value: aws.StringValue(instance.InstanceId),
+			},
+		},
+	}
+}
+
+// handleNotifyOwner mentions the owners of the instance of the clicked card
+// in the thread.
+func handleNotifyOwner(cb *ActionCallback, action slack.AttachmentAction) (*slack.Msg, error) {
+	instance, err := getInstance(action.Value)
+	if err != nil {
+		return nil, err
+	}
+	ev := cb.event()
+	if instance == nil {
+		return nil, ev.postNoInstance([]string{action.Value})
+	}
+	mentions := ownerMentions(redactedInstance(instance).Tags)
+	if len(mentions) == 0 {
+		return nil, ev.post(tr("%s has no owners", code(action.Value)), nil)
+	}
+	return nil, ev.post(tr("%s, <@%s> asked you to look at %s", strings.Join(mentions, " "), cb.User.ID, code(action.Value)), nil)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+var (
+	// pagerDutySecret is the secret signing the V3 webhooks of PagerDuty,
+	// $PAGERDUTY_WEBHOOK_SECRET, which enables the /pagerduty endpoint.
+	pagerDutySecret = getenv("PAGERDUTY_WEBHOOK_SECRET")
+	// pagerDutyToken is the REST API token reading the alerts of the
+	// incidents, $PAGERDUTY_API_TOKEN. Only the titles of the incidents are
+	// scanned without it.
+	pagerDutyToken = getenv("PAGERDUTY_API_TOKEN")
+	// pagerDutyChannels are the Slack channels of the incidents by the ID
+	// of their service, $PAGERDUTY_CHANNELS, such as
+	// "PABC123=C0123456,*=C0654321" where "*" is the default.
+	pagerDutyChannels = parseChannelMap("PAGERDUTY_CHANNELS")
+)
+
+var pagerDutyClient = &http.Client{Timeout: 10 * time.Second}
+
+// pagerDutyWebhook is the body of the V3 webhooks.
+type pagerDutyWebhook struct {
+	Event struct {
+		ID        string `json:"id"`
+		EventType string `json:"event_type"`
+		Data      struct {
+			ID      string `json:"id"`
+			Type    string `json:"type"`
+			Title   string `json:"title"`
+			HTMLURL string `json:"html_url"`
+			Service struct {
+				ID string `json:"id"`
+			} `json:"service"`
+		} `json:"data"`
+	} `json:"event"`
+}
+
+// verifyPagerDutySignature reports whether one of the signatures of the
+// X-PagerDuty-Signature header, such as "v1=<hex>,v1=<hex>", is that of the
+// body.
+func verifyPagerDutySignature(header string, body []byte) bool {
+	mac := hmac.New(sha256.New, []byte(pagerDutySecret))
+	mac.Write(body)
+	expected := "v1=" + hex.EncodeToString(mac.Sum(nil))
+	for _, s := range strings.Split(header, ",") {
+		if hmac.Equal([]byte(strings.TrimSpace(s)), []byte(expected)) {
+			return true
+		}
+	}
+	return false
+}
+
+// handlePagerDuty posts the cards of the resources in the incidents opened
+// in PagerDuty to the channel of their service, in the thread of a message
+// linking to the incident.
+func handlePagerDuty(c echo.Context) error {
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		return err
+	}
+	if !verifyPagerDutySignature(c.Request().Header.Get("X-PagerDuty-Signature"), body) {
+		return c.String(http.StatusUnauthorized, "failed to verify signature")
+	}
+	var w pagerDutyWebhook
+	if err := json.Unmarshal(body, &w); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if w.Event.EventType != "incident.triggered" {
+		return c.String(http.StatusOK, "ignore "+w.Event.EventType)
+	}
+	incident := w.Event.Data
+	channel := mappedChannel(pagerDutyChannels, incident.Service.ID)
+	if channel == "" {
+		return c.String(http.StatusOK, "no channel of service "+incident.Service.ID)
+	}
+	goBackground(func() { enrichIncident(w.Event.ID, channel, incident.ID, incident.Title, incident.HTMLURL) })
+	return c.String(http.StatusOK, "queued")
+}
+
+// enrichIncident resolves the resources in the title and the alerts of the
+// incident and posts their cards.
+func enrichIncident(eventID, channel, id, title, htmlURL string) {
+	texts := []string{title}
+	if pagerDutyToken != "" {
+		alerts, err := incidentAlerts(id)
+		if err != nil {
+			logError("failed to get PagerDuty alerts", err, logFields{"incident": id})
+		}
+		texts = append(texts, alerts...)
+	}
+	enrich("pagerduty:"+eventID, channel, tr("PagerDuty incident %s", link(htmlURL, title)), nil, strings.Join(texts, "\n"))
+}
+
+// incidentAlerts returns the summaries and the details of the alerts of
+// the incident as texts.
+func incidentAlerts(id string) ([]string, error) {
+	req, err := http.NewRequest(http.MethodGet, "https://api.pagerduty.com/incidents/"+id+"/alerts", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Token token="+pagerDutyToken)
+	req.Header.Set("Accept", "application/vnd.pagerduty+json;version=2")
+	resp, err := pagerDutyClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET alerts of incident %s: %s", id, resp.Status)
+	}
+	var list struct {
+		Alerts []struct {
+			Summary string `json:"summary"`
+			Body    struct {
+				Details json.RawMessage `json:"details"`
+			} `json:"body"`
+		} `json:"alerts"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	var texts []string
+	for _, a := range list.Alerts {
+		texts = append(texts, a.Summary)
+		if len(a.Body.Details) > 0 {
+			texts = append(texts, string(a.Body.Details))
+		}
+	}
+	return texts, nil
+}
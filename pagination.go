@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bgpat/ec2bot/internal/cache"
+	"github.com/nlopes/slack"
+)
+
+// summaryPages are the paginated summaries by ID, so that their buttons can
+// render other pages. They are kept in memory, so the buttons of summaries
+// posted before a restart or by another replica reply that they expired.
+var summaryPages = cache.NewLRU(1000, 24*time.Hour)
+
+// pagedSummary is a summary whose rows do not fit in a single message.
+type pagedSummary struct {
+	Text string
+	Rows []slack.Attachment
+}
+
+func init() {
+	actionHandlers["summary_page"] = handleSummaryPage
+}
+
+// postPagedSummary posts the first page of the rows with buttons to the
+// other pages.
+func (ev *Event) postPagedSummary(text string, rows []slack.Attachment) error {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	id := hex.EncodeToString(b)
+	s := &pagedSummary{Text: text, Rows: rows}
+	summaryPages.Add(id, s)
+	msg := s.page(id, 0)
+	return ev.post(msg.Text, msg.Attachments)
+}
+
+// page renders a page of the summary.
+func (s *pagedSummary) page(id string, page int) *slack.Msg {
+	pages := (len(s.Rows) + summaryRows - 1) / summaryRows
+	if page < 0 || page >= pages {
+		page = 0
+	}
+	end := (page + 1) * summaryRows
+	if end > len(s.Rows) {
+		end = len(s.Rows)
+	}
+	attachments := make([]slack.Attachment, 0, end-page*summaryRows+1)
+	attachments = append(attachments, s.Rows[page*summaryRows:end]...)
+	attachments = append(attachments, pagerAttachment("summary_page", s.Text, id, page, pages))
+	return &slack.Msg{
+		Text:        s.Text,
+		Attachments: attachments,
+	}
+}
+
+// handleSummaryPage replaces the summary with another page.
+func handleSummaryPage(cb *ActionCallback, action slack.AttachmentAction) (*slack.Msg, error) {
+	page, id, err := parsePageValue(action.Value)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := summaryPages.Get(id)
+	if !ok {
+		return nil, errors.New(tr("this summary has expired, look the resources up again"))
+	}
+	return v.(*pagedSummary).page(id, page), nil
+}
+
+// pagerAttachment renders the footer of a page with the buttons to the
+// previous and next pages. The key identifies the paginated results in the
+// values of the buttons, which parsePageValue parses.
+func pagerAttachment(callbackID, fallback, key string, page, pages int) slack.Attachment {
+	var actions []slack.AttachmentAction
+	if page > 0 {
+		actions = append(actions, slack.AttachmentAction{
+			Name:  "page",
+			Text:  tr("Previous"),
+			Type:  "button",
+			Value: fmt.Sprintf("%d %s", page-1, key),
+		})
+	}
+	if page+1 < pages {
+		actions = append(actions, slack.AttachmentAction{
+			Name:  "page",
+			Text:  tr("Next"),
+			Type:  "button",
+			Value: fmt.Sprintf("%d %s", page+1, key),
+		})
+	}
+	return slack.Attachment{
+		Fallback:   fallback,
+		Footer:     tr("page %d of %d", page+1, pages),
+		CallbackID: callbackID,
+		Actions:    actions,
+	}
+}
+
+// parsePageValue returns the page and the key of the value of a button
+// rendered by pagerAttachment.
+func parsePageValue(value string) (int, string, error) {
+	v := strings.SplitN(value, " ", 2)
+	if len(v) != 2 {
+		return 0, "", errors.New(tr("invalid request"))
+	}
+	page, err := strconv.Atoi(v[0])
+	if err != nil {
+		return 0, "", errors.New(tr("invalid request"))
+	}
+	return page, v[1], nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/nlopes/slack"
+)
+
+// resolveConcurrency is the maximum number of queries resolved or cards
+// rendered at once for a single message.
+var resolveConcurrency = 8
+
+func init() {
+	if v := getenv("RESOLVE_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			logWarn("cannot parse $RESOLVE_CONCURRENCY, use default '8'", nil)
+			return
+		}
+		resolveConcurrency = n
+	}
+}
+
+// parallel calls fn for each index below n with at most
+// resolveConcurrency calls at once, and returns the error of the lowest
+// index. Results are stored by index so that their order does not depend
+// on which call finishes first.
+func parallel(n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	sem := make(chan struct{}, resolveConcurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postInstances renders the cards of the instances in parallel and posts
+// them in order.
+func (ev *Event) postInstances(instances []*ec2.Instance) error {
+	// load the preferences before they are read concurrently
+	ev.userPrefs()
+	cards := make([][]slack.Attachment, len(instances))
+	err := parallel(len(instances), func(i int) error {
+		var err error
+		cards[i], err = ev.instanceAttachments(instances[i])
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for i, instance := range instances {
+		if err := ev.post(*instance.InstanceId, cards[i]); err != nil {
+			return err
+		}
+		ev.postDetailedCopy(*instance.InstanceId, func(p *Event) ([]slack.Attachment, error) {
+			return p.instanceAttachments(instance)
+		})
+	}
+	return nil
+}
+
+// postLoadBalancers renders the cards of the Classic ELBs, ALBs and NLBs in
+// parallel and posts them in order.
+func (ev *Event) postLoadBalancers(lbs []*elb.LoadBalancerDescription, lbsV2 []*elbv2.LoadBalancer) error {
+	ev.userPrefs()
+	cards := make([][]slack.Attachment, len(lbs)+len(lbsV2))
+	err := parallel(len(cards), func(i int) error {
+		var err error
+		if i < len(lbs) {
+			cards[i], err = ev.loadBalancerAttachments(lbs[i])
+		} else {
+			cards[i], err = ev.loadBalancerV2Attachments(lbsV2[i-len(lbs)])
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for i, card := range cards {
+		var name *string
+		if i < len(lbs) {
+			name = lbs[i].LoadBalancerName
+		} else {
+			name = lbsV2[i-len(lbs)].LoadBalancerName
+		}
+		if err := ev.post(aws.StringValue(name), card); err != nil {
+			return err
+		}
+		ev.postDetailedCopy(aws.StringValue(name), func(p *Event) ([]slack.Attachment, error) {
+			if i < len(lbs) {
+				return p.loadBalancerAttachments(lbs[i])
+			}
+			return p.loadBalancerV2Attachments(lbsV2[i-len(lbs)])
+		})
+	}
+	return nil
+}
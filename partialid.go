@@ -0,0 +1,40 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// truncatedIDPattern matches instance IDs truncated at the start such as
+// "i-…89abcdef0" in logs.
+var truncatedIDPattern = regexp.MustCompile(`\bi-(?:\.\.\.|…)[0-9a-f]{5,17}\b`)
+
+// isPartialID reports whether the query may be a shortened instance ID.
+func isPartialID(query string) bool {
+	return truncatedIDPattern.MatchString(query) || hostIDPattern.FindString(query) == query
+}
+
+// getInstancesByPartialID returns the instances whose ID starts or ends
+// with the hex digits of the query. Truncated IDs only match the end.
+func getInstancesByPartialID(query string) ([]*ec2.Instance, error) {
+	resp, err := describeInstances()
+	if err != nil {
+		return nil, err
+	}
+	digits := strings.TrimPrefix(query, "i-")
+	truncated := strings.HasPrefix(digits, "...") || strings.HasPrefix(digits, "…")
+	digits = strings.TrimLeft(strings.TrimLeft(digits, "."), "…")
+	var result []*ec2.Instance
+	for _, reservation := range resp.Reservations {
+		for _, instance := range reservation.Instances {
+			id := strings.TrimPrefix(aws.StringValue(instance.InstanceId), "i-")
+			if strings.HasSuffix(id, digits) || (!truncated && strings.HasPrefix(id, digits)) {
+				result = append(result, instance)
+			}
+		}
+	}
+	return result, nil
+}
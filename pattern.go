@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// MatchPattern is an additional pattern defined by the operator. Matches
+// are resolved as instances, load balancers or by a webhook.
+type MatchPattern struct {
+	Pattern string `json:"pattern"`
+	// Resolver is "instance", "loadbalancer" or "webhook".
+	Resolver string `json:"resolver"`
+	// Query rewrites the match into the query passed to the resolver, such
+	// as "name:$1". The whole match is used if it is empty.
+	Query string `json:"query"`
+	// URL is the endpoint of the webhook resolver.
+	URL string `json:"url"`
+
+	re *regexp.Regexp
+}
+
+// WebhookQuery is posted to the URL of a webhook resolver.
+type WebhookQuery struct {
+	Query   string `json:"query"`
+	TeamID  string `json:"team_id"`
+	Channel string `json:"channel"`
+	User    string `json:"user"`
+}
+
+// WebhookReply is the reply of a webhook resolver posted to the thread. An
+// empty reply posts nothing.
+type WebhookReply struct {
+	Text        string             `json:"text"`
+	Attachments []slack.Attachment `json:"attachments"`
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+func (p *MatchPattern) compile() error {
+	re, err := regexp.Compile(p.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", p.Pattern, err)
+	}
+	switch p.Resolver {
+	case "instance", "loadbalancer":
+	case "webhook":
+		if p.URL == "" {
+			return fmt.Errorf("pattern %q: missing url of webhook", p.Pattern)
+		}
+	default:
+		return fmt.Errorf("pattern %q: unknown resolver %q", p.Pattern, p.Resolver)
+	}
+	p.re = re
+	return nil
+}
+
+// findPatternQueries returns the queries matched by the configured
+// patterns of the resolver.
+func (ev *Event) findPatternQueries(resolver string) []string {
+	var queries []string
+	for _, p := range currentConfig().Patterns {
+		if p.Resolver == resolver {
+			queries = append(queries, ev.findPatternQuery(p)...)
+		}
+	}
+	return queries
+}
+
+func (ev *Event) findPatternQuery(p *MatchPattern) []string {
+	queries := make(map[string]struct{})
+	for _, text := range ev.texts() {
+		for _, m := range p.re.FindAllStringSubmatchIndex(text, -1) {
+			q := text[m[0]:m[1]]
+			if currentConfig().excluded(q) {
+				continue
+			}
+			if p.Query != "" {
+				q = string(p.re.ExpandString(nil, p.Query, text, m))
+			}
+			queries[q] = struct{}{}
+		}
+	}
+	result := make([]string, 0, len(queries))
+	for q := range queries {
+		result = append(result, q)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// resolveWebhooks posts the replies of the webhook resolvers to the queries
+// matched in the event.
+func (ev *Event) resolveWebhooks() {
+	for _, p := range currentConfig().Patterns {
+		if p.Resolver != "webhook" {
+			continue
+		}
+		for _, q := range ev.findPatternQuery(p) {
+			reply, err := ev.callWebhook(p.URL, q)
+			if err != nil {
+				logError("failed to call webhook", err, logFields{"query": q, "resolver": "webhook"})
+				continue
+			}
+			if reply.Text != "" || len(reply.Attachments) > 0 {
+				ev.post(reply.Text, reply.Attachments)
+			}
+		}
+	}
+}
+
+func (ev *Event) callWebhook(url, query string) (*WebhookReply, error) {
+	body, err := json.Marshal(&WebhookQuery{
+		Query:   query,
+		TeamID:  ev.TeamID,
+		Channel: ev.Event.Channel,
+		User:    ev.Event.User,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("webhook %s returned %s", url, resp.Status)
+	}
+	reply := new(WebhookReply)
+	if err := json.NewDecoder(resp.Body).Decode(reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/bgpat/ec2bot/internal/cache"
+	"github.com/nlopes/slack"
+)
+
+var (
+	// postureGroups are the security groups checked by region and ID.
+	postureGroups = cache.NewLRU(1000, 5*time.Minute)
+	// publicSubnets report whether the subnets by region and ID route to
+	// an internet gateway.
+	publicSubnets = cache.NewLRU(1000, 5*time.Minute)
+)
+
+// remoteAccessPorts are the ports which must not be open to the world.
+var remoteAccessPorts = []struct {
+	Port int64
+	Name string
+}{
+	{22, "SSH"},
+	{3389, "RDP"},
+}
+
+// worldCIDR reports whether the range is of all addresses.
+func worldCIDR(cidr string) bool {
+	return cidr == "0.0.0.0/0" || cidr == "::/0"
+}
+
+// postureChannel reports whether the channel of the event warns of risky
+// configurations.
+func (ev *Event) postureChannel() bool {
+	return getChannelPrefs(ev.Event.Channel).Posture
+}
+
+// postureAttachment warns of the risky configurations of the instance, or
+// returns nil if it has none or the channel does not warn of them.
+func (ev *Event) postureAttachment(instance *ec2.Instance) *slack.Attachment {
+	if !ev.postureChannel() {
+		return nil
+	}
+	region := instanceRegion(aws.StringValue(instance.InstanceId))
+	var warnings []string
+	for _, g := range instance.SecurityGroups {
+		group, err := postureGroup(region, aws.StringValue(g.GroupId))
+		if err != nil {
+			logError("failed to describe security group", err, logFields{"group_id": aws.StringValue(g.GroupId)})
+			continue
+		}
+		if group == nil {
+			continue
+		}
+		for _, p := range remoteAccessPorts {
+			if openToWorld(group, p.Port) {
+				warnings = append(warnings, tr("%s is open to the world by %s", p.Name, code(aws.StringValue(g.GroupId))))
+			}
+		}
+	}
+	if aws.StringValue(instance.PublicIpAddress) != "" && instance.SubnetId != nil {
+		public, err := publicSubnet(region, aws.StringValue(instance.SubnetId))
+		if err != nil {
+			logError("failed to describe route tables", err, logFields{"subnet_id": aws.StringValue(instance.SubnetId)})
+		} else if public {
+			warnings = append(warnings, tr("has a public IP address in the public subnet %s", code(aws.StringValue(instance.SubnetId))))
+		}
+	}
+	if len(warnings) == 0 {
+		return nil
+	}
+	return &slack.Attachment{
+		Title:      tr("Security warnings"),
+		Text:       ":warning: " + strings.Join(warnings, "\n:warning: "),
+		Color:      "warning",
+		MarkdownIn: []string{"text"},
+	}
+}
+
+// postureGroup returns the cached security group.
+func postureGroup(region, id string) (*ec2.SecurityGroup, error) {
+	key := region + "/" + id
+	if v, ok := postureGroups.Get(key); ok {
+		return v.(*ec2.SecurityGroup), nil
+	}
+	group, err := getSecurityGroup(region, id)
+	if err != nil {
+		return nil, err
+	}
+	postureGroups.Add(key, group)
+	return group, nil
+}
+
+// openToWorld reports whether the inbound rules of the group allow TCP
+// connections to the port from any address.
+func openToWorld(group *ec2.SecurityGroup, port int64) bool {
+	for _, p := range group.IpPermissions {
+		protocol := aws.StringValue(p.IpProtocol)
+		if protocol != "-1" && protocol != "tcp" && protocol != "6" {
+			continue
+		}
+		if protocol != "-1" && (aws.Int64Value(p.FromPort) > port || aws.Int64Value(p.ToPort) < port) {
+			continue
+		}
+		for _, r := range p.IpRanges {
+			if worldCIDR(aws.StringValue(r.CidrIp)) {
+				return true
+			}
+		}
+		for _, r := range p.Ipv6Ranges {
+			if worldCIDR(aws.StringValue(r.CidrIpv6)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// publicSubnet reports whether the route table of the subnet, which is the
+// main one of the VPC unless it is associated with another, routes to an
+// internet gateway.
+func publicSubnet(region, id string) (bool, error) {
+	key := region + "/" + id
+	if v, ok := publicSubnets.Get(key); ok {
+		return v.(bool), nil
+	}
+	svc := regionEC2Client(region)
+	resp, err := svc.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("association.subnet-id"),
+			Values: aws.StringSlice([]string{id}),
+		}},
+	})
+	if err != nil {
+		return false, err
+	}
+	tables := resp.RouteTables
+	if len(tables) == 0 {
+		subnets, err := svc.DescribeSubnets(&ec2.DescribeSubnetsInput{
+			SubnetIds: aws.StringSlice([]string{id}),
+		})
+		if err != nil {
+			return false, err
+		}
+		if len(subnets.Subnets) == 0 {
+			return false, nil
+		}
+		resp, err := svc.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
+			Filters: []*ec2.Filter{
+				{Name: aws.String("vpc-id"), Values: []*string{subnets.Subnets[0].VpcId}},
+				{Name: aws.String("association.main"), Values: aws.StringSlice([]string{"true"})},
+			},
+		})
+		if err != nil {
+			return false, err
+		}
+		tables = resp.RouteTables
+	}
+	public := false
+	for _, t := range tables {
+		for _, r := range t.Routes {
+			if strings.HasPrefix(aws.StringValue(r.GatewayId), "igw-") {
+				public = true
+			}
+		}
+	}
+	publicSubnets.Add(key, public)
+	return public, nil
+}
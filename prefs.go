@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const prefsBucket = "prefs"
+
+var resourceTypes = []string{"instance", "loadbalancer"}
+
+// UserPrefs holds per-user defaults applied to every reply to that user.
+type UserPrefs struct {
+	Timezone string   `json:"timezone,omitempty"`
+	Compact  bool     `json:"compact,omitempty"`
+	DM       bool     `json:"dm,omitempty"`
+	Muted    []string `json:"muted,omitempty"`
+}
+
+func getUserPrefs(user string) *UserPrefs {
+	prefs := new(UserPrefs)
+	if user == "" {
+		return prefs
+	}
+	if _, err := store.Get(prefsBucket, user, prefs); err != nil {
+		logError("failed to get preferences", err, logFields{"user": user})
+	}
+	return prefs
+}
+
+func putUserPrefs(user string, prefs *UserPrefs) error {
+	return store.Put(prefsBucket, user, prefs)
+}
+
+func (p *UserPrefs) location() *time.Location {
+	if p.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func (p *UserPrefs) isMuted(resourceType string) bool {
+	for _, m := range p.Muted {
+		if m == resourceType {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *UserPrefs) String() string {
+	tz := p.Timezone
+	if tz == "" {
+		tz = "UTC"
+	}
+	muted := strings.Join(p.Muted, ", ")
+	if muted == "" {
+		muted = "none"
+	}
+	return fmt.Sprintf(
+		"timezone: %s\ncompact: %s\ndm: %s\nmuted: %s",
+		tz, onOff(p.Compact), onOff(p.DM), muted,
+	)
+}
+
+// set applies a single "<key> <value>" preference change.
+func (p *UserPrefs) set(key, value string) error {
+	switch key {
+	case "timezone", "tz":
+		if _, err := time.LoadLocation(value); err != nil {
+			return fmt.Errorf("unknown timezone %q", value)
+		}
+		p.Timezone = value
+	case "compact":
+		b, err := parseOnOff(value)
+		if err != nil {
+			return err
+		}
+		p.Compact = b
+	case "verbose":
+		b, err := parseOnOff(value)
+		if err != nil {
+			return err
+		}
+		p.Compact = !b
+	case "dm":
+		b, err := parseOnOff(value)
+		if err != nil {
+			return err
+		}
+		p.DM = b
+	case "mute":
+		if !isResourceType(value) {
+			return fmt.Errorf("unknown resource type %q (%s)", value, strings.Join(resourceTypes, ", "))
+		}
+		if !p.isMuted(value) {
+			p.Muted = append(p.Muted, value)
+		}
+	case "unmute":
+		muted := make([]string, 0, len(p.Muted))
+		for _, m := range p.Muted {
+			if m != value {
+				muted = append(muted, m)
+			}
+		}
+		p.Muted = muted
+	default:
+		return fmt.Errorf("unknown preference %q", key)
+	}
+	return nil
+}
+
+func isResourceType(s string) bool {
+	for _, t := range resourceTypes {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func parseOnOff(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "on", "true", "yes", "1":
+		return true, nil
+	case "off", "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected on or off, got %q", s)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/bgpat/ec2bot/internal/cache"
+	"github.com/nlopes/slack"
+)
+
+// hoursPerMonth is the number of hours in a month used by the estimates of
+// AWS.
+const hoursPerMonth = 730
+
+var (
+	// showCost enables the estimates of the cost on the cards of instances,
+	// $SHOW_COST, which need pricing:GetProducts and
+	// ec2:DescribeSpotPriceHistory.
+	showCost = boolEnv("SHOW_COST", false)
+
+	// onDemandPrices are the hourly on-demand prices in USD by region and
+	// instance type, which rarely change.
+	onDemandPrices = cache.NewLRU(1000, 24*time.Hour)
+	// spotPrices are the current hourly spot prices in USD by availability
+	// zone and instance type.
+	spotPrices = cache.NewLRU(1000, 5*time.Minute)
+)
+
+// onDemandPrice returns the hourly on-demand price in USD of Linux
+// instances of the type in the region, or false if it is not listed.
+func onDemandPrice(region, instanceType string) (float64, bool, error) {
+	key := region + "/" + instanceType
+	if v, ok := onDemandPrices.Get(key); ok {
+		price, _ := v.(float64)
+		return price, ok && price > 0, nil
+	}
+	filter := func(field, value string) *pricing.Filter {
+		return &pricing.Filter{Type: aws.String("TERM_MATCH"), Field: aws.String(field), Value: aws.String(value)}
+	}
+	resp, err := pricingClient.GetProducts(&pricing.GetProductsInput{
+		ServiceCode: aws.String("AmazonEC2"),
+		Filters: []*pricing.Filter{
+			filter("regionCode", region),
+			filter("instanceType", instanceType),
+			filter("operatingSystem", "Linux"),
+			filter("tenancy", "Shared"),
+			filter("preInstalledSw", "NA"),
+			filter("capacitystatus", "Used"),
+		},
+		MaxResults: aws.Int64(1),
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	var price float64
+	for _, product := range resp.PriceList {
+		if p, ok := productPrice(product); ok {
+			price = p
+			break
+		}
+	}
+	// missing prices are cached too so that they are not looked up again
+	onDemandPrices.Add(key, price)
+	return price, price > 0, nil
+}
+
+// productPrice returns the hourly on-demand price of a product of the price
+// list, which is the only price of its only term.
+func productPrice(product aws.JSONValue) (float64, bool) {
+	terms, _ := product["terms"].(map[string]interface{})
+	onDemand, _ := terms["OnDemand"].(map[string]interface{})
+	for _, term := range onDemand {
+		term, _ := term.(map[string]interface{})
+		dimensions, _ := term["priceDimensions"].(map[string]interface{})
+		for _, dimension := range dimensions {
+			dimension, _ := dimension.(map[string]interface{})
+			perUnit, _ := dimension["pricePerUnit"].(map[string]interface{})
+			usd, _ := perUnit["USD"].(string)
+			price, err := strconv.ParseFloat(usd, 64)
+			if err == nil {
+				return price, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// spotPrice returns the current hourly spot price in USD of Linux instances
+// of the type in the availability zone.
+func spotPrice(region, zone, instanceType string) (float64, bool, error) {
+	key := zone + "/" + instanceType
+	if v, ok := spotPrices.Get(key); ok {
+		price, _ := v.(float64)
+		return price, price > 0, nil
+	}
+	svc := regionEC2Client(region)
+	resp, err := svc.DescribeSpotPriceHistory(&ec2.DescribeSpotPriceHistoryInput{
+		AvailabilityZone:    aws.String(zone),
+		InstanceTypes:       aws.StringSlice([]string{instanceType}),
+		ProductDescriptions: aws.StringSlice([]string{"Linux/UNIX"}),
+		StartTime:           aws.Time(time.Now()),
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	var price float64
+	for _, h := range resp.SpotPriceHistory {
+		if p, err := strconv.ParseFloat(aws.StringValue(h.SpotPrice), 64); err == nil {
+			price = p
+			break
+		}
+	}
+	spotPrices.Add(key, price)
+	return price, price > 0, nil
+}
+
+// formatPrice renders an hourly price with its monthly estimate.
+func formatPrice(hourly float64) string {
+	return tr("$%.4f/hour ($%.2f/month)", hourly, hourly*hoursPerMonth)
+}
+
+// costFields renders the on-demand price of the type of the instance, and
+// the spot price of spot instances. Prices which cannot be looked up are
+// omitted.
+func costFields(instance *ec2.Instance) []slack.AttachmentField {
+	if !showCost {
+		return nil
+	}
+	id := aws.StringValue(instance.InstanceId)
+	region := instanceRegion(id)
+	instanceType := aws.StringValue(instance.InstanceType)
+	var fields []slack.AttachmentField
+	price, ok, err := onDemandPrice(region, instanceType)
+	if err != nil {
+		logError("failed to get on-demand price", err, logFields{"instance_id": id, "instance_type": instanceType})
+	}
+	if ok {
+		fields = append(fields, slack.AttachmentField{
+			Title: tr("On-Demand Price"),
+			Value: formatPrice(price),
+			Short: true,
+		})
+	}
+	if aws.StringValue(instance.InstanceLifecycle) != ec2.InstanceLifecycleTypeSpot || instance.Placement == nil {
+		return fields
+	}
+	zone := aws.StringValue(instance.Placement.AvailabilityZone)
+	price, ok, err = spotPrice(region, zone, instanceType)
+	if err != nil {
+		logError("failed to get spot price", err, logFields{"instance_id": id, "instance_type": instanceType})
+	}
+	if ok {
+		fields = append(fields, slack.AttachmentField{
+			Title: tr("Spot Price"),
+			Value: fmt.Sprintf("%s (%s)", formatPrice(price), zone),
+			Short: true,
+		})
+	}
+	return fields
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// slackProxy is the proxy of the requests to Slack, $SLACK_PROXY, such as
+// "http://proxy.example.com:3128". The proxy of the environment such as
+// $HTTPS_PROXY is used if it is empty, and no proxy if it is "direct". The
+// AWS clients never use it, so they may reach the APIs through VPC
+// endpoints while Slack is reached through an egress proxy.
+var slackProxy = getenv("SLACK_PROXY")
+
+// slackHTTPClient is the client of the Slack APIs.
+var slackHTTPClient = newSlackHTTPClient(slackProxy)
+
+func newSlackHTTPClient(proxy string) *http.Client {
+	// same as http.DefaultTransport except for the proxy
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			DualStack: true,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	switch proxy {
+	case "":
+	case "direct":
+		transport.Proxy = nil
+	default:
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			logWarn("cannot parse $SLACK_PROXY, use default ''", nil)
+			break
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	return &http.Client{Timeout: slackTimeout, Transport: transport}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/bgpat/ec2bot/internal/awsclients"
+)
+
+// describeLimiter limits the Describe calls of all clients, so that bursts
+// of lookups missing the caches do not trip the throttling of the account.
+// It is nil if $AWS_DESCRIBE_RATE is zero.
+var describeLimiter *awsclients.RateLimiter
+
+// newAWSSession returns the session of the default region with the retries
+// of $AWS_MAX_RETRIES, the limiter of $AWS_DESCRIBE_RATE and
+// $AWS_DESCRIBE_BURST and the credentials of awsCredentials. Sessions of
+// other regions are copied from it.
+//
+// $AWS_ENDPOINT sends the calls of all services to a single endpoint, such
+// as "http://localhost:4566" of LocalStack, unless $AWS_EC2_ENDPOINT or
+// $AWS_ELB_ENDPOINT override it. It is not used to get the credentials
+// from the instance metadata. $AWS_S3_FORCE_PATH_STYLE addresses S3 buckets
+// by path instead of by host name, which such endpoints need.
+func newAWSSession() *session.Session {
+	config := aws.NewConfig()
+	if v := getenv("AWS_MAX_RETRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			logWarn("cannot parse $AWS_MAX_RETRIES, use the default of the SDK", nil)
+		} else {
+			config = config.WithMaxRetries(n)
+		}
+	}
+	rate, burst := 10.0, 20.0
+	if v := getenv("AWS_DESCRIBE_RATE"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			logWarn("cannot parse $AWS_DESCRIBE_RATE, use default '10'", nil)
+		} else {
+			rate = f
+		}
+	}
+	if v := getenv("AWS_DESCRIBE_BURST"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 1 {
+			logWarn("cannot parse $AWS_DESCRIBE_BURST, use default '20'", nil)
+		} else {
+			burst = f
+		}
+	}
+	creds, err := awsCredentials(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if creds != nil {
+		config = config.WithCredentials(creds)
+	}
+	if v := getenv("AWS_ENDPOINT"); v != "" {
+		config = config.WithEndpoint(v)
+	}
+	if boolEnv("AWS_S3_FORCE_PATH_STYLE", false) {
+		config = config.WithS3ForcePathStyle(true)
+	}
+	s := session.New(config)
+	setAWSTimeout(s)
+	if rate > 0 {
+		describeLimiter = awsclients.NewRateLimiter(rate, burst)
+		// retries are sent again, so they are limited too
+		s.Handlers.Send.PushFront(func(r *request.Request) {
+			if strings.HasPrefix(r.Operation.Name, "Describe") {
+				describeLimiter.Wait()
+			}
+		})
+	}
+	return s
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// Role grants its members permissions on the resources it selects.
+type Role struct {
+	Name        string   `json:"name"`
+	Users       []string `json:"users"`
+	UserGroups  []string `json:"user_groups"`
+	Permissions []string `json:"permissions"`
+	// Tags selects resources having all of the tags. An empty value
+	// matches any value of the tag.
+	Tags map[string]string `json:"tags"`
+	// Accounts selects resources owned by any of the AWS accounts.
+	Accounts []string `json:"accounts"`
+}
+
+// operatorUsers may perform every action on every resource when no roles
+// are configured.
+var operatorUsers = strings.FieldsFunc(getenv("SLACK_OPERATOR_USERS"), isComma)
+
+// actionPermissions maps actions to the permission required to perform
+// them. Actions which are not listed require the permission of the same
+// name.
+var actionPermissions = map[string]string{
+	"console_output": "read",
+	"cloudtrail":     "read",
+	"ssm_inventory":  "read",
+	"screenshot":     "read",
+	"start":          "start_stop",
+	"stop":           "start_stop",
+	"reboot":         "start_stop",
+}
+
+func permissionOf(action string) string {
+	if p, ok := actionPermissions[action]; ok {
+		return p
+	}
+	return action
+}
+
+// authorizeAction returns an error unless user may perform action on the
+// resource.
+func authorizeAction(teamID, user, action, resource string) error {
+	roles := currentConfig().Roles
+	if len(roles) == 0 {
+		for _, u := range operatorUsers {
+			if u == user {
+				return nil
+			}
+		}
+		return fmt.Errorf(tr("<@%s> is not allowed to %s %s"), user, action, resource)
+	}
+	var attrs *resourceAttributes
+	for _, role := range roles {
+		if !role.allows(action) || !role.hasMember(teamID, user) {
+			continue
+		}
+		if attrs == nil {
+			attrs = getResourceAttributes(resource)
+		}
+		if role.selects(attrs) {
+			return nil
+		}
+	}
+	return fmt.Errorf(tr("<@%s> is not allowed to %s %s"), user, action, resource)
+}
+
+func (r *Role) allows(action string) bool {
+	permission := permissionOf(action)
+	for _, p := range r.Permissions {
+		if p == "*" || p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Role) hasMember(teamID, user string) bool {
+	for _, u := range r.Users {
+		if u == user {
+			return true
+		}
+	}
+	for _, g := range r.UserGroups {
+		if isUserGroupMember(teamID, g, user) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Role) selects(attrs *resourceAttributes) bool {
+	for k, v := range r.Tags {
+		value, ok := attrs.Tags[k]
+		if !ok || (v != "" && v != value) {
+			return false
+		}
+	}
+	if len(r.Accounts) == 0 {
+		return true
+	}
+	for _, a := range r.Accounts {
+		if a == attrs.Account {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceAttributes are the attributes of a resource roles select by.
+type resourceAttributes struct {
+	Tags    map[string]string
+	Account string
+}
+
+// getResourceAttributes returns the tags and the account of an instance,
+// volume or load balancer. Attributes which cannot be resolved are left
+// empty so that they match only roles which do not select by them.
+func getResourceAttributes(resource string) *resourceAttributes {
+	attrs := &resourceAttributes{Tags: make(map[string]string)}
+	switch {
+	case strings.HasPrefix(resource, "i-"):
+		instance, err := getInstance(resource)
+		if err != nil {
+			logError("failed to get instance", err, logFields{"instance_id": resource})
+		}
+		if instance != nil {
+			for _, tag := range instance.Tags {
+				attrs.Tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+		}
+		attrs.Account = cacheSnapshot().InstanceAccounts[resource]
+	case strings.HasPrefix(resource, "vol-"):
+		svc := regionEC2Client(volumeRegion(resource))
+		resp, err := svc.DescribeVolumes(&ec2.DescribeVolumesInput{
+			VolumeIds: []*string{aws.String(resource)},
+		})
+		if err != nil {
+			logError("failed to describe volume", err, logFields{"volume_id": resource})
+			break
+		}
+		for _, v := range resp.Volumes {
+			for _, tag := range v.Tags {
+				attrs.Tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+		}
+	default:
+		tags, err := getLoadBalancerTags(resource)
+		if err != nil {
+			logError("failed to get load balancer tags", err, logFields{"load_balancer": resource})
+		}
+		for _, tag := range tags {
+			attrs.Tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// redactedValue replaces the values of redacted tags.
+const redactedValue = "[REDACTED]"
+
+// redactTag returns the value of the tag, or redactedValue if the key or
+// the value matches any of the redactions of the config.
+func (c *Config) redactTag(key, value string) string {
+	for _, re := range c.redactions {
+		if re.MatchString(key) || re.MatchString(value) {
+			return redactedValue
+		}
+	}
+	return value
+}
+
+// redactedInstance returns a copy of the instance whose tags are redacted,
+// so that neither the cards nor the dumps of the instance reveal them.
+func redactedInstance(instance *ec2.Instance) *ec2.Instance {
+	c := currentConfig()
+	if len(c.redactions) == 0 {
+		return instance
+	}
+	redacted := *instance
+	redacted.Tags = make([]*ec2.Tag, len(instance.Tags))
+	for i, tag := range instance.Tags {
+		redacted.Tags[i] = &ec2.Tag{
+			Key:   tag.Key,
+			Value: aws.String(c.redactTag(aws.StringValue(tag.Key), aws.StringValue(tag.Value))),
+		}
+	}
+	return &redacted
+}
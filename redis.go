@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisTimeout is the timeout of a single command to Redis.
+const redisTimeout = 5 * time.Second
+
+// redisMaxIdle is the maximum number of idle connections kept open.
+const redisMaxIdle = 4
+
+// RedisBackend is a CacheBackend storing the caches in Redis. It speaks
+// the minimal subset of the protocol used by the cache over pooled
+// connections, which are authenticated and select the database once.
+type RedisBackend struct {
+	Addr     string
+	Password string
+	DB       int
+
+	idle chan *redisConn
+}
+
+// redisConn is a connection to Redis ready to send commands.
+type redisConn struct {
+	net.Conn
+	rd *bufio.Reader
+}
+
+func newRedisBackend(u *url.URL) (*RedisBackend, error) {
+	r := &RedisBackend{Addr: u.Host, idle: make(chan *redisConn, redisMaxIdle)}
+	if !strings.Contains(r.Addr, ":") {
+		r.Addr += ":6379"
+	}
+	if u.User != nil {
+		r.Password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("invalid database %q", db)
+		}
+		r.DB = n
+	}
+	return r, nil
+}
+
+func (r *RedisBackend) Get(key string) ([]byte, error) {
+	v, err := r.do("GET", key)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply of GET: %v", v)
+	}
+	return b, nil
+}
+
+// Set stores the value of the key, which never expires if ttl is not
+// positive.
+func (r *RedisBackend) Set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ms := int64(ttl / time.Millisecond); ms > 0 {
+		args = append(args, "PX", strconv.FormatInt(ms, 10))
+	}
+	_, err := r.do(args...)
+	return err
+}
+
+// do sends the command on an idle or a new connection and returns the
+// reply. The connection is closed instead of being reused if the command
+// failed, as its replies may be out of sync.
+func (r *RedisBackend) do(args ...string) (interface{}, error) {
+	conn, err := r.conn()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.command(args)
+	if err != nil {
+		if _, ok := err.(redisError); !ok {
+			conn.Close()
+			return nil, err
+		}
+	}
+	select {
+	case r.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+// conn returns an idle connection, or dials a new one authenticating and
+// selecting the database if needed.
+func (r *RedisBackend) conn() (*redisConn, error) {
+	select {
+	case conn := <-r.idle:
+		return conn, nil
+	default:
+	}
+	c, err := net.DialTimeout("tcp", r.Addr, redisTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: c, rd: bufio.NewReader(c)}
+	if r.Password != "" {
+		if _, err := conn.command([]string{"AUTH", r.Password}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if r.DB != 0 {
+		if _, err := conn.command([]string{"SELECT", strconv.Itoa(r.DB)}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// command sends the command and reads its reply within redisTimeout.
+func (c *redisConn) command(args []string) (interface{}, error) {
+	c.SetDeadline(time.Now().Add(redisTimeout))
+	if err := writeRedisCommand(c, args); err != nil {
+		return nil, err
+	}
+	return readRedisReply(c.rd)
+}
+
+// redisError is an error reply of Redis, after which the connection may be
+// reused.
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+func writeRedisCommand(w io.Writer, args []string) error {
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf = append(buf, "$"+strconv.Itoa(len(arg))+"\r\n"...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// readRedisReply reads a reply, which is a string for status replies, an
+// int64 for integers, a []byte or nil for bulk strings and a []interface{}
+// for arrays. Error replies are returned as errors.
+func readRedisReply(rd *bufio.Reader) (interface{}, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply from Redis")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = readRedisReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unexpected reply from Redis: %q", line)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteRedisCommand(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeRedisCommand(&b, []string{"SET", "key", "a\r\nb"}); err != nil {
+		t.Fatal(err)
+	}
+	want := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$4\r\na\r\nb\r\n"
+	if b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
+
+func TestReadRedisReply(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  interface{}
+		err   string
+	}{
+		{reply: "+OK\r\n", want: "OK"},
+		{reply: ":42\r\n", want: int64(42)},
+		{reply: "$5\r\nhello\r\n", want: []byte("hello")},
+		{reply: "$0\r\n\r\n", want: []byte{}},
+		{reply: "$-1\r\n", want: nil},
+		{reply: "*2\r\n$1\r\na\r\n:1\r\n", want: []interface{}{[]byte("a"), int64(1)}},
+		{reply: "-ERR syntax error\r\n", err: "ERR syntax error"},
+		{reply: "?\r\n", err: "unexpected reply"},
+	}
+	for _, tt := range tests {
+		got, err := readRedisReply(bufio.NewReader(strings.NewReader(tt.reply)))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: got error %v, want %q", tt.reply, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.reply, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %#v, want %#v", tt.reply, got, tt.want)
+		}
+	}
+}
+
+// fakeRedis is a Redis server recording the commands of each connection.
+type fakeRedis struct {
+	net.Listener
+	mu       sync.Mutex
+	conns    int
+	commands [][]string
+	values   map[string]string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedis{Listener: l, values: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		v, err := readRedisReply(rd)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range v.([]interface{}) {
+			args = append(args, string(arg.([]byte)))
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, args)
+		reply := "+OK\r\n"
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			if v, ok := s.values[args[1]]; ok {
+				reply = "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+			} else {
+				reply = "$-1\r\n"
+			}
+		case "SET":
+			if len(args) != 3 && len(args) != 5 {
+				reply = "-ERR syntax error\r\n"
+			} else if len(args) == 5 && args[4] == "0" {
+				reply = "-ERR invalid expire time in 'set' command\r\n"
+			} else {
+				s.values[args[1]] = args[2]
+			}
+		}
+		s.mu.Unlock()
+		conn.Write([]byte(reply))
+	}
+}
+
+func TestRedisBackendReusesConnections(t *testing.T) {
+	s := newFakeRedis(t)
+	defer s.Close()
+	u, _ := url.Parse("redis://:secret@" + s.Addr().String() + "/2")
+	r, err := newRedisBackend(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Set("a", []byte("1"), 0); err != nil {
+		t.Fatalf("Set without TTL: %v", err)
+	}
+	if err := r.Set("b", []byte("2"), time.Minute); err != nil {
+		t.Fatalf("Set with TTL: %v", err)
+	}
+	got, err := r.Get("a")
+	if err != nil || string(got) != "1" {
+		t.Errorf("Get(a) = %q, %v, want \"1\"", got, err)
+	}
+	got, err = r.Get("missing")
+	if err != nil || got != nil {
+		t.Errorf("Get(missing) = %q, %v, want nil", got, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns != 1 {
+		t.Errorf("dialed %d connections, want 1", s.conns)
+	}
+	want := [][]string{
+		{"AUTH", "secret"},
+		{"SELECT", "2"},
+		{"SET", "a", "1"},
+		{"SET", "b", "2", "PX", "60000"},
+		{"GET", "a"},
+		{"GET", "missing"},
+	}
+	if !reflect.DeepEqual(s.commands, want) {
+		t.Errorf("got commands %q, want %q", s.commands, want)
+	}
+}
+
+func TestRedisBackendKeepsConnectionAfterErrorReply(t *testing.T) {
+	s := newFakeRedis(t)
+	defer s.Close()
+	u, _ := url.Parse("redis://" + s.Addr().String())
+	r, err := newRedisBackend(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.do("SET", "only-key"); err == nil {
+		t.Error("expected an error reply")
+	}
+	if err := r.Set("a", []byte("1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns != 1 {
+		t.Errorf("dialed %d connections, want 1", s.conns)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// backgroundRefresh refreshes the caches in the background every TTL of
+// each cache so that lookups are served from warm caches instead of waiting
+// for the AWS APIs.
+var backgroundRefresh = boolEnv("BACKGROUND_REFRESH", true)
+
+const (
+	// refreshJitter is the fraction of the interval by which refreshes
+	// are spread so that replicas do not call the APIs at once.
+	refreshJitter = 0.1
+	// minRefreshBackoff is the delay before retrying a failed refresh,
+	// which is doubled up to the interval while it keeps failing.
+	minRefreshBackoff = 5 * time.Second
+)
+
+// refreshCall is a refresh in flight whose result is shared by the callers
+// arriving while it runs.
+type refreshCall struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+var (
+	refreshCallsMu sync.Mutex
+	refreshCalls   = make(map[string]*refreshCall)
+)
+
+// coalesce calls fn unless a call with the same key is in flight, in which
+// case it waits for that call and returns its result, so that an expired
+// cache is refreshed once however many events arrive at the same time.
+// The key is the name of the cache in the metrics.
+func coalesce(key string, fn func() (interface{}, error)) (interface{}, error) {
+	refreshCallsMu.Lock()
+	if c, ok := refreshCalls[key]; ok {
+		refreshCallsMu.Unlock()
+		<-c.done
+		return c.val, c.err
+	}
+	c := &refreshCall{done: make(chan struct{})}
+	refreshCalls[key] = c
+	refreshCallsMu.Unlock()
+
+	start := time.Now()
+	c.val, c.err = fn()
+	recordCacheRefresh(key, start, c.err)
+	breakerFor(key).record(key, c.err)
+	refreshCallsMu.Lock()
+	delete(refreshCalls, key)
+	refreshCallsMu.Unlock()
+	close(c.done)
+	return c.val, c.err
+}
+
+// cacheStale reports whether a cache updated at t with the TTL has to be
+// refreshed before it is used. Caches refreshed in the background are used
+// until the next refresh unless they have been expired explicitly.
+func cacheStale(t time.Time, ttl time.Duration) bool {
+	if t.IsZero() {
+		return true
+	}
+	return !backgroundRefresh && t.Add(ttl).Before(time.Now())
+}
+
+// refreshCaches keeps refreshing each cache every its TTL until the
+// process exits.
+func refreshCaches() {
+	go refreshEvery("instance", instanceCacheTTL, func() error {
+		_, err := refreshInstances()
+		return err
+	})
+	go refreshEvery("loadbalancer", loadBalancerCacheTTL, func() error {
+		_, err := refreshLoadBalancers()
+		return err
+	})
+	refreshEvery("loadbalancer_v2", loadBalancerCacheTTL, func() error {
+		_, err := refreshLoadBalancersV2()
+		return err
+	})
+}
+
+// refreshEvery calls refresh every ttl while the resolver is enabled.
+func refreshEvery(resolver string, ttl time.Duration, refresh func() error) {
+	backoff := minRefreshBackoff
+	for {
+		if !resolverEnabled(resolver) {
+			time.Sleep(ttl)
+			continue
+		}
+		if err := refresh(); err != nil {
+			logError("failed to refresh cache", err, logFields{"resolver": resolver})
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > ttl {
+				backoff = ttl
+			}
+			continue
+		}
+		backoff = minRefreshBackoff
+		jitter := time.Duration((rand.Float64()*2 - 1) * refreshJitter * float64(ttl))
+		time.Sleep(ttl + jitter)
+	}
+}
+
+// refreshAll refreshes the caches of the enabled resolvers.
+func refreshAll() error {
+	if resolverEnabled("instance") {
+		if _, err := refreshInstances(); err != nil {
+			return err
+		}
+	}
+	if resolverEnabled("loadbalancer") {
+		if _, err := refreshLoadBalancers(); err != nil {
+			return err
+		}
+	}
+	if resolverEnabled("loadbalancer_v2") {
+		if _, err := refreshLoadBalancersV2(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
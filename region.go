@@ -0,0 +1,156 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/cloudtrail"
+	"github.com/aws/aws-sdk-go/service/cloudtrail/cloudtrailiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/bgpat/ec2bot/internal/awsclients"
+	"github.com/bgpat/ec2bot/internal/resolver"
+)
+
+// awsRegions are the regions whose instances and load balancers are cached
+// and searched by lookups. Only the default region is searched if it is
+// empty.
+var awsRegions = strings.FieldsFunc(getenv("AWS_REGIONS"), isComma)
+
+// lookupRegions returns the regions searched by lookups.
+func lookupRegions() []string {
+	if len(awsRegions) == 0 {
+		return []string{awsRegion()}
+	}
+	return awsRegions
+}
+
+// searchedRegion reports whether the resources of the region are cached.
+func searchedRegion(region string) bool {
+	for _, r := range lookupRegions() {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+func regionEC2Client(region string) ec2iface.EC2API {
+	if region == "" || region == awsRegion() {
+		return ec2Client
+	}
+	return ec2.New(regionSession(region), endpointConfig(ec2Endpoint, region))
+}
+
+func regionCloudWatchClient(region string) cloudwatchiface.CloudWatchAPI {
+	if region == "" || region == awsRegion() {
+		return cloudWatchClient
+	}
+	return cloudwatch.New(regionSession(region))
+}
+
+func regionCloudTrailClient(region string) cloudtrailiface.CloudTrailAPI {
+	if region == "" || region == awsRegion() {
+		return cloudTrailClient
+	}
+	return cloudtrail.New(regionSession(region))
+}
+
+func regionELBClient(region string) elbiface.ELBAPI {
+	if region == "" || region == awsRegion() {
+		return elbClient
+	}
+	return elb.New(regionSession(region), endpointConfig(elbEndpoint, region))
+}
+
+func regionELBV2Client(region string) elbv2iface.ELBV2API {
+	if region == "" || region == awsRegion() {
+		return elbv2Client
+	}
+	return elbv2.New(regionSession(region), endpointConfig(elbEndpoint, region))
+}
+
+func regionSSMClient(region string) ssmiface.SSMAPI {
+	if region == "" || region == awsRegion() {
+		return ssmClient
+	}
+	return ssm.New(regionSession(region))
+}
+
+// instanceRegion returns the region of the cached instance, or the default
+// region if it is not cached.
+func instanceRegion(id string) string {
+	cacheMu.RLock()
+	region := instanceCache.Regions[id]
+	cacheMu.RUnlock()
+	if region == "" {
+		return awsRegion()
+	}
+	return region
+}
+
+// loadBalancerRegion returns the region of the cached Classic ELB, or the
+// default region if it is not cached.
+func loadBalancerRegion(name string) string {
+	cacheMu.RLock()
+	region := loadBalancerCache.Regions[name]
+	cacheMu.RUnlock()
+	if region == "" {
+		return awsRegion()
+	}
+	return region
+}
+
+// arnRegion returns the region of the ARN, or the default region if it has
+// none.
+func arnRegion(arn string) string {
+	a, err := resolver.ParseARN(arn)
+	if err != nil || a.Region == "" {
+		return awsRegion()
+	}
+	return a.Region
+}
+
+// arnAccount returns the account of the ARN, or an empty string if it is
+// invalid.
+func arnAccount(arn string) string {
+	a, err := resolver.ParseARN(arn)
+	if err != nil {
+		return ""
+	}
+	return a.Account
+}
+
+// mergeRegions returns a copy of the regions with the added ones, so that
+// cached maps are never modified.
+func mergeRegions(regions, added map[string]string) map[string]string {
+	merged := make(map[string]string, len(regions)+len(added))
+	for k, v := range regions {
+		merged[k] = v
+	}
+	for k, v := range added {
+		merged[k] = v
+	}
+	return merged
+}
+
+// The describers of a region used by the resolvers and the caches. They may
+// be replaced with the fakes of internal/awsclients.
+var (
+	instanceDescriber = func(region string) awsclients.InstanceDescriber {
+		return regionEC2Client(region)
+	}
+	loadBalancerDescriber = func(region string) awsclients.LoadBalancerDescriber {
+		return regionELBClient(region)
+	}
+	loadBalancerV2Describer = func(region string) awsclients.LoadBalancerV2Describer {
+		return regionELBV2Client(region)
+	}
+)
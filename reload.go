@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// reloadableSettings apply the settings which may be changed by reloading
+// the config file. The other settings are read once at startup.
+var reloadableSettings = map[string]func(v string){
+	"TAG_CACHE_TTL": func(v string) {
+		ttl := loadBalancerCacheTTL
+		if v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				logWarn("cannot parse $TAG_CACHE_TTL, use the TTL of load balancers", nil)
+			} else {
+				ttl = d
+			}
+		}
+		loadBalancerTags.SetTTL(ttl)
+		loadBalancerV2Tags.SetTTL(ttl)
+	},
+	"NEGATIVE_CACHE_TTL": func(v string) {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || notFoundInstances == nil {
+			logWarn("cannot apply $NEGATIVE_CACHE_TTL without restart", nil)
+			return
+		}
+		notFoundInstances.SetTTL(d)
+	},
+}
+
+// reloadConfig replaces the config file in use with the file read again.
+// The patterns, exclusions, roles and SSM commands are replaced while the
+// caches are kept. The config in use is kept if the file is invalid.
+func reloadConfig() error {
+	if configPath == "" {
+		return errors.New("no config file is configured by $CONFIG_PATH")
+	}
+	c, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if err := c.checkSettings(); err != nil {
+		return err
+	}
+	configMu.Lock()
+	old := fileConfig
+	fileConfig, fileConfigErr, config = c, nil, c
+	configMu.Unlock()
+	if old == nil {
+		old = new(Config)
+	}
+	for key := range changedSettings(old, c) {
+		name := strings.ToUpper(key)
+		if _, ok := os.LookupEnv(name); ok {
+			// the environment variable overrides the setting
+			continue
+		}
+		if apply, ok := reloadableSettings[name]; ok {
+			apply(getenv(name))
+			continue
+		}
+		logWarn("setting "+key+" is changed, restart to apply it", nil)
+	}
+	logInfo("reloaded config", logFields{"path": configPath})
+	return nil
+}
+
+// changedSettings returns the names of the settings which differ between
+// the config files.
+func changedSettings(old, c *Config) map[string]struct{} {
+	changed := make(map[string]struct{})
+	for key, v := range old.Settings {
+		if !reflect.DeepEqual(v, c.Settings[key]) {
+			changed[key] = struct{}{}
+		}
+	}
+	for key, v := range c.Settings {
+		if !reflect.DeepEqual(v, old.Settings[key]) {
+			changed[key] = struct{}{}
+		}
+	}
+	return changed
+}
+
+// watchReload reloads the config file on SIGHUP.
+func watchReload() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	for range sig {
+		if err := reloadConfig(); err != nil {
+			logError("failed to reload config", err, logFields{"path": configPath})
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/bgpat/ec2bot/internal/awsclients"
+)
+
+// TestReloadConfigWhileLookingUp reloads the config file while instances
+// and settings are looked up, which is run with -race.
+func TestReloadConfigWhileLookingUp(t *testing.T) {
+	defer useFakeAWS(fakeAWS{
+		EC2: map[string]*awsclients.FakeEC2{
+			"us-east-1": {Reservations: fakeReservations(fakeInstance("i-00000001", "10.0.0.1"))},
+		},
+	})()
+	f, err := ioutil.TempFile("", "ec2bot-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	origPath := configPath
+	configMu.RLock()
+	origFile, origFileErr, origConfig := fileConfig, fileConfigErr, config
+	configMu.RUnlock()
+	defer func() {
+		configPath = origPath
+		configMu.Lock()
+		fileConfig, fileConfigErr, config = origFile, origFileErr, origConfig
+		configMu.Unlock()
+		loadBalancerTags.SetTTL(tagCacheTTL)
+		loadBalancerV2Tags.SetTTL(tagCacheTTL)
+	}()
+	configPath = f.Name()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				instance, err := getInstance("10.0.0.1")
+				if err != nil || aws.StringValue(instanceID(instance)) != "i-00000001" {
+					t.Errorf("getInstance(10.0.0.1) = %v, %v", instance, err)
+					return
+				}
+				getenv("AWS_ROLE_SESSION_NAME")
+			}
+		}()
+	}
+	for i, ttl := range []string{"1m", "2m", "3m", "4m", "5m", "6m", "7m", "8m"} {
+		if err := ioutil.WriteFile(f.Name(), []byte("settings:\n  tag_cache_ttl: "+ttl+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := reloadConfig(); err != nil {
+			t.Errorf("%d: reloadConfig: %v", i, err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/nlopes/slack"
+)
+
+// permalinkPattern matches the permalinks of messages, such as
+// "https://example.slack.com/archives/C0123/p1500000000000100" with an
+// optional "?thread_ts=1500000000.000050" of replies.
+var permalinkPattern = regexp.MustCompile(`/archives/([A-Z0-9]+)/p([0-9]{10})([0-9]{6})(?:\?.*thread_ts=([0-9.]+))?`)
+
+// channelMentionPattern matches a channel formatted by Slack in the text of
+// commands, such as "<#C0123|general>".
+var channelMentionPattern = regexp.MustCompile(`^<#([A-Z0-9]+)(?:\|[^>]*)?>$`)
+
+// parseReplayTarget returns the channel, the timestamp and the timestamp of
+// the thread of the message of a permalink or of "<channel> <ts>".
+func parseReplayTarget(args []string) (string, string, string, error) {
+	switch len(args) {
+	case 1:
+		m := permalinkPattern.FindStringSubmatch(args[0])
+		if m == nil {
+			return "", "", "", fmt.Errorf("invalid permalink %q", args[0])
+		}
+		return m[1], m[2] + "." + m[3], m[4], nil
+	case 2:
+		channel := args[0]
+		if m := channelMentionPattern.FindStringSubmatch(channel); m != nil {
+			channel = m[1]
+		}
+		return channel, args[1], "", nil
+	}
+	return "", "", "", fmt.Errorf("usage: /ec2 admin replay <permalink> | <channel> <ts>")
+}
+
+// fetchMessage returns the message of the channel at the timestamp. The
+// replies of the thread are searched if the thread is not empty.
+func fetchMessage(teamID, channel, ts, thread string) (*slack.Msg, error) {
+	method := "conversations.history"
+	values := url.Values{
+		"channel":   {channel},
+		"latest":    {ts},
+		"oldest":    {ts},
+		"inclusive": {"true"},
+		"limit":     {"1"},
+	}
+	if thread != "" && thread != ts {
+		method = "conversations.replies"
+		values.Set("ts", thread)
+		values.Set("limit", "2")
+	}
+	var resp struct {
+		Messages []slack.Msg `json:"messages"`
+	}
+	if err := callAPI(teamID, method, values, &resp); err != nil {
+		return nil, err
+	}
+	for i := range resp.Messages {
+		if msg := &resp.Messages[i]; msg.Timestamp == ts {
+			msg.Channel = channel
+			return msg, nil
+		}
+	}
+	return nil, fmt.Errorf("message %s not found in %s", ts, channel)
+}
+
+// replay handles the message again as if its event was received, so that
+// messages missed during downtime or before a fix of patterns are answered.
+func (cmd *Command) replay(args []string) *CommandResponse {
+	channel, ts, thread, err := parseReplayTarget(args)
+	if err != nil {
+		return ephemeral(err.Error())
+	}
+	msg, err := fetchMessage(cmd.TeamID, channel, ts, thread)
+	if err != nil {
+		logError("failed to fetch message to replay", err, logFields{"channel": channel, "ts": ts})
+		return ephemeral(err.Error())
+	}
+	ev := &Event{
+		Type:    "event_callback",
+		TeamID:  cmd.TeamID,
+		EventID: "replay-" + ts,
+		Event:   msg,
+	}
+	logInfo("replay message", logFields{"channel": channel, "ts": ts, "user": cmd.UserID})
+	goBackground(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		status, err := ev.handleMessage(ctx)
+		if err != nil {
+			logError("failed to replay message", err, ev.logFields())
+			return
+		}
+		logInfo("replayed message: "+status, ev.logFields())
+	})
+	return ephemeral(fmt.Sprintf("replaying message %s in <#%s>", ts, channel))
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/nlopes/slack"
+)
+
+// The sizes of the families of instance types from the smallest.
+var (
+	burstableSizes = []string{"nano", "micro", "small", "medium", "large", "xlarge", "2xlarge"}
+	m5Sizes        = []string{"large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge", "24xlarge", "metal"}
+	c5Sizes        = []string{"large", "xlarge", "2xlarge", "4xlarge", "9xlarge", "12xlarge", "18xlarge", "24xlarge", "metal"}
+	sixthGenSizes  = []string{"large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge", "24xlarge", "32xlarge", "metal"}
+	gravitonSizes  = []string{"medium", "large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge", "metal"}
+)
+
+// instanceSizes are the sizes of the instance types by family, so that
+// resizing offers only the types which exist. Instances of other families
+// cannot be resized.
+var instanceSizes = map[string][]string{
+	"t2":   burstableSizes,
+	"t3":   burstableSizes,
+	"t3a":  burstableSizes,
+	"t4g":  burstableSizes,
+	"m4":   {"large", "xlarge", "2xlarge", "4xlarge", "10xlarge", "16xlarge"},
+	"m5":   m5Sizes,
+	"m5d":  m5Sizes,
+	"m5a":  m5Sizes[:len(m5Sizes)-1],
+	"m6i":  sixthGenSizes,
+	"m6g":  gravitonSizes,
+	"c4":   {"large", "xlarge", "2xlarge", "4xlarge", "8xlarge"},
+	"c5":   c5Sizes,
+	"c5d":  c5Sizes,
+	"c5n":  {"large", "xlarge", "2xlarge", "4xlarge", "9xlarge", "18xlarge", "metal"},
+	"c6i":  sixthGenSizes,
+	"c6g":  gravitonSizes,
+	"r4":   {"large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "16xlarge"},
+	"r5":   m5Sizes,
+	"r5d":  m5Sizes,
+	"r5a":  m5Sizes[:len(m5Sizes)-1],
+	"r6i":  sixthGenSizes,
+	"r6g":  gravitonSizes,
+	"x1":   {"16xlarge", "32xlarge"},
+	"x1e":  {"xlarge", "2xlarge", "4xlarge", "8xlarge", "16xlarge", "32xlarge"},
+	"i3":   {"large", "xlarge", "2xlarge", "4xlarge", "8xlarge", "16xlarge", "metal"},
+	"d2":   {"xlarge", "2xlarge", "4xlarge", "8xlarge"},
+	"p3":   {"2xlarge", "8xlarge", "16xlarge"},
+	"g4dn": {"xlarge", "2xlarge", "4xlarge", "8xlarge", "12xlarge", "16xlarge", "metal"},
+}
+
+func init() {
+	dialogHandlers["resize"] = handleResizeDialog
+	approvalHandlers["resize"] = func(a *Approval) error {
+		goBackground(func() { a.event().resizeInstance(a.Resource, a.Parameter) })
+		return nil
+	}
+}
+
+func resizeButton(id string) slack.AttachmentAction {
+	return slack.AttachmentAction{
+		Name:  "resize",
+		Text:  tr("Resize"),
+		Type:  "button",
+		Value: id,
+	}
+}
+
+// compatibleInstanceTypes returns the other sizes in the family of the
+// instance type, or nil if the family is unknown.
+func compatibleInstanceTypes(instanceType string) []string {
+	i := strings.Index(instanceType, ".")
+	if i < 0 {
+		return nil
+	}
+	family := instanceType[:i]
+	sizes := instanceSizes[family]
+	types := make([]string, 0, len(sizes))
+	for _, size := range sizes {
+		t := family + "." + size
+		if t != instanceType {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// compatibleInstanceType reports whether the instance of the current type
+// may be resized to the instance type.
+func compatibleInstanceType(current, instanceType string) bool {
+	for _, t := range compatibleInstanceTypes(current) {
+		if t == instanceType {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmResize opens a dialog to choose the new type of the instance.
+func confirmResize(cb *ActionCallback, id string) error {
+	instance, err := describeInstance(id)
+	if err != nil {
+		return err
+	}
+	current := aws.StringValue(instance.InstanceType)
+	types := compatibleInstanceTypes(current)
+	if len(types) == 0 {
+		return fmt.Errorf(tr("no instance types are compatible with %s"), current)
+	}
+	options := make([]DialogOption, len(types))
+	for i, t := range types {
+		options[i] = DialogOption{Label: t, Value: t}
+	}
+	ev := cb.event()
+	return openDialog(cb.Team.ID, cb.TriggerID, &Dialog{
+		CallbackID:  "resize",
+		Title:       tr("Resize instance"),
+		SubmitLabel: tr("Resize"),
+		State:       id + " " + ev.Event.Timestamp,
+		Elements: []DialogElement{
+			DialogElement{
+				Type:    "select",
+				Label:   tr("Instance Type"),
+				Name:    "instance_type",
+				Hint:    tr("%s (%s) will be stopped, resized and started again if it is running.", id, current),
+				Options: options,
+			},
+		},
+	})
+}
+
+func handleResizeDialog(cb *ActionCallback) []DialogError {
+	state := strings.Fields(cb.State)
+	if len(state) != 2 {
+		return []DialogError{{Name: "instance_type", Error: tr("invalid request")}}
+	}
+	id, thread := state[0], state[1]
+	instanceType := cb.Submission["instance_type"]
+	instance, err := describeInstance(id)
+	if err != nil {
+		return []DialogError{{Name: "instance_type", Error: err.Error()}}
+	}
+	if current := aws.StringValue(instance.InstanceType); !compatibleInstanceType(current, instanceType) {
+		return []DialogError{{Name: "instance_type", Error: tr("%s is not compatible with %s", instanceType, current)}}
+	}
+
+	ev := cb.event()
+	ev.Event.Timestamp = thread
+	if err := authorizeAction(cb.Team.ID, cb.User.ID, "resize", id); err != nil {
+		ev.post(err.Error(), nil)
+		return nil
+	}
+	if requiresApproval("resize") {
+		if err := ev.requestApproval("resize", id, instanceType); err != nil {
+			ev.post(err.Error(), nil)
+		}
+		return nil
+	}
+	goBackground(func() { ev.resizeInstance(id, instanceType) })
+	return nil
+}
+
+// resizeInstance stops the instance if it is running, changes its type and
+// starts it again, posting the progress to the thread.
+func (ev *Event) resizeInstance(id, instanceType string) {
+	err := resizeInstance(id, instanceType, func(format string, args ...interface{}) {
+		ev.post(tr(format, args...), nil)
+	})
+	ev.audit("resize", id, err)
+	if err != nil {
+		logError("failed to resize instance", err, logFields{"instance_id": id})
+		ev.post(tr("failed to resize %s: %v", code(id), err), nil)
+		return
+	}
+	ev.post(tr("%s has been resized to %s (requested by <@%s>)", code(id), instanceType, ev.Event.User), nil)
+}
+
+func resizeInstance(id, instanceType string, progress func(format string, args ...interface{})) error {
+	instance, err := describeInstance(id)
+	if err != nil {
+		return err
+	}
+	// the type may have been changed while the resize was waiting for the
+	// approval
+	if current := aws.StringValue(instance.InstanceType); !compatibleInstanceType(current, instanceType) {
+		return fmt.Errorf(tr("%s is not compatible with %s"), instanceType, current)
+	}
+	svc := regionEC2Client(instanceRegion(id))
+	input := &ec2.DescribeInstancesInput{InstanceIds: []*string{aws.String(id)}}
+	running := aws.StringValue(instance.State.Name) == ec2.InstanceStateNameRunning
+	if running {
+		progress("stopping %s", code(id))
+		if _, err := stopInstance(id); err != nil {
+			return err
+		}
+		if err := svc.WaitUntilInstanceStopped(input); err != nil {
+			return err
+		}
+	}
+	progress("changing the type of %s to %s", code(id), instanceType)
+	_, err = svc.ModifyInstanceAttribute(&ec2.ModifyInstanceAttributeInput{
+		InstanceId:   aws.String(id),
+		InstanceType: &ec2.AttributeValue{Value: aws.String(instanceType)},
+	})
+	if err != nil {
+		return err
+	}
+	if running {
+		progress("starting %s", code(id))
+		if _, err := startInstance(id); err != nil {
+			return err
+		}
+		if err := svc.WaitUntilInstanceRunning(input); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func describeInstance(id string) (*ec2.Instance, error) {
+	svc := regionEC2Client(instanceRegion(id))
+	resp, err := svc.DescribeInstances(&ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, reservation := range resp.Reservations {
+		if len(reservation.Instances) > 0 {
+			return reservation.Instances[0], nil
+		}
+	}
+	return nil, fmt.Errorf("%s was not found", id)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+var (
+	// resolutionWebhook is the URL each resolved resource is posted to,
+	// $RESOLUTION_WEBHOOK_URL, so that other automation can consume them.
+	resolutionWebhook = getenv("RESOLUTION_WEBHOOK_URL")
+	// resolutionWebhookOnly posts the resources only to the webhook instead
+	// of replying with cards, $RESOLUTION_WEBHOOK_ONLY.
+	resolutionWebhookOnly = boolEnv("RESOLUTION_WEBHOOK_ONLY", false)
+)
+
+func init() {
+	if resolutionWebhookOnly && resolutionWebhook == "" {
+		logWarn("$RESOLUTION_WEBHOOK_ONLY is set without $RESOLUTION_WEBHOOK_URL, reply with cards", nil)
+		resolutionWebhookOnly = false
+	}
+}
+
+// Resolution is posted to the resolution webhook for each resolved
+// resource.
+type Resolution struct {
+	Resolver string      `json:"resolver"`
+	Queries  []string    `json:"queries"`
+	Resource interface{} `json:"resource"`
+	EventID  string      `json:"event_id,omitempty"`
+	TeamID   string      `json:"team_id"`
+	Channel  string      `json:"channel"`
+	User     string      `json:"user"`
+	Ts       string      `json:"ts"`
+}
+
+// sendResolutions posts the resources resolved from the queries to the
+// resolution webhook. Failures are logged, since they do not affect the
+// replies.
+func (ev *Event) sendResolutions(resolver string, queries []string, results []interface{}) {
+	if resolutionWebhook == "" {
+		return
+	}
+	for _, r := range results {
+		if instance, ok := r.(*ec2.Instance); ok {
+			r = redactedInstance(rawInstance(instance))
+		}
+		err := postResolution(&Resolution{
+			Resolver: resolver,
+			Queries:  queries,
+			Resource: r,
+			EventID:  ev.EventID,
+			TeamID:   ev.TeamID,
+			Channel:  ev.Event.Channel,
+			User:     ev.Event.User,
+			Ts:       ev.Event.Timestamp,
+		})
+		if err != nil {
+			f := ev.logFields()
+			f["resolver"] = resolver
+			logError("failed to post resolution", err, f)
+		}
+	}
+}
+
+func postResolution(r *Resolution) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(resolutionWebhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", resolutionWebhook, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+// Resolver looks up one type of resources mentioned in messages and posts
+// their cards. A new type of resources is added by registering a resolver
+// in the init function of its file.
+type Resolver interface {
+	// Name is the type of the resources, which users may mute.
+	Name() string
+	// Pattern matches the queries in messages.
+	Pattern() *regexp.Regexp
+	// Resolve returns the resources matching the queries. It posts the
+	// queries matching nothing itself.
+	Resolve(ctx context.Context, ev *Event, queries []string) ([]interface{}, error)
+	// Render posts the resources returned by Resolve.
+	Render(ev *Event, results []interface{}) error
+}
+
+// enabler is implemented by resolvers which are enabled by other switches
+// than their name.
+type enabler interface {
+	Enabled() bool
+}
+
+// queryFinder is implemented by resolvers whose queries are more than the
+// matches of their pattern.
+type queryFinder interface {
+	Queries(ev *Event) []string
+}
+
+// resolvers are tried in order, and only the resources of the first one
+// finding any are posted.
+var resolvers = []Resolver{instanceResolver{}, loadBalancerResolver{}}
+
+func registerResolver(r Resolver) {
+	resolvers = append(resolvers, r)
+	resourceTypes = append(resourceTypes, r.Name())
+}
+
+// resolve posts the resources of the first resolver finding any in the
+// event. It returns the name of the resolver, or an empty string if nothing
+// is found. Errors of posting are only logged.
+func (ev *Event) resolve(ctx context.Context) (string, error) {
+	for _, r := range resolvers {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if !resolverOn(r) || ev.userPrefs().isMuted(r.Name()) {
+			continue
+		}
+		queries := ev.resolverQueries(r)
+		if len(queries) == 0 {
+			continue
+		}
+		f := ev.logFields()
+		f["queries"] = queries
+		f["resolver"] = r.Name()
+		logDebug("resolve queries", f)
+		start := time.Now()
+		results, err := r.Resolve(ctx, ev, queries)
+		ev.recordLookup(r.Name(), start)
+		if err != nil {
+			return r.Name(), err
+		}
+		if len(results) > 0 {
+			ev.sendResolutions(r.Name(), queries, results)
+			if resolutionWebhookOnly {
+				return r.Name(), nil
+			}
+			if notice := ev.staleNotice(r.Name()); notice != "" {
+				ev.post(notice, nil)
+			}
+			// the resources are not resolved again on the retries of Slack
+			if err := r.Render(ev, results); err != nil {
+				logError("failed to post "+r.Name(), err, ev.logFields())
+			}
+			return r.Name(), nil
+		}
+	}
+	return "", nil
+}
+
+// resolverQueries returns the queries of the resolver in the event.
+func (ev *Event) resolverQueries(r Resolver) []string {
+	if f, ok := r.(queryFinder); ok {
+		return f.Queries(ev)
+	}
+	return ev.findQuery(r.Pattern())
+}
+
+// hasQueries reports whether the event has queries of any enabled
+// resolver.
+func (ev *Event) hasQueries() bool {
+	for _, r := range resolvers {
+		if resolverOn(r) && len(ev.resolverQueries(r)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// resolverOn reports whether the resolver is enabled by the config file.
+func resolverOn(r Resolver) bool {
+	if e, ok := r.(enabler); ok {
+		return e.Enabled()
+	}
+	return resolverEnabled(r.Name())
+}
+
+// knownResolver reports whether the name is a switch of resolvers.
+func knownResolver(name string) bool {
+	if name == "loadbalancer_v2" {
+		return true
+	}
+	for _, r := range resolvers {
+		if r.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+type instanceResolver struct{}
+
+func (instanceResolver) Name() string            { return "instance" }
+func (instanceResolver) Pattern() *regexp.Regexp { return hostIDPattern }
+
+func (instanceResolver) Queries(ev *Event) []string {
+	return withoutNotFound(ev.findInstanceQueries())
+}
+
+func (instanceResolver) Resolve(ctx context.Context, ev *Event, queries []string) ([]interface{}, error) {
+	instances, err := ev.findInstances(queries)
+	results := make([]interface{}, len(instances))
+	for i, instance := range instances {
+		results[i] = instance
+	}
+	return results, err
+}
+
+func (instanceResolver) Render(ev *Event, results []interface{}) error {
+	instances := make([]*ec2.Instance, len(results))
+	for i, r := range results {
+		instances[i] = r.(*ec2.Instance)
+	}
+	if ev.compactChannel() {
+		return ev.postSummary("", ev.instanceSummaryRows(instances))
+	}
+	if len(instances) > aggregateThreshold {
+		return ev.postInstanceSummary(instances)
+	}
+	return ev.postInstances(instances)
+}
+
+type loadBalancerResolver struct{}
+
+func (loadBalancerResolver) Name() string            { return "loadbalancer" }
+func (loadBalancerResolver) Pattern() *regexp.Regexp { return elbPattern }
+
+// Enabled reports whether either Classic ELBs or ALBs and NLBs are looked
+// up.
+func (loadBalancerResolver) Enabled() bool {
+	return resolverEnabled("loadbalancer") || resolverEnabled("loadbalancer_v2")
+}
+
+func (loadBalancerResolver) Queries(ev *Event) []string {
+	return ev.findLoadBalancerQueries()
+}
+
+func (loadBalancerResolver) Resolve(ctx context.Context, ev *Event, queries []string) ([]interface{}, error) {
+	lbs, lbsV2, err := ev.findLoadBalancers(queries)
+	results := make([]interface{}, 0, len(lbs)+len(lbsV2))
+	for _, lb := range lbs {
+		results = append(results, lb)
+	}
+	for _, lb := range lbsV2 {
+		results = append(results, lb)
+	}
+	return results, err
+}
+
+func (loadBalancerResolver) Render(ev *Event, results []interface{}) error {
+	var lbs []*elb.LoadBalancerDescription
+	var lbsV2 []*elbv2.LoadBalancer
+	for _, r := range results {
+		switch lb := r.(type) {
+		case *elb.LoadBalancerDescription:
+			lbs = append(lbs, lb)
+		case *elbv2.LoadBalancer:
+			lbsV2 = append(lbsV2, lb)
+		}
+	}
+	if ev.compactChannel() {
+		return ev.postSummary("", loadBalancerSummaryRows(lbs, lbsV2))
+	}
+	if len(results) > aggregateThreshold {
+		return ev.postLoadBalancerSummary(lbs, lbsV2)
+	}
+	return ev.postLoadBalancers(lbs, lbsV2)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/nlopes/slack"
+)
+
+// forceRefreshInterval is the minimum interval between refreshes forced by
+// lookups of instances missing in the cache.
+var forceRefreshInterval = time.Minute
+
+var (
+	forceRefreshMu    sync.Mutex
+	lastForcedRefresh time.Time
+)
+
+func init() {
+	actionHandlers["retry_lookup"] = handleRetryLookup
+	if v := getenv("FORCE_REFRESH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			logWarn("cannot parse $FORCE_REFRESH_INTERVAL, use default '1m'", nil)
+			return
+		}
+		forceRefreshInterval = d
+	}
+}
+
+// forceRefreshInstances refreshes the instances before the cache expires
+// unless it was forced within forceRefreshInterval. It reports whether the
+// cache was refreshed.
+func forceRefreshInstances() (bool, error) {
+	forceRefreshMu.Lock()
+	if time.Since(lastForcedRefresh) < forceRefreshInterval {
+		forceRefreshMu.Unlock()
+		return false, nil
+	}
+	lastForcedRefresh = time.Now()
+	forceRefreshMu.Unlock()
+	if _, err := refreshInstances(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// retryInstances resolves the queries which were not found again after
+// forcing a refresh, so that instances launched since the last refresh are
+// found. It returns the instances found and the queries which are still
+// not found.
+func (ev *Event) retryInstances(queries []string) ([]*ec2.Instance, []string, error) {
+	refreshed, err := forceRefreshInstances()
+	if err != nil || !refreshed {
+		return nil, queries, err
+	}
+	var (
+		found    []*ec2.Instance
+		notFound []string
+	)
+	for _, q := range queries {
+		matches, err := resolveInstances(q)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch len(matches) {
+		case 0:
+			notFound = append(notFound, q)
+		case 1:
+			found = append(found, matches[0])
+		default:
+			ev.postAmbiguousInstances(q, matches)
+		}
+	}
+	return found, notFound, nil
+}
+
+// retryAction is the button looking up the query again.
+func retryAction(query string) slack.AttachmentAction {
+	return slack.AttachmentAction{
+		Name:  "instance",
+		Text:  tr("Retry"),
+		Type:  "button",
+		Value: query,
+	}
+}
+
+// handleRetryLookup looks up the query of a "not found" message again,
+// posting the instances found and removing the query from the message.
+func handleRetryLookup(cb *ActionCallback, action slack.AttachmentAction) (*slack.Msg, error) {
+	ev := cb.event()
+	matches, err := resolveInstances(action.Value)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		found    []*ec2.Instance
+		notFound []string
+	)
+	switch len(matches) {
+	case 0:
+		found, notFound, err = ev.retryInstances([]string{action.Value})
+		if err != nil {
+			return nil, err
+		}
+	case 1:
+		found = matches
+	default:
+		ev.postAmbiguousInstances(action.Value, matches)
+	}
+	if err := ev.postInstances(found); err != nil {
+		return nil, err
+	}
+
+	msg := cb.OriginalMessage
+	if msg == nil {
+		return nil, nil
+	}
+	attachments := make([]slack.Attachment, 0, len(msg.Attachments))
+	for _, a := range msg.Attachments {
+		if len(a.Actions) > 0 && a.Actions[0].Value == action.Value {
+			if len(notFound) == 0 {
+				continue
+			}
+			a.Footer = tr("still not found at %s", ev.formatTime(time.Now()))
+		}
+		attachments = append(attachments, a)
+	}
+	msg.Attachments = attachments
+	if len(attachments) == 0 {
+		msg.Text = tr("all instances were found")
+	}
+	return msg, nil
+}
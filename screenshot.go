@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/nlopes/slack"
+)
+
+func init() {
+	mentionCommands["screenshot"] = (*Event).screenshot
+}
+
+func screenshotButton(id string) slack.AttachmentAction {
+	return slack.AttachmentAction{
+		Name:  "screenshot",
+		Text:  tr("Screenshot"),
+		Type:  "button",
+		Value: id,
+	}
+}
+
+// screenshot handles "@ec2bot screenshot i-xxx" by uploading a screenshot
+// of the console of each instance.
+func (ev *Event) screenshot(args []string) error {
+	ids := hostIDPattern.FindAllString(strings.Join(args, " "), -1)
+	if len(ids) == 0 {
+		return errors.New(tr("usage: screenshot <instance id>"))
+	}
+	for _, id := range ids {
+		if err := authorizeAction(ev.TeamID, ev.Event.User, "screenshot", id); err != nil {
+			ev.post(err.Error(), nil)
+			continue
+		}
+		if err := ev.uploadScreenshot(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadScreenshot uploads a screenshot of the console of the instance.
+func (ev *Event) uploadScreenshot(id string) error {
+	image, err := getConsoleScreenshot(id)
+	ev.audit("screenshot", id, err)
+	if err != nil {
+		return ev.post(tr("failed to get screenshot of %s: %v", id, err), nil)
+	}
+	ext := "jpg"
+	if http.DetectContentType(image) == "image/png" {
+		ext = "png"
+	}
+	return ev.upload(&File{
+		Name:    id + "-screenshot." + ext,
+		Type:    ext,
+		Title:   tr("Screenshot of %s", id),
+		Content: image,
+	})
+}
+
+// getConsoleScreenshot returns the decoded screenshot of the console of
+// the instance.
+func getConsoleScreenshot(id string) ([]byte, error) {
+	svc := regionEC2Client(instanceRegion(id))
+	resp, err := svc.GetConsoleScreenshot(&ec2.GetConsoleScreenshotInput{
+		InstanceId: aws.String(id),
+		WakeUp:     aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(aws.StringValue(resp.ImageData))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode screenshot: %v", err)
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty screenshot")
+	}
+	return b, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/nlopes/slack"
+)
+
+// securityGroupAttachments lists the security groups of the instance, each
+// with a button expanding its rules in the thread.
+func securityGroupAttachments(instance *ec2.Instance) []slack.Attachment {
+	region := instanceRegion(aws.StringValue(instance.InstanceId))
+	attachments := make([]slack.Attachment, len(instance.SecurityGroups))
+	for i, g := range instance.SecurityGroups {
+		// groups are looked up in the region of the instance on expansion
+		query := region + "/" + aws.StringValue(g.GroupId)
+		attachments[i] = summaryRow("security_group", query, aws.StringValue(g.GroupId), aws.StringValue(g.GroupName))
+		if i == 0 {
+			attachments[i].Title = tr("Security Groups")
+		}
+	}
+	return attachments
+}
+
+// getSecurityGroup returns the security group of the ID in the region, or
+// nil if it is not found.
+func getSecurityGroup(region, id string) (*ec2.SecurityGroup, error) {
+	svc := regionEC2Client(region)
+	resp, err := svc.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("group-id"),
+			Values: aws.StringSlice([]string{id}),
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range resp.SecurityGroups {
+		if aws.StringValue(g.GroupId) == id {
+			return g, nil
+		}
+	}
+	return nil, nil
+}
+
+// formatPermission renders a rule such as "tcp 443 from 0.0.0.0/0".
+func formatPermission(p *ec2.IpPermission, direction string) string {
+	protocol := aws.StringValue(p.IpProtocol)
+	ports := ""
+	switch {
+	case protocol == "-1":
+		protocol = tr("all traffic")
+	case p.FromPort == nil:
+	case aws.Int64Value(p.FromPort) == aws.Int64Value(p.ToPort):
+		ports = fmt.Sprintf(" %d", aws.Int64Value(p.FromPort))
+	default:
+		ports = fmt.Sprintf(" %d-%d", aws.Int64Value(p.FromPort), aws.Int64Value(p.ToPort))
+	}
+	var peers []string
+	for _, r := range p.IpRanges {
+		peers = append(peers, aws.StringValue(r.CidrIp))
+	}
+	for _, r := range p.Ipv6Ranges {
+		peers = append(peers, aws.StringValue(r.CidrIpv6))
+	}
+	for _, g := range p.UserIdGroupPairs {
+		peers = append(peers, aws.StringValue(g.GroupId))
+	}
+	for _, l := range p.PrefixListIds {
+		peers = append(peers, aws.StringValue(l.PrefixListId))
+	}
+	return fmt.Sprintf("%s%s %s %s", code(protocol), ports, direction, strings.Join(peers, ", "))
+}
+
+// postSecurityGroup posts the rules of the security group.
+func (ev *Event) postSecurityGroup(region string, group *ec2.SecurityGroup) error {
+	id := aws.StringValue(group.GroupId)
+	inbound := make([]string, len(group.IpPermissions))
+	for i, p := range group.IpPermissions {
+		inbound[i] = formatPermission(p, tr("from"))
+	}
+	outbound := make([]string, len(group.IpPermissionsEgress))
+	for i, p := range group.IpPermissionsEgress {
+		outbound[i] = formatPermission(p, tr("to"))
+	}
+	none := tr("none")
+	if len(inbound) == 0 {
+		inbound = []string{none}
+	}
+	if len(outbound) == 0 {
+		outbound = []string{none}
+	}
+	return ev.post(fmt.Sprintf("%s (%s)", id, aws.StringValue(group.GroupName)), []slack.Attachment{
+		{
+			Text: aws.StringValue(group.Description),
+			Fields: []slack.AttachmentField{
+				{Title: tr("Inbound Rules"), Value: strings.Join(inbound, "\n")},
+				{Title: tr("Outbound Rules"), Value: strings.Join(outbound, "\n")},
+			},
+			MarkdownIn: []string{"fields"},
+			Footer:     accountContext(aws.StringValue(group.OwnerId), region),
+		},
+		consoleAttachment(consoleURL(region, "SecurityGroup:groupId="+id)),
+	})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/labstack/echo"
+)
+
+// sentry reports errors to Sentry or a compatible service of $SENTRY_DSN.
+// It is nil if the DSN is not set.
+var sentry = newSentryClient(getenv("SENTRY_DSN"), getenv("SENTRY_ENVIRONMENT"))
+
+// sentryTags are the fields of log entries which are indexed as tags of
+// events. The other fields are sent as extra data.
+var sentryTags = []string{"event_id", "team_id", "channel", "user", "resolver", "aws_error_code"}
+
+// SentryClient posts events to the store API of a Sentry project. Events
+// are queued and sent in the background so that logging never blocks, and
+// dropped while the queue is full.
+type SentryClient struct {
+	Endpoint    string
+	Key         string
+	Environment string
+
+	queue  chan *SentryEvent
+	client *http.Client
+}
+
+// SentryEvent is an event of the store API.
+type SentryEvent struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   string                 `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Platform    string                 `json:"platform"`
+	Logger      string                 `json:"logger"`
+	ServerName  string                 `json:"server_name,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	Release     string                 `json:"release"`
+	Message     string                 `json:"message"`
+	Exception   []SentryException      `json:"exception,omitempty"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+}
+
+// SentryException is the error of an event.
+type SentryException struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func newSentryClient(dsn, environment string) *SentryClient {
+	if dsn == "" {
+		return nil
+	}
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil || u.Host == "" {
+		logWarn("cannot parse $SENTRY_DSN, disable error reporting", nil)
+		return nil
+	}
+	i := strings.LastIndex(u.Path, "/")
+	project := u.Path[i+1:]
+	if project == "" {
+		logWarn("cannot parse $SENTRY_DSN, disable error reporting", nil)
+		return nil
+	}
+	s := &SentryClient{
+		Endpoint:    fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, u.Path[:i], project),
+		Key:         u.User.Username(),
+		Environment: environment,
+		queue:       make(chan *SentryEvent, 100),
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+	go s.run()
+	return s
+}
+
+func (s *SentryClient) run() {
+	for ev := range s.queue {
+		if err := s.send(ev); err != nil {
+			// not logged as an error, which would be reported again
+			logWarn("failed to report error to Sentry: "+err.Error(), nil)
+		}
+	}
+}
+
+func (s *SentryClient) send(ev *SentryEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf(
+		"Sentry sentry_version=7, sentry_client=ec2bot/%s, sentry_timestamp=%d, sentry_key=%s",
+		version, time.Now().Unix(), s.Key,
+	))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", s.Endpoint, resp.Status)
+	}
+	return nil
+}
+
+// capture queues an event of the error logged with the fields.
+func (s *SentryClient) capture(msg string, err error, fields logFields) {
+	id := make([]byte, 16)
+	rand.Read(id)
+	host, _ := os.Hostname()
+	ev := &SentryEvent{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   time.Now().UTC().Format("2006-01-02T15:04:05"),
+		Level:       "error",
+		Platform:    "go",
+		Logger:      "ec2bot",
+		ServerName:  host,
+		Environment: s.Environment,
+		Release:     version,
+		Message:     msg,
+		Tags:        make(map[string]string),
+		Extra:       make(map[string]interface{}),
+	}
+	if err != nil {
+		ev.Exception = []SentryException{{Type: fmt.Sprintf("%T", err), Value: redact(err.Error())}}
+		if aerr, ok := err.(awserr.Error); ok {
+			ev.Tags["aws_error_code"] = aerr.Code()
+		}
+		if rerr, ok := err.(awserr.RequestFailure); ok {
+			ev.Extra["aws_request_id"] = rerr.RequestID()
+		}
+	}
+	for k, v := range fields {
+		if k == "error" {
+			continue
+		}
+		if e, ok := v.(error); ok {
+			v = e.Error()
+		}
+		ev.Extra[k] = v
+		for _, tag := range sentryTags {
+			if s, ok := v.(string); ok && k == tag && s != "" {
+				ev.Tags[k] = s
+				delete(ev.Extra, k)
+			}
+		}
+	}
+	select {
+	case s.queue <- ev:
+	default:
+	}
+}
+
+// reportPanic logs and reports the value of a recovered panic with the
+// stack.
+func reportPanic(r interface{}, fields logFields) {
+	f := logFields{"stack": string(debug.Stack())}
+	for k, v := range fields {
+		f[k] = v
+	}
+	logError("panic", fmt.Errorf("%v", r), f)
+}
+
+// recoverPanics is the middleware responding 500 to requests whose handler
+// panics instead of crashing the process.
+func recoverPanics(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				reportPanic(r, logFields{"path": c.Path()})
+				err = echo.NewHTTPError(http.StatusInternalServerError)
+			}
+		}()
+		return next(c)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/nlopes/slack"
+)
+
+// getAgentInformation returns the SSM agent information of the instance,
+// or nil if the instance is not managed by SSM.
+func getAgentInformation(id string) (*ssm.InstanceInformation, error) {
+	svc := regionSSMClient(instanceRegion(id))
+	resp, err := svc.DescribeInstanceInformation(&ssm.DescribeInstanceInformationInput{
+		Filters: []*ssm.InstanceInformationStringFilter{
+			&ssm.InstanceInformationStringFilter{
+				Key:    aws.String("InstanceIds"),
+				Values: []*string{aws.String(id)},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.InstanceInformationList) == 0 {
+		return nil, nil
+	}
+	return resp.InstanceInformationList[0], nil
+}
+
+func sessionManagerURL(region, instanceID string) string {
+	return fmt.Sprintf(
+		"https://%s/systems-manager/session-manager/%s?region=%s",
+		consoleHost(region),
+		instanceID,
+		url.QueryEscape(region),
+	)
+}
+
+// sessionAttachment shows the status of the SSM agent of the instance and
+// links to a Session Manager session if the agent is online, with a button
+// posting its inventory. It returns nil if the instance is not managed by
+// SSM.
+func sessionAttachment(instanceID string) *slack.Attachment {
+	info, err := getAgentInformation(instanceID)
+	if err != nil {
+		logError("failed to get SSM agent information", err, logFields{"instance_id": instanceID})
+		return nil
+	}
+	if info == nil {
+		return nil
+	}
+	status := aws.StringValue(info.PingStatus)
+	a := &slack.Attachment{
+		CallbackID: "instance_action",
+		Actions:    []slack.AttachmentAction{inventoryButton(instanceID)},
+		Fields: []slack.AttachmentField{
+			slack.AttachmentField{
+				Title: tr("SSM Agent"),
+				Value: fmt.Sprintf("%s (%s)", status, aws.StringValue(info.AgentVersion)),
+				Short: true,
+			},
+		},
+	}
+	if status == ssm.PingStatusOnline {
+		a.Title = tr("Start Session Manager session")
+		a.TitleLink = sessionManagerURL(instanceRegion(instanceID), instanceID)
+	}
+	return a
+}
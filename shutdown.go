@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// shutdownTimeout bounds the time spent finishing the requests and the
+// background tasks in flight on SIGTERM or SIGINT.
+var shutdownTimeout = 30 * time.Second
+
+// background tracks the tasks posting to Slack after the request which
+// started them, such as waiting for SSM commands, so that they are finished
+// before the bot exits.
+var background sync.WaitGroup
+
+func init() {
+	if v := getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			logWarn("cannot parse $SHUTDOWN_TIMEOUT, use default '30s'", nil)
+			return
+		}
+		shutdownTimeout = d
+	}
+}
+
+// goBackground runs fn in a goroutine tracked by background. A panic of fn
+// is reported instead of crashing the process.
+func goBackground(fn func()) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				reportPanic(r, nil)
+			}
+		}()
+		fn()
+	}()
+}
+
+// serve runs the server until it fails or the process is signaled, in
+// which case it stops accepting requests, waits for the requests and the
+// background tasks in flight and persists the caches.
+func serve(e *echo.Echo) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- start(e)
+	}()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+	select {
+	case err := <-errc:
+		return err
+	case s := <-sig:
+		logInfo("shutting down", logFields{"signal": s.String()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := e.Shutdown(ctx); err != nil && err != http.ErrServerClosed {
+		logError("failed to shut down the server", err, nil)
+	}
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logWarn("shut down before background tasks finished", nil)
+	}
+	persistCaches()
+	flushStats()
+	return nil
+}
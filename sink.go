@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/nlopes/slack"
+)
+
+// Notification is a finding sent by proactive subsystems such as alerts,
+// reports and compliance checks.
+type Notification struct {
+	Title       string             `json:"title"`
+	Text        string             `json:"text"`
+	Attachments []slack.Attachment `json:"attachments,omitempty"`
+}
+
+// Sink delivers notifications to an audience. The sinks are configured for
+// the audit log by $AUDIT_SINKS; the alarm and health event rules still post
+// to the Slack channels of their own settings.
+type Sink interface {
+	Send(n *Notification) error
+}
+
+var sesSender = getenv("SES_SENDER")
+
+// parseSink parses a sink specification such as "slack:C0123456",
+// "email:ops@example.com,oncall@example.com", "sns:<topic arn>" or
+// "webhook:https://example.com/hook".
+func parseSink(spec string) (Sink, error) {
+	i := strings.Index(spec, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid sink %q", spec)
+	}
+	kind, target := spec[:i], spec[i+1:]
+	if target == "" {
+		return nil, fmt.Errorf("invalid sink %q: missing target", spec)
+	}
+	switch kind {
+	case "slack":
+		return SlackSink{Channel: target}, nil
+	case "email":
+		if sesSender == "" {
+			return nil, fmt.Errorf("invalid sink %q: $SES_SENDER is not set", spec)
+		}
+		return EmailSink{To: strings.Split(target, ",")}, nil
+	case "sns":
+		return SNSSink{TopicARN: target}, nil
+	case "webhook":
+		return WebhookSink{URL: target}, nil
+	}
+	return nil, fmt.Errorf("invalid sink %q: unknown type %q", spec, kind)
+}
+
+// parseSinks parses a whitespace separated list of sink specifications.
+func parseSinks(specs string) ([]Sink, error) {
+	var sinks []Sink
+	for _, spec := range strings.Fields(specs) {
+		sink, err := parseSink(spec)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// notify sends n to every sink and returns the first error.
+func notify(sinks []Sink, n *Notification) error {
+	var firstErr error
+	for _, sink := range sinks {
+		if err := sink.Send(n); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type SlackSink struct {
+	Channel string
+}
+
+func (s SlackSink) Send(n *Notification) error {
+	_, _, err := api.PostMessage(
+		s.Channel,
+		n.Title,
+		messageParameters("", append([]slack.Attachment{
+			slack.Attachment{Text: n.Text},
+		}, n.Attachments...)),
+	)
+	return err
+}
+
+type EmailSink struct {
+	To []string
+}
+
+func (s EmailSink) Send(n *Notification) error {
+	svc := sesClient
+	_, err := svc.SendEmail(&ses.SendEmailInput{
+		Source: aws.String(sesSender),
+		Destination: &ses.Destination{
+			ToAddresses: aws.StringSlice(s.To),
+		},
+		Message: &ses.Message{
+			Subject: &ses.Content{Data: aws.String(n.Title)},
+			Body: &ses.Body{
+				Text: &ses.Content{Data: aws.String(n.plainText())},
+			},
+		},
+	})
+	return err
+}
+
+type SNSSink struct {
+	TopicARN string
+}
+
+func (s SNSSink) Send(n *Notification) error {
+	svc := snsClient
+	_, err := svc.Publish(&sns.PublishInput{
+		TopicArn: aws.String(s.TopicARN),
+		Subject:  aws.String(snsSubject(n.Title)),
+		Message:  aws.String(n.plainText()),
+	})
+	return err
+}
+
+// snsSubject returns the title cut to the 99 characters allowed in the
+// subjects of SNS messages.
+func snsSubject(title string) string {
+	n := 0
+	for i := range title {
+		if n == 99 {
+			return title[:i]
+		}
+		n++
+	}
+	return title
+}
+
+type WebhookSink struct {
+	URL string
+}
+
+func (s WebhookSink) Send(n *Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(s.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", s.URL, resp.Status)
+	}
+	return nil
+}
+
+// plainText renders the notification for mail-based audiences.
+func (n *Notification) plainText() string {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, n.Title)
+	if n.Text != "" {
+		fmt.Fprintf(&buf, "\n%s\n", n.Text)
+	}
+	for _, a := range n.Attachments {
+		if a.Title != "" {
+			fmt.Fprintf(&buf, "\n%s\n", a.Title)
+		}
+		if a.Text != "" {
+			fmt.Fprintln(&buf, a.Text)
+		}
+		for _, f := range a.Fields {
+			fmt.Fprintf(&buf, "%s: %s\n", f.Title, f.Value)
+		}
+	}
+	return buf.String()
+}
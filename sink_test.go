@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSNSSubject(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"short", "short"},
+		{strings.Repeat("a", 99), strings.Repeat("a", 99)},
+		{strings.Repeat("a", 150), strings.Repeat("a", 99)},
+		{strings.Repeat("あ", 50), strings.Repeat("あ", 50)},
+		{strings.Repeat("あ", 120), strings.Repeat("あ", 99)},
+	}
+	for _, tt := range tests {
+		got := snsSubject(tt.title)
+		if got != tt.want {
+			t.Errorf("snsSubject(%d runes) = %d runes, want %d", utf8.RuneCountInString(tt.title), utf8.RuneCountInString(got), utf8.RuneCountInString(tt.want))
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("snsSubject(%d runes) is not valid UTF-8", utf8.RuneCountInString(tt.title))
+		}
+	}
+}
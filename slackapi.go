@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+
+	"github.com/nlopes/slack"
+)
+
+// Dialog is a Slack dialog collecting input from a user.
+type Dialog struct {
+	CallbackID  string          `json:"callback_id"`
+	Title       string          `json:"title"`
+	SubmitLabel string          `json:"submit_label,omitempty"`
+	State       string          `json:"state,omitempty"`
+	Elements    []DialogElement `json:"elements"`
+}
+
+type DialogElement struct {
+	Type        string         `json:"type"`
+	Label       string         `json:"label"`
+	Name        string         `json:"name"`
+	Placeholder string         `json:"placeholder,omitempty"`
+	Hint        string         `json:"hint,omitempty"`
+	Optional    bool           `json:"optional,omitempty"`
+	Options     []DialogOption `json:"options,omitempty"`
+}
+
+// DialogOption is an option of a select element.
+type DialogOption struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// DialogError reports an invalid value of a dialog element.
+type DialogError struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// callAPI calls a Slack Web API method which is not covered by the client
+// library with the token of the team. The response is decoded into v unless
+// it is nil.
+func callAPI(teamID, method string, values url.Values, v interface{}) error {
+	values.Set("token", slackToken(teamID))
+	resp, err := slackHTTPClient.PostForm("https://slack.com/api/"+method, values)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeAPIResponse(method, resp.Body, v)
+}
+
+func decodeAPIResponse(method string, body io.Reader, v interface{}) error {
+	var raw json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return err
+	}
+	var r struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return err
+	}
+	if !r.OK {
+		return fmt.Errorf("%s: %s", method, r.Error)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func openDialog(teamID, triggerID string, dialog *Dialog) error {
+	b, err := json.Marshal(dialog)
+	if err != nil {
+		return err
+	}
+	return callAPI(teamID, "dialog.open", url.Values{
+		"trigger_id": {triggerID},
+		"dialog":     {string(b)},
+	}, nil)
+}
+
+// updateMessage replaces the text and attachments of a message posted by
+// the bot.
+func updateMessage(teamID, channel, ts, text string, attachments []slack.Attachment) error {
+	b, err := json.Marshal(attachments)
+	if err != nil {
+		return err
+	}
+	return callAPI(teamID, "chat.update", url.Values{
+		"channel":     {channel},
+		"ts":          {ts},
+		"text":        {text},
+		"attachments": {string(b)},
+	}, nil)
+}
+
+// permalink returns a link to the message, falling back to the archive URL
+// of the channel if it cannot be resolved.
+func permalink(teamID, channel, ts string) string {
+	var r struct {
+		Permalink string `json:"permalink"`
+	}
+	err := callAPI(teamID, "chat.getPermalink", url.Values{
+		"channel":    {channel},
+		"message_ts": {ts},
+	}, &r)
+	if err != nil {
+		logError("failed to get permalink", err, nil)
+		return "https://slack.com/archives/" + channel
+	}
+	return r.Permalink
+}
+
+// File is a file shared by the bot.
+type File struct {
+	Name    string
+	Type    string
+	Title   string
+	Comment string
+	Content []byte
+}
+
+// uploadFile shares the file in the channel, in the thread if it is not
+// empty.
+func uploadFile(teamID, channel, thread string, file *File) error {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	fields := map[string]string{
+		"token":           slackToken(teamID),
+		"channels":        channel,
+		"thread_ts":       thread,
+		"filename":        file.Name,
+		"filetype":        file.Type,
+		"title":           file.Title,
+		"initial_comment": file.Comment,
+	}
+	for k, v := range fields {
+		if v == "" {
+			continue
+		}
+		if err := w.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	part, err := w.CreateFormFile("file", file.Name)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(file.Content); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	resp, err := slackHTTPClient.Post("https://slack.com/api/files.upload", w.FormDataContentType(), &body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeAPIResponse("files.upload", resp.Body, nil)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// requiredFields are the fields of instances which are rendered on cards or
+// indexed for lookups, so they are always cached.
+var requiredFields = []string{
+	"InstanceId",
+	"InstanceLifecycle",
+	"InstanceType",
+	"LaunchTime",
+	"NetworkInterfaces",
+	"PrivateDnsName",
+	"PrivateIpAddress",
+	"PublicDnsName",
+	"PublicIpAddress",
+	"SecurityGroups",
+	"SpotInstanceRequestId",
+	"State",
+	"StateTransitionReason",
+	"Tags",
+}
+
+var (
+	// retainedFields are the fields of instances kept in the cache, which
+	// are the required fields and those of $INSTANCE_FIELDS. The other
+	// fields are described on demand when the details of an instance are
+	// rendered. It is nil if $INSTANCE_FIELDS is "all", in which case
+	// instances are cached as described.
+	retainedFields = map[string]struct{}{
+		"ImageId":   {},
+		"Placement": {},
+		"SubnetId":  {},
+		"VpcId":     {},
+	}
+	// maxInstanceCacheBytes stops loading pages of DescribeInstances once
+	// the cached instances take about as many bytes, estimated by their
+	// JSON encoding. There is no limit if it is zero.
+	maxInstanceCacheBytes int
+)
+
+func init() {
+	for _, name := range requiredFields {
+		retainedFields[name] = struct{}{}
+	}
+	if v := getenv("INSTANCE_FIELDS"); v == "all" {
+		retainedFields = nil
+	} else if v != "" {
+		t := reflect.TypeOf(ec2.Instance{})
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if f, ok := t.FieldByName(name); !ok || f.PkgPath != "" {
+				logWarn("unknown field of $INSTANCE_FIELDS, ignore it", logFields{"field": name})
+				continue
+			}
+			retainedFields[name] = struct{}{}
+		}
+	}
+	if v := getenv("INSTANCE_CACHE_MAX_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			logWarn("cannot parse $INSTANCE_CACHE_MAX_BYTES, use default '0'", nil)
+		} else {
+			maxInstanceCacheBytes = n
+		}
+	}
+}
+
+// slimInstance returns a copy of the instance with only the retained
+// fields.
+func slimInstance(instance *ec2.Instance) *ec2.Instance {
+	if retainedFields == nil {
+		return instance
+	}
+	src := reflect.ValueOf(instance).Elem()
+	slim := new(ec2.Instance)
+	dst := reflect.ValueOf(slim).Elem()
+	for name := range retainedFields {
+		dst.FieldByName(name).Set(src.FieldByName(name))
+	}
+	return slim
+}
+
+// slimReservations returns copies of the reservations with slimmed
+// instances, and the estimated size of the instances if
+// $INSTANCE_CACHE_MAX_BYTES is set.
+func slimReservations(reservations []*ec2.Reservation) ([]*ec2.Reservation, int) {
+	var size int
+	result := make([]*ec2.Reservation, len(reservations))
+	for i, r := range reservations {
+		reservation := *r
+		reservation.Instances = make([]*ec2.Instance, len(r.Instances))
+		for j, instance := range r.Instances {
+			reservation.Instances[j] = slimInstance(instance)
+			if maxInstanceCacheBytes > 0 {
+				b, _ := json.Marshal(reservation.Instances[j])
+				size += len(b)
+			}
+		}
+		result[i] = &reservation
+	}
+	return result, size
+}
+
+// rawInstance returns the instance with all fields, describing it again if
+// the cached one is slimmed. The cached instance is returned if it cannot
+// be described.
+func rawInstance(instance *ec2.Instance) *ec2.Instance {
+	if retainedFields == nil {
+		return instance
+	}
+	svc := instanceDescriber(instanceRegion(aws.StringValue(instance.InstanceId)))
+	resp, err := svc.DescribeInstances(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("instance-id"),
+			Values: []*string{instance.InstanceId},
+		}},
+	})
+	if err != nil {
+		logError("failed to describe instance", err, logFields{"instance_id": aws.StringValue(instance.InstanceId)})
+		return instance
+	}
+	for _, r := range resp.Reservations {
+		for _, raw := range r.Instances {
+			if aws.StringValue(raw.InstanceId) == aws.StringValue(instance.InstanceId) {
+				return raw
+			}
+		}
+	}
+	return instance
+}
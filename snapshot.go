@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/nlopes/slack"
+)
+
+// CacheSnapshot is a read-only copy of the caches taken after each refresh.
+// Reports and analytics read it instead of the live caches so that they
+// never contend with interactive lookups.
+type CacheSnapshot struct {
+	TakenAt          time.Time
+	Instances        []*ec2.Instance
+	InstanceAccounts map[string]string
+	LoadBalancers    []*elb.LoadBalancerDescription
+	LoadBalancerTags map[string][]*elb.Tag
+}
+
+var snapshot atomic.Value
+
+func init() {
+	snapshot.Store(&CacheSnapshot{
+		InstanceAccounts: make(map[string]string),
+		LoadBalancerTags: make(map[string][]*elb.Tag),
+	})
+}
+
+// cacheSnapshot returns the latest snapshot. It must not be modified.
+func cacheSnapshot() *CacheSnapshot {
+	return snapshot.Load().(*CacheSnapshot)
+}
+
+// takeSnapshot copies the current caches into a new snapshot. It must be
+// called with cacheMu locked.
+func takeSnapshot() {
+	s := &CacheSnapshot{
+		TakenAt:          time.Now(),
+		InstanceAccounts: make(map[string]string),
+		LoadBalancerTags: make(map[string][]*elb.Tag),
+	}
+	if resp := instanceCache.Instances; resp != nil {
+		for _, reservation := range resp.Reservations {
+			s.Instances = append(s.Instances, reservation.Instances...)
+			for _, instance := range reservation.Instances {
+				if instance.InstanceId != nil && reservation.OwnerId != nil {
+					s.InstanceAccounts[*instance.InstanceId] = *reservation.OwnerId
+				}
+			}
+		}
+	}
+	if resp := loadBalancerCache.LoadBalancers; resp != nil {
+		s.LoadBalancers = append(s.LoadBalancers, resp.LoadBalancerDescriptions...)
+	}
+	for name, tags := range loadBalancerTags.Items() {
+		s.LoadBalancerTags[name] = tags.([]*elb.Tag)
+	}
+	snapshot.Store(s)
+}
+
+// fleetAttachment summarizes the snapshot for reports.
+func (s *CacheSnapshot) fleetAttachment() slack.Attachment {
+	states := make(map[string]int)
+	for _, instance := range s.Instances {
+		if instance.State != nil && instance.State.Name != nil {
+			states[*instance.State.Name]++
+		}
+	}
+	footer := "cache not loaded yet"
+	if !s.TakenAt.IsZero() {
+		footer = "as of " + s.TakenAt.UTC().Format(time.RFC3339)
+	}
+	return slack.Attachment{
+		Title: "Fleet",
+		Fields: []slack.AttachmentField{
+			slack.AttachmentField{
+				Title: "Instances",
+				Value: fmt.Sprint(len(s.Instances)),
+				Short: true,
+			},
+			slack.AttachmentField{
+				Title: "Load Balancers",
+				Value: fmt.Sprint(len(s.LoadBalancers)),
+				Short: true,
+			},
+			slack.AttachmentField{
+				Title: "Instances by State",
+				Value: rankCounts(states, "%s"),
+			},
+		},
+		Footer: footer,
+	}
+}
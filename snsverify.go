@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/bgpat/ec2bot/internal/cache"
+)
+
+// snsHostPattern matches the hosts of SNS serving the signing certificates
+// and the subscribe URLs in all partitions.
+var snsHostPattern = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(?:\.cn)?$`)
+
+// snsCerts are the signing certificates by their URLs.
+var snsCerts = cache.NewLRU(10, time.Hour)
+
+// verifySNSMessage verifies the signature of the message with the signing
+// certificate of SNS.
+func verifySNSMessage(m *snsMessage) error {
+	var hash crypto.Hash
+	switch m.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return fmt.Errorf("unknown signature version %q", m.SignatureVersion)
+	}
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return err
+	}
+	cert, err := snsCert(m.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("signing certificate has no RSA key")
+	}
+	var digest []byte
+	if hash == crypto.SHA1 {
+		sum := sha1.Sum(snsStringToSign(m))
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256(snsStringToSign(m))
+		digest = sum[:]
+	}
+	return rsa.VerifyPKCS1v15(key, hash, digest, signature)
+}
+
+// snsStringToSign returns the signed fields of the message.
+func snsStringToSign(m *snsMessage) []byte {
+	var fields []string
+	if m.Type == "Notification" {
+		fields = []string{"Message", m.Message, "MessageId", m.MessageID}
+		if m.Subject != "" {
+			fields = append(fields, "Subject", m.Subject)
+		}
+		fields = append(fields, "Timestamp", m.Timestamp, "TopicArn", m.TopicArn, "Type", m.Type)
+	} else {
+		fields = []string{
+			"Message", m.Message,
+			"MessageId", m.MessageID,
+			"SubscribeURL", m.SubscribeURL,
+			"Timestamp", m.Timestamp,
+			"Token", m.Token,
+			"TopicArn", m.TopicArn,
+			"Type", m.Type,
+		}
+	}
+	var b []byte
+	for _, f := range fields {
+		b = append(b, f...)
+		b = append(b, '\n')
+	}
+	return b
+}
+
+// snsCert returns the signing certificate of the URL, which has to be that
+// of SNS.
+func snsCert(certURL string) (*x509.Certificate, error) {
+	if v, ok := snsCerts.Get(certURL); ok {
+		return v.(*x509.Certificate), nil
+	}
+	u, err := url.Parse(certURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" || !snsHostPattern.MatchString(u.Host) {
+		return nil, fmt.Errorf("invalid signing certificate URL %q", certURL)
+	}
+	resp, err := webhookClient.Get(certURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", certURL, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, errors.New("no certificate in " + certURL)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	snsCerts.Add(certURL, cert)
+	return cert, nil
+}
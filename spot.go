@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/bgpat/ec2bot/internal/cache"
+	"github.com/nlopes/slack"
+)
+
+// The detail types of the EventBridge events warning of spot
+// interruptions, which are received by /events.
+const (
+	spotInterruptionWarning = "EC2 Spot Instance Interruption Warning"
+	rebalanceRecommendation = "EC2 Instance Rebalance Recommendation"
+)
+
+var (
+	// spotNotices are the interruption warnings and the rebalance
+	// recommendations received by instance ID. Instances are interrupted
+	// two minutes after the warnings, so they are kept briefly.
+	spotNotices = cache.NewLRU(10000, 2*time.Hour)
+	// spotRequests are the recent spot requests by ID.
+	spotRequests = cache.NewLRU(1000, time.Minute)
+)
+
+// spotNotice is an interruption warning or a rebalance recommendation of a
+// spot instance.
+type spotNotice struct {
+	DetailType string
+	// Action is the action of an interruption, such as "terminate".
+	Action string
+	Time   time.Time
+}
+
+// recordSpotNotice records an interruption warning or a rebalance
+// recommendation of the instance.
+func recordSpotNotice(instanceID, detailType, action string) {
+	spotNotices.Add(instanceID, &spotNotice{
+		DetailType: detailType,
+		Action:     action,
+		Time:       time.Now(),
+	})
+	logInfo("spot notice", logFields{"instance_id": instanceID, "detail_type": detailType, "action": action})
+}
+
+// getSpotRequest returns the spot request of the ID, or nil if it is not
+// found.
+func getSpotRequest(region, id string) (*ec2.SpotInstanceRequest, error) {
+	if v, ok := spotRequests.Get(id); ok {
+		return v.(*ec2.SpotInstanceRequest), nil
+	}
+	svc := regionEC2Client(region)
+	resp, err := svc.DescribeSpotInstanceRequests(&ec2.DescribeSpotInstanceRequestsInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("spot-instance-request-id"),
+			Values: aws.StringSlice([]string{id}),
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var request *ec2.SpotInstanceRequest
+	for _, r := range resp.SpotInstanceRequests {
+		if aws.StringValue(r.SpotInstanceRequestId) == id {
+			request = r
+		}
+	}
+	spotRequests.Add(id, request)
+	return request, nil
+}
+
+// lifecycleField renders whether the instance is on-demand or spot with
+// its spot request, and any pending interruption or rebalance
+// recommendation. It reports true if the instance is about to be
+// interrupted.
+func (ev *Event) lifecycleField(instance *ec2.Instance) (slack.AttachmentField, bool) {
+	field := slack.AttachmentField{Title: tr("Lifecycle"), Short: true}
+	lifecycle := aws.StringValue(instance.InstanceLifecycle)
+	if lifecycle == "" {
+		field.Value = tr("on-demand")
+		return field, false
+	}
+	field.Value = lifecycle
+	if lifecycle != ec2.InstanceLifecycleTypeSpot {
+		return field, false
+	}
+	id := aws.StringValue(instance.InstanceId)
+	warning := false
+	if requestID := aws.StringValue(instance.SpotInstanceRequestId); requestID != "" {
+		field.Value += " " + code(requestID)
+		request, err := getSpotRequest(instanceRegion(id), requestID)
+		if err != nil {
+			logError("failed to describe spot request", err, logFields{"instance_id": id, "spot_request_id": requestID})
+		} else if request != nil && request.Status != nil {
+			status := aws.StringValue(request.Status.Code)
+			if strings.HasPrefix(status, "marked-for-") {
+				field.Value += "\n:warning: " + code(status)
+				warning = true
+			}
+		}
+	}
+	if v, ok := spotNotices.Get(id); ok {
+		n := v.(*spotNotice)
+		switch n.DetailType {
+		case spotInterruptionWarning:
+			field.Value += "\n:warning: " + tr("interruption (%s) warned at %s", n.Action, ev.formatTime(n.Time))
+		case rebalanceRecommendation:
+			field.Value += "\n:warning: " + tr("rebalance recommended at %s", ev.formatTime(n.Time))
+		}
+		warning = true
+	}
+	return field, warning
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// SSMCommand is an SSM document which may be run on instances from Slack.
+type SSMCommand struct {
+	Description string              `json:"description"`
+	Document    string              `json:"document"`
+	Parameters  map[string][]string `json:"parameters"`
+	Timeout     int64               `json:"timeout"`
+}
+
+const (
+	defaultSSMDocument = "AWS-RunShellScript"
+	defaultSSMTimeout  = 600
+)
+
+// ssmPollInterval is the interval between checks of the status of a
+// running command.
+var ssmPollInterval = 5 * time.Second
+
+func init() {
+	mentionCommands["run"] = (*Event).runCommand
+	approvalHandlers["ssm"] = func(a *Approval) error {
+		return a.event().runSSMCommand(a.Parameter, a.Resource)
+	}
+}
+
+// runCommand handles "@ec2bot run <command> i-xxx" by running the allowed
+// command on each instance and posting its output when it completes.
+func (ev *Event) runCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(ssmUsage())
+	}
+	name := args[0]
+	if _, ok := currentConfig().SSMCommands[name]; !ok {
+		return fmt.Errorf("%s\n%s", tr("unknown command %q", name), ssmUsage())
+	}
+	ids := hostIDPattern.FindAllString(strings.Join(args[1:], " "), -1)
+	if len(ids) == 0 {
+		return errors.New(ssmUsage())
+	}
+	for _, id := range ids {
+		if err := authorizeAction(ev.TeamID, ev.Event.User, "ssm", id); err != nil {
+			ev.post(err.Error(), nil)
+			continue
+		}
+		if requiresApproval("ssm") {
+			if err := ev.requestApproval("ssm", id, name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := ev.runSSMCommand(name, id); err != nil {
+			ev.post(tr("failed to run %s on %s: %v", name, code(id), err), nil)
+		}
+	}
+	return nil
+}
+
+// runSSMCommand starts the allowed command on the instance and posts its
+// output when it completes.
+func (ev *Event) runSSMCommand(name, id string) error {
+	cmd, ok := currentConfig().SSMCommands[name]
+	if !ok {
+		return errors.New(tr("unknown command %q", name))
+	}
+	commandID, err := cmd.send(id, ev.Event.User)
+	ev.audit("ssm", id, err)
+	if err != nil {
+		return err
+	}
+	ev.post(tr("running %s on %s (requested by <@%s>)", name, code(id), ev.Event.User), nil)
+	goBackground(func() { ev.waitCommand(name, commandID, id, cmd.timeout()) })
+	return nil
+}
+
+func ssmUsage() string {
+	commands := currentConfig().SSMCommands
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return tr("no commands are configured")
+	}
+	lines := []string{tr("usage: run <command> <instance id>")}
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("• %s: %s", name, commands[name].Description))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c *SSMCommand) document() string {
+	if c.Document == "" {
+		return defaultSSMDocument
+	}
+	return c.Document
+}
+
+func (c *SSMCommand) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return defaultSSMTimeout * time.Second
+	}
+	return time.Duration(c.Timeout) * time.Second
+}
+
+// send starts the command on the instance and returns the command ID.
+func (c *SSMCommand) send(instanceID, user string) (string, error) {
+	params := make(map[string][]*string, len(c.Parameters))
+	for k, values := range c.Parameters {
+		params[k] = aws.StringSlice(values)
+	}
+	svc := regionSSMClient(instanceRegion(instanceID))
+	resp, err := svc.SendCommand(&ssm.SendCommandInput{
+		DocumentName:   aws.String(c.document()),
+		InstanceIds:    []*string{aws.String(instanceID)},
+		Parameters:     params,
+		TimeoutSeconds: aws.Int64(int64(c.timeout() / time.Second)),
+		Comment:        aws.String("Requested by Slack user " + user),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(resp.Command.CommandId), nil
+}
+
+// waitCommand polls the invocation of the command on the instance until it
+// completes and uploads its output to the thread of the event.
+func (ev *Event) waitCommand(name, commandID, instanceID string, timeout time.Duration) {
+	svc := regionSSMClient(instanceRegion(instanceID))
+	deadline := time.Now().Add(timeout + time.Minute)
+	for time.Now().Before(deadline) {
+		time.Sleep(ssmPollInterval)
+		resp, err := svc.GetCommandInvocation(&ssm.GetCommandInvocationInput{
+			CommandId:  aws.String(commandID),
+			InstanceId: aws.String(instanceID),
+		})
+		if err != nil {
+			// the invocation may not be visible right after the command is sent
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ssm.ErrCodeInvocationDoesNotExist {
+				continue
+			}
+			logError("failed to get command invocation", err, logFields{"command_id": commandID, "instance_id": instanceID})
+			ev.post(tr("failed to get the result of %s on %s: %v", name, code(instanceID), err), nil)
+			return
+		}
+		status := aws.StringValue(resp.Status)
+		switch status {
+		case ssm.CommandInvocationStatusPending,
+			ssm.CommandInvocationStatusInProgress,
+			ssm.CommandInvocationStatusDelayed:
+			continue
+		}
+		output := aws.StringValue(resp.StandardOutputContent)
+		if stderr := aws.StringValue(resp.StandardErrorContent); stderr != "" {
+			output += "\n--- stderr ---\n" + stderr
+		}
+		comment := tr("%s on %s: %s", name, code(instanceID), status)
+		if output == "" {
+			ev.post(comment, nil)
+			return
+		}
+		if err := ev.upload(&File{
+			Name:    instanceID + "-" + name + ".txt",
+			Type:    "text",
+			Title:   fmt.Sprintf("%s (%s)", name, instanceID),
+			Comment: comment,
+			Content: []byte(output),
+		}); err != nil {
+			logError("failed to upload command output", err, logFields{"command_id": commandID, "instance_id": instanceID})
+		}
+		return
+	}
+	ev.post(tr("timed out waiting for %s on %s", name, code(instanceID)), nil)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+const statsBucket = "stats"
+
+// DailyStats aggregates bot usage for a single UTC day.
+type DailyStats struct {
+	Lookups   int                     `json:"lookups"`
+	Latency   time.Duration           `json:"latency"`
+	Users     map[string]int          `json:"users"`
+	Channels  map[string]int          `json:"channels"`
+	Resolvers map[string]int          `json:"resolvers"`
+	Actions   map[string]*ActionStats `json:"actions"`
+}
+
+type ActionStats struct {
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+var (
+	statsMu sync.Mutex
+	// pendingStats are the counts by day not flushed to the store yet, so
+	// that lookups do not rewrite the store.
+	pendingStats = make(map[string]*DailyStats)
+
+	// statsRetention is the number of days the stats are kept,
+	// $STATS_RETENTION_DAYS.
+	statsRetention = 90
+)
+
+// statsFlushInterval is how often the pending stats are flushed.
+const statsFlushInterval = time.Minute
+
+func init() {
+	if v := getenv("STATS_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			logWarn("cannot parse $STATS_RETENTION_DAYS, use default '90'", nil)
+			return
+		}
+		statsRetention = n
+	}
+}
+
+func newDailyStats() *DailyStats {
+	return &DailyStats{
+		Users:     make(map[string]int),
+		Channels:  make(map[string]int),
+		Resolvers: make(map[string]int),
+		Actions:   make(map[string]*ActionStats),
+	}
+}
+
+// add adds the counts of o to s.
+func (s *DailyStats) add(o *DailyStats) {
+	s.Lookups += o.Lookups
+	s.Latency += o.Latency
+	mergeCounts(s.Users, o.Users)
+	mergeCounts(s.Channels, o.Channels)
+	mergeCounts(s.Resolvers, o.Resolvers)
+	for name, a := range o.Actions {
+		t, ok := s.Actions[name]
+		if !ok {
+			t = new(ActionStats)
+			s.Actions[name] = t
+		}
+		t.Succeeded += a.Succeeded
+		t.Failed += a.Failed
+	}
+}
+
+// updateStats counts in the pending stats of the day of t.
+func updateStats(t time.Time, f func(*DailyStats)) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	key := t.UTC().Format("2006-01-02")
+	s, ok := pendingStats[key]
+	if !ok {
+		s = newDailyStats()
+		pendingStats[key] = s
+	}
+	f(s)
+}
+
+// flushStatsEvery flushes the pending stats every interval.
+func flushStatsEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		flushStats()
+	}
+}
+
+// flushStats adds the pending stats to the stored ones and deletes the
+// stats older than statsRetention days.
+func flushStats() {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	for key, pending := range pendingStats {
+		s := newDailyStats()
+		if _, err := store.Get(statsBucket, key, s); err != nil {
+			logError("failed to get stats", err, logFields{"date": key})
+			continue
+		}
+		s.add(pending)
+		if err := store.Put(statsBucket, key, s); err != nil {
+			logError("failed to store stats", err, logFields{"date": key})
+			continue
+		}
+		delete(pendingStats, key)
+	}
+	oldest := time.Now().UTC().AddDate(0, 0, -statsRetention).Format("2006-01-02")
+	for _, key := range store.Keys(statsBucket) {
+		if key < oldest {
+			if err := store.Delete(statsBucket, key); err != nil {
+				logError("failed to delete stats", err, logFields{"date": key})
+			}
+		}
+	}
+}
+
+// recordLookup counts a resolution of queries by resolver and how long it took.
+func recordLookup(user, channel, resolver string, latency time.Duration) {
+	updateStats(time.Now(), func(s *DailyStats) {
+		s.Lookups++
+		s.Latency += latency
+		if user != "" {
+			s.Users[user]++
+		}
+		s.Channels[channel]++
+		s.Resolvers[resolver]++
+	})
+}
+
+// recordAction counts the outcome of an action performed by user.
+func recordAction(user, action string, err error) {
+	updateStats(time.Now(), func(s *DailyStats) {
+		if user != "" {
+			s.Users[user]++
+		}
+		a, ok := s.Actions[action]
+		if !ok {
+			a = new(ActionStats)
+			s.Actions[action] = a
+		}
+		if err != nil {
+			a.Failed++
+		} else {
+			a.Succeeded++
+		}
+	})
+}
+
+// collectStats sums the daily stats of the last days including the
+// pending ones.
+func collectStats(days int) *DailyStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	total := newDailyStats()
+	now := time.Now().UTC()
+	for i := 0; i < days; i++ {
+		key := now.AddDate(0, 0, -i).Format("2006-01-02")
+		s := newDailyStats()
+		if ok, err := store.Get(statsBucket, key, s); err == nil && ok {
+			total.add(s)
+		}
+		if pending, ok := pendingStats[key]; ok {
+			total.add(pending)
+		}
+	}
+	return total
+}
+
+func mergeCounts(dst, src map[string]int) {
+	for k, v := range src {
+		dst[k] += v
+	}
+}
+
+// parseDays parses a period such as "30d" or "2w" into a number of days.
+func parseDays(s string) (int, error) {
+	if s == "" {
+		return 30, nil
+	}
+	num, unit := s, 1
+	switch {
+	case strings.HasSuffix(s, "d"):
+		num = strings.TrimSuffix(s, "d")
+	case strings.HasSuffix(s, "w"):
+		num = strings.TrimSuffix(s, "w")
+		unit = 7
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid period %q", s)
+	}
+	return n * unit, nil
+}
+
+func (s *DailyStats) attachments(days int) []slack.Attachment {
+	meanLatency := time.Duration(0)
+	if s.Lookups > 0 {
+		meanLatency = s.Latency / time.Duration(s.Lookups)
+	}
+	actions := make([]string, 0, len(s.Actions))
+	for name, a := range s.Actions {
+		rate := float64(a.Succeeded) / float64(a.Succeeded+a.Failed) * 100
+		actions = append(actions, fmt.Sprintf("%s: %.1f%% (%d/%d)", name, rate, a.Succeeded, a.Succeeded+a.Failed))
+	}
+	sort.Strings(actions)
+	if len(actions) == 0 {
+		actions = append(actions, "none")
+	}
+	return []slack.Attachment{
+		slack.Attachment{
+			Title: fmt.Sprintf("Usage in the last %d days", days),
+			Fields: []slack.AttachmentField{
+				slack.AttachmentField{
+					Title: "Active Users",
+					Value: strconv.Itoa(len(s.Users)),
+					Short: true,
+				},
+				slack.AttachmentField{
+					Title: "Lookups",
+					Value: strconv.Itoa(s.Lookups),
+					Short: true,
+				},
+				slack.AttachmentField{
+					Title: "Mean Resolution Latency",
+					Value: meanLatency.String(),
+					Short: true,
+				},
+			},
+		},
+		slack.Attachment{
+			Title: "Lookups per Channel",
+			Text:  rankCounts(s.Channels, "<#%s>"),
+		},
+		slack.Attachment{
+			Title: "Most Used Resolvers",
+			Text:  rankCounts(s.Resolvers, "%s"),
+		},
+		slack.Attachment{
+			Title: "Action Success Rates",
+			Text:  strings.Join(actions, "\n"),
+		},
+	}
+}
+
+// rankCounts formats counts as lines sorted by descending count.
+func rankCounts(counts map[string]int, format string) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	lines := make([]string, len(keys))
+	for i, k := range keys {
+		lines[i] = fmt.Sprintf(format+": %d", k, counts[k])
+	}
+	if len(lines) == 0 {
+		return "none"
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/bgpat/ec2bot/internal/cache"
+	"github.com/nlopes/slack"
+)
+
+var (
+	// showStatusChecks enables the results of the status checks and the
+	// scheduled events on the cards of instances, $SHOW_STATUS_CHECKS,
+	// which need ec2:DescribeInstanceStatus.
+	showStatusChecks = boolEnv("SHOW_STATUS_CHECKS", true)
+
+	// instanceStatuses are the recent statuses by instance ID, which are
+	// shared by the status checks and the scheduled events of a card.
+	instanceStatuses = cache.NewLRU(1000, time.Minute)
+)
+
+// upcomingEvent is the period before the not-before date of a scheduled
+// event in which it is highlighted.
+const upcomingEvent = 7 * 24 * time.Hour
+
+// getInstanceStatus returns the status of the instance, or nil if it has
+// none, such as a stopped instance.
+func getInstanceStatus(instanceID string) (*ec2.InstanceStatus, error) {
+	if v, ok := instanceStatuses.Get(instanceID); ok {
+		return v.(*ec2.InstanceStatus), nil
+	}
+	svc := regionEC2Client(instanceRegion(instanceID))
+	resp, err := svc.DescribeInstanceStatus(&ec2.DescribeInstanceStatusInput{
+		InstanceIds: aws.StringSlice([]string{instanceID}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var status *ec2.InstanceStatus
+	for _, s := range resp.InstanceStatuses {
+		if aws.StringValue(s.InstanceId) == instanceID {
+			status = s
+		}
+	}
+	instanceStatuses.Add(instanceID, status)
+	return status, nil
+}
+
+// statusCheckField renders the results of the system and instance status
+// checks of the instance, such as "1/2 checks failed". It returns nil if
+// it is disabled or the instance has no status.
+func statusCheckField(instance *ec2.Instance) *slack.AttachmentField {
+	if !showStatusChecks || instance.State == nil || aws.StringValue(instance.State.Name) != ec2.InstanceStateNameRunning {
+		return nil
+	}
+	id := aws.StringValue(instance.InstanceId)
+	status, err := getInstanceStatus(id)
+	if err != nil {
+		logError("failed to describe instance status", err, logFields{"instance_id": id})
+		return nil
+	}
+	if status == nil {
+		return nil
+	}
+	failed, pending := statusCheckResults(status)
+	value := tr("%d/%d checks passed", statusChecks-len(failed)-len(pending), statusChecks)
+	switch {
+	case len(failed) > 0:
+		value = ":warning: " + tr("%d/%d checks failed", len(failed), statusChecks) + " (" + strings.Join(failed, ", ") + ")"
+	case len(pending) > 0:
+		value = tr("initializing (%s)", strings.Join(pending, ", "))
+	}
+	return &slack.AttachmentField{
+		Title: tr("Status Checks"),
+		Value: value,
+		Short: true,
+	}
+}
+
+// statusChecks is the number of the status checks of instances, which are
+// the system and instance ones.
+const statusChecks = 2
+
+// statusCheckResults returns the failed status checks of the instance
+// status with their statuses, and the initializing ones.
+func statusCheckResults(status *ec2.InstanceStatus) (failed, pending []string) {
+	checks := []struct {
+		name    string
+		summary *ec2.InstanceStatusSummary
+	}{
+		{tr("system"), status.SystemStatus},
+		{tr("instance"), status.InstanceStatus},
+	}
+	for _, c := range checks {
+		if c.summary == nil {
+			continue
+		}
+		switch aws.StringValue(c.summary.Status) {
+		case ec2.SummaryStatusOk:
+		case ec2.SummaryStatusInitializing:
+			pending = append(pending, c.name)
+		default:
+			failed = append(failed, fmt.Sprintf("%s: %s", c.name, aws.StringValue(c.summary.Status)))
+		}
+	}
+	return failed, pending
+}
+
+// scheduledEventsAttachment lists the scheduled events of the instance
+// such as retirements. Events starting within upcomingEvent are
+// highlighted, and completed or canceled ones are omitted. It returns nil
+// if it is disabled or there are no events.
+func (ev *Event) scheduledEventsAttachment(instance *ec2.Instance) *slack.Attachment {
+	if !showStatusChecks || instance.State == nil || aws.StringValue(instance.State.Name) != ec2.InstanceStateNameRunning {
+		return nil
+	}
+	id := aws.StringValue(instance.InstanceId)
+	status, err := getInstanceStatus(id)
+	if err != nil {
+		logError("failed to describe instance status", err, logFields{"instance_id": id})
+		return nil
+	}
+	if status == nil {
+		return nil
+	}
+	color := "warning"
+	var lines []string
+	for _, e := range status.Events {
+		description := aws.StringValue(e.Description)
+		if strings.HasPrefix(description, "[Completed]") || strings.HasPrefix(description, "[Canceled]") {
+			continue
+		}
+		notBefore := ""
+		if e.NotBefore != nil {
+			notBefore = ev.formatTime(*e.NotBefore)
+			if time.Until(*e.NotBefore) < upcomingEvent {
+				notBefore = "*" + notBefore + "*"
+				color = "danger"
+			}
+		}
+		lines = append(lines, tr("%s from %s: %s", code(aws.StringValue(e.Code)), notBefore, description))
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return &slack.Attachment{
+		Title:      tr("Scheduled Events"),
+		Text:       strings.Join(lines, "\n"),
+		Color:      color,
+		MarkdownIn: []string{"text"},
+	}
+}
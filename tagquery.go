@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/nlopes/slack"
+)
+
+// tagQueryLimit is the maximum number of instances a query lists.
+const tagQueryLimit = 200
+
+func init() {
+	mentionCommands["find"] = (*Event).listInstances
+	mentionCommands["list"] = (*Event).listInstances
+	actionHandlers["tag_query"] = handleTagQueryPage
+}
+
+// filterNames maps the keys of query expressions to the names of
+// DescribeInstances filters.
+var filterNames = map[string]string{
+	"state":  "instance-state-name",
+	"type":   "instance-type",
+	"az":     "availability-zone",
+	"vpc":    "vpc-id",
+	"subnet": "subnet-id",
+	"image":  "image-id",
+	"key":    "key-name",
+	"sg":     "instance.group-id",
+}
+
+// parseInstanceQuery parses a query such as
+// "state:running type:m5.large tag:Environment=prod tag:Service" into
+// filters. Values separated by commas match any of them, and a tag without
+// a value matches any value.
+func parseInstanceQuery(args []string) ([]*ec2.Filter, error) {
+	if len(args) == 0 {
+		return nil, errors.New(instanceQueryUsage())
+	}
+	filters := make([]*ec2.Filter, 0, len(args))
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "tag:") {
+			filter, err := parseTagFilter(arg)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, filter)
+			continue
+		}
+		kv := strings.SplitN(arg, ":", 2)
+		name, ok := filterNames[kv[0]]
+		if !ok || len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("%s\n%s", tr("invalid query %q", arg), instanceQueryUsage())
+		}
+		filters = append(filters, &ec2.Filter{
+			Name:   aws.String(name),
+			Values: aws.StringSlice(strings.Split(kv[1], ",")),
+		})
+	}
+	return filters, nil
+}
+
+func instanceQueryUsage() string {
+	keys := make([]string, 0, len(filterNames)+1)
+	for key := range filterNames {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	keys = append(keys, "tag:<key>[=<value>]")
+	return tr("usage: list <key>:<value>[,<value>...]...") + "\n" + tr("keys: %s", strings.Join(keys, ", "))
+}
+
+// parseTagFilter parses a tag expression such as "tag:Environment=prod".
+func parseTagFilter(arg string) (*ec2.Filter, error) {
+	kv := strings.SplitN(strings.TrimPrefix(arg, "tag:"), "=", 2)
+	if kv[0] == "" {
+		return nil, fmt.Errorf(tr("invalid tag query %q"), arg)
+	}
+	if len(kv) == 1 {
+		return &ec2.Filter{
+			Name:   aws.String("tag-key"),
+			Values: []*string{aws.String(kv[0])},
+		}, nil
+	}
+	return &ec2.Filter{
+		Name:   aws.String("tag:" + kv[0]),
+		Values: aws.StringSlice(strings.Split(kv[1], ",")),
+	}, nil
+}
+
+// queryInstances returns up to tagQueryLimit instances matching the
+// filters, and whether there were more.
+func queryInstances(filters []*ec2.Filter) ([]*ec2.Instance, bool, error) {
+	return describeInstancesUpTo(filters, tagQueryLimit)
+}
+
+// describeInstancesUpTo returns up to limit instances matching the filters,
+// and whether there were more.
+func describeInstancesUpTo(filters []*ec2.Filter, limit int) ([]*ec2.Instance, bool, error) {
+	svc := instanceDescriber("")
+	var (
+		instances []*ec2.Instance
+		truncated bool
+	)
+	err := svc.DescribeInstancesPages(&ec2.DescribeInstancesInput{
+		Filters: filters,
+	}, func(resp *ec2.DescribeInstancesOutput, last bool) bool {
+		for _, reservation := range resp.Reservations {
+			for _, instance := range reservation.Instances {
+				if len(instances) == limit {
+					truncated = true
+					return false
+				}
+				instances = append(instances, instance)
+			}
+		}
+		return true
+	})
+	return instances, truncated, err
+}
+
+// tagQueryMessage renders a page of the instances matching the query for
+// the event.
+func (ev *Event) tagQueryMessage(query string, page int) (*slack.Msg, error) {
+	filters, err := parseInstanceQuery(strings.Fields(query))
+	if err != nil {
+		return nil, err
+	}
+	instances, truncated, err := queryInstances(filters)
+	if err != nil {
+		return nil, err
+	}
+	if len(instances) == 0 {
+		return &slack.Msg{Text: tr("no instances match %s", code(query))}, nil
+	}
+	pages := (len(instances) + summaryRows - 1) / summaryRows
+	if page < 0 || page >= pages {
+		page = 0
+	}
+	end := (page + 1) * summaryRows
+	if end > len(instances) {
+		end = len(instances)
+	}
+	count := strconv.Itoa(len(instances))
+	if truncated {
+		count += "+"
+	}
+
+	attachments := ev.instanceSummaryRows(instances[page*summaryRows : end])
+	pager := pagerAttachment("tag_query", query, query, page, pages)
+	pager.Actions = append(pager.Actions, exportButton(query))
+	attachments = append(attachments, pager)
+	return &slack.Msg{
+		Text:        tr("%s instances match %s", count, code(query)),
+		Attachments: attachments,
+	}, nil
+}
+
+// listInstances handles "@ec2bot list state:running tag:<key>=<value>..."
+// and its alias "@ec2bot find".
+func (ev *Event) listInstances(args []string) error {
+	msg, err := ev.tagQueryMessage(strings.Join(args, " "), 0)
+	if err != nil {
+		return err
+	}
+	return ev.post(msg.Text, msg.Attachments)
+}
+
+// handleTagQueryPage replaces the result card with another page, or
+// uploads all the matching instances for the export button.
+func handleTagQueryPage(cb *ActionCallback, action slack.AttachmentAction) (*slack.Msg, error) {
+	if action.Name == "export" {
+		return nil, cb.event().exportInstances(action.Value, "csv")
+	}
+	page, query, err := parsePageValue(action.Value)
+	if err != nil {
+		return nil, err
+	}
+	return cb.event().tagQueryMessage(query, page)
+}
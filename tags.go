@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/nlopes/slack"
+)
+
+func init() {
+	actionHandlers["edit_tags"] = handleEditTags
+	dialogHandlers["edit_tags"] = handleEditTagsDialog
+}
+
+// editTagsAttachment returns a button to edit the tags of the resource.
+func editTagsAttachment(resourceType, id string) slack.Attachment {
+	return slack.Attachment{
+		Fallback:   id,
+		CallbackID: "edit_tags",
+		Actions: []slack.AttachmentAction{
+			slack.AttachmentAction{
+				Name:  resourceType,
+				Text:  tr("Edit tags"),
+				Type:  "button",
+				Value: id,
+			},
+		},
+	}
+}
+
+// handleEditTags opens a dialog to add, change or delete a tag of the
+// resource of the clicked card.
+func handleEditTags(cb *ActionCallback, action slack.AttachmentAction) (*slack.Msg, error) {
+	if err := authorizeAction(cb.Team.ID, cb.User.ID, "tag", action.Value); err != nil {
+		return nil, err
+	}
+	if action.Name != "instance" && action.Name != "loadbalancer" {
+		return nil, fmt.Errorf("unknown resource type %q", action.Name)
+	}
+	ev := cb.event()
+	return nil, openDialog(cb.Team.ID, cb.TriggerID, &Dialog{
+		CallbackID:  "edit_tags",
+		Title:       tr("Edit tags"),
+		SubmitLabel: tr("Save"),
+		State:       strings.Join([]string{action.Name, action.Value, cb.MessageTs, ev.Event.Timestamp}, " "),
+		Elements: []DialogElement{
+			DialogElement{
+				Type:  "text",
+				Label: tr("Key"),
+				Name:  "key",
+			},
+			DialogElement{
+				Type:     "text",
+				Label:    tr("Value"),
+				Name:     "value",
+				Hint:     tr("Leave empty to delete the tag."),
+				Optional: true,
+			},
+		},
+	})
+}
+
+func handleEditTagsDialog(cb *ActionCallback) []DialogError {
+	state := strings.Fields(cb.State)
+	if len(state) != 4 {
+		return []DialogError{{Name: "key", Error: tr("invalid request")}}
+	}
+	resourceType, id, messageTs, thread := state[0], state[1], state[2], state[3]
+	key := strings.TrimSpace(cb.Submission["key"])
+	value := strings.TrimSpace(cb.Submission["value"])
+	if key == "" {
+		return []DialogError{{Name: "key", Error: tr("invalid request")}}
+	}
+	if strings.HasPrefix(key, "aws:") {
+		return []DialogError{{Name: "key", Error: tr("tags starting with aws: are reserved")}}
+	}
+
+	ev := cb.event()
+	ev.Event.Timestamp = thread
+	if err := authorizeAction(cb.Team.ID, cb.User.ID, "tag", id); err != nil {
+		ev.post(err.Error(), nil)
+		return nil
+	}
+
+	var err error
+	switch resourceType {
+	case "instance":
+		err = tagInstance(id, key, value)
+	case "loadbalancer":
+		err = tagLoadBalancer(id, key, value)
+	}
+	ev.audit("tag", id, err)
+	if err != nil {
+		ev.post(tr("failed to edit tags of %s: %v", code(id), err), nil)
+		return nil
+	}
+	if value == "" {
+		ev.post(tr("%s: deleted tag %s (requested by <@%s>)", code(id), code(key), cb.User.ID), nil)
+	} else {
+		ev.post(tr("%s: set tag %s to %s (requested by <@%s>)", code(id), code(key), code(currentConfig().redactTag(key, value)), cb.User.ID), nil)
+	}
+	if err := ev.refreshCard(resourceType, id, messageTs); err != nil {
+		logError("failed to refresh card", err, logFields{"resource": id})
+	}
+	return nil
+}
+
+// tagInstance sets the tag of the instance, or deletes it if value is
+// empty.
+func tagInstance(id, key, value string) error {
+	svc := regionEC2Client(instanceRegion(id))
+	var err error
+	if value == "" {
+		_, err = svc.DeleteTags(&ec2.DeleteTagsInput{
+			Resources: []*string{aws.String(id)},
+			Tags:      []*ec2.Tag{{Key: aws.String(key)}},
+		})
+	} else {
+		_, err = svc.CreateTags(&ec2.CreateTagsInput{
+			Resources: []*string{aws.String(id)},
+			Tags:      []*ec2.Tag{{Key: aws.String(key), Value: aws.String(value)}},
+		})
+	}
+	if err != nil {
+		return err
+	}
+	setCachedInstanceTag(id, key, value)
+	return nil
+}
+
+// setCachedInstanceTag sets the tag of the cached instance so that lookups
+// show the new tag without describing all the instances again. The cached
+// responses are shared with readers, so the reservation of the instance is
+// copied instead of modified.
+func setCachedInstanceTag(id, key, value string) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if instanceCache.Instances == nil {
+		return
+	}
+	reservations := append([]*ec2.Reservation{}, instanceCache.Instances.Reservations...)
+	for i, reservation := range reservations {
+		for j, instance := range reservation.Instances {
+			if aws.StringValue(instance.InstanceId) != id {
+				continue
+			}
+			var tags []*ec2.Tag
+			for _, tag := range instance.Tags {
+				if aws.StringValue(tag.Key) != key {
+					tags = append(tags, tag)
+				}
+			}
+			if value != "" {
+				tags = append(tags, &ec2.Tag{Key: aws.String(key), Value: aws.String(value)})
+			}
+			updated := *instance
+			updated.Tags = tags
+			r := *reservation
+			r.Instances = append([]*ec2.Instance{}, reservation.Instances...)
+			r.Instances[j] = &updated
+			reservations[i] = &r
+			instanceCache.Instances = &ec2.DescribeInstancesOutput{Reservations: reservations}
+			instanceCache.Index = indexInstances(instanceCache.Instances)
+			takeSnapshot()
+			return
+		}
+	}
+}
+
+// tagLoadBalancer sets the tag of the load balancer, or deletes it if value
+// is empty.
+func tagLoadBalancer(name, key, value string) error {
+	svc := regionELBClient(loadBalancerRegion(name))
+	var err error
+	if value == "" {
+		_, err = svc.RemoveTags(&elb.RemoveTagsInput{
+			LoadBalancerNames: []*string{aws.String(name)},
+			Tags:              []*elb.TagKeyOnly{{Key: aws.String(key)}},
+		})
+	} else {
+		_, err = svc.AddTags(&elb.AddTagsInput{
+			LoadBalancerNames: []*string{aws.String(name)},
+			Tags:              []*elb.Tag{{Key: aws.String(key), Value: aws.String(value)}},
+		})
+	}
+	if err != nil {
+		return err
+	}
+	loadBalancerTags.Remove(name)
+	return nil
+}
+
+// refreshCard replaces the card of the resource posted at messageTs with
+// its current state.
+func (ev *Event) refreshCard(resourceType, id, messageTs string) error {
+	var (
+		attachments []slack.Attachment
+		err         error
+	)
+	switch resourceType {
+	case "instance":
+		var instance *ec2.Instance
+		instance, err = describeInstance(id)
+		if err == nil {
+			attachments, err = ev.instanceAttachments(instance)
+		}
+	case "loadbalancer":
+		var lb *elb.LoadBalancerDescription
+		lb, err = describeLoadBalancer(id)
+		if err == nil {
+			attachments, err = ev.loadBalancerAttachments(lb)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return updateMessage(ev.TeamID, ev.Event.Channel, messageTs, id, attachments)
+}
+
+func describeLoadBalancer(name string) (*elb.LoadBalancerDescription, error) {
+	svc := regionELBClient(loadBalancerRegion(name))
+	resp, err := svc.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{
+		LoadBalancerNames: []*string{aws.String(name)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.LoadBalancerDescriptions) == 0 {
+		return nil, fmt.Errorf("%s was not found", name)
+	}
+	return resp.LoadBalancerDescriptions[0], nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/bgpat/ec2bot/internal/awsclients"
+)
+
+func TestSetCachedInstanceTag(t *testing.T) {
+	tagged := fakeInstance("i-00000001", "10.0.0.1")
+	tagged.Tags = []*ec2.Tag{
+		{Key: aws.String("Name"), Value: aws.String("web-1")},
+		{Key: aws.String("team"), Value: aws.String("core")},
+	}
+	east := &awsclients.FakeEC2{
+		Reservations: fakeReservations(tagged, fakeInstance("i-00000002", "10.0.0.2")),
+	}
+	defer useFakeAWS(fakeAWS{EC2: map[string]*awsclients.FakeEC2{"us-east-1": east}})()
+	if _, err := describeInstances(); err != nil {
+		t.Fatal(err)
+	}
+	before, err := getInstance("i-00000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := atomic.LoadInt64(&east.Calls)
+
+	setCachedInstanceTag("i-00000001", "team", "platform")
+	setCachedInstanceTag("i-00000001", "Name", "")
+	setCachedInstanceTag("i-00000003", "team", "core")
+
+	after, err := getInstance("10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := tagValue(after.Tags, "team"); v != "platform" || len(after.Tags) != 1 {
+		t.Errorf("tags = %v, want only team=platform", after.Tags)
+	}
+	if v := tagValue(before.Tags, "team"); v != "core" || len(before.Tags) != 2 {
+		t.Errorf("cached response was modified: %v", before.Tags)
+	}
+	if other, err := getInstance("i-00000002"); err != nil || other == nil {
+		t.Errorf("getInstance(i-00000002) = %v, %v", other, err)
+	}
+	if n := atomic.LoadInt64(&east.Calls); n != calls {
+		t.Errorf("described instances %d times after tagging, want the cache", n-calls)
+	}
+}
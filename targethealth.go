@@ -0,0 +1,154 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+// targetHealthInterval is how often the health of the instances behind the
+// load balancers is described for the unhealthy targets gauge,
+// $TARGET_HEALTH_INTERVAL. It calls DescribeInstanceHealth of every Classic
+// ELB and DescribeTargetHealth of every target group in the searched
+// regions, so it is disabled unless it is set.
+var targetHealthInterval time.Duration
+
+var (
+	unhealthyTargetsMu sync.Mutex
+	// unhealthyTargets are the numbers of the unhealthy targets by the
+	// Classic ELBs and the target groups in the regions.
+	unhealthyTargets []unhealthyTargetCount
+)
+
+// unhealthyTargetCount is the number of the unhealthy targets of a Classic
+// ELB or a target group.
+type unhealthyTargetCount struct {
+	Region       string
+	LoadBalancer string
+	TargetGroup  string
+	Count        int
+}
+
+func init() {
+	if v := getenv("TARGET_HEALTH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			logWarn("cannot parse $TARGET_HEALTH_INTERVAL, use default '0'", nil)
+			return
+		}
+		targetHealthInterval = d
+	}
+}
+
+// collectTargetHealth counts the unhealthy targets every interval.
+func collectTargetHealth(interval time.Duration) {
+	for {
+		var counts []unhealthyTargetCount
+		for _, region := range lookupRegions() {
+			c, err := countUnhealthyTargets(region)
+			if err != nil {
+				logError("failed to describe target health", err, logFields{"region": region})
+			}
+			counts = append(counts, c...)
+		}
+		sort.Slice(counts, func(i, j int) bool {
+			a, b := counts[i], counts[j]
+			if a.Region != b.Region {
+				return a.Region < b.Region
+			}
+			if a.LoadBalancer != b.LoadBalancer {
+				return a.LoadBalancer < b.LoadBalancer
+			}
+			return a.TargetGroup < b.TargetGroup
+		})
+		unhealthyTargetsMu.Lock()
+		unhealthyTargets = counts
+		unhealthyTargetsMu.Unlock()
+		time.Sleep(interval)
+	}
+}
+
+// countUnhealthyTargets counts the instances out of service of the Classic
+// ELBs and the unhealthy targets of the target groups in the region. It
+// returns the counts described before an error.
+func countUnhealthyTargets(region string) ([]unhealthyTargetCount, error) {
+	var counts []unhealthyTargetCount
+	svc := regionELBClient(region)
+	var names []*string
+	err := svc.DescribeLoadBalancersPages(nil, func(resp *elb.DescribeLoadBalancersOutput, last bool) bool {
+		for _, lb := range resp.LoadBalancerDescriptions {
+			names = append(names, lb.LoadBalancerName)
+		}
+		return true
+	})
+	if err != nil {
+		return counts, err
+	}
+	for _, name := range names {
+		resp, err := svc.DescribeInstanceHealth(&elb.DescribeInstanceHealthInput{
+			LoadBalancerName: name,
+		})
+		if err != nil {
+			return counts, err
+		}
+		n := 0
+		for _, s := range resp.InstanceStates {
+			if targetHealth(aws.StringValue(s.State)) == Unhealthy {
+				n++
+			}
+		}
+		counts = append(counts, unhealthyTargetCount{Region: region, LoadBalancer: aws.StringValue(name), Count: n})
+	}
+
+	svc2 := regionELBV2Client(region)
+	var groups []*elbv2.TargetGroup
+	err = svc2.DescribeTargetGroupsPages(nil, func(resp *elbv2.DescribeTargetGroupsOutput, last bool) bool {
+		groups = append(groups, resp.TargetGroups...)
+		return true
+	})
+	if err != nil {
+		return counts, err
+	}
+	for _, g := range groups {
+		resp, err := svc2.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{
+			TargetGroupArn: g.TargetGroupArn,
+		})
+		if err != nil {
+			return counts, err
+		}
+		n := 0
+		for _, d := range resp.TargetHealthDescriptions {
+			if d.TargetHealth != nil && targetHealth(aws.StringValue(d.TargetHealth.State)) == Unhealthy {
+				n++
+			}
+		}
+		counts = append(counts, unhealthyTargetCount{Region: region, TargetGroup: aws.StringValue(g.TargetGroupName), Count: n})
+	}
+	return counts, nil
+}
+
+// targetHealthMetrics returns the gauges of the unhealthy targets counted
+// last.
+func targetHealthMetrics() []Metric {
+	unhealthyTargetsMu.Lock()
+	counts := unhealthyTargets
+	unhealthyTargetsMu.Unlock()
+	metrics := make([]Metric, len(counts))
+	for i, c := range counts {
+		labels := map[string]string{"region": c.Region, "kind": "load_balancer", "name": c.LoadBalancer}
+		if c.LoadBalancer == "" {
+			labels["kind"], labels["name"] = "target_group", c.TargetGroup
+		}
+		metrics[i] = Metric{
+			Name:   "fleet_unhealthy_targets",
+			Help:   "Number of unhealthy instances behind each Classic ELB (kind=load_balancer) and target group (kind=target_group).",
+			Labels: labels,
+			Value:  float64(c.Count),
+		}
+	}
+	return metrics
+}
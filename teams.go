@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+	"github.com/nlopes/slack"
+)
+
+// teams is the client of the Microsoft Teams bot registered with the
+// Bot Framework as $TEAMS_APP_ID and $TEAMS_APP_PASSWORD, whose messaging
+// endpoint is /teams. The messages are scanned like those of Slack and the
+// cards are posted as Adaptive Cards. It is nil if the integration is
+// disabled.
+//
+// Bots only receive the channel messages mentioning them unless they are
+// granted the ChannelMessage.Read.Group permission. Interactive buttons,
+// slash commands and dialogs are Slack only, so the buttons are dropped.
+var teams *TeamsClient
+
+const (
+	teamsOpenIDConfigURL = "https://login.botframework.com/v1/.well-known/openidconfiguration"
+	teamsTokenIssuer     = "https://api.botframework.com"
+	teamsTokenURL        = "https://login.microsoftonline.com/botframework.com/oauth2/v2.0/token"
+	teamsTokenScope      = "https://api.botframework.com/.default"
+)
+
+// teamsContainerStyles are the styles of the containers of the cards of
+// the named colors of Slack.
+var teamsContainerStyles = map[string]string{
+	"good":    "good",
+	"warning": "warning",
+	"danger":  "attention",
+}
+
+// slackLinkMarkup matches the links of Slack such as "<https://...|text>".
+var slackLinkMarkup = regexp.MustCompile(`<(https?://[^|>]+)\|([^>]+)>`)
+
+func init() {
+	id, password := getenv("TEAMS_APP_ID"), getenv("TEAMS_APP_PASSWORD")
+	if id != "" && password != "" {
+		teams = &TeamsClient{
+			AppID:    id,
+			Password: password,
+			client:   &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+}
+
+// TeamsClient is a minimal client of the Bot Framework connector API.
+type TeamsClient struct {
+	AppID    string
+	Password string
+
+	client *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	keys        map[string]*rsa.PublicKey
+	keysExpiry  time.Time
+}
+
+// TeamsActivity is an activity of the Bot Framework, which is a message
+// received or posted.
+type TeamsActivity struct {
+	Type         string             `json:"type"`
+	ID           string             `json:"id,omitempty"`
+	ServiceURL   string             `json:"serviceUrl,omitempty"`
+	From         *TeamsAccount      `json:"from,omitempty"`
+	Recipient    *TeamsAccount      `json:"recipient,omitempty"`
+	Conversation *TeamsConversation `json:"conversation,omitempty"`
+	Text         string             `json:"text,omitempty"`
+	TextFormat   string             `json:"textFormat,omitempty"`
+	Attachments  []*TeamsAttachment `json:"attachments,omitempty"`
+	ChannelData  *TeamsChannelData  `json:"channelData,omitempty"`
+}
+
+// TeamsAccount is a user or a bot.
+type TeamsAccount struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// TeamsConversation is a channel, a group chat or a personal chat.
+type TeamsConversation struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenantId,omitempty"`
+}
+
+// TeamsChannelData is the data specific to Teams of an activity.
+type TeamsChannelData struct {
+	Tenant *struct {
+		ID string `json:"id"`
+	} `json:"tenant,omitempty"`
+}
+
+// TeamsAttachment is a card of an activity.
+type TeamsAttachment struct {
+	ContentType string      `json:"contentType"`
+	Content     interface{} `json:"content"`
+}
+
+// TeamsBackend posts to the conversations of the service of an activity.
+type TeamsBackend struct {
+	ServiceURL string
+	TenantID   string
+}
+
+// accessToken returns the token of the bot for the connector API.
+func (t *TeamsClient) accessToken() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Before(t.tokenExpiry) {
+		return t.token, nil
+	}
+	resp, err := t.client.PostForm(teamsTokenURL, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {t.AppID},
+		"client_secret": {t.Password},
+		"scope":         {teamsTokenScope},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get Bot Framework token: %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	t.token = token.AccessToken
+	// renew the token a minute before it expires
+	t.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn-60) * time.Second)
+	return t.token, nil
+}
+
+// signingKeys returns the keys signing the requests of the Bot Framework by
+// ID, which are refreshed daily.
+func (t *TeamsClient) signingKeys() (map[string]*rsa.PublicKey, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.keys != nil && time.Now().Before(t.keysExpiry) {
+		return t.keys, nil
+	}
+	var config struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := t.getJSON(teamsOpenIDConfigURL, &config); err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := t.getJSON(config.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	t.keys, t.keysExpiry = keys, time.Now().Add(24*time.Hour)
+	return keys, nil
+}
+
+func (t *TeamsClient) getJSON(u string, v interface{}) error {
+	resp, err := t.client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// verify returns an error unless the authorization header is a token of
+// the Bot Framework issued to the bot for the service of the activity.
+func (t *TeamsClient) verify(authorization, serviceURL string) error {
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return errors.New("missing token")
+	}
+	keys, err := t.signingKeys()
+	if err != nil {
+		return err
+	}
+	token, err := jwt.Parse(strings.TrimPrefix(authorization, "Bearer "), func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return errors.New("invalid claims")
+	}
+	if !claims.VerifyIssuer(teamsTokenIssuer, true) {
+		return errors.New("invalid issuer")
+	}
+	if !claims.VerifyAudience(t.AppID, true) {
+		return errors.New("invalid audience")
+	}
+	if s, _ := claims["serviceurl"].(string); s != serviceURL {
+		return errors.New("invalid service URL")
+	}
+	return nil
+}
+
+// botID returns the ID of the bot in the conversations.
+func (t *TeamsClient) botID() string {
+	return "28:" + t.AppID
+}
+
+// post sends the activity to the path of the connector API of the service
+// and decodes the response into v unless it is nil.
+func (b *TeamsBackend) post(path string, activity interface{}, v interface{}) error {
+	token, err := teams.accessToken()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(b.ServiceURL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := teams.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s: %s", path, resp.Status)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// reply posts the activity in the conversation, as a reply to the activity
+// of the thread if it is not empty.
+func (b *TeamsBackend) reply(channel, thread string, activity *TeamsActivity) error {
+	path := "/v3/conversations/" + url.PathEscape(channel) + "/activities"
+	if thread != "" {
+		path += "/" + url.PathEscape(thread)
+	}
+	return b.post(path, activity, nil)
+}
+
+func (b *TeamsBackend) PostMessage(channel, thread, text string, attachments []slack.Attachment) error {
+	activity := &TeamsActivity{
+		Type:       "message",
+		Text:       slackLinkMarkup.ReplaceAllString(text, "[$2]($1)"),
+		TextFormat: "markdown",
+	}
+	if card := adaptiveCard(attachments); card != nil {
+		activity.Attachments = []*TeamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     card,
+		}}
+	}
+	if activity.Text == "" && len(activity.Attachments) == 0 {
+		return nil
+	}
+	return b.reply(channel, thread, activity)
+}
+
+// UploadFile posts the file as a code block, truncated to detailsLimit,
+// since bots cannot share files in channels.
+func (b *TeamsBackend) UploadFile(channel, thread string, file *File) error {
+	content := string(file.Content)
+	if len(content) > detailsLimit {
+		content = content[:detailsLimit] + "\n..."
+	}
+	title := file.Title
+	if file.Comment != "" {
+		title = file.Comment
+	}
+	return b.reply(channel, thread, &TeamsActivity{
+		Type:       "message",
+		Text:       title + "\n\n```\n" + content + "\n```",
+		TextFormat: "markdown",
+	})
+}
+
+func (b *TeamsBackend) OpenDM(user string) (string, error) {
+	var conversation struct {
+		ID string `json:"id"`
+	}
+	err := b.post("/v3/conversations", map[string]interface{}{
+		"bot":      &TeamsAccount{ID: teams.botID()},
+		"members":  []*TeamsAccount{{ID: user}},
+		"isGroup":  false,
+		"tenantId": b.TenantID,
+	}, &conversation)
+	return conversation.ID, err
+}
+
+// Mention returns the mention of the bot, into which handleTeams rewrites
+// the mentions of Teams.
+func (b *TeamsBackend) Mention() string {
+	return "<@" + teams.botID() + ">"
+}
+
+// adaptiveCard converts the attachments into an Adaptive Card, each in a
+// container colored like the attachment. It returns nil if the attachments
+// consist only of buttons.
+func adaptiveCard(attachments []slack.Attachment) map[string]interface{} {
+	var body []interface{}
+	for _, a := range attachments {
+		var items []interface{}
+		if a.Pretext != "" {
+			items = append(items, teamsTextBlock(a.Pretext, ""))
+		}
+		if a.Title != "" {
+			title := a.Title
+			if a.TitleLink != "" {
+				title = "[" + title + "](" + a.TitleLink + ")"
+			}
+			items = append(items, teamsTextBlock(title, "bolder"))
+		}
+		if a.Text != "" {
+			items = append(items, teamsTextBlock(a.Text, ""))
+		}
+		if len(a.Fields) > 0 {
+			facts := make([]map[string]string, len(a.Fields))
+			for i, f := range a.Fields {
+				value := f.Value
+				if value == "" {
+					value = placeholder
+				}
+				facts[i] = map[string]string{
+					"title": f.Title,
+					"value": slackLinkMarkup.ReplaceAllString(value, "[$2]($1)"),
+				}
+			}
+			items = append(items, map[string]interface{}{"type": "FactSet", "facts": facts})
+		}
+		if a.ImageURL != "" {
+			items = append(items, map[string]interface{}{"type": "Image", "url": a.ImageURL})
+		}
+		if a.Footer != "" {
+			block := teamsTextBlock(a.Footer, "")
+			block["size"] = "small"
+			block["isSubtle"] = true
+			items = append(items, block)
+		}
+		if len(items) == 0 {
+			continue
+		}
+		container := map[string]interface{}{"type": "Container", "items": items}
+		if style, ok := teamsContainerStyles[a.Color]; ok {
+			container["style"] = style
+		}
+		body = append(body, container)
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.2",
+		"body":    body,
+	}
+}
+
+func teamsTextBlock(text, weight string) map[string]interface{} {
+	block := map[string]interface{}{
+		"type": "TextBlock",
+		"text": slackLinkMarkup.ReplaceAllString(text, "[$2]($1)"),
+		"wrap": true,
+	}
+	if weight != "" {
+		block["weight"] = weight
+	}
+	return block
+}
+
+// handleTeams queues the messages of the Bot Framework as events, like
+// those of Slack.
+func handleTeams(c echo.Context) error {
+	activity := new(TeamsActivity)
+	if err := c.Bind(activity); err != nil {
+		logError("failed to bind Teams activity", err, nil)
+		return c.String(http.StatusBadRequest, "invalid activity")
+	}
+	if err := teams.verify(c.Request().Header.Get("Authorization"), activity.ServiceURL); err != nil {
+		logWarn("failed to verify Teams activity", logFields{"error": err.Error()})
+		return c.String(http.StatusUnauthorized, "failed to verify token")
+	}
+	if activity.Type != "message" || activity.From == nil || activity.Conversation == nil {
+		return c.NoContent(http.StatusOK)
+	}
+	tenant := activity.Conversation.TenantID
+	if tenant == "" && activity.ChannelData != nil && activity.ChannelData.Tenant != nil {
+		tenant = activity.ChannelData.Tenant.ID
+	}
+	backend := &TeamsBackend{ServiceURL: activity.ServiceURL, TenantID: tenant}
+	// mentions of the bot are written as "<at>ec2bot</at>"
+	text := activity.Text
+	if activity.Recipient != nil && activity.Recipient.Name != "" {
+		text = strings.Replace(text, "<at>"+activity.Recipient.Name+"</at>", backend.Mention(), -1)
+	}
+	ev := &Event{
+		EventID: "teams:" + activity.ID,
+		TeamID:  tenant,
+		Event: &slack.Msg{
+			Type:      "message",
+			Channel:   activity.Conversation.ID,
+			User:      activity.From.ID,
+			Text:      strings.TrimSpace(text),
+			Timestamp: activity.ID,
+		},
+		backend: backend,
+	}
+	if ev.seen() {
+		return c.NoContent(http.StatusOK)
+	}
+	if !ev.enqueue() {
+		return c.String(http.StatusServiceUnavailable, "queue is full")
+	}
+	return c.NoContent(http.StatusOK)
+}
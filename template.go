@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/ghodss/yaml"
+	"github.com/nlopes/slack"
+)
+
+// CardData is passed to the templates of the cards. Resource is the SDK
+// struct of the resource, which includes the details hidden from users
+// without the permission to view them, so templates should check Detailed
+// before rendering them.
+type CardData struct {
+	Resource interface{}
+	Tags     map[string]string
+	Region   string
+	Account  string
+	Detailed bool
+}
+
+// templateFuncs are the helper functions of the templates. Those depending
+// on the event are replaced when a card is rendered.
+func templateFuncs(ev *Event) template.FuncMap {
+	return template.FuncMap{
+		"code": code,
+		"tr":   tr,
+		"str":  aws.StringValue,
+		"int":  aws.Int64Value,
+		"bool": aws.BoolValue,
+		"yaml": func(v interface{}) (string, error) {
+			b, err := yaml.Marshal(v)
+			return string(b), err
+		},
+		"time": func(t *time.Time) string {
+			if t == nil || ev == nil {
+				return ""
+			}
+			return ev.formatTime(*t)
+		},
+	}
+}
+
+// loadTemplates parses the template files of the config by resolver name.
+func (c *Config) loadTemplates() error {
+	c.templates = make(map[string]*template.Template, len(c.Templates))
+	for name, path := range c.Templates {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		t, err := template.New(name).Funcs(templateFuncs(nil)).Parse(string(b))
+		if err != nil {
+			return fmt.Errorf("template of %s: %v", name, err)
+		}
+		c.templates[name] = t
+	}
+	return nil
+}
+
+// renderCard renders the main attachment of a card by the template of the
+// resolver. It reports false if there is no template or it fails, in which
+// case the default card is posted.
+func (ev *Event) renderCard(resolver string, data *CardData) (slack.Attachment, bool) {
+	t, ok := currentConfig().templates[resolver]
+	if !ok {
+		return slack.Attachment{}, false
+	}
+	t, err := t.Clone()
+	if err != nil {
+		logError("failed to render card", err, logFields{"resolver": resolver})
+		return slack.Attachment{}, false
+	}
+	var buf bytes.Buffer
+	if err := t.Funcs(templateFuncs(ev)).Execute(&buf, data); err != nil {
+		logError("failed to render card", err, logFields{"resolver": resolver})
+		return slack.Attachment{}, false
+	}
+	return slack.Attachment{
+		Text:       buf.String(),
+		MarkdownIn: []string{"text"},
+		Footer:     accountContext(data.Account, data.Region),
+	}, true
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/nlopes/slack"
+)
+
+func init() {
+	dialogHandlers["terminate"] = handleTerminateDialog
+	approvalHandlers["terminate"] = func(a *Approval) error {
+		return a.event().terminate(a.Resource)
+	}
+}
+
+func terminateButton(id string) slack.AttachmentAction {
+	return slack.AttachmentAction{
+		Name:  "terminate",
+		Text:  tr("Terminate"),
+		Type:  "button",
+		Style: "danger",
+		Value: id,
+	}
+}
+
+// confirmTerminate opens a dialog asking the user to retype the ID of the
+// instance before it is terminated.
+func confirmTerminate(cb *ActionCallback, id string) error {
+	if err := checkTerminationProtection(id); err != nil {
+		return err
+	}
+	ev := cb.event()
+	return openDialog(cb.Team.ID, cb.TriggerID, &Dialog{
+		CallbackID:  "terminate",
+		Title:       tr("Terminate instance"),
+		SubmitLabel: tr("Terminate"),
+		State:       id + " " + ev.Event.Timestamp,
+		Elements: []DialogElement{
+			DialogElement{
+				Type:        "text",
+				Label:       tr("Instance ID"),
+				Name:        "instance_id",
+				Placeholder: id,
+				Hint:        tr("Type %s to terminate the instance. This cannot be undone.", id),
+			},
+		},
+	})
+}
+
+func handleTerminateDialog(cb *ActionCallback) []DialogError {
+	state := strings.Fields(cb.State)
+	if len(state) != 2 {
+		return []DialogError{{Name: "instance_id", Error: tr("invalid request")}}
+	}
+	id, thread := state[0], state[1]
+	if strings.TrimSpace(cb.Submission["instance_id"]) != id {
+		return []DialogError{{Name: "instance_id", Error: tr("does not match %s", id)}}
+	}
+
+	ev := cb.event()
+	ev.Event.Timestamp = thread
+	if err := authorizeAction(cb.Team.ID, cb.User.ID, "terminate", id); err != nil {
+		ev.post(err.Error(), nil)
+		return nil
+	}
+	if requiresApproval("terminate") {
+		if err := ev.requestApproval("terminate", id, ""); err != nil {
+			ev.post(err.Error(), nil)
+		}
+		return nil
+	}
+	if err := ev.terminate(id); err != nil {
+		ev.post(tr("failed to terminate %s: %v", id, err), nil)
+	}
+	return nil
+}
+
+// terminate terminates the instance and posts the state transition to the
+// thread.
+func (ev *Event) terminate(id string) error {
+	change, err := terminateInstance(id)
+	ev.audit("terminate", id, err)
+	if err != nil {
+		return err
+	}
+	return ev.post(tr(
+		"%s: %s → %s by <@%s>",
+		id,
+		aws.StringValue(change.PreviousState.Name),
+		aws.StringValue(change.CurrentState.Name),
+		ev.Event.User,
+	), nil)
+}
+
+// checkTerminationProtection returns an error if the instance has
+// termination protection enabled.
+func checkTerminationProtection(id string) error {
+	svc := regionEC2Client(instanceRegion(id))
+	resp, err := svc.DescribeInstanceAttribute(&ec2.DescribeInstanceAttributeInput{
+		InstanceId: aws.String(id),
+		Attribute:  aws.String(ec2.InstanceAttributeNameDisableApiTermination),
+	})
+	if err != nil {
+		return err
+	}
+	if resp.DisableApiTermination != nil && aws.BoolValue(resp.DisableApiTermination.Value) {
+		return fmt.Errorf(tr("%s has termination protection enabled"), id)
+	}
+	return nil
+}
+
+func terminateInstance(id string) (*ec2.InstanceStateChange, error) {
+	if err := checkTerminationProtection(id); err != nil {
+		return nil, err
+	}
+	svc := regionEC2Client(instanceRegion(id))
+	resp, err := svc.TerminateInstances(&ec2.TerminateInstancesInput{
+		InstanceIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.TerminatingInstances) == 0 {
+		return nil, fmt.Errorf("%s was not terminated", id)
+	}
+	return resp.TerminatingInstances[0], nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/labstack/echo"
+	"github.com/nlopes/slack"
+)
+
+var (
+	// requestTimeout is the deadline of the context of requests to the
+	// bot.
+	requestTimeout = durationEnv("REQUEST_TIMEOUT", 30*time.Second)
+	// awsTimeout is the deadline of each AWS operation including its
+	// retries, unless the caller sets one.
+	awsTimeout = durationEnv("AWS_TIMEOUT", 30*time.Second)
+	// slackTimeout is the timeout of each call of the Slack API.
+	slackTimeout = durationEnv("SLACK_TIMEOUT", 15*time.Second)
+)
+
+func init() {
+	slack.SetHTTPClient(slackHTTPClient)
+}
+
+// durationEnv returns the duration of the environment variable, or def if
+// it is not set or invalid.
+func durationEnv(key string, def time.Duration) time.Duration {
+	v := getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		logWarn("cannot parse $"+key+", use default '"+def.String()+"'", nil)
+		return def
+	}
+	return d
+}
+
+// setAWSTimeout makes the clients of the session give up operations after
+// awsTimeout, so that a hung call cannot pin a handler.
+func setAWSTimeout(s *session.Session) {
+	if awsTimeout <= 0 {
+		return
+	}
+	s.Handlers.Validate.PushFront(func(r *request.Request) {
+		if r.Context() != aws.BackgroundContext() {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), awsTimeout)
+		r.SetContext(ctx)
+		// the context is released when it expires since requests have
+		// no hook after their last attempt
+		time.AfterFunc(awsTimeout, cancel)
+	})
+}
+
+// withRequestTimeout is the middleware setting the deadline of the
+// context of requests to requestTimeout.
+func withRequestTimeout(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if requestTimeout <= 0 {
+			return next(c)
+		}
+		ctx, cancel := context.WithTimeout(c.Request().Context(), requestTimeout)
+		defer cancel()
+		c.SetRequest(c.Request().WithContext(ctx))
+		err := next(c)
+		if ctx.Err() == context.DeadlineExceeded {
+			logWarn("request exceeded its deadline", logFields{"path": c.Path(), "timeout": requestTimeout.String()})
+		}
+		return err
+	}
+}
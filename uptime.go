@@ -0,0 +1,88 @@
+package main
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/nlopes/slack"
+)
+
+// recentChange is the period in which launched or stopped instances are
+// flagged on their cards, since they are usually the interesting ones
+// during incidents.
+const recentChange = time.Hour
+
+// stateTransitionTimePattern matches the time in the state transition
+// reason such as "User initiated (2018-06-01 12:34:56 GMT)".
+var stateTransitionTimePattern = regexp.MustCompile(`\((\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) GMT\)`)
+
+// humanizeDuration renders a duration roughly, such as "37 days".
+func humanizeDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return tr("less than a minute")
+	case d < 2*time.Minute:
+		return tr("1 minute")
+	case d < time.Hour:
+		return tr("%d minutes", int(d/time.Minute))
+	case d < 2*time.Hour:
+		return tr("1 hour")
+	case d < 48*time.Hour:
+		return tr("%d hours", int(d/time.Hour))
+	}
+	return tr("%d days", int(d/(24*time.Hour)))
+}
+
+// stoppedAt returns the time the instance was stopped, which is only in
+// the reason of the state transition.
+func stoppedAt(instance *ec2.Instance) (time.Time, bool) {
+	if instance.State == nil || aws.StringValue(instance.State.Name) != ec2.InstanceStateNameStopped {
+		return time.Time{}, false
+	}
+	m := stateTransitionTimePattern.FindStringSubmatch(aws.StringValue(instance.StateTransitionReason))
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", m[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// uptimeFields renders the launch time of the instance with its age, and
+// the time it was stopped. Changes within recentChange are flagged.
+func (ev *Event) uptimeFields(instance *ec2.Instance) []slack.AttachmentField {
+	var fields []slack.AttachmentField
+	running := instance.State != nil && aws.StringValue(instance.State.Name) == ec2.InstanceStateNameRunning
+	if instance.LaunchTime != nil {
+		age := time.Since(*instance.LaunchTime)
+		value := ev.formatTime(*instance.LaunchTime)
+		switch {
+		case age < recentChange:
+			value = ":new: " + value + " (" + tr("launched %s ago", humanizeDuration(age)) + ")"
+		case running:
+			value += " (" + tr("up %s", humanizeDuration(age)) + ")"
+		default:
+			value += " (" + tr("%s ago", humanizeDuration(age)) + ")"
+		}
+		fields = append(fields, slack.AttachmentField{
+			Title: tr("Launch Time"),
+			Value: value,
+		})
+	}
+	if t, ok := stoppedAt(instance); ok {
+		age := time.Since(t)
+		value := ev.formatTime(t) + " (" + tr("%s ago", humanizeDuration(age)) + ")"
+		if age < recentChange {
+			value = ":warning: " + value
+		}
+		fields = append(fields, slack.AttachmentField{
+			Title: tr("Stopped"),
+			Value: value,
+		})
+	}
+	return fields
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+
+	"github.com/labstack/echo"
+)
+
+// The build of the bot, which is set at build time such as
+// -ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse HEAD)".
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// BuildInfo is the build of the bot reported by /version and the usage.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+func buildInfo() *BuildInfo {
+	return &BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	}
+}
+
+func (b *BuildInfo) String() string {
+	s := "ec2bot " + b.Version
+	if b.Commit != "" {
+		c := b.Commit
+		if len(c) > 7 {
+			c = c[:7]
+		}
+		s += fmt.Sprintf(" (%s)", c)
+	}
+	if b.BuildDate != "" {
+		s += " built at " + b.BuildDate
+	}
+	return s + " with " + b.GoVersion
+}
+
+// handleVersion responds the build of the bot.
+func handleVersion(c echo.Context) error {
+	return c.JSON(http.StatusOK, buildInfo())
+}
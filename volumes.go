@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/bgpat/ec2bot/internal/cache"
+	"github.com/nlopes/slack"
+)
+
+var (
+	// showVolumes enables the lists of the EBS volumes on the cards of
+	// instances, $SHOW_VOLUMES, which need ec2:DescribeVolumes.
+	showVolumes = boolEnv("SHOW_VOLUMES", true)
+
+	// instanceVolumes are the recent volumes attached to instances by
+	// instance ID.
+	instanceVolumes = cache.NewLRU(1000, time.Minute)
+	// volumeRegions are the regions of the described volumes by volume ID.
+	volumeRegions = cache.NewLRU(10000, 24*time.Hour)
+)
+
+// maxVolumes is the maximum number of volumes listed on an instance card.
+const maxVolumes = 10
+
+// getInstanceVolumes returns the volumes attached to the instance.
+func getInstanceVolumes(instanceID string) ([]*ec2.Volume, error) {
+	if v, ok := instanceVolumes.Get(instanceID); ok {
+		return v.([]*ec2.Volume), nil
+	}
+	var volumes []*ec2.Volume
+	svc := regionEC2Client(instanceRegion(instanceID))
+	err := svc.DescribeVolumesPages(&ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("attachment.instance-id"),
+			Values: aws.StringSlice([]string{instanceID}),
+		}},
+	}, func(page *ec2.DescribeVolumesOutput, lastPage bool) bool {
+		volumes = append(volumes, page.Volumes...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range volumes {
+		volumeRegions.Add(aws.StringValue(v.VolumeId), instanceRegion(instanceID))
+	}
+	instanceVolumes.Add(instanceID, volumes)
+	return volumes, nil
+}
+
+// volumeRegion returns the region of the volume, which is searched in the
+// lookup regions unless it was described before. It is the default region
+// if the volume is not found.
+func volumeRegion(id string) string {
+	if v, ok := volumeRegions.Get(id); ok {
+		return v.(string)
+	}
+	regions := lookupRegions()
+	if len(regions) == 1 {
+		return regions[0]
+	}
+	for _, region := range regions {
+		volume, err := getVolume(region, id)
+		if err != nil {
+			logError("failed to describe volume", err, logFields{"volume_id": id, "region": region})
+			continue
+		}
+		if volume != nil {
+			return region
+		}
+	}
+	return awsRegion()
+}
+
+// getVolume returns the volume of the ID in the region, or nil if it is
+// not found.
+func getVolume(region, id string) (*ec2.Volume, error) {
+	svc := regionEC2Client(region)
+	resp, err := svc.DescribeVolumes(&ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("volume-id"),
+			Values: aws.StringSlice([]string{id}),
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range resp.Volumes {
+		if aws.StringValue(v.VolumeId) == id {
+			volumeRegions.Add(id, region)
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+// volumeDevice returns the device name of the volume on the instance.
+func volumeDevice(volume *ec2.Volume, instanceID string) string {
+	for _, a := range volume.Attachments {
+		if aws.StringValue(a.InstanceId) == instanceID {
+			return aws.StringValue(a.Device)
+		}
+	}
+	return ""
+}
+
+func volumeEncryption(volume *ec2.Volume) string {
+	if aws.BoolValue(volume.Encrypted) {
+		return tr("encrypted")
+	}
+	return tr("not encrypted")
+}
+
+// volumeSummary renders the size, the type and the encryption of the
+// volume, such as "8 GiB gp2, encrypted".
+func volumeSummary(volume *ec2.Volume) string {
+	return fmt.Sprintf("%d GiB %s, %s", aws.Int64Value(volume.Size), aws.StringValue(volume.VolumeType), volumeEncryption(volume))
+}
+
+// volumeAttachments lists the volumes attached to the instance, each with
+// a button expanding it into a volume card. It returns nil if it is
+// disabled.
+func volumeAttachments(instance *ec2.Instance) []slack.Attachment {
+	if !showVolumes {
+		return nil
+	}
+	id := aws.StringValue(instance.InstanceId)
+	volumes, err := getInstanceVolumes(id)
+	if err != nil {
+		logError("failed to describe volumes", err, logFields{"instance_id": id})
+		return nil
+	}
+	attachments := make([]slack.Attachment, 0, len(volumes))
+	for i, v := range volumes {
+		if i == maxVolumes {
+			attachments = append(attachments, slack.Attachment{Text: tr("and %d more", len(volumes)-maxVolumes)})
+			break
+		}
+		// volumes are looked up in the region of the instance on expansion
+		query := instanceRegion(id) + "/" + aws.StringValue(v.VolumeId)
+		row := summaryRow("volume", query, volumeDevice(v, id), aws.StringValue(v.VolumeId), volumeSummary(v))
+		if i == 0 {
+			row.Title = tr("Volumes")
+		}
+		attachments = append(attachments, row)
+	}
+	return attachments
+}
+
+// postVolume posts the card of the volume.
+func (ev *Event) postVolume(region string, volume *ec2.Volume) error {
+	id := aws.StringValue(volume.VolumeId)
+	fields := []slack.AttachmentField{
+		{Title: tr("Volume ID"), Value: id, Short: true},
+		{Title: tr("State"), Value: aws.StringValue(volume.State), Short: true},
+		{Title: tr("Size"), Value: fmt.Sprintf("%d GiB", aws.Int64Value(volume.Size)), Short: true},
+		{Title: tr("Type"), Value: aws.StringValue(volume.VolumeType), Short: true},
+	}
+	if volume.Iops != nil {
+		fields = append(fields, slack.AttachmentField{Title: tr("IOPS"), Value: fmt.Sprint(aws.Int64Value(volume.Iops)), Short: true})
+	}
+	fields = append(fields,
+		slack.AttachmentField{Title: tr("Availability Zone"), Value: aws.StringValue(volume.AvailabilityZone), Short: true},
+		slack.AttachmentField{Title: tr("Encryption"), Value: volumeEncryption(volume), Short: true},
+	)
+	if kms := aws.StringValue(volume.KmsKeyId); kms != "" {
+		fields = append(fields, slack.AttachmentField{Title: tr("KMS Key"), Value: code(kms)})
+	}
+	for _, a := range volume.Attachments {
+		fields = append(fields, slack.AttachmentField{
+			Title: tr("Attachment"),
+			Value: fmt.Sprintf("%s %s (%s)", code(aws.StringValue(a.InstanceId)), aws.StringValue(a.Device), aws.StringValue(a.State)),
+		})
+	}
+	if volume.CreateTime != nil {
+		fields = append(fields, slack.AttachmentField{Title: tr("Created"), Value: ev.formatTime(*volume.CreateTime)})
+	}
+	for _, tag := range volume.Tags {
+		fields = append(fields, slack.AttachmentField{
+			Title: aws.StringValue(tag.Key),
+			Value: currentConfig().redactTag(aws.StringValue(tag.Key), aws.StringValue(tag.Value)),
+			Short: true,
+		})
+	}
+	return ev.post(id, []slack.Attachment{
+		{
+			Fields:     fields,
+			MarkdownIn: []string{"fields"},
+			Footer:     accountContext("", region),
+		},
+		{
+			Fallback:   id,
+			CallbackID: "instance_action",
+			Actions:    []slack.AttachmentAction{snapshotButton(id)},
+		},
+		consoleAttachment(consoleURL(region, "Volumes:volumeId="+id)),
+	})
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/labstack/echo"
+)
+
+// workflowStepCallbackID is the callback ID of the "Look up EC2 resource"
+// step, which has to be that of the step in the app configuration.
+const workflowStepCallbackID = "lookup_ec2"
+
+// workflowStepFormats are the formats of the "summary" output of the step.
+var workflowStepFormats = append([]string{"text"}, detailsFormats...)
+
+// WorkflowStep is the step of a workflow of Workflow Builder in the
+// callbacks and the events.
+type WorkflowStep struct {
+	WorkflowStepEditID    string                       `json:"workflow_step_edit_id,omitempty"`
+	WorkflowStepExecuteID string                       `json:"workflow_step_execute_id,omitempty"`
+	Inputs                map[string]WorkflowStepInput `json:"inputs,omitempty"`
+	Outputs               []WorkflowStepOutput         `json:"outputs,omitempty"`
+}
+
+// WorkflowStepInput is an input of a step, which may contain the variables
+// of the previous steps.
+type WorkflowStepInput struct {
+	Value string `json:"value"`
+}
+
+// WorkflowStepOutput is an output of a step for the later steps.
+type WorkflowStepOutput struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Label string `json:"label"`
+}
+
+// workflowStepOutputs are the outputs of the step, which are the fields of
+// the first instance found and the summary of all of them.
+var workflowStepOutputs = []WorkflowStepOutput{
+	{Name: "instance_id", Type: "text", Label: "Instance ID"},
+	{Name: "name", Type: "text", Label: "Name"},
+	{Name: "instance_type", Type: "text", Label: "Instance type"},
+	{Name: "state", Type: "text", Label: "State"},
+	{Name: "private_ip", Type: "text", Label: "Private IP"},
+	{Name: "availability_zone", Type: "text", Label: "Availability zone"},
+	{Name: "summary", Type: "text", Label: "Summary"},
+}
+
+// View is a modal view of Block Kit. Blocks are encoded as is.
+type View struct {
+	Type       string        `json:"type"`
+	CallbackID string        `json:"callback_id,omitempty"`
+	Blocks     []interface{} `json:"blocks,omitempty"`
+	State      *struct {
+		Values map[string]map[string]struct {
+			Value          string `json:"value"`
+			SelectedOption *struct {
+				Value string `json:"value"`
+			} `json:"selected_option"`
+		} `json:"values"`
+	} `json:"state,omitempty"`
+}
+
+// value returns the value of the element of the submitted view.
+func (v *View) value(blockID, actionID string) string {
+	if v.State == nil {
+		return ""
+	}
+	e := v.State.Values[blockID][actionID]
+	if e.SelectedOption != nil {
+		return e.SelectedOption.Value
+	}
+	return e.Value
+}
+
+// handleWorkflowStep opens the configuration of the step, and saves the
+// inputs submitted.
+func handleWorkflowStep(c echo.Context, cb *ActionCallback) error {
+	if cb.CallbackID != workflowStepCallbackID && (cb.View == nil || cb.View.CallbackID != workflowStepCallbackID) {
+		return c.String(http.StatusBadRequest, "unknown workflow step")
+	}
+	if cb.WorkflowStep == nil {
+		return c.String(http.StatusBadRequest, "invalid workflow step")
+	}
+	var err error
+	if cb.Type == "workflow_step_edit" {
+		err = openWorkflowStepConfig(cb.Team.ID, cb.TriggerID, cb.WorkflowStep)
+	} else {
+		err = callAPI(cb.Team.ID, "workflows.updateStep", url.Values{
+			"workflow_step_edit_id": {cb.WorkflowStep.WorkflowStepEditID},
+			"inputs": {jsonString(map[string]WorkflowStepInput{
+				"query":  {Value: cb.View.value("query", "query")},
+				"format": {Value: cb.View.value("format", "format")},
+			})},
+			"outputs": {jsonString(workflowStepOutputs)},
+		}, nil)
+	}
+	if err != nil {
+		logError("failed to configure workflow step", err, logFields{"team_id": cb.Team.ID, "type": cb.Type})
+		return err
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// openWorkflowStepConfig opens the configuration of the step with the
+// inputs saved.
+func openWorkflowStepConfig(teamID, triggerID string, step *WorkflowStep) error {
+	format := step.Inputs["format"].Value
+	if format == "" {
+		format = "text"
+	}
+	options := make([]interface{}, len(workflowStepFormats))
+	initial := 0
+	for i, f := range workflowStepFormats {
+		options[i] = map[string]interface{}{
+			"text":  map[string]string{"type": "plain_text", "text": f},
+			"value": f,
+		}
+		if f == format {
+			initial = i
+		}
+	}
+	view := &View{
+		Type:       "workflow_step",
+		CallbackID: workflowStepCallbackID,
+		Blocks: []interface{}{
+			map[string]interface{}{
+				"type":     "input",
+				"block_id": "query",
+				"label":    map[string]string{"type": "plain_text", "text": tr("Instance")},
+				"hint":     map[string]string{"type": "plain_text", "text": tr("An instance ID, a name:<Name tag>, an IP address or a host name.")},
+				"element": map[string]interface{}{
+					"type":          "plain_text_input",
+					"action_id":     "query",
+					"initial_value": step.Inputs["query"].Value,
+				},
+			},
+			map[string]interface{}{
+				"type":     "input",
+				"block_id": "format",
+				"label":    map[string]string{"type": "plain_text", "text": tr("Output format")},
+				"element": map[string]interface{}{
+					"type":           "static_select",
+					"action_id":      "format",
+					"options":        options,
+					"initial_option": options[initial],
+				},
+			},
+		},
+	}
+	return callAPI(teamID, "views.open", url.Values{
+		"trigger_id": {triggerID},
+		"view":       {jsonString(view)},
+	}, nil)
+}
+
+// workflowStepEvent is the event of the executions of the step.
+type workflowStepEvent struct {
+	Token   string `json:"token"`
+	TeamID  string `json:"team_id"`
+	EventID string `json:"event_id"`
+	Event   struct {
+		Type         string        `json:"type"`
+		CallbackID   string        `json:"callback_id"`
+		WorkflowStep *WorkflowStep `json:"workflow_step"`
+	} `json:"event"`
+}
+
+// interceptWorkflowStep handles the events of the executions of the step,
+// whose steps are not decoded into the messages of the other events.
+func interceptWorkflowStep(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		body, err := ioutil.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		c.Request().Body = ioutil.NopCloser(bytes.NewReader(body))
+		var ev workflowStepEvent
+		if json.Unmarshal(body, &ev) != nil || ev.Event.Type != "workflow_step_execute" {
+			return next(c)
+		}
+		if ev.Token != slackVerifyToken {
+			logWarn("failed to verify token", logFields{"team_id": ev.TeamID})
+			return c.String(http.StatusUnauthorized, "failed to verify token")
+		}
+		if ev.Event.CallbackID != workflowStepCallbackID || ev.Event.WorkflowStep == nil {
+			return c.String(http.StatusOK, "ignore workflow step")
+		}
+		if (&Event{EventID: ev.EventID}).seen() {
+			return c.String(http.StatusOK, "ignore retry")
+		}
+		goBackground(func() { executeWorkflowStep(ev.TeamID, ev.Event.WorkflowStep) })
+		return c.String(http.StatusOK, "queued")
+	}
+}
+
+// executeWorkflowStep looks up the instances of the query of the step, and
+// completes the step with their fields as the outputs. The step fails if
+// none are found.
+func executeWorkflowStep(teamID string, step *WorkflowStep) {
+	outputs, err := workflowStepResult(step.Inputs["query"].Value, step.Inputs["format"].Value)
+	if err != nil {
+		err = callAPI(teamID, "workflows.stepFailed", url.Values{
+			"workflow_step_execute_id": {step.WorkflowStepExecuteID},
+			"error":                    {jsonString(map[string]string{"message": err.Error()})},
+		}, nil)
+	} else {
+		err = callAPI(teamID, "workflows.stepCompleted", url.Values{
+			"workflow_step_execute_id": {step.WorkflowStepExecuteID},
+			"outputs":                  {jsonString(outputs)},
+		}, nil)
+	}
+	if err != nil {
+		logError("failed to complete workflow step", err, logFields{"team_id": teamID})
+	}
+}
+
+// workflowStepResult returns the outputs of the instances of the query.
+func workflowStepResult(query, format string) (map[string]string, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errors.New(tr("invalid request"))
+	}
+	if !resolverEnabled("instance") {
+		return nil, errors.New("instance lookups are disabled")
+	}
+	instances, err := resolveInstances(query)
+	if err != nil {
+		logError("failed to resolve instances", err, logFields{"query": query})
+		return nil, errors.New("failed to resolve instances")
+	}
+	if len(instances) == 0 {
+		return nil, errors.New(tr("no instances match %s", query))
+	}
+	redacted := make([]*ec2.Instance, len(instances))
+	for i, instance := range instances {
+		redacted[i] = redactedInstance(instance)
+	}
+	summary, err := workflowStepSummary(redacted, format)
+	if err != nil {
+		return nil, err
+	}
+	first := redacted[0]
+	outputs := map[string]string{
+		"instance_id":   aws.StringValue(first.InstanceId),
+		"name":          tagValue(first.Tags, "Name"),
+		"instance_type": aws.StringValue(first.InstanceType),
+		"private_ip":    aws.StringValue(first.PrivateIpAddress),
+		"summary":       summary,
+	}
+	if first.State != nil {
+		outputs["state"] = aws.StringValue(first.State.Name)
+	}
+	if first.Placement != nil {
+		outputs["availability_zone"] = aws.StringValue(first.Placement.AvailabilityZone)
+	}
+	return outputs, nil
+}
+
+// workflowStepSummary renders the instances in the format, which is a line
+// of each instance for "text" and the details of them otherwise.
+func workflowStepSummary(instances []*ec2.Instance, format string) (string, error) {
+	if isDetailsFormat(format) {
+		b, err := marshalDetails(instances, format)
+		return string(b), err
+	}
+	// the private address is one of the outputs of the step anyway
+	rows := instanceRows(instances, true)
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		lines[i] = strings.Join(strings.Fields(row.Fallback), " ")
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// jsonString encodes the value as a parameter of the API.
+func jsonString(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/nlopes/slack"
+)
+
+const teamsBucket = "teams"
+
+// oauthStateCookie binds the state of an installation to the browser which
+// started it, so that other pages cannot complete installations.
+const oauthStateCookie = "ec2bot_oauth_state"
+
+// oauthStateTTL is how long an installation may take.
+const oauthStateTTL = 10 * time.Minute
+
+// Team is a Slack workspace the bot has been installed into.
+type Team struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	BotUserID string `json:"bot_user_id"`
+	BotToken  string `json:"bot_token"`
+}
+
+var (
+	slackClientID     = getenv("SLACK_CLIENT_ID")
+	slackClientSecret = getenv("SLACK_CLIENT_SECRET")
+	slackRedirectURL  = getenv("SLACK_REDIRECT_URL")
+
+	teamClientsMu sync.Mutex
+	teamClients   = make(map[string]*slack.Client)
+)
+
+// slackClient returns the client authorized for the team, falling back to
+// the client of $SLACK_ACCESS_TOKEN.
+func slackClient(teamID string) *slack.Client {
+	teamClientsMu.Lock()
+	defer teamClientsMu.Unlock()
+	if client, ok := teamClients[teamID]; ok {
+		return client
+	}
+	team := getTeam(teamID)
+	if team == nil {
+		return api
+	}
+	client := slack.New(team.BotToken)
+	teamClients[teamID] = client
+	return client
+}
+
+// slackToken returns the bot token of the team.
+func slackToken(teamID string) string {
+	if team := getTeam(teamID); team != nil {
+		return team.BotToken
+	}
+	return slackAccessToken
+}
+
+func getTeam(teamID string) *Team {
+	if teamID == "" {
+		return nil
+	}
+	team := new(Team)
+	ok, err := store.Get(teamsBucket, teamID, team)
+	if err != nil {
+		logError("failed to get team", err, logFields{"team_id": teamID})
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	return team
+}
+
+func putTeam(team *Team) error {
+	teamClientsMu.Lock()
+	delete(teamClients, team.ID)
+	teamClientsMu.Unlock()
+	return store.Put(teamsBucket, team.ID, team)
+}
+
+func handleInstall(c echo.Context) error {
+	if slackClientID == "" {
+		return c.String(http.StatusNotFound, "installation is not enabled")
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	state := hex.EncodeToString(b)
+	c.SetCookie(&http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/",
+		Expires:  time.Now().Add(oauthStateTTL),
+		MaxAge:   int(oauthStateTTL / time.Second),
+		Secure:   c.IsTLS() || c.Scheme() == "https",
+		HttpOnly: true,
+	})
+	q := url.Values{
+		"client_id":    {slackClientID},
+		"scope":        {"bot,commands"},
+		"redirect_uri": {slackRedirectURL},
+		"state":        {state},
+	}
+	return c.Redirect(http.StatusFound, "https://slack.com/oauth/authorize?"+q.Encode())
+}
+
+func handleOAuthRedirect(c echo.Context) error {
+	if slackClientID == "" {
+		return c.String(http.StatusNotFound, "installation is not enabled")
+	}
+	cookie, err := c.Cookie(oauthStateCookie)
+	state := c.QueryParam("state")
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return c.String(http.StatusForbidden, "invalid installation state, start the installation again")
+	}
+	c.SetCookie(&http.Cookie{Name: oauthStateCookie, Path: "/", MaxAge: -1})
+	if e := c.QueryParam("error"); e != "" {
+		return c.String(http.StatusForbidden, "installation cancelled: "+e)
+	}
+	resp, err := slack.GetOAuthResponse(
+		slackClientID,
+		slackClientSecret,
+		c.QueryParam("code"),
+		slackRedirectURL,
+		false,
+	)
+	if err != nil {
+		logError("failed to get OAuth access token", err, nil)
+		return c.String(http.StatusBadRequest, "failed to complete installation")
+	}
+	err = putTeam(&Team{
+		ID:        resp.TeamID,
+		Name:      resp.TeamName,
+		BotUserID: resp.Bot.BotUserID,
+		BotToken:  resp.Bot.BotAccessToken,
+	})
+	if err != nil {
+		logError("failed to store team", err, nil)
+		return err
+	}
+	logInfo("installed to team", logFields{"team_id": resp.TeamID, "team_name": resp.TeamName})
+	return c.String(http.StatusOK, "ec2bot has been installed to "+resp.TeamName)
+}